@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/mattkimber/gorender/internal/renderer"
+	"github.com/mattkimber/gorender/internal/spritesheet"
+	"github.com/mattkimber/gorender/internal/utils/fileutils"
+	"github.com/mattkimber/gorender/internal/utils/imageutils"
+	"image"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// The spritesheets written for a file, to be packed into the combined spritesheets
+type combinedEntry struct {
+	outputFilename string
+	layout         spritesheet.Layout
+
+	// The file each kind of spritesheet was written to
+	filenames map[string]string
+}
+
+// The position of each file's sprites in the combined spritesheets
+type combinedMap []combinedFile
+
+type combinedFile struct {
+	Name    string             `json:"name"`
+	Y       int                `json:"y"`
+	Sprites spritesheet.Layout `json:"sprites"`
+}
+
+var combinedEntries = make(map[string]combinedEntry)
+var combinedMutex sync.Mutex
+
+func (m *combinedMap) OutputToWriter(w io.Writer) (err error) {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(m)
+	return
+}
+
+func addToCombined(outputFilename string, sheets *spritesheet.Spritesheets) {
+	if flags.Combine == "" {
+		return
+	}
+
+	entry := combinedEntry{outputFilename: outputFilename, layout: sheets.Layout, filenames: make(map[string]string)}
+	for key := range sheets.Data {
+		entry.filenames[key] = sheets.GetFilename(outputFilename, key)
+	}
+
+	combinedMutex.Lock()
+	combinedEntries[outputFilename] = entry
+	combinedMutex.Unlock()
+}
+
+// Stack the spritesheets of all rendered files into one spritesheet of each kind per
+// scale, in the order the files were given, and write a map of where each sprite is
+func writeCombined(jobs []fileJob) error {
+	splitScales := strings.Split(flags.Scales, ",")
+
+	for _, scale := range splitScales {
+		var entries []combinedEntry
+		seen := make(map[string]bool)
+
+		for _, job := range jobs {
+			outputFilename := getOutputFilename(job.inputFilename, scale, len(splitScales))
+
+			// Only files rendered are combined, so the manifest has already been read successfully
+			m, _ := getObjectManifest(job.manifestFilename, job.inputFilename)
+
+			// Liveries and night sprites follow the sprites they are a variant of, and purchase
+			// menu sprites and icons follow the file's other sprites
+			var filenames []string
+			for _, base := range []string{outputFilename, outputFilename + renderer.PurchaseSuffix, outputFilename + renderer.IconSuffix} {
+				filenames = append(filenames, renderer.GetVariantFilenames(base, m)...)
+			}
+
+			for _, filename := range filenames {
+				if entry, ok := combinedEntries[filename]; ok && !seen[filename] {
+					entries = append(entries, entry)
+					seen[filename] = true
+				}
+			}
+		}
+
+		if len(entries) == 0 {
+			continue
+		}
+
+		filename := getScaledFilename(fileutils.GetBaseFilename(flags.Combine), scale, len(splitScales))
+		if err := writeCombinedScale(filename, entries); err != nil {
+			return fmt.Errorf("%s: %v", filename, err)
+		}
+	}
+
+	return nil
+}
+
+func writeCombinedScale(filename string, entries []combinedEntry) error {
+	var offsets []int
+
+	for _, key := range getCombinedKeys(entries) {
+		images := make([]image.Image, len(entries))
+		var example image.Image
+
+		for i, entry := range entries {
+			sheetFilename, ok := entry.filenames[key]
+			if !ok {
+				continue
+			}
+
+			img, err := readPng(sheetFilename)
+			if err != nil {
+				return err
+			}
+
+			images[i], example = img, img
+		}
+
+		// Files without this kind of spritesheet keep their space empty, so sprites are at
+		// the same position in every combined spritesheet
+		for i, entry := range entries {
+			if images[i] == nil {
+				width, height := getEntrySize(entry)
+				images[i] = getEmptyImage(example, image.Rect(0, 0, width, height))
+			}
+		}
+
+		var result image.Image
+		result, offsets = imageutils.Stack(images)
+
+		sheetFilename := filename + "_" + key + ".png"
+		if err := fileutils.WriteToFile(sheetFilename, spritesheet.Spritesheet{Image: result}); err != nil {
+			return err
+		}
+
+		addChecksum(sheetFilename)
+		runPostOutputHook(sheetFilename, flags.Combine, key)
+	}
+
+	m := make(combinedMap, len(entries))
+	for i, entry := range entries {
+		m[i] = combinedFile{Name: entry.outputFilename, Y: offsets[i], Sprites: make(spritesheet.Layout, len(entry.layout))}
+		for j, spr := range entry.layout {
+			spr.Y += offsets[i]
+			m[i].Sprites[j] = spr
+		}
+	}
+
+	return fileutils.WriteToFile(filename+"_map.json", &m)
+}
+
+// Get every kind of spritesheet written for any of the files, in a consistent order
+func getCombinedKeys(entries []combinedEntry) (keys []string) {
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		for key := range entry.filenames {
+			if !seen[key] {
+				keys = append(keys, key)
+				seen[key] = true
+			}
+		}
+	}
+
+	sort.Strings(keys)
+	return
+}
+
+// Get the size of a file's spritesheets from its layout
+func getEntrySize(entry combinedEntry) (width, height int) {
+	for _, spr := range entry.layout {
+		width = max(width, spr.X+spr.Width)
+		height = max(height, spr.Y+spr.Height)
+	}
+
+	return
+}
+
+func getEmptyImage(example image.Image, bounds image.Rectangle) image.Image {
+	switch img := example.(type) {
+	case *image.Paletted:
+		return image.NewPaletted(bounds, img.Palette)
+	case *image.Gray16:
+		return image.NewGray16(bounds)
+	}
+
+	return image.NewRGBA(bounds)
+}
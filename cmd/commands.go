@@ -0,0 +1,122 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+type command struct {
+	name        string
+	usage       string
+	description string
+	setup       func(fs *flag.FlagSet)
+	run         func(args []string) error
+}
+
+var commands = []command{
+	{"render", "[flags] [manifest.json] file.vox...", "render sprites from voxel files", addRenderFlags, render},
+	{"validate", "[-manifest file] [-palette file] [file.vox...]", "check a manifest, and the objects it uses from voxel files", addValidateFlags, validate},
+	{"inspect", "[-palette file] file.vox...", "show the size, layers, nodes and colours of voxel files", addPaletteFlag, inspect},
+	{"preview", "[-addr address] [flags] file.vox", "serve an interactive preview of a voxel file in a web browser", addPreviewFlags, previewCommand},
+	{"palette", "[-palette file] [-output file.png]", "show the ranges and special colours of a palette", addPaletteCommandFlags, showPalette},
+	{"pack", "-output file.png sheet.png...", "combine spritesheets into a single image", addPackFlags, pack},
+	{"turntable", "[-manifest file] [-frames n] [-fps n] [-output file.mp4] file.vox", "render a video of a voxel file turning around, encoded with ffmpeg", addTurntableFlags, turntable},
+	{"verify", "[flags] [manifest.json] file.vox...", "check rendered output is up to date with voxel files and the manifest", addRenderFlags, verify},
+	{"worker", "-connect address [-jobs n]", "render files handed out by a coordinator started with render -distribute", addWorkerFlags, worker},
+	{"serve", "[-addr address] [-grpc address] [-max-jobs n] [-palette file]", "serve render APIs for other applications", addServeCommandFlags, serveCommand},
+}
+
+func main() {
+	cmd, args := getCommand(os.Args[1:])
+
+	fs := flag.NewFlagSet(cmd.name, flag.ExitOnError)
+	fs.Usage = func() {
+		if cmd.name == "" {
+			printUsage()
+		} else {
+			_, _ = fmt.Fprintf(fs.Output(), "Usage: gorender %s %s\n\n%s\n\n", cmd.name, cmd.usage, cmd.description)
+		}
+		fs.PrintDefaults()
+	}
+
+	cmd.setup(fs)
+	_ = fs.Parse(args)
+
+	if err := cmd.run(fs.Args()); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "%v\n", err)
+		if cmd.name == "" && len(fs.Args()) == 0 {
+			fs.Usage()
+		}
+		os.Exit(1)
+	}
+}
+
+// Get the command to run and its arguments. Without a command name, the original flat set
+// of flags is used to render files.
+func getCommand(args []string) (command, []string) {
+	if len(args) > 0 {
+		for _, cmd := range commands {
+			if cmd.name == args[0] {
+				return cmd, args[1:]
+			}
+		}
+
+		if args[0] == "help" {
+			printUsage()
+			os.Exit(0)
+		}
+	}
+
+	return command{setup: addLegacyFlags, run: legacy}, args
+}
+
+func printUsage() {
+	_, _ = fmt.Fprintf(os.Stderr, "Usage: gorender <command> [flags] [files]\n\nCommands:\n")
+	for _, cmd := range commands {
+		_, _ = fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.description)
+	}
+	_, _ = fmt.Fprintf(os.Stderr, "\nUse gorender <command> -h for the flags of each command.\n"+
+		"gorender [flags] file.vox... renders files with the render flags below.\n\n")
+}
+
+func addLegacyFlags(fs *flag.FlagSet) {
+	addRenderFlags(fs)
+	addServeFlags(fs, "serve")
+	fs.StringVar(&flags.Preview, "preview", "", "serve an interactive preview on this address (e.g. localhost:8080) instead of writing files")
+}
+
+func legacy(args []string) error {
+	if flags.Serve != "" || flags.Grpc != "" {
+		return serveCommand(args)
+	}
+
+	return render(args)
+}
+
+func addPreviewFlags(fs *flag.FlagSet) {
+	addRenderFlags(fs)
+	fs.StringVar(&flags.Preview, "addr", "localhost:8080", "address to serve the preview on")
+}
+
+func previewCommand(args []string) error {
+	if len(getFileJobs(args)) != 1 {
+		return fmt.Errorf("preview needs exactly one voxel file")
+	}
+
+	return render(args)
+}
+
+func addServeCommandFlags(fs *flag.FlagSet) {
+	addServeFlags(fs, "addr")
+	addPaletteFlag(fs)
+}
+
+func serveCommand(args []string) error {
+	if flags.Serve == "" && flags.Grpc == "" {
+		return fmt.Errorf("at least one of -addr or -grpc must be set")
+	}
+
+	serve()
+	return nil
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/mattkimber/gandalf/magica"
+	"github.com/mattkimber/gorender/internal/colour"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"github.com/mattkimber/gorender/internal/queue"
+	"github.com/mattkimber/gorender/internal/spritesheet"
+	"github.com/mattkimber/gorender/internal/utils/fileutils"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Render files by handing them out to workers connecting on flags.Distribute. Each file
+// and scale which is out of date becomes a task, and the output is written here as
+// workers return it.
+func distribute(jobs []fileJob) error {
+	if flags.Relight || flags.GBuffer {
+		return fmt.Errorf("-distribute cannot be used with -relight or -gbuffer")
+	}
+
+	// Workers can't read base palettes, so are sent the palette with its base and
+	// adjustments already applied
+	loadedPalette, err := getPalette(flags.PaletteFile)
+	if err != nil {
+		return fmt.Errorf("could not read palette: %v", err)
+	}
+
+	palette, err := json.Marshal(loadedPalette)
+	if err != nil {
+		return fmt.Errorf("could not send palette: %v", err)
+	}
+
+	splitScales := strings.Split(flags.Scales, ",")
+
+	var tasks []*queue.Task
+	var inputFilenames, outputFilenames []string
+	var outputTemplates []manifest.Template
+	upToDate := 0
+
+	for _, job := range jobs {
+		if !strings.HasSuffix(job.inputFilename, ".vox") {
+			fmt.Printf("Files does not have .vox extension: %s\n", job.inputFilename)
+			continue
+		}
+
+		runPreRenderHook(job.inputFilename, job.manifestFilename)
+
+		task, template, err := getTask(job, palette)
+		if err != nil {
+			return fmt.Errorf("%s: %v", job.inputFilename, err)
+		}
+
+		for _, scale := range splitScales {
+			exist, err := allPotentialOutputFilesExist(job.inputFilename, scale, len(splitScales), job.manifestFilename)
+			if err != nil {
+				return fmt.Errorf("error attempting to stat files: %v", err)
+			}
+
+			if exist {
+				upToDate++
+				continue
+			}
+
+			scaleTask := task
+			scaleTask.ID = len(tasks)
+			if scaleTask.Scale, err = strconv.ParseFloat(scale, 64); err != nil {
+				return fmt.Errorf("could not interpret scale %s: %v", scale, err)
+			}
+
+			tasks = append(tasks, &scaleTask)
+			inputFilenames = append(inputFilenames, job.inputFilename)
+			outputFilenames = append(outputFilenames, getOutputFilename(job.inputFilename, scale, len(splitScales)))
+			outputTemplates = append(outputTemplates, template)
+		}
+	}
+
+	if len(tasks) == 0 {
+		fmt.Printf("All %d outputs are up to date\n", upToDate)
+		return nil
+	}
+
+	listener, err := net.Listen("tcp", flags.Distribute)
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = listener.Close() }()
+
+	failed := 0
+	c := queue.Coordinator{
+		Log: func(format string, args ...interface{}) {
+			fmt.Printf(format+"\n", args...)
+		},
+		Handle: func(t *queue.Task, r queue.Result) {
+			if r.Error != "" {
+				fmt.Printf("%s: %s\n", inputFilenames[t.ID], r.Error)
+				failed++
+				return
+			}
+
+			if err := saveResult(inputFilenames[t.ID], outputFilenames[t.ID], outputTemplates[t.ID], r); err != nil {
+				fmt.Printf("%s: %v\n", inputFilenames[t.ID], err)
+				failed++
+				return
+			}
+
+			if flags.ProgressIndicator {
+				fmt.Print("o")
+			}
+		},
+	}
+
+	fmt.Printf("Waiting for workers at %s to render %d outputs (%d up to date)\n", listener.Addr(), len(tasks), upToDate)
+	if err := c.Run(listener, tasks); err != nil {
+		return err
+	}
+
+	if flags.ProgressIndicator {
+		fmt.Println()
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d outputs could not be rendered", failed, len(tasks))
+	}
+
+	return nil
+}
+
+// Get a task containing the input file, manifest and any objects used by sprites, and the
+// manifest's template for naming the output
+func getTask(job fileJob, palette []byte) (task queue.Task, template manifest.Template, err error) {
+	task = queue.Task{
+		Input:    filepath.Base(job.inputFilename),
+		Files:    make(map[string][]byte),
+		Palette:  palette,
+		Fast:     flags.Fast,
+		Debug:    flags.Debug,
+		Only8bpp: flags.Output8bppOnly,
+		Target:   flags.Target,
+	}
+
+	if task.Manifest, err = os.ReadFile(job.manifestFilename); err != nil {
+		return task, template, fmt.Errorf("could not read manifest: %v", err)
+	}
+
+	m, err := manifest.FromJson(bytes.NewReader(task.Manifest))
+	if err == nil {
+		m, err = m.GetTargetManifest(task.Target)
+	}
+
+	if err != nil {
+		return task, template, fmt.Errorf("%s: %v", job.manifestFilename, err)
+	}
+
+	index := m.GetObjectIndex(job.inputFilename, job.manifestFilename)
+	m, task.Object = m.GetObjectManifest(index), index+1
+
+	template = m.GetTemplate()
+
+	if m.Symmetric || flags.AutoSymmetry {
+		object, err := magica.FromFile(job.inputFilename)
+		if err != nil {
+			return task, template, err
+		}
+
+		checkSymmetry(job.inputFilename, &m, object)
+		task.Symmetric = m.Symmetric
+	}
+
+	// Files are sent using only the base of their name, so these must be unique
+	paths := map[string]string{task.Input: job.inputFilename}
+	for _, spr := range m.Sprites {
+		filename, _ := spr.GetObjectSource(job.inputFilename)
+		name := filepath.Base(filename)
+
+		if path, ok := paths[name]; ok && path != filename {
+			return task, template, fmt.Errorf("object files %s and %s have the same name", path, filename)
+		}
+
+		paths[name] = filename
+	}
+
+	for name, path := range paths {
+		if task.Files[name], err = os.ReadFile(path); err != nil {
+			return task, template, err
+		}
+	}
+
+	return task, template, nil
+}
+
+func saveResult(inputFilename, outputFilename string, template manifest.Template, r queue.Result) error {
+	checkStrict(inputFilename, r.Report)
+
+	for key, data := range r.Sheets {
+		filename := template.GetFilename(outputFilename, key)
+		if err := os.WriteFile(filename, data, 0644); err != nil {
+			return err
+		}
+
+		addChecksum(filename)
+		runPostOutputHook(filename, inputFilename, key)
+	}
+
+	if r.Layout != nil {
+		if err := fileutils.WriteToFile(outputFilename+"_layout.json", &r.Layout); err != nil {
+			return err
+		}
+	}
+
+	outputReport(outputFilename, r.Report)
+	return nil
+}
+
+func addWorkerFlags(fs *flag.FlagSet) {
+	fs.StringVar(&flags.Connect, "connect", "", "address of the coordinator to get work from (e.g. render-host:9000)")
+	fs.IntVar(&flags.Jobs, "jobs", 1, "number of files to render at once")
+	fs.IntVar(&flags.MaxMemory, "max-memory", 0, "raycast large sprites in bands so raycast output fits in this many MB (0 for no limit)")
+}
+
+// Processed objects are kept between tasks, as each scale of a file is a separate task and
+// would otherwise process the same object (with its normals, ambient occlusion and
+// distance field) again. This keeps the most recently used, one for each job.
+var taskDefinitions struct {
+	sync.Mutex
+	entries []*taskDefinition
+}
+
+type taskDefinition struct {
+	key  [sha256.Size]byte
+	once sync.Once
+	def  manifest.Definition
+	err  error
+}
+
+// Get the definition for a task, processing its objects only if no earlier task had the
+// same files and settings
+func getTaskDefinition(t queue.Task) (manifest.Definition, error) {
+	// Only the scale and output settings differ between the tasks for each scale of a file
+	t.ID, t.Scale, t.Debug, t.Only8bpp = 0, 0, false, false
+	data, err := json.Marshal(t)
+	if err != nil {
+		return manifest.Definition{}, err
+	}
+
+	key := sha256.Sum256(data)
+
+	taskDefinitions.Lock()
+	var entry *taskDefinition
+	for i, e := range taskDefinitions.entries {
+		if e.key == key {
+			entry = e
+			taskDefinitions.entries = append(taskDefinitions.entries[:i], taskDefinitions.entries[i+1:]...)
+			break
+		}
+	}
+
+	if entry == nil {
+		entry = &taskDefinition{key: key}
+	}
+
+	taskDefinitions.entries = append([]*taskDefinition{entry}, taskDefinitions.entries...)
+	if len(taskDefinitions.entries) > max(flags.Jobs, 1) {
+		taskDefinitions.entries = taskDefinitions.entries[:max(flags.Jobs, 1)]
+	}
+	taskDefinitions.Unlock()
+
+	entry.once.Do(func() {
+		entry.def, entry.err = processTask(t)
+	})
+
+	return entry.def, entry.err
+}
+
+// Process the objects of a task from the files sent with it
+func processTask(t queue.Task) (def manifest.Definition, err error) {
+	dir, err := os.MkdirTemp("", "gorender-task")
+	if err != nil {
+		return
+	}
+
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	for name, data := range t.Files {
+		if err = os.WriteFile(filepath.Join(dir, filepath.Base(name)), data, 0644); err != nil {
+			return
+		}
+	}
+
+	palette, err := colour.FromJson(bytes.NewReader(t.Palette))
+	if err != nil {
+		return def, fmt.Errorf("could not read palette: %v", err)
+	}
+
+	m, err := manifest.FromJson(bytes.NewReader(t.Manifest))
+	if err == nil {
+		m, err = m.GetTargetManifest(t.Target)
+	}
+
+	if err != nil {
+		return def, fmt.Errorf("could not read manifest: %v", err)
+	}
+
+	m = m.GetObjectManifest(t.Object - 1)
+
+	if t.Fast {
+		setFastSettings(&m)
+	}
+
+	if t.Symmetric {
+		m.Symmetric = true
+	}
+
+	// Point sprite objects at the files sent with the task
+	for i, spr := range m.Sprites {
+		filename, node := spr.GetObjectSource("")
+		if filename == "" {
+			continue
+		}
+
+		m.Sprites[i].Object = filepath.Join(dir, filepath.Base(filename))
+		if node != "" {
+			m.Sprites[i].Object += "#" + node
+		}
+	}
+
+	return processJob(filepath.Join(dir, t.Input), m, t.Scale, palette)
+}
+
+// Render tasks from a coordinator. Workers keep running after the coordinator finishes,
+// and pick up work from the next coordinator started on the same address.
+func worker(args []string) error {
+	if flags.Connect == "" {
+		return fmt.Errorf("-connect must be set")
+	}
+
+	fmt.Printf("Getting work from %s\n", flags.Connect)
+
+	for i := 1; i < flags.Jobs; i++ {
+		go getWork()
+	}
+
+	getWork()
+	return nil
+}
+
+func getWork() {
+	for {
+		err := queue.Work(flags.Connect, renderTask)
+
+		// Waiting for a coordinator to start isn't worth reporting
+		var opErr *net.OpError
+		if err != nil && !(errors.As(err, &opErr) && opErr.Op == "dial") {
+			fmt.Printf("lost connection to %s: %v\n", flags.Connect, err)
+		}
+
+		time.Sleep(5 * time.Second)
+	}
+}
+
+func renderTask(t queue.Task) queue.Result {
+	def, err := getTaskDefinition(t)
+	if err != nil {
+		return queue.Result{Error: err.Error()}
+	}
+
+	// The definition may be shared with other tasks, and the layout of the sprites is
+	// written to the manifest while rendering
+	m := def.Manifest
+	def.Manifest.Sprites = slices.Clone(m.Sprites)
+
+	def.Scale = t.Scale
+	def.Debug = t.Debug
+	def.Only8bpp = t.Only8bpp
+	def.MaxMemory = int64(flags.MaxMemory) << 20
+	sheets := spritesheet.GetSpritesheets(def)
+	defer sheets.Release()
+
+	r := queue.Result{Sheets: make(map[string][]byte), Report: sheets.Report}
+	if m.Deduplicate {
+		r.Layout = sheets.Layout
+	}
+
+	for key, sheet := range sheets.Data {
+		buf := bytes.Buffer{}
+		if err := sheet.OutputToWriter(&buf); err != nil {
+			return queue.Result{Error: fmt.Sprintf("could not encode %s image: %v", key, err)}
+		}
+		r.Sheets[key] = buf.Bytes()
+	}
+
+	return r
+}
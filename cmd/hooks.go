@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"unicode"
+)
+
+// Number of hooks which failed, so the run can fail once everything is written
+var hookFailures atomic.Int32
+
+// Run the -pre-render hook for a voxel file
+func runPreRenderHook(inputFilename, manifestFilename string) {
+	runHook("pre-render", flags.PreRender, map[string]string{"input": inputFilename, "manifest": manifestFilename})
+}
+
+// Run the -post-output hook for a file which has been written
+func runPostOutputHook(filename, inputFilename, kind string) {
+	runHook("post-output", flags.PostOutput, map[string]string{"file": filename, "input": inputFilename, "kind": kind})
+}
+
+// Run the -post-run hook once all files have been rendered
+func runPostRunHook(jobs []fileJob) {
+	runHook("post-run", flags.PostRun, map[string]string{
+		"files":      fmt.Sprint(len(jobs)),
+		"rendered":   fmt.Sprint(renderedCount.Load()),
+		"up_to_date": fmt.Sprint(upToDateCount.Load()),
+	})
+}
+
+// Run a hook command, replacing {name} placeholders in its arguments with values. The
+// command is run directly rather than by a shell, so values don't need quoting.
+func runHook(name, command string, values map[string]string) {
+	if command == "" {
+		return
+	}
+
+	args, err := splitCommand(command)
+	if err != nil {
+		fmt.Printf("%s hook: %v\n", name, err)
+		hookFailures.Add(1)
+		return
+	}
+
+	for i := range args {
+		for key, value := range values {
+			args[i] = strings.ReplaceAll(args[i], "{"+key+"}", value)
+		}
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		fmt.Printf("%s hook %s failed: %v\n", name, strings.Join(args, " "), err)
+		hookFailures.Add(1)
+	}
+}
+
+// Split a command into arguments on spaces. Single or double quotes group words containing
+// spaces into one argument.
+func splitCommand(command string) (args []string, err error) {
+	var current strings.Builder
+	var quote rune
+	inArg := false
+
+	for _, r := range command {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote, inArg = r, true
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in %s", command)
+	}
+
+	if inArg {
+		args = append(args, current.String())
+	}
+
+	if len(args) == 0 {
+		return nil, fmt.Errorf("empty command")
+	}
+
+	return args, nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"github.com/mattkimber/gandalf/magica"
+	"github.com/mattkimber/gorender/internal/colour"
+	"github.com/mattkimber/gorender/internal/voxelobject"
+	"strings"
+)
+
+// Show the size, layers, nodes and colours used by voxel files
+func inspect(files []string) error {
+	if len(files) == 0 {
+		return fmt.Errorf("no files supplied")
+	}
+
+	palette, err := getPalette(flags.PaletteFile)
+	if err != nil {
+		return err
+	}
+
+	for _, filename := range files {
+		object, err := magica.FromFile(filename)
+		if err != nil {
+			return fmt.Errorf("%s: %v", filename, err)
+		}
+
+		layers, nodes, err := voxelobject.GetNames(filename)
+		if err != nil {
+			return err
+		}
+
+		counts := make([]int, 256)
+		total := 0
+		object.Iterate(func(x, y, z int) {
+			if index := object.Voxels[x][y][z]; index != 0 {
+				counts[index]++
+				total++
+			}
+		})
+
+		fmt.Printf("%s\n", filename)
+		fmt.Printf("  size:      %d x %d x %d\n", object.Size.X, object.Size.Y, object.Size.Z)
+		fmt.Printf("  voxels:    %d\n", total)
+		fmt.Printf("  layers:    %s\n", strings.Join(layers, ", "))
+		fmt.Printf("  nodes:     %s\n", strings.Join(nodes, ", "))
+		fmt.Printf("  symmetric: %s\n", getSymmetryDescription(object))
+		fmt.Printf("  colours:\n")
+
+		for index, count := range counts {
+			if count > 0 {
+				fmt.Printf("    %3d: %d %s\n", index, count, getColourDescription(palette, byte(index)))
+			}
+		}
+	}
+
+	return nil
+}
+
+func getSymmetryDescription(object magica.VoxelObject) string {
+	differing, total := voxelobject.GetAsymmetry(object)
+	if differing == 0 {
+		return "yes"
+	}
+
+	return fmt.Sprintf("no (%d of %d voxels differ from their mirror image)", differing, total)
+}
+
+// Describe the special properties of a palette colour
+func getColourDescription(palette colour.Palette, index byte) string {
+	if int(index) >= len(palette.Entries) || palette.Entries[index].Range == nil {
+		return ""
+	}
+
+	return getRangeDescription(*palette.Entries[index].Range)
+}
+
+func getRangeDescription(r colour.PaletteRange) string {
+	properties := make([]string, 0)
+
+	for _, p := range []struct {
+		isSet bool
+		name  string
+	}{
+		{r.IsPrimaryCompanyColour, "primary company colour"},
+		{r.IsSecondaryCompanyColour, "secondary company colour"},
+		{r.IsAnimatedLight, "animated"},
+		{r.IsProcessColour, "process colour"},
+		{r.IsNonRenderable, "non-renderable"},
+		{r.Transparency > 0, "transparent"},
+	} {
+		if p.isSet {
+			properties = append(properties, p.name)
+		}
+	}
+
+	if r.Smoothness > 0 {
+		properties = append(properties, fmt.Sprintf("smoothness %d", r.Smoothness))
+	}
+
+	if r.Class != "" {
+		properties = append(properties, fmt.Sprintf("class %s", r.Class))
+	}
+
+	return strings.Join(properties, ", ")
+}
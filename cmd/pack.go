@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/mattkimber/gorender/internal/utils/imageutils"
+	"image"
+	"image/png"
+	"os"
+)
+
+var packOutput string
+
+func addPackFlags(fs *flag.FlagSet) {
+	fs.StringVar(&packOutput, "output", "", "file name of the combined PNG")
+	fs.StringVar(&packOutput, "o", "", "shorthand for -output")
+}
+
+// Stack spritesheets vertically into a single image, and print the y offset of each
+func pack(files []string) error {
+	if packOutput == "" || len(files) == 0 {
+		return fmt.Errorf("an output file and at least one spritesheet are needed")
+	}
+
+	images := make([]image.Image, len(files))
+	for i, filename := range files {
+		img, err := readPng(filename)
+		if err != nil {
+			return fmt.Errorf("%s: %v", filename, err)
+		}
+		images[i] = img
+	}
+
+	result, offsets := imageutils.Stack(images)
+
+	out, err := os.Create(packOutput)
+	if err != nil {
+		return err
+	}
+
+	if err := png.Encode(out, result); err != nil {
+		_ = out.Close()
+		return err
+	}
+
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	for i, filename := range files {
+		fmt.Printf("%s: y=%d\n", filename, offsets[i])
+	}
+
+	return nil
+}
+
+func readPng(filename string) (image.Image, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+
+	return png.Decode(file)
+}
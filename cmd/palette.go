@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/mattkimber/gorender/internal/spritesheet"
+	"github.com/mattkimber/gorender/internal/utils/fileutils"
+)
+
+var paletteOutput string
+
+func addPaletteCommandFlags(fs *flag.FlagSet) {
+	addPaletteFlag(fs)
+	fs.StringVar(&paletteOutput, "output", "", "also write an image of the palette's colours and ranges to this PNG file")
+	fs.StringVar(&paletteOutput, "o", "", "shorthand for -output")
+}
+
+// Show the ranges defined by the palette and their special properties
+func showPalette(args []string) error {
+	palette, err := getPalette(flags.PaletteFile)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s: %d colours, %d ranges\n", flags.PaletteFile, len(palette.Entries), len(palette.Ranges))
+
+	warnings, _ := palette.Validate()
+	for _, w := range warnings {
+		fmt.Printf("%s: warning: %s\n", flags.PaletteFile, w)
+	}
+
+	for _, r := range palette.Ranges {
+		fmt.Printf("  %3d-%3d %s\n", r.Start, r.End, getRangeDescription(r))
+	}
+
+	if paletteOutput != "" {
+		return fileutils.WriteToFile(paletteOutput, spritesheet.Spritesheet{Image: palette.GetSwatchImage()})
+	}
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"github.com/mattkimber/gandalf/magica"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"github.com/mattkimber/gorender/internal/preview"
+	"github.com/mattkimber/gorender/internal/renderer"
+	"log"
+	"strconv"
+)
+
+func servePreview(inputFilename string, manifestFilename string, scale string, object magica.VoxelObject, def manifest.Definition) {
+	scaleF, err := strconv.ParseFloat(scale, 64)
+	if err != nil {
+		log.Fatalf("Could not interpret scale %s: %v", scale, err)
+	}
+
+	def.Scale, def.Time = scaleF, false
+	palette := def.Palette
+
+	server := preview.Server{
+		Definition:       def,
+		ManifestFilename: manifestFilename,
+		LoadManifest: func() (manifest.Manifest, error) {
+			reloaded, err := getObjectManifest(manifestFilename, inputFilename)
+			applyFastSettings(&reloaded)
+			return reloaded, err
+		},
+		Process: func(reloaded manifest.Manifest) (manifest.Definition, error) {
+			def, err := renderer.GetDefinition(inputFilename, object, reloaded, palette, false)
+			def.Scale = scaleF
+			return def, err
+		},
+	}
+
+	fmt.Printf("Previewing %s at http://%s/\n", inputFilename, flags.Preview)
+	log.Fatal(server.ListenAndServe(flags.Preview))
+}
@@ -4,8 +4,11 @@ import (
 	"flag"
 	"fmt"
 	"github.com/mattkimber/gandalf/magica"
+	"github.com/mattkimber/gorender/internal/checksum"
 	"github.com/mattkimber/gorender/internal/colour"
 	"github.com/mattkimber/gorender/internal/manifest"
+	"github.com/mattkimber/gorender/internal/renderer"
+	"github.com/mattkimber/gorender/internal/report"
 	"github.com/mattkimber/gorender/internal/spritesheet"
 	"github.com/mattkimber/gorender/internal/utils/fileutils"
 	"github.com/mattkimber/gorender/internal/utils/timingutils"
@@ -16,6 +19,8 @@ import (
 	"runtime/pprof"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -34,69 +39,258 @@ type Flags struct {
 	ProgressIndicator             bool
 	PaletteFile                   string
 	Overwrite                     bool
+	Report                        bool
+	Strict                        strictCategories
+	GBuffer                       bool
+	Relight                       bool
+	Preview                       string
+	Serve                         string
+	MaxJobs                       int
+	JobExpiry                     time.Duration
+	Grpc                          string
+	Jobs                          int
+	CombinedReport                string
+	Distribute                    string
+	Connect                       string
+	Checksums                     string
+	AutoSymmetry                  bool
+	MaxMemory                     int
+	Combine                       string
+	Target                        string
+	PreRender                     string
+	PostOutput                    string
+	PostRun                       string
 }
 
+// A voxel file to render and the manifest to render it with
+type fileJob struct {
+	inputFilename, manifestFilename string
+}
+
+var combinedReport report.Combined
+
+var checksums checksum.Checksums
+
+// Number of files rendered and skipped as up to date, for the progress summary
+var renderedCount, upToDateCount atomic.Int32
+
 var flags Flags
 
-func init() {
+// Flags used when rendering, shared by all commands which render or check rendered output
+func addRenderFlags(fs *flag.FlagSet) {
 	// Long format
-	flag.StringVar(&flags.Scales, "scale", "1.0", "comma-separated list of scales to render sprites at")
-	flag.BoolVar(&flags.SubDirs, "subdirs", false, "output each scale in its own subdirectory.")
-	flag.StringVar(&flags.InputFilename, "input", "", "voxel file to process")
-	flag.StringVar(&flags.OutputFilename, "output", "", "base file name of output PNG files, bit depth will be appended")
-	flag.StringVar(&flags.ManifestFilename, "manifest", "files/manifest.json", "manifest file to use (see documentation)")
-	flag.BoolVar(&flags.OutputTime, "time", false, "output basic profiling information")
-	flag.BoolVar(&flags.Debug, "debug", false, "output extra debugging spritesheets")
-	flag.StringVar(&flags.ProfileFile, "profile", "", "output Go profiling information to the specified file")
-	flag.BoolVar(&flags.Output8bppOnly, "8bpp", false, "output only 8bpp sprites.")
-	flag.StringVar(&flags.Suffix, "suffix", "", "add this suffix to all output files")
-	flag.BoolVar(&flags.StripDirectory, "strip-directory", false, "strip paths from input files")
-	flag.BoolVar(&flags.ProgressIndicator, "progress", false, "show simple progress indicator")
-	flag.StringVar(&flags.PaletteFile, "palette", "files/ttd_palette.json", "specify a palette file other than the default")
-	flag.BoolVar(&flags.Overwrite, "overwrite", false, "force overwriting of existing files")
-
-	flag.BoolVar(&flags.Fast, "fast", false, "force fast rendering output")
+	fs.StringVar(&flags.Scales, "scale", "1.0", "comma-separated list of scales to render sprites at")
+	fs.BoolVar(&flags.SubDirs, "subdirs", false, "output each scale in its own subdirectory.")
+	fs.StringVar(&flags.InputFilename, "input", "", "voxel file to process")
+	fs.StringVar(&flags.OutputFilename, "output", "", "base file name of output PNG files, bit depth will be appended")
+	fs.StringVar(&flags.ManifestFilename, "manifest", "files/manifest.json", "manifest file to use (see documentation)")
+	fs.BoolVar(&flags.OutputTime, "time", false, "output basic profiling information")
+	fs.BoolVar(&flags.Debug, "debug", false, "output extra debugging spritesheets")
+	fs.StringVar(&flags.ProfileFile, "profile", "", "output Go profiling information to the specified file")
+	fs.BoolVar(&flags.Output8bppOnly, "8bpp", false, "output only 8bpp sprites.")
+	fs.StringVar(&flags.Suffix, "suffix", "", "add this suffix to all output files")
+	fs.BoolVar(&flags.StripDirectory, "strip-directory", false, "strip paths from input files")
+	fs.BoolVar(&flags.ProgressIndicator, "progress", false, "show simple progress indicator")
+	fs.BoolVar(&flags.Overwrite, "overwrite", false, "force overwriting of existing files")
+	fs.BoolVar(&flags.Report, "report", false, "output a JSON report of sprite statistics and warnings")
+	fs.Var(&flags.Strict, "strict", "fail if sprites contain unexpected animated pixels, or warnings in this comma-separated list of categories (or all)")
+	fs.BoolVar(&flags.GBuffer, "gbuffer", false, "output the raycast G-buffer for later use with -relight")
+	fs.BoolVar(&flags.Relight, "relight", false, "re-shade sprites from a previously output G-buffer instead of raycasting")
+	fs.IntVar(&flags.Jobs, "jobs", 1, "number of files to render at once")
+	fs.StringVar(&flags.CombinedReport, "combined-report", "", "output a JSON report for all files rendered to this file")
+	fs.BoolVar(&flags.AutoSymmetry, "auto-symmetry", false, "render objects found to be symmetric as if the manifest set symmetric")
+	fs.StringVar(&flags.Checksums, "checksums", "", "record the SHA256 checksum of each spritesheet output in this file")
+	fs.IntVar(&flags.MaxMemory, "max-memory", 0, "raycast large sprites in bands so raycast output fits in this many MB (0 for no limit)")
+	fs.StringVar(&flags.Combine, "combine", "", "also pack the spritesheets of all files into shared spritesheets with this base file name")
+	fs.StringVar(&flags.PreRender, "pre-render", "", "command to run before each file is rendered, e.g. \"export.sh {input}\"")
+	fs.StringVar(&flags.PostOutput, "post-output", "", "command to run on each spritesheet written, e.g. \"optipng {file}\"")
+	fs.StringVar(&flags.PostRun, "post-run", "", "command to run once all files are rendered")
+	fs.StringVar(&flags.Distribute, "distribute", "", "hand out files to workers connecting on this address (e.g. :9000) instead of rendering them")
+	fs.StringVar(&flags.Target, "target", "", "render the outputs of this named target from the manifest")
+
+	fs.BoolVar(&flags.Fast, "fast", false, "force fast rendering output")
 
 	// Short format
-	flag.StringVar(&flags.Scales, "s", "1.0", "shorthand for -scale")
-	flag.BoolVar(&flags.SubDirs, "u", false, "shorthand for -subdirs")
-	flag.StringVar(&flags.InputFilename, "i", "", "shorthand for -input")
-	flag.StringVar(&flags.OutputFilename, "o", "", "shorthand for -output")
-	flag.StringVar(&flags.ManifestFilename, "m", "files/manifest.json", "shorthand for -manifest")
-	flag.BoolVar(&flags.OutputTime, "t", false, "shorthand for -time")
-	flag.BoolVar(&flags.Debug, "d", false, "shorthand for -debug")
-	flag.BoolVar(&flags.Fast, "f", false, "shorthand for -fast")
-	flag.StringVar(&flags.Suffix, "x", "", "shorthand for -suffix")
-	flag.BoolVar(&flags.Output8bppOnly, "8", false, "shorthand for -8.")
-	flag.BoolVar(&flags.StripDirectory, "r", false, "shorthand for -strip-directory")
-	flag.BoolVar(&flags.ProgressIndicator, "p", false, "show simple progress indicator")
+	fs.StringVar(&flags.Scales, "s", "1.0", "shorthand for -scale")
+	fs.BoolVar(&flags.SubDirs, "u", false, "shorthand for -subdirs")
+	fs.StringVar(&flags.InputFilename, "i", "", "shorthand for -input")
+	fs.StringVar(&flags.OutputFilename, "o", "", "shorthand for -output")
+	fs.StringVar(&flags.ManifestFilename, "m", "files/manifest.json", "shorthand for -manifest")
+	fs.BoolVar(&flags.OutputTime, "t", false, "shorthand for -time")
+	fs.BoolVar(&flags.Debug, "d", false, "shorthand for -debug")
+	fs.BoolVar(&flags.Fast, "f", false, "shorthand for -fast")
+	fs.StringVar(&flags.Suffix, "x", "", "shorthand for -suffix")
+	fs.BoolVar(&flags.Output8bppOnly, "8", false, "shorthand for -8.")
+	fs.BoolVar(&flags.StripDirectory, "r", false, "shorthand for -strip-directory")
+	fs.BoolVar(&flags.ProgressIndicator, "p", false, "show simple progress indicator")
+
+	addPaletteFlag(fs)
+}
 
+func addPaletteFlag(fs *flag.FlagSet) {
+	fs.StringVar(&flags.PaletteFile, "palette", "files/ttd_palette.json", "specify a palette file other than the default")
 }
 
-func main() {
-	if err := setupFlags(); err != nil {
-		return
+func addServeFlags(fs *flag.FlagSet, addrFlag string) {
+	fs.StringVar(&flags.Serve, addrFlag, "", "serve a REST API for render jobs on this address (e.g. localhost:8080)")
+	fs.StringVar(&flags.Grpc, "grpc", "", "serve a gRPC render service on this address (e.g. localhost:9090)")
+	fs.IntVar(&flags.MaxJobs, "max-jobs", 2, "maximum number of render jobs to run at once when serving")
+	fs.DurationVar(&flags.JobExpiry, "job-expiry", time.Hour, "time to keep finished jobs and their files for when serving (0 keeps them until deleted)")
+}
+
+func render(args []string) error {
+	jobs := getFileJobs(args)
+	if len(jobs) == 0 {
+		return fmt.Errorf("no files supplied on command line and input flag not set")
+	}
+
+	if err := applyTargetScales(jobs); err != nil {
+		return err
+	}
+
+	if flags.MaxMemory > 0 && flags.GBuffer && !flags.Relight {
+		return fmt.Errorf("-max-memory cannot be used with -gbuffer, as the G-buffer holds all raycast output")
+	}
+
+	if flags.Combine != "" && flags.Distribute != "" {
+		return fmt.Errorf("-combine cannot be used with -distribute")
+	}
+
+	if flags.ProfileFile != "" {
+		f, err := os.Create(flags.ProfileFile)
+		if err != nil {
+			return fmt.Errorf("could not create CPU profile: %v", err)
+		}
+		defer func(f *os.File) {
+			_ = f.Close()
+		}(f)
+		if err := pprof.StartCPUProfile(f); err != nil {
+			return fmt.Errorf("could not start CPU profile: %v", err)
+		}
+		defer pprof.StopCPUProfile()
+	}
+
+	if flags.Checksums != "" {
+		// Keep the checksums of files which are already up to date
+		if err := fileutils.InstantiateFromFile(flags.Checksums, &checksums); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("could not read checksums: %v", err)
+		}
+	}
+
+	if flags.Distribute != "" {
+		if err := distribute(jobs); err != nil {
+			return err
+		}
+	} else {
+		timingutils.Time("\nTotal", flags.OutputTime, func() {
+			runFileJobs(jobs)
+		})
+	}
+
+	if flags.Combine != "" {
+		if err := writeCombined(jobs); err != nil {
+			return err
+		}
+	}
+
+	if flags.CombinedReport != "" {
+		if err := fileutils.WriteToFile(flags.CombinedReport, &combinedReport); err != nil {
+			return err
+		}
+	}
+
+	if flags.Checksums != "" {
+		if err := fileutils.WriteToFile(flags.Checksums, &checksums); err != nil {
+			return err
+		}
+	}
+
+	runPostRunHook(jobs)
+
+	if failed := hookFailures.Load(); failed > 0 {
+		return fmt.Errorf("%d hooks failed", failed)
 	}
 
-	timingutils.Time("\nTotal", flags.OutputTime, process)
+	return nil
 }
 
-func process() {
+// Get the files to render from the command line. Manifest files may be given between voxel
+// files, in which case they are used for all the voxel files following them. A manifest with
+// no voxel files following it renders the objects it lists.
+func getFileJobs(args []string) (jobs []fileJob) {
 	if flags.InputFilename != "" {
-		processFile(flags.InputFilename)
-	} else {
-		for _, file := range flag.Args() {
-			processFile(file)
+		args = []string{flags.InputFilename}
+	}
+
+	// The -manifest flag's objects are only rendered when nothing is given on the command line
+	manifestFilename, hasFiles := flags.ManifestFilename, len(args) > 0
+	addObjects := func() {
+		if hasFiles {
+			return
+		}
+
+		// A manifest which can't be read is reported when rendering, if it has any files
+		m, _ := getManifest(manifestFilename)
+		for _, filename := range m.GetObjectFilenames(manifestFilename) {
+			jobs = append(jobs, fileJob{inputFilename: filename, manifestFilename: manifestFilename})
+		}
+	}
+
+	for _, arg := range args {
+		if strings.HasSuffix(arg, ".json") {
+			addObjects()
+			manifestFilename, hasFiles = arg, false
+		} else {
+			jobs = append(jobs, fileJob{inputFilename: arg, manifestFilename: manifestFilename})
+			hasFiles = true
 		}
 	}
+
+	addObjects()
+	return
 }
 
-func processFile(inputFilename string) {
+// Render files using a pool of flags.Jobs workers
+func runFileJobs(jobs []fileJob) {
+	queue := make(chan fileJob)
+
+	workers := flags.Jobs
+	if workers < 1 {
+		workers = 1
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			for job := range queue {
+				processFile(job.inputFilename, job.manifestFilename)
+			}
+			wg.Done()
+		}()
+	}
+
+	for _, job := range jobs {
+		queue <- job
+	}
+
+	close(queue)
+	wg.Wait()
+
+	if flags.ProgressIndicator && len(jobs) > 1 {
+		fmt.Printf("\n%d files: %d rendered, %d up to date\n", len(jobs), renderedCount.Load(), upToDateCount.Load())
+	}
+}
+
+func processFile(inputFilename string, manifestFilename string) {
 	if !strings.HasSuffix(inputFilename, ".vox") {
 		fmt.Printf("Files does not have .vox extension: %s\n", inputFilename)
 		return
 	}
 
+	runPreRenderHook(inputFilename, manifestFilename)
+
 	splitScales := strings.Split(flags.Scales, ",")
 	numScales := len(splitScales)
 
@@ -104,7 +298,7 @@ func processFile(inputFilename string) {
 
 	// Check if there are files to output
 	for _, scale := range splitScales {
-		exist, err := allPotentialOutputFilesExist(inputFilename, scale, numScales, flags.ManifestFilename)
+		exist, err := allPotentialOutputFilesExist(inputFilename, scale, numScales, manifestFilename)
 
 		if err != nil {
 			fmt.Printf("error attempting to stat files: %v", err)
@@ -118,6 +312,7 @@ func processFile(inputFilename string) {
 	}
 
 	if allFilesExist {
+		upToDateCount.Add(1)
 		if flags.ProgressIndicator {
 			fmt.Print(".")
 		}
@@ -129,57 +324,86 @@ func processFile(inputFilename string) {
 		log.Fatal(err)
 	}
 
-	renderManifest, err := getManifest(flags.ManifestFilename)
+	renderManifest, err := getObjectManifest(manifestFilename, inputFilename)
 	if err != nil {
-		log.Fatal(err)
+		log.Fatalf("%s: %v", manifestFilename, err)
 	}
 
-	if flags.Fast {
-		renderManifest.Sampler = "square"
-		renderManifest.Accuracy = 1
-		renderManifest.Overlap = 0
-	}
+	applyFastSettings(&renderManifest)
 
 	object, err := magica.FromFile(inputFilename)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	if flags.ProfileFile != "" {
-		f, err := os.Create(flags.ProfileFile)
-		if err != nil {
-			log.Fatal("could not create CPU profile: ", err)
-		}
-		defer func(f *os.File) {
-			_ = f.Close()
-		}(f)
-		if err := pprof.StartCPUProfile(f); err != nil {
-			log.Fatal("could not start CPU profile: ", err)
-		}
-		defer pprof.StopCPUProfile()
+	checkSymmetry(inputFilename, &renderManifest, object)
+
+	// The unpainted object is kept for the preview, which paints it again when the manifest changes
+	def, err := renderer.GetDefinition(inputFilename, object, renderManifest, palette, flags.OutputTime)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	var processedObject voxelobject.ProcessedVoxelObject
-	timingutils.Time("Voxel processing", flags.OutputTime, func() {
-		processedObject = voxelobject.GetProcessedVoxelObject(object, &palette, renderManifest.TiledNormals, renderManifest.TilingMode, renderManifest.SolidBase)
-	})
+	if flags.Preview != "" {
+		servePreview(inputFilename, manifestFilename, splitScales[0], object, def)
+		return
+	}
 
-	// Check if there are files to output
+	output := getOutput(inputFilename)
 	for _, scale := range splitScales {
 		timingutils.Time(fmt.Sprintf("Total (%sx)", scale), flags.OutputTime, func() {
-			renderScale(inputFilename, scale, renderManifest, processedObject, palette, numScales)
+			scaledDef, err := getScaledDefinition(scale, def)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+
+			if err := output.Render(scaledDef, getOutputFilename(inputFilename, scale, numScales)); err != nil {
+				log.Fatal(err)
+			}
 		})
 	}
 
+	renderedCount.Add(1)
 	if flags.ProgressIndicator {
 		fmt.Print("o")
 	}
 
 }
 
+// Override manifest settings with the fastest options if fast rendering was requested
+func applyFastSettings(m *manifest.Manifest) {
+	if flags.Fast {
+		setFastSettings(m)
+	}
+}
+
+func setFastSettings(m *manifest.Manifest) {
+	m.Sampler = "square"
+	m.Accuracy = 1
+	m.Overlap = 0
+	m.SinglePassDither = true
+}
+
+// Warn if an object is rendered as symmetric but isn't, and render symmetric objects as
+// symmetric if requested
+func checkSymmetry(inputFilename string, m *manifest.Manifest, object magica.VoxelObject) {
+	if !m.Symmetric && !flags.AutoSymmetry {
+		return
+	}
+
+	differing, total := voxelobject.GetAsymmetry(object)
+	if m.Symmetric && differing > 0 {
+		fmt.Printf("%s: warning: manifest sets symmetric, but %d of %d voxels differ from their mirror image\n", inputFilename, differing, total)
+	} else if differing == 0 && total > 0 {
+		m.Symmetric = true
+	}
+}
+
 func allPotentialOutputFilesExist(inputFilename string, scale string, numScales int, manifestFilepath string) (bool, error) {
-	// Always overwrite files if the flag is set
-	if flags.Overwrite {
+	// Always overwrite files if the flag is set, and never skip files being previewed or
+	// combined, as combining needs the layout of every file
+	if flags.Overwrite || flags.Preview != "" || flags.Combine != "" {
 		return false, nil
 	}
 
@@ -202,8 +426,52 @@ func allPotentialOutputFilesExist(inputFilename string, scale string, numScales
 	if manifestNewer {
 		return false, nil
 	}
+
+	// A manifest which can't be read is reported when rendering
+	m, err := getObjectManifest(manifestFilepath, inputFilename)
+	if err != nil {
+		return false, nil
+	}
+
+	// Sprite files are always output in full
+	if m.SpriteFiles {
+		check = []string{"8bpp", "32bpp", "mask"}
+	}
+
+	template := m.GetTemplate()
+	var filenames []string
 	for _, f := range check {
-		newer, err := fileIsNewerThanDate(outputFilename+"_"+f+".png", inputFileStats.ModTime())
+		for _, filename := range renderer.GetVariantFilenames(outputFilename, m) {
+			filenames = append(filenames, template.GetFilename(filename, f))
+
+			if m.SpriteFiles {
+				for i := range m.Sprites {
+					filenames = append(filenames, spritesheet.GetSpriteFilename(filename, i, f))
+				}
+			}
+		}
+
+		if m.Purchase != nil {
+			for _, filename := range renderer.GetVariantFilenames(outputFilename+renderer.PurchaseSuffix, m) {
+				filenames = append(filenames, m.GetPurchaseManifest().GetTemplate().GetFilename(filename, f))
+			}
+		}
+
+		if m.Icon != nil {
+			for _, filename := range renderer.GetVariantFilenames(outputFilename+renderer.IconSuffix, m) {
+				filenames = append(filenames, manifest.Template{}.GetFilename(filename, f))
+			}
+		}
+	}
+
+	if m.Aseprite {
+		for _, filename := range renderer.GetVariantFilenames(outputFilename, m) {
+			filenames = append(filenames, filename+".aseprite")
+		}
+	}
+
+	for _, filename := range filenames {
+		newer, err := fileIsNewerThanDate(filename, inputFileStats.ModTime())
 		if err != nil {
 			return false, err
 		}
@@ -235,36 +503,65 @@ func fileIsNewerThanDate(filename string, date time.Time) (bool, error) {
 	return false, nil
 }
 
-func renderScale(inputFilename string, scale string, m manifest.Manifest, processedObject voxelobject.ProcessedVoxelObject, palette colour.Palette, numScales int) {
+// Get the definition for rendering at a scale, with the output settings from the command line
+func getScaledDefinition(scale string, def manifest.Definition) (manifest.Definition, error) {
 	if flags.OutputTime {
 		fmt.Printf("\n=== Scale %sx ===\n", scale)
 	}
 
 	scaleF, err := strconv.ParseFloat(scale, 64)
 	if err != nil {
-		fmt.Printf("Could not interpret scale %s: %v\n", scale, err)
-		return
+		return manifest.Definition{}, fmt.Errorf("Could not interpret scale %s: %v", scale, err)
 	}
 
-	def := manifest.Definition{
-		Object:   processedObject,
-		Manifest: m,
-		Palette:  palette,
-		Scale:    scaleF,
-		Debug:    flags.Debug,
-		Time:     flags.OutputTime,
-		Only8bpp: flags.Output8bppOnly,
-	}
+	def.Scale = scaleF
+	def.Debug = flags.Debug
+	def.Only8bpp = flags.Output8bppOnly && !def.Manifest.SpriteFiles
+	def.MaxMemory = int64(flags.MaxMemory) << 20
+	return def, nil
+}
 
-	sheets := spritesheet.GetSpritesheets(def)
+// Get the output for rendering a file, which checks strict mode, records checksums and runs
+// hooks for everything written
+func getOutput(inputFilename string) renderer.Output {
+	return renderer.Output{
+		Report:  flags.Report,
+		GBuffer: flags.GBuffer,
+		Relight: flags.Relight,
+		OnSheets: func(outputFilename string, sheets *spritesheet.Spritesheets) error {
+			checkStrict(inputFilename, sheets.Report)
+			addToCombined(outputFilename, sheets)
+			if flags.CombinedReport != "" {
+				combinedReport.Add(outputFilename, sheets.Report)
+			}
+			return nil
+		},
+		OnFile: func(filename string, key string) error {
+			addChecksum(filename)
+			runPostOutputHook(filename, inputFilename, key)
+			return nil
+		},
+	}
+}
 
-	outputFilename := getOutputFilename(inputFilename, scale, numScales)
+func addChecksum(filename string) {
+	if flags.Checksums != "" {
+		if err := checksums.Add(filename); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
 
-	timingutils.Time("PNG output", flags.OutputTime, func() {
-		if err := sheets.SaveAll(outputFilename); err != nil {
+func outputReport(outputFilename string, r report.Report) {
+	if flags.Report {
+		if err := fileutils.WriteToFile(outputFilename+"_report.json", &r); err != nil {
 			log.Fatal(err)
 		}
-	})
+	}
+
+	if flags.CombinedReport != "" {
+		combinedReport.Add(outputFilename, r)
+	}
 }
 
 func getOutputFilename(inputFilename string, scale string, numScales int) string {
@@ -280,43 +577,71 @@ func getOutputFilename(inputFilename string, scale string, numScales int) string
 		outputFilename = fileutils.GetBaseFilename(flags.OutputFilename)
 	}
 
-	outputFilename += flags.Suffix
+	return getScaledFilename(outputFilename+flags.Suffix, scale, numScales)
+}
 
+// Put output for a scale in its own subdirectory, or add the scale to its name, if needed
+func getScaledFilename(filename string, scale string, numScales int) string {
 	if numScales > 1 || flags.SubDirs {
 		if flags.SubDirs {
-			outputFilename = scale + "x/" + outputFilename
-			if _, err := os.Stat(scale + "x/"); os.IsNotExist(err) {
-				if err := os.Mkdir(scale+"x/", 0755); err != nil {
-					log.Fatal(err)
-				}
+			filename = scale + "x/" + filename
+			if err := os.MkdirAll(scale+"x/", 0755); err != nil {
+				log.Fatal(err)
 			}
 		} else {
-			outputFilename = outputFilename + "_" + scale + "x"
+			filename = filename + "_" + scale + "x"
 		}
 	}
 
-	return outputFilename
+	return filename
 }
 
-func setupFlags() error {
-	flag.Parse()
+func getPalette(filename string) (colour.Palette, error) {
+	return colour.FromFile(filename)
+}
 
-	if flags.InputFilename == "" && len(flag.Args()) == 0 {
-		flag.Usage()
-		return fmt.Errorf("no files supplied on command line and input flag not set")
+func getManifest(filename string) (manifest manifest.Manifest, err error) {
+	// Default if empty
+	manifest.DepthInfluence = 0.1
+	if err = fileutils.InstantiateFromFile(filename, &manifest); err != nil {
+		return
 	}
 
-	return nil
+	return manifest.GetTargetManifest(flags.Target)
 }
 
-func getPalette(filename string) (palette colour.Palette, err error) {
-	err = fileutils.InstantiateFromFile(filename, &palette)
-	return
+// Use the scales of the -target instead of -scale, if it sets any. Scales apply to the whole
+// run, so must be the same in every manifest.
+func applyTargetScales(jobs []fileJob) error {
+	if flags.Target == "" {
+		return nil
+	}
+
+	scales, scalesFilename := "", ""
+	for _, job := range jobs {
+		m, err := getManifest(job.manifestFilename)
+		if err != nil {
+			return fmt.Errorf("%s: %v", job.manifestFilename, err)
+		}
+
+		s := m.Targets[flags.Target].GetScales()
+		if scalesFilename != "" && s != scales {
+			return fmt.Errorf("target %s has different scales in %s and %s", flags.Target, scalesFilename, job.manifestFilename)
+		}
+
+		scales, scalesFilename = s, job.manifestFilename
+	}
+
+	if scales != "" {
+		flags.Scales = scales
+	}
+
+	return nil
 }
 
-func getManifest(filename string) (manifest manifest.Manifest, err error) {
-	// Default if empty
-	manifest.DepthInfluence = 0.1
-	err = fileutils.InstantiateFromFile(filename, &manifest)
-	return
+// Get the manifest for rendering a voxel file, with the sprites of the manifest's object for
+// the file if it has one
+func getObjectManifest(manifestFilename, inputFilename string) (manifest.Manifest, error) {
+	m, err := getManifest(manifestFilename)
+	return m.GetObjectManifest(m.GetObjectIndex(inputFilename, manifestFilename)), err
 }
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"github.com/mattkimber/gandalf/magica"
+	"github.com/mattkimber/gorender/internal/colour"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"github.com/mattkimber/gorender/internal/renderer"
+	"github.com/mattkimber/gorender/internal/server"
+	"github.com/mattkimber/gorender/internal/utils/fileutils"
+	"log"
+)
+
+func serve() {
+	palette, err := getPalette(flags.PaletteFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	s := server.Server{
+		MaxJobs: flags.MaxJobs,
+		Expiry:  flags.JobExpiry,
+		Process: func(inputFilename string, m manifest.Manifest, scale float64) (manifest.Definition, error) {
+			return processJob(inputFilename, m, scale, palette)
+		},
+		Render: func(inputFilename string, m manifest.Manifest, scale float64) error {
+			return renderJob(inputFilename, m, scale, palette)
+		},
+	}
+
+	if flags.Grpc != "" {
+		fmt.Printf("Serving gRPC render service at %s\n", flags.Grpc)
+		if flags.Serve == "" {
+			log.Fatal(s.ListenAndServeGrpc(flags.Grpc))
+		}
+
+		go func() { log.Fatal(s.ListenAndServeGrpc(flags.Grpc)) }()
+	}
+
+	fmt.Printf("Serving render jobs at http://%s/jobs\n", flags.Serve)
+	log.Fatal(s.ListenAndServe(flags.Serve))
+}
+
+// Get the definition for a job submitted to the server
+func processJob(inputFilename string, m manifest.Manifest, scale float64, palette colour.Palette) (manifest.Definition, error) {
+	object, err := magica.FromFile(inputFilename)
+	if err != nil {
+		return manifest.Definition{}, err
+	}
+
+	def, err := renderer.GetDefinition(inputFilename, object, m, palette, false)
+	def.Scale = scale
+	return def, err
+}
+
+// Render a job submitted to the server, writing all output files and the report alongside
+// the input file
+func renderJob(inputFilename string, m manifest.Manifest, scale float64, palette colour.Palette) error {
+	def, err := processJob(inputFilename, m, scale, palette)
+	if err != nil {
+		return err
+	}
+
+	output := renderer.Output{Report: true}
+	return output.Render(def, fileutils.GetBaseFilename(inputFilename))
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"github.com/mattkimber/gorender/internal/report"
+	"log"
+	"slices"
+	"strings"
+)
+
+// Categories of report warning which stop rendering in strict mode. The flag can be
+// given on its own, which only fails on unexpected animated pixels as strict mode
+// always has, or with a comma-separated list of categories or "all".
+type strictCategories []string
+
+func (s *strictCategories) String() string {
+	if s == nil {
+		return ""
+	}
+
+	return strings.Join(*s, ",")
+}
+
+func (s *strictCategories) IsBoolFlag() bool {
+	return true
+}
+
+func (s *strictCategories) Set(value string) error {
+	switch value {
+	case "true":
+		*s = strictCategories{report.CategoryUnexpectedAnimation}
+	case "false":
+		*s = nil
+	case "all":
+		*s = report.Categories
+	default:
+		*s = nil
+		for _, category := range strings.Split(value, ",") {
+			if !slices.Contains(report.Categories, category) {
+				return fmt.Errorf("unknown warning category %s (must be one of %s)", category, strings.Join(report.Categories, ", "))
+			}
+
+			*s = append(*s, category)
+		}
+	}
+
+	return nil
+}
+
+// Stop without writing output if the report has warnings in any category strict mode is on for
+func checkStrict(inputFilename string, r report.Report) {
+	failed := make([]string, 0)
+	for _, category := range flags.Strict {
+		if r.HasWarnings(category) {
+			failed = append(failed, category)
+		}
+	}
+
+	if len(failed) == 0 {
+		return
+	}
+
+	for _, w := range r.Warnings {
+		if slices.Contains(failed, w.Category) {
+			fmt.Printf("%s: %s\n", inputFilename, w)
+		}
+	}
+
+	log.Fatalf("%s: strict mode warnings in output (%s)", inputFilename, strings.Join(failed, ", "))
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"github.com/mattkimber/gandalf/magica"
+	"github.com/mattkimber/gorender/internal/geometry"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"github.com/mattkimber/gorender/internal/renderer"
+	"github.com/mattkimber/gorender/internal/spritesheet"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var (
+	turntableOutput     string
+	turntableFrames     int
+	turntableFPS        int
+	turntableFFmpeg     string
+	turntableBackground string
+)
+
+func addTurntableFlags(fs *flag.FlagSet) {
+	addPaletteFlag(fs)
+	fs.StringVar(&flags.ManifestFilename, "manifest", "files/manifest.json", "manifest file to use (see documentation)")
+	fs.StringVar(&flags.ManifestFilename, "m", "files/manifest.json", "shorthand for -manifest")
+	fs.StringVar(&flags.Scales, "scale", "1.0", "scale to render the frames at")
+	fs.StringVar(&flags.Scales, "s", "1.0", "shorthand for -scale")
+	fs.StringVar(&turntableOutput, "output", "", "video file to write, e.g. turntable.mp4 or turntable.webm (default: the voxel file with .mp4)")
+	fs.StringVar(&turntableOutput, "o", "", "shorthand for -output")
+	fs.IntVar(&turntableFrames, "frames", 72, "number of angles to render for a full turn")
+	fs.IntVar(&turntableFPS, "fps", 24, "frames per second of the video")
+	fs.StringVar(&turntableFFmpeg, "ffmpeg", "ffmpeg", "ffmpeg command used to encode the video")
+	fs.StringVar(&turntableBackground, "background", "ffffff", "hex colour shown behind the object")
+}
+
+// Render a full turn of a voxel file, using the first sprite in the manifest for the size
+// and settings of every frame, and encode it as a video by piping the frames to ffmpeg
+func turntable(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("turntable needs exactly one voxel file")
+	}
+
+	if turntableFrames < 1 || turntableFPS < 1 {
+		return fmt.Errorf("-frames and -fps must be at least 1")
+	}
+
+	background, err := strconv.ParseUint(strings.TrimPrefix(turntableBackground, "#"), 16, 32)
+	if err != nil || len(strings.TrimPrefix(turntableBackground, "#")) != 6 {
+		return fmt.Errorf("invalid background colour %s", turntableBackground)
+	}
+
+	inputFilename := args[0]
+	outputFilename := turntableOutput
+	if outputFilename == "" {
+		outputFilename = strings.TrimSuffix(inputFilename, filepath.Ext(inputFilename)) + ".mp4"
+	}
+
+	frames, err := renderTurntable(inputFilename)
+	if err != nil {
+		return err
+	}
+
+	// Frames are centred on a canvas large enough for all of them, with even dimensions
+	// as most video codecs need them
+	bounds := image.Rectangle{}
+	for _, f := range frames {
+		bounds = bounds.Union(f.Bounds())
+	}
+
+	width, height := (bounds.Dx()+1)&^1, (bounds.Dy()+1)&^1
+	canvas := image.NewRGBA(image.Rect(0, 0, width, height))
+	bg := image.NewUniform(color.RGBA{R: uint8(background >> 16), G: uint8(background >> 8), B: uint8(background), A: 255})
+
+	cmd := exec.Command(turntableFFmpeg, "-y", "-loglevel", "error",
+		"-f", "rawvideo", "-pix_fmt", "rgba", "-s", fmt.Sprintf("%dx%d", width, height), "-r", fmt.Sprint(turntableFPS), "-i", "-",
+		"-pix_fmt", "yuv420p", outputFilename)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("could not run %s: %v", turntableFFmpeg, err)
+	}
+
+	w := bufio.NewWriter(stdin)
+	for _, f := range frames {
+		draw.Draw(canvas, canvas.Bounds(), bg, image.Point{}, draw.Src)
+		offset := image.Pt((width-f.Bounds().Dx())/2, (height-f.Bounds().Dy())/2)
+		draw.Draw(canvas, f.Bounds().Add(offset), f, image.Point{}, draw.Over)
+
+		if _, err := w.Write(canvas.Pix); err != nil {
+			break
+		}
+	}
+
+	_ = w.Flush()
+	_ = stdin.Close()
+
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("%s failed: %v", turntableFFmpeg, err)
+	}
+
+	fmt.Printf("%s: %d frames, %dx%d\n", outputFilename, len(frames), width, height)
+	return nil
+}
+
+// Render the 32bpp sprite for each angle of the turntable
+func renderTurntable(inputFilename string) ([]*image.RGBA, error) {
+	palette, err := getPalette(flags.PaletteFile)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := getObjectManifest(flags.ManifestFilename, inputFilename)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", flags.ManifestFilename, err)
+	}
+
+	if len(m.Sprites) == 0 {
+		return nil, fmt.Errorf("%s: no sprites", flags.ManifestFilename)
+	}
+
+	m = getTurntableManifest(m, turntableFrames)
+
+	object, err := magica.FromFile(inputFilename)
+	if err != nil {
+		return nil, err
+	}
+
+	def, err := renderer.GetDefinition(inputFilename, object, m, palette, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if def, err = getScaledDefinition(strings.Split(flags.Scales, ",")[0], def); err != nil {
+		return nil, err
+	}
+
+	def.Only8bpp = false
+
+	// Render one frame at a time, so only one frame's raycast output is held at once
+	frames := make([]*image.RGBA, len(m.Sprites))
+	for i, spr := range m.Sprites {
+		frameDef := def
+		frameDef.Manifest.Sprites = []manifest.Sprite{spr}
+
+		sheets := spritesheet.GetSpritesheets(frameDef)
+
+		// Crop the spacing from the single sprite on the sheet, using its size after any auto sizing
+		frames[i] = image.NewRGBA(image.Rect(0, 0, sheets.Layout[0].Width, sheets.Layout[0].Height))
+		draw.Draw(frames[i], frames[i].Bounds(), sheets.Data["32bpp"].Image, image.Point{}, draw.Src)
+		sheets.Release()
+	}
+
+	return frames, nil
+}
+
+// Get a manifest with a copy of its first sprite for each angle of a full turn. Every frame
+// has the same size, large enough for the widest angle, and is zoomed so the object is drawn
+// at the same scale as in the first sprite. Settings which depend on the manifest's own list
+// of sprites are turned off.
+func getTurntableManifest(m manifest.Manifest, frames int) manifest.Manifest {
+	first := m.Sprites[0]
+	first.Flip, first.FlipHorizontal = false, false
+
+	zoom := first.Zoom
+	if zoom <= 0 {
+		zoom = 1
+	}
+
+	// Sprites fit the width of the object at their angle, so find the scale of the first
+	// sprite in pixels per voxel, and the widest the object gets as it turns
+	pixelsPerVoxel := float64(first.Width) * zoom / getTurntableObjectWidth(m.Size, first.Angle)
+
+	m.Sprites = make([]manifest.Sprite, frames)
+	maxWidth := 0.0
+	for i := range m.Sprites {
+		m.Sprites[i] = first
+		m.Sprites[i].Angle = first.Angle + 360*float64(i)/float64(frames)
+		maxWidth = math.Max(maxWidth, getTurntableObjectWidth(m.Size, m.Sprites[i].Angle))
+	}
+
+	width := int(math.Ceil(pixelsPerVoxel * maxWidth))
+	height := 0
+	for i := range m.Sprites {
+		spr := &m.Sprites[i]
+		spr.Width, spr.Height, spr.ZError = width, 0, 0
+		spr.Zoom = pixelsPerVoxel * getTurntableObjectWidth(m.Size, spr.Angle) / float64(width)
+	}
+
+	m.Symmetric, m.Deduplicate, m.Template = false, false, ""
+	m.SetSpriteSizes()
+
+	// Use the height of the tallest frame for all of them, so the object doesn't move. Auto
+	// heights are calculated for the object filling the width, so are reduced by the zoom.
+	for _, spr := range m.Sprites {
+		height = max(height, int(math.Ceil(float64(spr.Height)*spr.Zoom)))
+	}
+
+	for i := range m.Sprites {
+		m.Sprites[i].Height = height
+	}
+
+	return m
+}
+
+// Get the width of the object across the screen when viewed from an angle, in voxels
+func getTurntableObjectWidth(size geometry.Vector3, angle float64) float64 {
+	rad := geometry.DegToRad(angle)
+	return math.Abs(size.X*math.Sin(rad)) + math.Abs(size.Y*math.Cos(rad))
+}
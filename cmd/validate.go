@@ -0,0 +1,141 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/mattkimber/gandalf/magica"
+	"github.com/mattkimber/gorender/internal/colour"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"github.com/mattkimber/gorender/internal/voxelobject"
+	"os"
+	"sort"
+	"strings"
+)
+
+func addValidateFlags(fs *flag.FlagSet) {
+	fs.StringVar(&flags.ManifestFilename, "manifest", "files/manifest.json", "manifest file to use (see documentation)")
+	fs.StringVar(&flags.ManifestFilename, "m", "files/manifest.json", "shorthand for -manifest")
+	addPaletteFlag(fs)
+}
+
+// Check the manifest and palette, and if voxel files are given (or the manifest lists its own
+// objects), that the objects, nodes and layers used by sprites can be found
+func validate(files []string) error {
+	palette, paletteErr := validatePalette()
+
+	file, err := os.Open(flags.ManifestFilename)
+	if err != nil {
+		return err
+	}
+
+	warnings, errs := manifest.Validate(file)
+	_ = file.Close()
+
+	for _, w := range warnings {
+		fmt.Printf("%s: warning: %s\n", flags.ManifestFilename, w)
+	}
+
+	if len(errs) == 0 {
+		m, err := getManifest(flags.ManifestFilename)
+		if err != nil {
+			return err
+		}
+
+		if paletteErr == nil {
+			for _, name := range getUnusedColourClasses(m, palette) {
+				fmt.Printf("%s: warning: colour class %s is not used by any palette range\n", flags.ManifestFilename, name)
+			}
+		}
+
+		if len(files) == 0 {
+			files = m.GetObjectFilenames(flags.ManifestFilename)
+		}
+
+		for _, inputFilename := range files {
+			m := m.GetObjectManifest(m.GetObjectIndex(inputFilename, flags.ManifestFilename))
+			errs = append(errs, validateSymmetry(inputFilename, m)...)
+
+			for i, spr := range m.Sprites {
+				if !spr.HasOwnObject() {
+					continue
+				}
+
+				filename, node := spr.GetObjectSource(inputFilename)
+				if _, err := voxelobject.FromFileWithSelection(filename, node, spr.VisibleLayers); err != nil {
+					errs = append(errs, fmt.Errorf("%s: sprite %d: %v", filename, i, err))
+				}
+			}
+		}
+	}
+
+	for _, err := range errs {
+		fmt.Printf("%s: %v\n", flags.ManifestFilename, err)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s: %d problems found", flags.ManifestFilename, len(errs))
+	}
+
+	if paletteErr != nil {
+		return paletteErr
+	}
+
+	fmt.Printf("%s: ok\n", flags.ManifestFilename)
+	return nil
+}
+
+// Check the palette, printing any problems found
+func validatePalette() (colour.Palette, error) {
+	palette, err := getPalette(flags.PaletteFile)
+	if err != nil {
+		// Palette errors are joined one per line, so label each line
+		for _, line := range strings.Split(err.Error(), "\n") {
+			fmt.Printf("%s: %s\n", flags.PaletteFile, line)
+		}
+
+		return palette, fmt.Errorf("%s: palette is not valid", flags.PaletteFile)
+	}
+
+	warnings, _ := palette.Validate()
+	for _, w := range warnings {
+		fmt.Printf("%s: warning: %s\n", flags.PaletteFile, w)
+	}
+
+	return palette, nil
+}
+
+// Get the colour classes configured in the manifest which no palette range declares,
+// as these are usually misspelled
+func getUnusedColourClasses(m manifest.Manifest, palette colour.Palette) (unused []string) {
+	used := make(map[string]bool)
+	for _, r := range palette.Ranges {
+		used[r.Class] = true
+	}
+
+	for name := range m.ColourClasses {
+		if !used[name] {
+			unused = append(unused, name)
+		}
+	}
+
+	sort.Strings(unused)
+	return
+}
+
+// Check objects declared symmetric are symmetric, and suggest symmetric rendering for
+// objects which could use it
+func validateSymmetry(inputFilename string, m manifest.Manifest) (errs []error) {
+	object, err := magica.FromFile(inputFilename)
+	if err != nil {
+		return []error{fmt.Errorf("%s: %v", inputFilename, err)}
+	}
+
+	differing, total := voxelobject.GetAsymmetry(object)
+	if m.Symmetric && differing > 0 {
+		errs = append(errs, fmt.Errorf("%s: symmetric is set, but %d of %d voxels differ from their mirror image", inputFilename, differing, total))
+	} else if !m.Symmetric && differing == 0 && total > 0 {
+		fmt.Printf("%s: suggestion: object is symmetric, set symmetric to render it faster\n", inputFilename)
+	}
+
+	return
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"github.com/mattkimber/gorender/internal/utils/fileutils"
+	"strings"
+)
+
+// Check all output files are newer than their voxel files and the manifest, without rendering.
+// If a checksum file is given, also check the output files match it.
+func verify(args []string) error {
+	jobs := getFileJobs(args)
+	if len(jobs) == 0 && flags.Checksums == "" {
+		return fmt.Errorf("no files supplied")
+	}
+
+	if err := applyTargetScales(jobs); err != nil {
+		return err
+	}
+
+	scales := strings.Split(flags.Scales, ",")
+	stale := 0
+
+	if flags.Checksums != "" {
+		if err := fileutils.InstantiateFromFile(flags.Checksums, &checksums); err != nil {
+			return fmt.Errorf("could not read checksums: %v", err)
+		}
+
+		mismatches := checksums.Verify()
+		for _, m := range mismatches {
+			fmt.Println(m)
+		}
+
+		if len(mismatches) > 0 {
+			return fmt.Errorf("%d of %d files do not match %s", len(mismatches), len(checksums.Files), flags.Checksums)
+		}
+	}
+
+	for _, job := range jobs {
+		for _, scale := range scales {
+			exist, err := allPotentialOutputFilesExist(job.inputFilename, scale, len(scales), job.manifestFilename)
+			if err != nil {
+				return err
+			}
+
+			if !exist {
+				fmt.Printf("%s: output at %sx is missing or out of date\n", job.inputFilename, scale)
+				stale++
+			}
+		}
+	}
+
+	if stale > 0 {
+		return fmt.Errorf("%d outputs need rendering", stale)
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"github.com/mattkimber/gorender/internal/renderer"
+	"syscall/js"
+)
+
+var r renderer.Renderer
+
+// Expose the renderer to JavaScript as the global gorender object. Load functions
+// return null on success or an error message, and renderSprite returns an object
+// which can be passed to the ImageData constructor, or one with an error message.
+func main() {
+	js.Global().Set("gorender", js.ValueOf(map[string]interface{}{
+		"loadPalette":  js.FuncOf(loadPalette),
+		"loadManifest": js.FuncOf(loadManifest),
+		"loadVox":      js.FuncOf(loadVox),
+		"spriteCount":  js.FuncOf(spriteCount),
+		"renderSprite": js.FuncOf(renderSprite),
+	}))
+
+	// Keep running so the functions remain available
+	select {}
+}
+
+func getError(err error) interface{} {
+	if err != nil {
+		return err.Error()
+	}
+
+	return nil
+}
+
+// loadPalette(json: string)
+func loadPalette(this js.Value, args []js.Value) interface{} {
+	return getError(r.LoadPalette([]byte(args[0].String())))
+}
+
+// loadManifest(json: string)
+func loadManifest(this js.Value, args []js.Value) interface{} {
+	return getError(r.LoadManifest([]byte(args[0].String())))
+}
+
+// loadVox(data: Uint8Array)
+func loadVox(this js.Value, args []js.Value) interface{} {
+	data := make([]byte, args[0].Get("length").Int())
+	js.CopyBytesToGo(data, args[0])
+	return getError(r.LoadVox(data))
+}
+
+// spriteCount(): number
+func spriteCount(this js.Value, args []js.Value) interface{} {
+	return r.NumSprites()
+}
+
+// renderSprite(index: number, scale: number, depth: "8bpp" | "32bpp" | "mask")
+func renderSprite(this js.Value, args []js.Value) interface{} {
+	img, err := r.RenderSprite(args[0].Int(), args[1].Float(), args[2].String())
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}
+	}
+
+	data := js.Global().Get("Uint8ClampedArray").New(len(img.Pix))
+	js.CopyBytesToJS(data, img.Pix)
+
+	return map[string]interface{}{
+		"width":  img.Bounds().Dx(),
+		"height": img.Bounds().Dy(),
+		"data":   data,
+	}
+}
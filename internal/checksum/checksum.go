@@ -0,0 +1,115 @@
+package checksum
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// A list of files and their SHA256 checksums, read and written in the same format as
+// sha256sum so it can also be checked with "sha256sum -c"
+type Checksums struct {
+	sync.Mutex
+	Files map[string]string
+}
+
+// Add a file, replacing any existing checksum for it
+func (c *Checksums) Add(filename string) error {
+	sum, err := File(filename)
+	if err != nil {
+		return err
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	if c.Files == nil {
+		c.Files = make(map[string]string)
+	}
+
+	c.Files[filepath.ToSlash(filename)] = sum
+	return nil
+}
+
+// Check every file still has the recorded checksum, returning a description of
+// each file which does not
+func (c *Checksums) Verify() (mismatches []string) {
+	for _, filename := range c.filenames() {
+		sum, err := File(filepath.FromSlash(filename))
+		if os.IsNotExist(err) {
+			mismatches = append(mismatches, fmt.Sprintf("%s: missing", filename))
+		} else if err != nil {
+			mismatches = append(mismatches, fmt.Sprintf("%s: %v", filename, err))
+		} else if sum != c.Files[filename] {
+			mismatches = append(mismatches, fmt.Sprintf("%s: checksum does not match", filename))
+		}
+	}
+
+	return
+}
+
+func (c *Checksums) filenames() (filenames []string) {
+	for filename := range c.Files {
+		filenames = append(filenames, filename)
+	}
+
+	sort.Strings(filenames)
+	return
+}
+
+func (c *Checksums) OutputToWriter(w io.Writer) (err error) {
+	for _, filename := range c.filenames() {
+		if _, err = fmt.Fprintf(w, "%s  %s\n", c.Files[filename], filename); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func (c *Checksums) GetFromReader(r io.Reader) (err error) {
+	c.Files = make(map[string]string)
+	scanner := bufio.NewScanner(r)
+
+	for line := 1; scanner.Scan(); line++ {
+		if scanner.Text() == "" {
+			continue
+		}
+
+		// The filename is preceded by a space, and a "*" in binary mode
+		sum, filename, ok := strings.Cut(scanner.Text(), " ")
+		if !ok || len(sum) != sha256.Size*2 || len(filename) < 2 {
+			return fmt.Errorf("line %d is not a valid checksum", line)
+		}
+
+		c.Files[filename[1:]] = sum
+	}
+
+	return scanner.Err()
+}
+
+// Get the SHA256 checksum of a file as a hex string
+func File(filename string) (string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,88 @@
+package checksum
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestChecksums(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a_8bpp.png"), filepath.Join(dir, "b_8bpp.png")
+
+	for _, f := range []string{a, b} {
+		if err := os.WriteFile(f, []byte(f), 0644); err != nil {
+			t.Fatalf("could not write test file: %v", err)
+		}
+	}
+
+	c := Checksums{}
+	for _, f := range []string{b, a} {
+		if err := c.Add(f); err != nil {
+			t.Fatalf("could not add %s: %v", f, err)
+		}
+	}
+
+	if mismatches := c.Verify(); len(mismatches) != 0 {
+		t.Errorf("expected no mismatches, got %v", mismatches)
+	}
+
+	// Round trip through the sha256sum format
+	buf := bytes.Buffer{}
+	if err := c.OutputToWriter(&buf); err != nil {
+		t.Fatalf("could not write checksums: %v", err)
+	}
+
+	read := Checksums{}
+	if err := read.GetFromReader(&buf); err != nil {
+		t.Fatalf("could not read checksums: %v", err)
+	}
+
+	if len(read.Files) != 2 || read.Files[filepath.ToSlash(a)] != c.Files[filepath.ToSlash(a)] {
+		t.Errorf("expected %v, got %v", c.Files, read.Files)
+	}
+
+	_ = os.WriteFile(a, []byte("changed"), 0644)
+	_ = os.Remove(b)
+
+	mismatches := read.Verify()
+	expected := []string{filepath.ToSlash(a) + ": checksum does not match", filepath.ToSlash(b) + ": missing"}
+
+	if len(mismatches) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, mismatches)
+	}
+
+	for i := range expected {
+		if mismatches[i] != expected[i] {
+			t.Errorf("mismatch %d: expected %s, got %s", i, expected[i], mismatches[i])
+		}
+	}
+}
+
+func TestChecksums_GetFromReader(t *testing.T) {
+	sum := "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	testCases := []struct {
+		input    string
+		expected string
+		valid    bool
+	}{
+		{sum + "  a.png\n", "a.png", true},
+		{sum + " *a.png\n", "a.png", true},
+		{sum + "  dir/a b.png", "dir/a b.png", true},
+		{"abc  a.png\n", "", false},
+		{sum + "\n", "", false},
+	}
+
+	for _, testCase := range testCases {
+		c := Checksums{}
+		err := c.GetFromReader(bytes.NewBufferString(testCase.input))
+
+		if testCase.valid && (err != nil || c.Files[testCase.expected] != sum) {
+			t.Errorf("input %q: expected %s, got %v (%v)", testCase.input, testCase.expected, c.Files, err)
+		} else if !testCase.valid && err == nil {
+			t.Errorf("input %q: expected error, got %v", testCase.input, c.Files)
+		}
+	}
+}
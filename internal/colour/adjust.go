@@ -0,0 +1,156 @@
+package colour
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+// Base palettes can be based on other palettes, up to this depth
+const maxBaseDepth = 8
+
+// A change to the colours of a range of palette entries, made when the palette is loaded,
+// so the look of a shared palette can be tuned without copying it
+type PaletteAdjustment struct {
+	Start byte `json:"start"`
+	End   byte `json:"end"`
+
+	// Multiplies the colours, e.g. 0.9 to darken them by 10%. 0 leaves them unchanged.
+	Brightness float64 `json:"brightness"`
+
+	// Gamma correction, where values above 1 lighten the midtones and values below 1
+	// darken them. 0 leaves them unchanged.
+	Gamma float64 `json:"gamma"`
+}
+
+// Load a palette from a JSON file. If the palette has a base, the base palette's file is
+// loaded relative to this one, and anything the palette doesn't set is taken from it.
+func FromFile(filename string) (Palette, error) {
+	return fromFile(filename, 0)
+}
+
+func fromFile(filename string, depth int) (p Palette, err error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return Palette{}, err
+	}
+
+	if err = json.Unmarshal(data, &p); err != nil {
+		return Palette{}, err
+	}
+
+	if p.Base != "" {
+		if depth >= maxBaseDepth {
+			return Palette{}, fmt.Errorf("base palettes are nested more than %d deep", maxBaseDepth)
+		}
+
+		base, err := fromFile(filepath.Join(filepath.Dir(filename), p.Base), depth+1)
+		if err != nil {
+			return Palette{}, fmt.Errorf("base palette %s: %v", p.Base, err)
+		}
+
+		p.setBase(base)
+	}
+
+	return p.finishLoading()
+}
+
+// Take the entries, ranges and settings the palette doesn't set from its base
+func (p *Palette) setBase(base Palette) {
+	if len(p.Entries) == 0 {
+		p.Entries = append([]PaletteEntry{}, base.Entries...)
+	}
+
+	if len(p.Ranges) == 0 {
+		p.Ranges = append([]PaletteRange{}, base.Ranges...)
+	}
+
+	if p.CompanyColourLightingContribution == 0 {
+		p.CompanyColourLightingContribution = base.CompanyColourLightingContribution
+	}
+
+	if p.DefaultBrightness == 0 {
+		p.DefaultBrightness = base.DefaultBrightness
+	}
+
+	if p.CompanyColourLightingScale == 0 {
+		p.CompanyColourLightingScale = base.CompanyColourLightingScale
+	}
+
+	p.Base = ""
+}
+
+// Check the palette, make its adjustments and set up its ranges. Adjustments are removed
+// once made, so the palette can be saved and loaded again without making them twice.
+func (p Palette) finishLoading() (Palette, error) {
+	if p.Base != "" {
+		return Palette{}, fmt.Errorf("base palette %s can only be used when loading the palette from a file", p.Base)
+	}
+
+	// Report every problem with the ranges, rather than only the first SetRanges finds
+	if _, errs := p.Validate(); len(errs) > 0 {
+		return Palette{}, errors.Join(errs...)
+	}
+
+	for _, a := range p.Adjustments {
+		p.adjust(a)
+	}
+
+	p.Adjustments = nil
+
+	if err := p.SetRanges(p.Ranges); err != nil {
+		return Palette{}, err
+	}
+
+	return p, nil
+}
+
+func (p *Palette) adjust(a PaletteAdjustment) {
+	adjustChannel := func(c byte) byte {
+		v := float64(c) / 255
+		if a.Gamma > 0 {
+			v = math.Pow(v, 1/a.Gamma)
+		}
+
+		if a.Brightness > 0 {
+			v *= a.Brightness
+		}
+
+		return byte(math.Round(math.Max(0, math.Min(1, v)) * 255))
+	}
+
+	for i := int(a.Start); i <= int(a.End) && i < len(p.Entries); i++ {
+		e := &p.Entries[i]
+		e.R, e.G, e.B = adjustChannel(e.R), adjustChannel(e.G), adjustChannel(e.B)
+	}
+}
+
+// Check adjustments are in order, inside the palette and have valid values
+func (p Palette) validateAdjustments() (errs []error) {
+	for i, a := range p.Adjustments {
+		name := fmt.Sprintf("adjustment %d (%d-%d)", i, a.Start, a.End)
+
+		if a.Start > a.End {
+			errs = append(errs, fmt.Errorf("%s: start is after end", name))
+		} else if int(a.End) >= len(p.Entries) {
+			errs = append(errs, fmt.Errorf("%s: colour %d is outside the palette of %d colours", name, a.End, len(p.Entries)))
+		}
+
+		if a.Brightness < 0 {
+			errs = append(errs, fmt.Errorf("%s: brightness must not be negative", name))
+		}
+
+		if a.Gamma < 0 {
+			errs = append(errs, fmt.Errorf("%s: gamma must not be negative", name))
+		}
+	}
+
+	return
+}
+
+func (pe PaletteEntry) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]int{int(pe.R), int(pe.G), int(pe.B)})
+}
@@ -0,0 +1,109 @@
+package colour
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFromJson_Adjustments(t *testing.T) {
+	palette, err := FromJson(strings.NewReader(`{"entries": [[0,0,0],[200,100,50],[64,64,64]], "ranges": [{"start": 1, "end": 2}],
+		"adjustments": [{"start": 1, "end": 1, "brightness": 0.5}, {"start": 2, "end": 2, "gamma": 0.5}]}`))
+	if err != nil {
+		t.Fatalf("could not load palette: %v", err)
+	}
+
+	expected := []PaletteEntry{{R: 0, G: 0, B: 0}, {R: 100, G: 50, B: 25}, {R: 16, G: 16, B: 16}}
+	for i, e := range expected {
+		if palette.Entries[i].R != e.R || palette.Entries[i].G != e.G || palette.Entries[i].B != e.B {
+			t.Errorf("entry %d expected %v, got %v", i, e, palette.Entries[i])
+		}
+	}
+
+	if palette.Adjustments != nil {
+		t.Errorf("expected adjustments to be removed once made, got %v", palette.Adjustments)
+	}
+}
+
+func TestFromFile_Base(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "shared"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	writePalette(t, filepath.Join(dir, "shared", "base.json"), `{"default_brightness": 0.5, "entries": [[0,0,0],[200,200,200],[100,0,0]], "ranges": [{"start": 1, "end": 2}]}`)
+	writePalette(t, filepath.Join(dir, "project.json"), `{"base": "shared/base.json", "adjustments": [{"start": 1, "end": 1, "brightness": 0.9}]}`)
+	writePalette(t, filepath.Join(dir, "loop.json"), `{"base": "loop.json"}`)
+
+	palette, err := FromFile(filepath.Join(dir, "project.json"))
+	if err != nil {
+		t.Fatalf("could not load palette: %v", err)
+	}
+
+	if len(palette.Entries) != 3 || palette.Entries[1].R != 180 || palette.Entries[2].R != 100 {
+		t.Errorf("expected the base entries with colour 1 darkened, got %v", palette.Entries)
+	}
+
+	if palette.DefaultBrightness != 0.5 || len(palette.Ranges) != 1 || palette.Entries[1].Range == nil {
+		t.Errorf("expected the base settings and ranges, got %v and %v", palette.DefaultBrightness, palette.Ranges)
+	}
+
+	// Saved palettes have their base and adjustments applied, so load the same anywhere
+	data, err := json.Marshal(palette)
+	if err != nil {
+		t.Fatalf("could not save palette: %v", err)
+	}
+
+	saved, err := FromJson(strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatalf("could not load saved palette: %v", err)
+	}
+
+	for i := range palette.Entries {
+		if saved.Entries[i].R != palette.Entries[i].R || saved.Entries[i].G != palette.Entries[i].G || saved.Entries[i].B != palette.Entries[i].B {
+			t.Errorf("saved entry %d expected %v, got %v", i, palette.Entries[i], saved.Entries[i])
+		}
+	}
+
+	if _, err := FromFile(filepath.Join(dir, "loop.json")); err == nil {
+		t.Errorf("expected error for palette which is its own base")
+	}
+
+	if _, err := FromJson(strings.NewReader(`{"base": "shared/base.json"}`)); err == nil {
+		t.Errorf("expected error for base palette when not loading from a file")
+	}
+}
+
+func TestPalette_Validate_Adjustments(t *testing.T) {
+	palette := Palette{Entries: make([]PaletteEntry, 16), Ranges: []PaletteRange{{Start: 1, End: 15}}, Adjustments: []PaletteAdjustment{
+		{Start: 1, End: 15, Brightness: 0.9, Gamma: 1.2},
+		{Start: 8, End: 4},
+		{Start: 1, End: 20, Brightness: -1, Gamma: -1},
+	}}
+
+	expected := []string{
+		"adjustment 1 (8-4): start is after end",
+		"adjustment 2 (1-20): colour 20 is outside the palette of 16 colours",
+		"adjustment 2 (1-20): brightness must not be negative",
+		"adjustment 2 (1-20): gamma must not be negative",
+	}
+
+	_, errs := palette.Validate()
+	if len(errs) != len(expected) {
+		t.Fatalf("expected errors %v, got %v", expected, errs)
+	}
+
+	for i, err := range errs {
+		if err.Error() != expected[i] {
+			t.Errorf("expected error %q, got %q", expected[i], err)
+		}
+	}
+}
+
+func writePalette(t *testing.T, filename string, data string) {
+	if err := os.WriteFile(filename, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,47 @@
+package colour
+
+import "math"
+
+// Get a table of the index each palette index is moved to when its brightness is remapped from
+// the range sourceMin-sourceMax onto targetMin-targetMax (all from 0 to 1). Indexes stay within
+// their own palette range, so keep their hue, and take the brightness closest to the target.
+// Indexes outside any range, animated lights and non-renderable colours are not moved.
+func (p Palette) GetBrightnessRemap(sourceMin, sourceMax, targetMin, targetMax float64) *[256]byte {
+	remap := new([256]byte)
+	for i := range remap {
+		remap[i] = byte(i)
+	}
+
+	if sourceMax <= sourceMin {
+		return remap
+	}
+
+	for i := range p.Entries {
+		idx := byte(i)
+		if i >= len(remap) || !p.IsRenderable(idx) || p.IsAnimatedLight(idx) {
+			continue
+		}
+
+		t := Clamp((p.getBrightness(idx)-sourceMin)/(sourceMax-sourceMin), 0, 1)
+		target := targetMin + t*(targetMax-targetMin)
+
+		rng := p.Entries[i].Range
+		best, bestDistance := idx, math.MaxFloat64
+		for j := int(rng.Start); j <= int(rng.End) && j < len(p.Entries); j++ {
+			distance := math.Abs(p.getBrightness(byte(j)) - target)
+			if distance < bestDistance || (distance == bestDistance && math.Abs(float64(j-i)) < math.Abs(float64(int(best)-i))) {
+				best, bestDistance = byte(j), distance
+			}
+		}
+
+		remap[i] = best
+	}
+
+	return remap
+}
+
+// Get the brightness of a palette entry, from 0 to 1
+func (p Palette) getBrightness(index byte) float64 {
+	e := p.Entries[index]
+	return (0.299*float64(e.R) + 0.587*float64(e.G) + 0.114*float64(e.B)) / 255
+}
@@ -0,0 +1,34 @@
+package colour
+
+import (
+	"strings"
+	"testing"
+)
+
+const remapJson = `{"entries": [[0,0,0],[40,0,0],[80,0,0],[160,0,0],[240,0,0],[0,40,0],[0,100,0],[0,200,0],[100,100,100]],
+	"ranges": [{"start": 1, "end": 4}, {"start": 5, "end": 7}, {"start": 8, "end": 8, "is_animated_light": true}]}`
+
+func TestPalette_GetBrightnessRemap(t *testing.T) {
+	palette, err := FromJson(strings.NewReader(remapJson))
+	if err != nil {
+		t.Fatalf("encountered error: %v", err)
+	}
+
+	// Double the brightness of everything, keeping each colour in its own range
+	remap := palette.GetBrightnessRemap(0, 0.5, 0, 1)
+	expected := []byte{0, 2, 3, 4, 4, 6, 7, 7, 8}
+
+	for i, e := range expected {
+		if remap[i] != e {
+			t.Errorf("index %d: expected %d, got %d", i, e, remap[i])
+		}
+	}
+
+	// An empty source range leaves every index where it is
+	remap = palette.GetBrightnessRemap(0.5, 0.5, 0, 1)
+	for i := range expected {
+		if remap[i] != byte(i) {
+			t.Errorf("index %d: expected no change, got %d", i, remap[i])
+		}
+	}
+}
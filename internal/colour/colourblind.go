@@ -0,0 +1,77 @@
+package colour
+
+import (
+	"image/color"
+	"math"
+)
+
+// Matrices simulating complete loss of each type of cone in linear RGB, from Machado,
+// Oliveira and Fernandes (2009), "A Physiologically-based Model for Simulation of Color
+// Vision Deficiency"
+var colourBlindness = map[string][3][3]float64{
+	"protanopia": {
+		{0.152286, 1.052583, -0.204868},
+		{0.114503, 0.786281, 0.099216},
+		{-0.003882, -0.048116, 1.051998},
+	},
+	"deuteranopia": {
+		{0.367322, 0.860646, -0.227968},
+		{0.280085, 0.672501, 0.047413},
+		{-0.011820, 0.042940, 0.968881},
+	},
+	"tritanopia": {
+		{1.255528, -0.076749, -0.178779},
+		{-0.078411, 0.930809, 0.147602},
+		{0.004733, 0.691367, 0.303900},
+	},
+}
+
+// Check if colour blindness of this type can be simulated
+func IsColourBlindness(kind string) bool {
+	_, ok := colourBlindness[kind]
+	return ok
+}
+
+// Get the palette as it would be seen with a type of colour blindness (protanopia,
+// deuteranopia or tritanopia). Unknown types leave the palette unchanged.
+func (p Palette) GetColourBlindPalette(kind string) (pal color.Palette) {
+	pal = p.GetGoPalette()
+
+	m, ok := colourBlindness[kind]
+	if !ok {
+		return
+	}
+
+	for i, e := range p.Entries {
+		rgb := [3]float64{srgbToLinear(e.R), srgbToLinear(e.G), srgbToLinear(e.B)}
+
+		var result [3]byte
+		for c := range result {
+			result[c] = linearToSrgb(m[c][0]*rgb[0] + m[c][1]*rgb[1] + m[c][2]*rgb[2])
+		}
+
+		pal[i] = color.RGBA{R: result[0], G: result[1], B: result[2], A: 255}
+	}
+
+	return
+}
+
+func srgbToLinear(c byte) float64 {
+	v := float64(c) / 255
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func linearToSrgb(v float64) byte {
+	v = math.Max(0, math.Min(1, v))
+	if v <= 0.0031308 {
+		v *= 12.92
+	} else {
+		v = 1.055*math.Pow(v, 1/2.4) - 0.055
+	}
+
+	return byte(math.Round(v * 255))
+}
@@ -0,0 +1,43 @@
+package colour
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestPalette_GetColourBlindPalette(t *testing.T) {
+	p := Palette{Entries: []PaletteEntry{{R: 128, G: 128, B: 128}, {R: 200, G: 40, B: 40}, {R: 40, G: 160, B: 40}}}
+
+	// Unknown types leave the palette unchanged
+	if result := p.GetColourBlindPalette("unknown"); result[1] != (color.RGBA{R: 200, G: 40, B: 40, A: 255}) {
+		t.Errorf("expected unknown type to leave red unchanged, got %v", result[1])
+	}
+
+	for _, kind := range []string{"protanopia", "deuteranopia", "tritanopia"} {
+		result := p.GetColourBlindPalette(kind)
+
+		// Greys are seen the same way
+		if c := result[0].(color.RGBA); absDiff(c.R, 128) > 1 || absDiff(c.G, 128) > 1 || absDiff(c.B, 128) > 1 {
+			t.Errorf("%s: expected grey to stay grey, got %v", kind, c)
+		}
+	}
+
+	// Without red or green cones, reds and greens are only seen as shades of yellow, which
+	// have equal red and green
+	for _, kind := range []string{"protanopia", "deuteranopia"} {
+		result := p.GetColourBlindPalette(kind)
+		for _, c := range []color.RGBA{result[1].(color.RGBA), result[2].(color.RGBA)} {
+			if absDiff(c.R, c.G) > 20 {
+				t.Errorf("%s: expected red and green to be seen as yellow, got %v", kind, c)
+			}
+		}
+	}
+}
+
+func absDiff(a, b byte) int {
+	if a > b {
+		return int(a - b)
+	}
+
+	return int(b - a)
+}
@@ -14,16 +14,19 @@ type PaletteEntry struct {
 }
 
 type PaletteRange struct {
-	Start                    byte `json:"start"`
-	End                      byte `json:"end"`
-	IsPrimaryCompanyColour   bool `json:"is_primary_company_colour"`
-	IsSecondaryCompanyColour bool `json:"is_secondary_company_colour"`
-	IsAnimatedLight          bool `json:"is_animated_light"`
-	IsProcessColour          bool `json:"is_process_colour"`
-	Smoothness               int  `json:"smoothness"`
-	IsNonRenderable          bool `json:"non_renderable"`
-	MaxGapInRegion           int  `json:"max_gap_in_region"`
-	ExpectedColourRange      byte `json:"expected_colour_range"`
+	Start                    byte    `json:"start"`
+	End                      byte    `json:"end"`
+	IsPrimaryCompanyColour   bool    `json:"is_primary_company_colour"`
+	IsSecondaryCompanyColour bool    `json:"is_secondary_company_colour"`
+	IsAnimatedLight          bool    `json:"is_animated_light"`
+	IsProcessColour          bool    `json:"is_process_colour"`
+	Smoothness               int     `json:"smoothness"`
+	IsNonRenderable          bool    `json:"non_renderable"`
+	MaxGapInRegion           int     `json:"max_gap_in_region"`
+	ExpectedColourRange      byte    `json:"expected_colour_range"`
+	Transparency             float64 `json:"transparency"`
+	Class                    string  `json:"class"`
+	Priority                 int     `json:"priority"`
 }
 
 type Palette struct {
@@ -32,6 +35,10 @@ type Palette struct {
 	CompanyColourLightingContribution float64        `json:"company_colour_lighting_contribution"`
 	DefaultBrightness                 float64        `json:"default_brightness"`
 	CompanyColourLightingScale        float64        `json:"company_colour_lighting_scale"`
+
+	// A palette to take entries, ranges and settings from, relative to this palette's file
+	Base        string              `json:"base,omitempty"`
+	Adjustments []PaletteAdjustment `json:"adjustments,omitempty"`
 }
 
 func (pe *PaletteEntry) GetRGB() (output RGB) {
@@ -120,6 +127,16 @@ func (p Palette) GetSmoothness(index byte) (smoothness int) {
 	return
 }
 
+// Get the priority of an index's range, which decides the modal index of a pixel when
+// indexes from different ranges are sampled equally
+func (p Palette) GetPriority(index byte) (priority int) {
+	if int(index) < len(p.Entries) && p.Entries[index].Range != nil {
+		priority = p.Entries[index].Range.Priority
+	}
+
+	return
+}
+
 func (p Palette) GetMaskColour(index byte) (msk byte) {
 	if int(index) < len(p.Entries) {
 		entry := p.Entries[index]
@@ -149,6 +166,42 @@ func (p Palette) IsSpecialColour(index byte) bool {
 	return false
 }
 
+func (p Palette) IsCompanyColour(index byte) bool {
+	if int(index) < len(p.Entries) && p.Entries[index].Range != nil {
+		return p.Entries[index].Range.IsPrimaryCompanyColour || p.Entries[index].Range.IsSecondaryCompanyColour
+	}
+
+	return false
+}
+
+func (p Palette) IsAnimatedLight(index byte) bool {
+	if int(index) < len(p.Entries) && p.Entries[index].Range != nil {
+		return p.Entries[index].Range.IsAnimatedLight
+	}
+
+	return false
+}
+
+// Get how transparent voxels of this colour are, from 0 (opaque) to 1 (invisible)
+func (p Palette) GetTransparency(index byte) float64 {
+	if int(index) < len(p.Entries) && p.Entries[index].Range != nil {
+		return p.Entries[index].Range.Transparency
+	}
+
+	return 0
+}
+
+// Whether any colour of the palette is transparent
+func (p Palette) HasTransparency() bool {
+	for _, r := range p.Ranges {
+		if r.Transparency > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (p Palette) GetRGB(index byte, resolveSpecialColours bool) (output RGB) {
 	if int(index) < len(p.Entries) {
 		entry := p.Entries[index]
@@ -205,6 +258,16 @@ func (p Palette) GetLitIndexed(index byte, l float64) (idx byte) {
 }
 
 func (p Palette) GetLitRGB(index byte, l float64, brightness float64, contrast float64, resolveSpecialColours bool, influence float64) (output RGB) {
+	return p.getLitRGB(index, l, brightness, contrast, resolveSpecialColours, influence, true)
+}
+
+// Get the lit colour of an index without clipping lighting above 1 to white, for tone mapping.
+// Brighter lighting carries on past white at the rate it approached it.
+func (p Palette) GetLinearLitRGB(index byte, l float64, brightness float64, contrast float64, resolveSpecialColours bool, influence float64) (output RGB) {
+	return p.getLitRGB(index, l, brightness, contrast, resolveSpecialColours, influence, false)
+}
+
+func (p Palette) getLitRGB(index byte, l float64, brightness float64, contrast float64, resolveSpecialColours bool, influence float64, clipToWhite bool) (output RGB) {
 	output = p.GetRGB(index, resolveSpecialColours)
 
 	entry := p.Entries[index]
@@ -217,7 +280,7 @@ func (p Palette) GetLitRGB(index byte, l float64, brightness float64, contrast f
 	}
 
 	// Clamp to [-1,1]
-	if l > 1 {
+	if l > 1 && clipToWhite {
 		l = 1
 	} else if l < -1 {
 		l = -1
@@ -268,11 +331,7 @@ func FromJson(handle io.Reader) (p Palette, err error) {
 		return Palette{}, err
 	}
 
-	if err := p.SetRanges(p.Ranges); err != nil {
-		return Palette{}, err
-	}
-
-	return
+	return p.finishLoading()
 }
 
 func (p *Palette) SetRanges(ranges []PaletteRange) (err error) {
@@ -290,6 +349,10 @@ func (p *Palette) SetRanges(ranges []PaletteRange) (err error) {
 			ranges[i].ExpectedColourRange = 3
 		}
 
+		if int(r.End) >= len(p.Entries) {
+			return fmt.Errorf("range %d ends at colour %d, outside the palette", i, r.End)
+		}
+
 		for j := int(r.Start); j <= int(r.End); j++ {
 			if p.Entries[j].Range != nil {
 				return fmt.Errorf("range %d overlaps colour %d", i, j)
@@ -2,6 +2,7 @@ package colour
 
 import (
 	"image/color"
+	"math"
 	"strings"
 	"testing"
 )
@@ -82,6 +83,27 @@ func TestPalette_GetLitRGB(t *testing.T) {
 	}
 }
 
+func TestPalette_GetLinearLitRGB(t *testing.T) {
+	palette := Palette{Entries: []PaletteEntry{{R: 255, G: 0, B: 0}}}
+
+	testCases := []struct {
+		lighting float64
+		expected RGB
+	}{
+		{0.5, RGB{R: 65535, G: 32767.5, B: 32767.5}},
+		{1, RGB{R: 65535, G: 65535, B: 65535}},
+		{1.5, RGB{R: 65535, G: 98302.5, B: 98302.5}},
+		{-1.5, RGB{}},
+	}
+
+	for _, testCase := range testCases {
+		rgb := palette.GetLinearLitRGB(0, testCase.lighting, 0.0, 1.0, false, 1.0)
+		if math.Abs(rgb.R-testCase.expected.R) > 1 || math.Abs(rgb.G-testCase.expected.G) > 1 || math.Abs(rgb.B-testCase.expected.B) > 1 {
+			t.Errorf("lighting %f returned %v, expected %v", testCase.lighting, rgb, testCase.expected)
+		}
+	}
+}
+
 func TestPalette_GetMaskColour(t *testing.T) {
 	palette, _ := FromJson(strings.NewReader(exampleJson))
 	palette.SetRanges([]PaletteRange{{Start: 2, End: 2, IsPrimaryCompanyColour: true}})
@@ -100,7 +122,7 @@ func TestPalette_GetFromReader_DetectsDuplicateRanges(t *testing.T) {
 	const json = "{\"entries\": [[0,0,0],[255,255,255],[255,127,0]], \"ranges\": [{\"start\": 0, \"end\": 1},{\"start\": 1, \"end\": 2}]}"
 	_, err := FromJson(strings.NewReader(json))
 
-	if err == nil || err.Error() != "range 1 overlaps colour 1" {
+	if err == nil || err.Error() != "range 1 (1-2) overlaps range 0 (0-1) at colour 1" {
 		t.Errorf("encountered unexpected error: %v", err)
 	}
 }
@@ -144,3 +166,16 @@ func TestPalette_GetLitIndexed(t *testing.T) {
 	}
 
 }
+
+func TestPalette_GetTransparency(t *testing.T) {
+	palette, _ := FromJson(strings.NewReader(exampleJson))
+	palette.SetRanges([]PaletteRange{{Start: 1, End: 1}, {Start: 2, End: 2, Transparency: 0.5}})
+
+	expected := []float64{0, 0, 0.5, 0}
+
+	for i, e := range expected {
+		if result := palette.GetTransparency(byte(i)); result != e {
+			t.Errorf("transparency at %d expected %f, got %f", i, e, result)
+		}
+	}
+}
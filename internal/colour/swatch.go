@@ -0,0 +1,150 @@
+package colour
+
+import (
+	"fmt"
+	"github.com/mattkimber/gorender/internal/utils/imageutils"
+	"image"
+	"image/color"
+	"strings"
+)
+
+const (
+	swatchWidth, swatchHeight = 32, 28
+	swatchColourHeight        = 20
+	swatchColumns             = 16
+	legendWidth               = 200
+)
+
+var (
+	swatchBackground = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	swatchText       = color.RGBA{A: 255}
+	swatchExcluded   = color.RGBA{R: 255, A: 255}
+	swatchGroups     = []color.RGBA{{R: 224, G: 224, B: 224, A: 255}, {R: 192, G: 192, B: 192, A: 255}}
+)
+
+// Codes shown under each swatch for the properties of its range
+var rangeCodes = []struct {
+	code, description string
+	isSet             func(r *PaletteRange) bool
+}{
+	{"C1", "primary company colour", func(r *PaletteRange) bool { return r.IsPrimaryCompanyColour }},
+	{"C2", "secondary company colour", func(r *PaletteRange) bool { return r.IsSecondaryCompanyColour }},
+	{"A", "animated", func(r *PaletteRange) bool { return r.IsAnimatedLight }},
+	{"P", "process colour", func(r *PaletteRange) bool { return r.IsProcessColour }},
+	{"N", "non-renderable", func(r *PaletteRange) bool { return r.IsNonRenderable }},
+	{"T", "transparent", func(r *PaletteRange) bool { return r.Transparency > 0 }},
+}
+
+// Get an image of the palette for checking its definition. Colours are shown as a grid
+// of swatches labelled with their index and the properties of their range, with a line
+// between swatches in different ranges and a red cross on colours outside any range.
+// A legend lists the ranges.
+func (p Palette) GetSwatchImage() *image.RGBA {
+	rows := (len(p.Entries) + swatchColumns - 1) / swatchColumns
+
+	legend := p.getLegend()
+	gridWidth := swatchColumns * swatchWidth
+	height := max(rows*swatchHeight, (len(legend)+2)*imageutils.CharHeight)
+
+	img := imageutils.GetUniformImage(image.Rect(0, 0, gridWidth+legendWidth, height), swatchBackground)
+
+	group := 0
+	for i, e := range p.Entries {
+		if i > 0 && e.Range != p.Entries[i-1].Range {
+			group = 1 - group
+		}
+
+		x, y := (i%swatchColumns)*swatchWidth, (i/swatchColumns)*swatchHeight
+		p.drawSwatch(img, i, x, y, swatchGroups[group])
+
+		// Mark the start of each range which doesn't start a row
+		if i > 0 && x > 0 && e.Range != p.Entries[i-1].Range {
+			fill(img, image.Rect(x, y, x+2, y+swatchHeight), swatchText)
+		}
+	}
+
+	for i, line := range legend {
+		imageutils.DrawText(img, gridWidth+imageutils.CharWidth, (i+1)*imageutils.CharHeight, line, swatchText)
+	}
+
+	return img
+}
+
+func (p Palette) drawSwatch(img *image.RGBA, index, x, y int, group color.RGBA) {
+	e := p.Entries[index]
+	c := color.RGBA{R: e.R, G: e.G, B: e.B, A: 255}
+
+	fill(img, image.Rect(x, y, x+swatchWidth, y+swatchColourHeight), c)
+	fill(img, image.Rect(x, y+swatchColourHeight, x+swatchWidth, y+swatchHeight), group)
+
+	// Label dark colours in white
+	label := swatchText
+	if 299*int(e.R)+587*int(e.G)+114*int(e.B) < 128000 {
+		label = swatchBackground
+	}
+
+	imageutils.DrawText(img, x+3, y+3, fmt.Sprint(index), label)
+
+	if e.Range == nil {
+		for i := 0; i < swatchColourHeight; i++ {
+			dx := i * swatchWidth / swatchColourHeight
+			img.Set(x+dx, y+i, swatchExcluded)
+			img.Set(x+swatchWidth-1-dx, y+i, swatchExcluded)
+		}
+		return
+	}
+
+	imageutils.DrawText(img, x+3, y+swatchColourHeight+1, getRangeCodes(e.Range), swatchText)
+}
+
+// Get the lines of the legend: a key to the codes, followed by each range
+func (p Palette) getLegend() (lines []string) {
+	for _, rc := range rangeCodes {
+		lines = append(lines, fmt.Sprintf("%-2s %s", rc.code, rc.description))
+	}
+
+	lines = append(lines, "X  not in a range", "", "ranges:")
+
+	for i := range p.Ranges {
+		r := &p.Ranges[i]
+		parts := []string{fmt.Sprintf("%3d-%3d", r.Start, r.End)}
+		if codes := getRangeCodes(r); codes != "" {
+			parts = append(parts, codes)
+		}
+
+		if r.Smoothness != 0 {
+			parts = append(parts, fmt.Sprintf("smooth %d", r.Smoothness))
+		}
+
+		if r.Priority != 0 {
+			parts = append(parts, fmt.Sprintf("priority %d", r.Priority))
+		}
+
+		if r.Class != "" {
+			parts = append(parts, r.Class)
+		}
+
+		lines = append(lines, strings.Join(parts, " "))
+	}
+
+	return
+}
+
+func getRangeCodes(r *PaletteRange) string {
+	codes := make([]string, 0)
+	for _, rc := range rangeCodes {
+		if rc.isSet(r) {
+			codes = append(codes, rc.code)
+		}
+	}
+
+	return strings.Join(codes, " ")
+}
+
+func fill(img *image.RGBA, rect image.Rectangle, c color.RGBA) {
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package colour
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestPalette_GetSwatchImage(t *testing.T) {
+	palette, _ := FromJson(strings.NewReader(exampleJson))
+	palette.SetRanges([]PaletteRange{{Start: 1, End: 2, IsPrimaryCompanyColour: true}})
+
+	img := palette.GetSwatchImage()
+
+	// One row of swatches, with the legend taller than the row
+	expectedRect := image.Rect(0, 0, swatchColumns*swatchWidth+legendWidth, 12*6)
+	if img.Bounds() != expectedRect {
+		t.Errorf("expected size %v, got %v", expectedRect, img.Bounds())
+	}
+
+	// Swatch 2 is orange, and colour 0 is outside any range so is crossed out
+	if c := img.RGBAAt(2*swatchWidth+swatchWidth/2, swatchColourHeight-2); c != (color.RGBA{R: 255, G: 127, A: 255}) {
+		t.Errorf("expected swatch 2 to be orange, got %v", c)
+	}
+
+	if c := img.RGBAAt(0, 0); c != swatchExcluded {
+		t.Errorf("expected colour 0 to be crossed out, got %v", c)
+	}
+
+	// Swatch 1 starts a range, so is separated from swatch 0
+	if c := img.RGBAAt(swatchWidth, swatchHeight-1); c != swatchText {
+		t.Errorf("expected a line between ranges, got %v", c)
+	}
+}
+
+func TestPalette_getLegend(t *testing.T) {
+	palette := Palette{Ranges: []PaletteRange{{Start: 1, End: 15, Smoothness: -1}, {Start: 80, End: 87, IsSecondaryCompanyColour: true, IsAnimatedLight: true, Priority: 2}}}
+	legend := palette.getLegend()
+
+	expected := []string{"  1- 15 smooth -1", " 80- 87 C2 A priority 2"}
+	for i, line := range expected {
+		if result := legend[len(legend)-len(expected)+i]; result != line {
+			t.Errorf("range %d expected %q, got %q", i, line, result)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package colour
+
+import "math"
+
+// Map a lit colour, which may be brighter than white, into the displayable range. Exposure
+// is in stops, so each +1 doubles the brightness before mapping. Colours are clipped to
+// white when no operator is set, otherwise the operator compresses highlights so bright
+// surfaces keep some of their detail:
+//   - "reinhard": x / (1 + x), which is gentle but darkens mid-tones
+//   - "filmic": John Hable's Uncharted 2 curve, with a white point of 11.2
+//   - "aces": Krzysztof Narkowicz's fit of the ACES filmic curve
+func ToneMap(c RGB, operator string, exposure float64) RGB {
+	scale := math.Exp2(exposure) / 65535
+
+	return RGB{
+		R: toneMapChannel(c.R*scale, operator) * 65535,
+		G: toneMapChannel(c.G*scale, operator) * 65535,
+		B: toneMapChannel(c.B*scale, operator) * 65535,
+	}
+}
+
+func toneMapChannel(x float64, operator string) float64 {
+	x = math.Max(x, 0)
+
+	switch operator {
+	case "reinhard":
+		return x / (1 + x)
+	case "filmic":
+		const exposureBias, whitePoint = 2.0, 11.2
+		return math.Min(hable(x*exposureBias)/hable(whitePoint), 1)
+	case "aces":
+		return Clamp((x*(2.51*x+0.03))/(x*(2.43*x+0.59)+0.14), 0, 1)
+	}
+
+	return math.Min(x, 1)
+}
+
+func hable(x float64) float64 {
+	const a, b, c, d, e, f = 0.15, 0.50, 0.10, 0.20, 0.02, 0.30
+	return ((x*(a*x+c*b) + d*e) / (x*(a*x+b) + d*f)) - e/f
+}
@@ -0,0 +1,42 @@
+package colour
+
+import (
+	"math"
+	"testing"
+)
+
+func TestToneMap(t *testing.T) {
+	testCases := []struct {
+		operator string
+		exposure float64
+		input    float64
+		expected float64
+	}{
+		{"", 0, 32767.5, 32767.5},
+		{"", 0, 131070, 65535},
+		{"", 1, 32767.5, 65535},
+		{"", 0, -100, 0},
+		{"reinhard", 0, 65535, 32767.5},
+		{"reinhard", -1, 131070, 32767.5},
+		{"aces", 0, 0, 0},
+		{"aces", 0, 65535 * 1000, 65535},
+		{"filmic", 0, 0, 0},
+		{"filmic", 0, 65535 * 5.6, 65535},
+	}
+
+	for _, testCase := range testCases {
+		result := ToneMap(RGB{R: testCase.input, G: testCase.input, B: testCase.input}, testCase.operator, testCase.exposure)
+		if math.Abs(result.R-testCase.expected) > 1 || result.R != result.G || result.G != result.B {
+			t.Errorf("%s tone mapping of %f at exposure %f expected %f, got %v", testCase.operator, testCase.input, testCase.exposure, testCase.expected, result)
+		}
+	}
+}
+
+func TestToneMap_PreservesHighlights(t *testing.T) {
+	for _, operator := range []string{"reinhard", "filmic", "aces"} {
+		bright, brighter := ToneMap(RGB{R: 65535}, operator, 0), ToneMap(RGB{R: 131070}, operator, 0)
+		if bright.R >= brighter.R || brighter.R >= 65535 {
+			t.Errorf("%s tone mapping expected to separate highlights, got %f and %f", operator, bright.R, brighter.R)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package colour
+
+import (
+	"fmt"
+	"math"
+)
+
+// Check a palette definition for ranges which are out of order, outside the palette,
+// overlapping or have contradictory properties. All problems found are returned rather
+// than stopping at the first. Colours other than 0 (transparent) which aren't in any
+// range are returned as warnings, as objects can't use them.
+func (p Palette) Validate() (warnings []string, errs []error) {
+	if len(p.Entries) == 0 {
+		errs = append(errs, fmt.Errorf("no entries"))
+	} else if len(p.Entries) > 256 {
+		errs = append(errs, fmt.Errorf("%d entries, but at most 256 colours can be used", len(p.Entries)))
+	}
+
+	owners := make([]int, len(p.Entries))
+	for i := range owners {
+		owners[i] = -1
+	}
+
+	for i, r := range p.Ranges {
+		name := fmt.Sprintf("range %d (%d-%d)", i, r.Start, r.End)
+
+		if r.Start > r.End {
+			errs = append(errs, fmt.Errorf("%s: start is after end", name))
+			continue
+		}
+
+		if int(r.End) >= len(p.Entries) {
+			errs = append(errs, fmt.Errorf("%s: colour %d is outside the palette of %d colours", name, r.End, len(p.Entries)))
+			continue
+		}
+
+		errs = append(errs, getOverlaps(p.Ranges, owners, i)...)
+
+		for _, err := range getContradictions(r) {
+			errs = append(errs, fmt.Errorf("%s: %v", name, err))
+		}
+	}
+
+	errs = append(errs, p.validateAdjustments()...)
+
+	for start := 1; start < len(owners); start++ {
+		if owners[start] != -1 {
+			continue
+		}
+
+		end := start
+		for end+1 < len(owners) && owners[end+1] == -1 {
+			end++
+		}
+
+		if start == end {
+			warnings = append(warnings, fmt.Sprintf("colour %d is not in any range", start))
+		} else {
+			warnings = append(warnings, fmt.Sprintf("colours %d-%d are not in any range", start, end))
+		}
+
+		start = end
+	}
+
+	return
+}
+
+// Mark the colours of range i as belonging to it, returning an error for each earlier
+// range it overlaps
+func getOverlaps(ranges []PaletteRange, owners []int, i int) (errs []error) {
+	r := ranges[i]
+	reported := make(map[int]bool)
+
+	for j := int(r.Start); j <= int(r.End); j++ {
+		if owner := owners[j]; owner != -1 && !reported[owner] {
+			other := ranges[owner]
+			start, end := max(r.Start, other.Start), min(r.End, other.End)
+			colours := fmt.Sprintf("colours %d-%d", start, end)
+			if start == end {
+				colours = fmt.Sprintf("colour %d", start)
+			}
+
+			errs = append(errs, fmt.Errorf("range %d (%d-%d) overlaps range %d (%d-%d) at %s",
+				i, r.Start, r.End, owner, other.Start, other.End, colours))
+			reported[owner] = true
+		} else if owner == -1 {
+			owners[j] = i
+		}
+	}
+
+	return
+}
+
+// Get the properties of a range which can't be used together
+func getContradictions(r PaletteRange) (errs []error) {
+	isCompanyColour := r.IsPrimaryCompanyColour || r.IsSecondaryCompanyColour
+
+	if r.IsPrimaryCompanyColour && r.IsSecondaryCompanyColour {
+		errs = append(errs, fmt.Errorf("can't be both primary and secondary company colour"))
+	}
+
+	if isCompanyColour && r.IsAnimatedLight {
+		errs = append(errs, fmt.Errorf("can't be both company colour and animated"))
+	}
+
+	if r.IsNonRenderable && (isCompanyColour || r.IsAnimatedLight || r.IsProcessColour) {
+		errs = append(errs, fmt.Errorf("is non-renderable, so can't be a company colour, animated or a process colour"))
+	}
+
+	if r.Transparency < 0 || r.Transparency > 1 || math.IsNaN(r.Transparency) {
+		errs = append(errs, fmt.Errorf("transparency must be between 0 and 1"))
+	}
+
+	return
+}
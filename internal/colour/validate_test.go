@@ -0,0 +1,54 @@
+package colour
+
+import (
+	"testing"
+)
+
+func TestPalette_Validate(t *testing.T) {
+	entries := make([]PaletteEntry, 16)
+
+	testCases := []struct {
+		name     string
+		ranges   []PaletteRange
+		warnings []string
+		errs     []string
+	}{
+		{"valid", []PaletteRange{{Start: 1, End: 7}, {Start: 8, End: 15, IsPrimaryCompanyColour: true}}, nil, nil},
+		{"gaps", []PaletteRange{{Start: 2, End: 7}, {Start: 10, End: 14}}, []string{"colour 1 is not in any range", "colours 8-9 are not in any range", "colour 15 is not in any range"}, nil},
+		{"mis-ordered", []PaletteRange{{Start: 1, End: 15}, {Start: 9, End: 4}}, nil, []string{"range 1 (9-4): start is after end"}},
+		{"out of bounds", []PaletteRange{{Start: 1, End: 20}}, []string{"colours 1-15 are not in any range"}, []string{"range 0 (1-20): colour 20 is outside the palette of 16 colours"}},
+		{"overlap", []PaletteRange{{Start: 1, End: 10}, {Start: 8, End: 15}, {Start: 5, End: 5}}, nil, []string{
+			"range 1 (8-15) overlaps range 0 (1-10) at colours 8-10",
+			"range 2 (5-5) overlaps range 0 (1-10) at colour 5",
+		}},
+		{"contradictory", []PaletteRange{{Start: 1, End: 15, IsPrimaryCompanyColour: true, IsSecondaryCompanyColour: true, IsNonRenderable: true, Transparency: 2}}, nil, []string{
+			"range 0 (1-15): can't be both primary and secondary company colour",
+			"range 0 (1-15): is non-renderable, so can't be a company colour, animated or a process colour",
+			"range 0 (1-15): transparency must be between 0 and 1",
+		}},
+	}
+
+	for _, testCase := range testCases {
+		warnings, errs := Palette{Entries: entries, Ranges: testCase.ranges}.Validate()
+
+		if len(warnings) != len(testCase.warnings) {
+			t.Errorf("%s: expected warnings %v, got %v", testCase.name, testCase.warnings, warnings)
+		} else {
+			for i, w := range warnings {
+				if w != testCase.warnings[i] {
+					t.Errorf("%s: expected warning %q, got %q", testCase.name, testCase.warnings[i], w)
+				}
+			}
+		}
+
+		if len(errs) != len(testCase.errs) {
+			t.Errorf("%s: expected errors %v, got %v", testCase.name, testCase.errs, errs)
+		} else {
+			for i, err := range errs {
+				if err.Error() != testCase.errs[i] {
+					t.Errorf("%s: expected error %q, got %q", testCase.name, testCase.errs[i], err)
+				}
+			}
+		}
+	}
+}
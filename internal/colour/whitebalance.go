@@ -0,0 +1,48 @@
+package colour
+
+import "math"
+
+// Colour temperature in Kelvin which gives no change in white balance
+const NeutralTemperature = 6500
+
+// Get the amount to multiply each channel by to light a scene with light of the given colour
+// temperature in Kelvin, with lower temperatures warmer (more orange) and higher ones cooler
+// (more blue). Tint shifts the balance towards magenta (positive) or green (negative). The
+// multipliers are scaled to keep the overall brightness of grey the same.
+func GetWhiteBalance(temperature, tint float64) RGB {
+	if temperature == 0 {
+		temperature = NeutralTemperature
+	}
+
+	c, neutral := getBlackBodyColour(temperature), getBlackBodyColour(NeutralTemperature)
+	wb := RGB{R: c.R / neutral.R, G: c.G / neutral.G * (1 - tint/2), B: c.B / neutral.B}
+
+	luminance := 0.2126*wb.R + 0.7152*wb.G + 0.0722*wb.B
+	return wb.MultiplyBy(1 / luminance)
+}
+
+// Approximate the colour of a black body at the given temperature, in the range 0-255.
+// This uses Tanner Helland's fit, which is accurate enough for lighting between 1000K
+// and 40000K.
+func getBlackBodyColour(temperature float64) (c RGB) {
+	t := temperature / 100
+
+	if t <= 66 {
+		c.R = 255
+		c.G = 99.4708025861*math.Log(t) - 161.1195681661
+	} else {
+		c.R = 329.698727446 * math.Pow(t-60, -0.1332047592)
+		c.G = 288.1221695283 * math.Pow(t-60, -0.0755148492)
+	}
+
+	if t >= 66 {
+		c.B = 255
+	} else if t <= 19 {
+		c.B = 0
+	} else {
+		c.B = 138.5177312231*math.Log(t-10) - 305.0447927307
+	}
+
+	// Avoid a zero channel, which would make the balance impossible to normalise
+	return RGB{R: Clamp(c.R, 1, 255), G: Clamp(c.G, 1, 255), B: Clamp(c.B, 1, 255)}
+}
@@ -0,0 +1,38 @@
+package colour
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGetWhiteBalance(t *testing.T) {
+	if wb := GetWhiteBalance(0, 0); math.Abs(wb.R-1) > 1e-9 || math.Abs(wb.G-1) > 1e-9 || math.Abs(wb.B-1) > 1e-9 {
+		t.Errorf("Neutral white balance expected no change, got %v", wb)
+	}
+
+	testCases := []struct {
+		temperature, tint float64
+		isWarm, isGreen   bool
+	}{
+		{3000, 0, true, false},
+		{10000, 0, false, false},
+		{6500, 0.5, false, false},
+		{6500, -0.5, false, true},
+	}
+
+	for _, testCase := range testCases {
+		wb := GetWhiteBalance(testCase.temperature, testCase.tint)
+
+		if luminance := 0.2126*wb.R + 0.7152*wb.G + 0.0722*wb.B; math.Abs(luminance-1) > 1e-9 {
+			t.Errorf("%fK tint %f expected luminance 1, got %f", testCase.temperature, testCase.tint, luminance)
+		}
+
+		if isWarm := wb.R > wb.B; testCase.temperature != 6500 && isWarm != testCase.isWarm {
+			t.Errorf("%fK expected warm %v, got %v", testCase.temperature, testCase.isWarm, wb)
+		}
+
+		if isGreen := wb.G > wb.R; testCase.tint != 0 && isGreen != testCase.isGreen {
+			t.Errorf("Tint %f expected green %v, got %v", testCase.tint, testCase.isGreen, wb)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package manifest
+
+// Settings for sizing sprites to fit the object. The canvas of each sprite is set to the
+// box containing its voxels when projected, plus a margin, and the offsets move the object
+// to the middle of the canvas. Sprites which set their own canvas size are left alone.
+type AutoSize struct {
+	// Unset is the default, rather than 0, as no margin at all is a valid choice
+	Margin *int `json:"margin"`
+}
+
+// The number of empty pixels at 1x scale left around the object on each side
+func (a AutoSize) GetMargin() int {
+	if a.Margin == nil {
+		return 1
+	}
+
+	return *a.Margin
+}
+
+// Check if a sprite is sized to fit the object. Tiles always fill their canvas, so are
+// never sized this way.
+func (m *Manifest) IsAutoSized(spr Sprite) bool {
+	return m.AutoSize != nil && spr.CanvasWidth == 0 && spr.CanvasHeight == 0 && spr.Type != "tile"
+}
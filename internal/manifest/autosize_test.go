@@ -0,0 +1,39 @@
+package manifest
+
+import "testing"
+
+func TestManifest_IsAutoSized(t *testing.T) {
+	testCases := []struct {
+		autoSize *AutoSize
+		sprite   Sprite
+		expected bool
+	}{
+		{nil, Sprite{Width: 32}, false},
+		{&AutoSize{}, Sprite{Width: 32}, true},
+		{&AutoSize{}, Sprite{Width: 32, CanvasWidth: 40}, false},
+		{&AutoSize{}, Sprite{Width: 32, CanvasHeight: 40}, false},
+		{&AutoSize{}, Sprite{Width: 32, Type: "tile"}, false},
+	}
+
+	for _, testCase := range testCases {
+		m := Manifest{AutoSize: testCase.autoSize}
+		if result := m.IsAutoSized(testCase.sprite); result != testCase.expected {
+			t.Errorf("%+v with auto size %v: expected %v, got %v", testCase.sprite, testCase.autoSize, testCase.expected, result)
+		}
+	}
+
+	zero := 0
+	marginCases := []struct {
+		autoSize AutoSize
+		expected int
+	}{
+		{AutoSize{}, 1},
+		{AutoSize{Margin: &zero}, 0},
+	}
+
+	for _, testCase := range marginCases {
+		if margin := testCase.autoSize.GetMargin(); margin != testCase.expected {
+			t.Errorf("expected margin %d, got %d", testCase.expected, margin)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package manifest
+
+import (
+	"fmt"
+	"math"
+)
+
+type cameraPreset struct {
+	Elevation int
+
+	// The elevation of sprites looking along the object's length (0 and 180 degrees), if
+	// different from the other sprites
+	EndOnElevation int
+}
+
+// Presets set the camera elevation for the projection used by a game. The renderer's
+// elevation sets the vertical component of the view direction, so 30 gives a 2:1
+// projection and 45 a true isometric one. The viewport is always upright, so 90 is the
+// steepest view possible, looking down at 45 degrees.
+var cameraPresets = map[string]cameraPreset{
+	"openttd":   {Elevation: 30, EndOnElevation: 33},
+	"simutrans": {Elevation: 30},
+	"dimetric":  {Elevation: 30},
+	"isometric": {Elevation: 45},
+	"top_down":  {Elevation: 90},
+}
+
+func (m *Manifest) applyCameraPreset(name string) error {
+	if name == "" {
+		return nil
+	}
+
+	preset, ok := cameraPresets[name]
+	if !ok {
+		return fmt.Errorf("unknown camera preset %s", name)
+	}
+
+	m.RenderElevationAngle = preset.Elevation
+	return nil
+}
+
+// Get the elevation a sprite is rendered at if it doesn't set its own
+func (m *Manifest) getDefaultSpriteElevation(spr Sprite) int {
+	if preset := cameraPresets[m.Camera]; preset.EndOnElevation != 0 && math.Mod(spr.Angle, 180) == 0 {
+		return preset.EndOnElevation
+	}
+
+	return m.RenderElevationAngle
+}
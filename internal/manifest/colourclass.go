@@ -0,0 +1,43 @@
+package manifest
+
+// A colour class sets how the shader treats colours in palette ranges which declare
+// the class, in addition to any company colour or animated behaviour of the range.
+type ColourClass struct {
+	// Ignore scene lighting, so colours are drawn at their own brightness (e.g. glowing lights)
+	Unlit bool `json:"unlit"`
+	// Offset to the lighting of the colour, from -1 (black) to 1 (white)
+	Brightness float64 `json:"brightness"`
+	// Output the sampled index without dithering, fosterising or diffusing error to
+	// neighbouring pixels, as is done for animated colours
+	KeepIndex bool `json:"keep_index"`
+	// Include pixels of the class in the mask output
+	Mask bool `json:"mask"`
+	// How glossy the colour is in the gloss map, from 0 (matt) to 1 (mirror-like)
+	Gloss float64 `json:"gloss"`
+}
+
+// Get the colour class of a palette index, if its range declares a class the manifest
+// configures
+func (d *Definition) GetColourClass(index byte) (class ColourClass, ok bool) {
+	if len(d.Manifest.ColourClasses) == 0 || int(index) >= len(d.Palette.Entries) {
+		return
+	}
+
+	rng := d.Palette.Entries[index].Range
+	if rng == nil || rng.Class == "" {
+		return
+	}
+
+	class, ok = d.Manifest.ColourClasses[rng.Class]
+	return
+}
+
+// Get the lighting offset to use for a colour of the class, given the offset from
+// scene lighting
+func (c ColourClass) GetLighting(lighting float64) float64 {
+	if c.Unlit {
+		return c.Brightness
+	}
+
+	return lighting + c.Brightness
+}
@@ -0,0 +1,48 @@
+package manifest
+
+import (
+	"github.com/mattkimber/gorender/internal/colour"
+	"testing"
+)
+
+func TestDefinition_GetColourClass(t *testing.T) {
+	palette := colour.Palette{Entries: make([]colour.PaletteEntry, 4)}
+	palette.SetRanges([]colour.PaletteRange{{Start: 1, End: 1, Class: "glow"}, {Start: 2, End: 2, Class: "reflective"}})
+	def := Definition{Palette: palette, Manifest: Manifest{ColourClasses: map[string]ColourClass{"glow": {Unlit: true}}}}
+
+	testCases := []struct {
+		index      byte
+		expectedOk bool
+	}{
+		{0, false},
+		{1, true},
+		{2, false},
+		{3, false},
+		{200, false},
+	}
+
+	for _, testCase := range testCases {
+		if class, ok := def.GetColourClass(testCase.index); ok != testCase.expectedOk || class.Unlit != ok {
+			t.Errorf("Index %d expected class %v, got %v %v", testCase.index, testCase.expectedOk, ok, class)
+		}
+	}
+}
+
+func TestColourClass_GetLighting(t *testing.T) {
+	testCases := []struct {
+		class    ColourClass
+		lighting float64
+		expected float64
+	}{
+		{ColourClass{}, -0.25, -0.25},
+		{ColourClass{Brightness: 0.5}, -0.25, 0.25},
+		{ColourClass{Unlit: true}, -0.25, 0},
+		{ColourClass{Unlit: true, Brightness: 0.5}, -0.25, 0.5},
+	}
+
+	for _, testCase := range testCases {
+		if result := testCase.class.GetLighting(testCase.lighting); result != testCase.expected {
+			t.Errorf("Lighting %f for %v expected %f, got %f", testCase.lighting, testCase.class, testCase.expected, result)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+package manifest
+
+import "slices"
+
+// Check if detail_boost applies to a voxel with this palette index at this location. It
+// applies everywhere unless limited to palette ranges or boxes of voxels, in which case the
+// voxel must be in one of the ranges and one of the boxes.
+func (m *Manifest) IsDetailBoosted(index byte, x, y, z int) bool {
+	if len(m.DetailBoostRanges) > 0 && !slices.ContainsFunc(m.DetailBoostRanges, func(r IndexRange) bool { return r.Contains(index) }) {
+		return false
+	}
+
+	return len(m.DetailBoostBoxes) == 0 || slices.ContainsFunc(m.DetailBoostBoxes, func(b ClipBox) bool { return b.Contains(x, y, z) })
+}
@@ -0,0 +1,38 @@
+package manifest
+
+import (
+	"github.com/mattkimber/gorender/internal/geometry"
+	"testing"
+)
+
+func TestManifest_IsDetailBoosted(t *testing.T) {
+	ranges := []IndexRange{{Start: 16, End: 23}}
+	boxes := []ClipBox{{From: geometry.Point{X: 2, Y: 0, Z: 4}, To: geometry.Point{X: 5, Y: 3, Z: 4}}}
+
+	testCases := []struct {
+		ranges   []IndexRange
+		boxes    []ClipBox
+		index    byte
+		x, y, z  int
+		expected bool
+	}{
+		{nil, nil, 1, 0, 0, 0, true},
+		{ranges, nil, 16, 0, 0, 0, true},
+		{ranges, nil, 23, 0, 0, 0, true},
+		{ranges, nil, 24, 0, 0, 0, false},
+		{nil, boxes, 1, 2, 0, 4, true},
+		{nil, boxes, 1, 5, 3, 4, true},
+		{nil, boxes, 1, 5, 3, 5, false},
+		{ranges, boxes, 20, 3, 1, 4, true},
+		{ranges, boxes, 1, 3, 1, 4, false},
+		{ranges, boxes, 20, 1, 1, 4, false},
+	}
+
+	for _, testCase := range testCases {
+		m := Manifest{DetailBoostRanges: testCase.ranges, DetailBoostBoxes: testCase.boxes}
+		if result := m.IsDetailBoosted(testCase.index, testCase.x, testCase.y, testCase.z); result != testCase.expected {
+			t.Errorf("index %d at [%d,%d,%d] with ranges %v and boxes %v: expected %v, got %v",
+				testCase.index, testCase.x, testCase.y, testCase.z, testCase.ranges, testCase.boxes, testCase.expected, result)
+		}
+	}
+}
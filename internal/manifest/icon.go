@@ -0,0 +1,34 @@
+package manifest
+
+// Settings for a GUI icon, rendered alongside the object's other sprites. Icons are rendered
+// at a multiple of their size and reduced, so thin details stay crisp at small sizes.
+type Icon struct {
+	Angle                float64 `json:"angle"`
+	Width                int     `json:"width"`
+	Height               int     `json:"height"`
+	RenderElevationAngle int     `json:"render_elevation"`
+	Zoom                 float64 `json:"zoom"`
+	Supersample          int     `json:"supersample"`
+	Sharpen              float64 `json:"sharpen"`
+}
+
+// How many times larger than its size the icon is rendered before being reduced
+func (i Icon) GetSupersample() int {
+	if i.Supersample == 0 {
+		return 4
+	}
+
+	return i.Supersample
+}
+
+// Get a manifest for rendering the icon at its final size
+func (m Manifest) GetIconManifest() Manifest {
+	i := m.Icon
+
+	return m.getSingleSpriteManifest(Sprite{
+		Angle:  i.Angle,
+		Width:  i.Width,
+		Height: i.Height,
+		Zoom:   i.Zoom,
+	}, i.RenderElevationAngle)
+}
@@ -0,0 +1,33 @@
+package manifest
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestManifest_GetIconManifest(t *testing.T) {
+	m, err := FromJson(strings.NewReader(`{
+		"size": {"x": 16, "y": 8, "z": 8},
+		"sprites": [{"angle": 0, "width": 8}],
+		"purchase": {"width": 32},
+		"icon": {"angle": 210, "width": 20, "height": 16}
+	}`))
+
+	if err != nil {
+		t.Fatalf("error reading manifest: %v", err)
+	}
+
+	i := m.GetIconManifest()
+
+	if len(i.Sprites) != 1 || i.Sprites[0].Angle != 210 || i.Sprites[0].Width != 20 || i.Sprites[0].Height != 16 {
+		t.Errorf("Icon sprite not set from icon block: %v", i.Sprites)
+	}
+
+	if i.Icon != nil || i.Purchase != nil || i.FlatLighting {
+		t.Errorf("Icon manifest expected no icon or purchase and normal lighting")
+	}
+
+	if s := m.Icon.GetSupersample(); s != 4 {
+		t.Errorf("Icon supersample expected to default to 4, got %d", s)
+	}
+}
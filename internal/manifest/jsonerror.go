@@ -0,0 +1,121 @@
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Only suggest known settings this many edits away from an unknown one
+const maxSuggestionDistance = 2
+
+// Get an error from reading manifest JSON which says where in the file the problem is,
+// and which setting it is in
+func getJsonError(data []byte, err error) error {
+	var syntaxError *json.SyntaxError
+	var typeError *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &syntaxError):
+		// The offset is after the character which couldn't be read
+		line, column := getLineAndColumn(data, syntaxError.Offset-1)
+		return fmt.Errorf("line %d, column %d: %v", line, column, strings.TrimPrefix(syntaxError.Error(), "json: "))
+	case errors.As(err, &typeError) && typeError.Field != "":
+		line, column := getLineAndColumn(data, getKeyOffset(data, typeError.Field, typeError.Offset))
+		return fmt.Errorf("line %d, column %d: %s must be %s, not %s", line, column, typeError.Field, describeType(typeError.Type), typeError.Value)
+	case errors.As(err, &typeError):
+		line, column := getLineAndColumn(data, typeError.Offset)
+		return fmt.Errorf("line %d, column %d: expected %s, not %s", line, column, describeType(typeError.Type), typeError.Value)
+	}
+
+	return err
+}
+
+// Get the 1-based line and column of an offset into the data
+func getLineAndColumn(data []byte, offset int64) (line, column int) {
+	offset = min(max(offset, 0), int64(len(data)))
+	before := data[:offset]
+
+	line = bytes.Count(before, []byte("\n")) + 1
+	column = len(before) - bytes.LastIndexByte(before, '\n')
+	return
+}
+
+// Find the last key of a field path before the offset of its value, so errors point at
+// the setting rather than the end of its value
+func getKeyOffset(data []byte, field string, valueOffset int64) int64 {
+	key := field
+	if i := strings.LastIndexByte(field, '.'); i != -1 {
+		key = field[i+1:]
+	}
+
+	end := min(max(valueOffset, 0), int64(len(data)))
+	if i := bytes.LastIndex(data[:end], []byte(`"`+key+`"`)); i != -1 {
+		return int64(i)
+	}
+
+	return valueOffset
+}
+
+func describeType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "a whole number"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Bool:
+		return "true or false"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice, reflect.Array:
+		return "a list"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	case reflect.Pointer:
+		return describeType(t.Elem())
+	}
+
+	return t.String()
+}
+
+// Get the known name closest to an unknown one, or an empty string if none are close
+// enough to be a likely misspelling
+func getSuggestion(name string, known []string) (suggestion string) {
+	best := maxSuggestionDistance + 1
+	for _, k := range known {
+		if d := getEditDistance(name, k); d < best {
+			suggestion, best = k, d
+		}
+	}
+
+	return
+}
+
+// Get the number of single character insertions, deletions and substitutions needed to
+// change one string into another
+func getEditDistance(a, b string) int {
+	previous, current := make([]int, len(b)+1), make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+
+		previous, current = current, previous
+	}
+
+	return previous[len(b)]
+}
@@ -0,0 +1,64 @@
+package manifest
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFromJson_ErrorLocation(t *testing.T) {
+	testCases := []struct {
+		json     string
+		expected string
+	}{
+		{"{\n  \"accuracy\": \"high\"\n}", "line 2, column 3: accuracy must be a whole number, not string"},
+		{"{\n  \"sprites\": [\n    {\"width\": 8},\n    {\"angle\": true}\n  ]\n}", "line 4, column 6: sprites"},
+		{"{\n  \"size\": {\"x\": 1},\n}", "line 3, column 1: invalid character '}' looking for beginning of object key string"},
+		{"{\"quality\": 3}", "line 1, column 2: quality must be a string, not number"},
+	}
+
+	for _, testCase := range testCases {
+		_, err := FromJson(strings.NewReader(testCase.json))
+		if err == nil || !strings.HasPrefix(err.Error(), testCase.expected) {
+			t.Errorf("%q expected error starting %q, got %v", testCase.json, testCase.expected, err)
+		}
+	}
+}
+
+func TestGetSuggestion(t *testing.T) {
+	known := []string{"lighting_angle", "lighting_elevation", "noise", "size"}
+
+	testCases := []struct {
+		name, expected string
+	}{
+		{"lighting_angel", "lighting_angle"},
+		{"lightingangle", "lighting_angle"},
+		{"nose", "noise"},
+		{"sizes", "size"},
+		{"depth_buffer", ""},
+	}
+
+	for _, testCase := range testCases {
+		if result := getSuggestion(testCase.name, known); result != testCase.expected {
+			t.Errorf("Suggestion for %s expected %q, got %q", testCase.name, testCase.expected, result)
+		}
+	}
+}
+
+func TestGetEditDistance(t *testing.T) {
+	testCases := []struct {
+		a, b     string
+		expected int
+	}{
+		{"", "", 0},
+		{"angle", "angle", 0},
+		{"angle", "angel", 2},
+		{"zoom", "zooms", 1},
+		{"", "abc", 3},
+	}
+
+	for _, testCase := range testCases {
+		if result := getEditDistance(testCase.a, testCase.b); result != testCase.expected {
+			t.Errorf("Edit distance from %s to %s expected %d, got %d", testCase.a, testCase.b, testCase.expected, result)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package manifest
+
+// A layer is a separately output set of sprites containing only the pixels whose
+// source voxels are in one of the layer's palette ranges.
+type Layer struct {
+	Name   string       `json:"name"`
+	Ranges []IndexRange `json:"ranges"`
+}
+
+type IndexRange struct {
+	Start byte `json:"start"`
+	End   byte `json:"end"`
+}
+
+// Check if a palette index is in any of the layer's ranges
+func (l Layer) Contains(index byte) bool {
+	for _, r := range l.Ranges {
+		if r.Contains(index) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Check if a palette index is in the range, which includes both ends
+func (r IndexRange) Contains(index byte) bool {
+	return index >= r.Start && index <= r.End
+}
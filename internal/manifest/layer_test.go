@@ -0,0 +1,26 @@
+package manifest
+
+import "testing"
+
+func TestLayer_Contains(t *testing.T) {
+	layer := Layer{Name: "glass", Ranges: []IndexRange{{Start: 10, End: 12}, {Start: 20, End: 20}}}
+
+	testCases := []struct {
+		index    byte
+		expected bool
+	}{
+		{0, false},
+		{9, false},
+		{10, true},
+		{12, true},
+		{13, false},
+		{20, true},
+		{21, false},
+	}
+
+	for _, testCase := range testCases {
+		if result := layer.Contains(testCase.index); result != testCase.expected {
+			t.Errorf("Layer contains %d expected %v, got %v", testCase.index, testCase.expected, result)
+		}
+	}
+}
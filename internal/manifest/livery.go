@@ -0,0 +1,40 @@
+package manifest
+
+// A livery renders every sprite again with some palette indexes substituted for others, so
+// one object can be output in several colour schemes without a voxel file for each.
+type Livery struct {
+	Name  string  `json:"name"`
+	Remap []Remap `json:"remap"`
+}
+
+// Substitute the indexes from Start to End with the same number of indexes starting at To
+type Remap struct {
+	Start byte `json:"start"`
+	End   byte `json:"end"`
+	To    byte `json:"to"`
+}
+
+// Get a table of the index each palette index is shaded as in this livery
+func (l Livery) GetRemap() *[256]byte {
+	remap := new([256]byte)
+	for i := range remap {
+		remap[i] = byte(i)
+	}
+
+	for _, r := range l.Remap {
+		for i := int(r.Start); i <= int(r.End) && int(r.To)+i-int(r.Start) < len(remap); i++ {
+			remap[i] = byte(int(r.To) + i - int(r.Start))
+		}
+	}
+
+	return remap
+}
+
+// Get the palette index a voxel's index is shaded as, once any livery has been applied
+func (d *Definition) GetRemappedIndex(index byte) byte {
+	if d.Remap == nil {
+		return index
+	}
+
+	return d.Remap[index]
+}
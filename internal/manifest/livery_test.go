@@ -0,0 +1,31 @@
+package manifest
+
+import "testing"
+
+func TestLivery_GetRemap(t *testing.T) {
+	livery := Livery{Remap: []Remap{{Start: 10, End: 12, To: 20}, {Start: 30, End: 30, To: 40}, {Start: 250, End: 255, To: 253}}}
+	def := Definition{Remap: livery.GetRemap()}
+
+	testCases := []struct {
+		index, expected byte
+	}{
+		{0, 0},
+		{9, 9},
+		{10, 20},
+		{12, 22},
+		{13, 13},
+		{30, 40},
+		{252, 255},
+		{253, 253},
+	}
+
+	for _, testCase := range testCases {
+		if result := def.GetRemappedIndex(testCase.index); result != testCase.expected {
+			t.Errorf("Index %d expected to be remapped to %d, got %d", testCase.index, testCase.expected, result)
+		}
+	}
+
+	if result := (&Definition{}).GetRemappedIndex(10); result != 10 {
+		t.Errorf("Index 10 expected to be unchanged without a livery, got %d", result)
+	}
+}
@@ -2,69 +2,201 @@ package manifest
 
 import (
 	"encoding/json"
+	"fmt"
 	"github.com/mattkimber/gorender/internal/colour"
 	"github.com/mattkimber/gorender/internal/geometry"
+	"github.com/mattkimber/gorender/internal/utils/timingutils"
 	"github.com/mattkimber/gorender/internal/voxelobject"
 	"io"
 	"math"
+	"strings"
 )
 
 type Definition struct {
-	Object   voxelobject.ProcessedVoxelObject
-	Palette  colour.Palette
-	Manifest Manifest
-	Scale    float64
-	Debug    bool
-	Time     bool
-	Only8bpp bool
+	Object        voxelobject.ProcessedVoxelObject
+	SlopedObjects map[string]voxelobject.ProcessedVoxelObject
+	SpriteObjects map[string]voxelobject.ProcessedVoxelObject
+	Palette       colour.Palette
+	Manifest      Manifest
+	Scale         float64
+	Debug         bool
+	Time          bool
+	Only8bpp      bool
+	OutputGBuffer bool
+	MaxMemory     int64
+	Timings       *timingutils.Recorder
+
+	// Palette indexes are substituted with these when shading, to render a livery
+	Remap *[256]byte
+
+	// Snow settled on the sprites when shading, to render a snow-covered variant
+	Snow *Snow
 }
 
 type Sprite struct {
 	Angle                float64 `json:"angle"`
 	Width                int     `json:"width"`
 	Height               int     `json:"height"`
+	CanvasWidth          int     `json:"canvas_width"`
+	CanvasHeight         int     `json:"canvas_height"`
 	OffsetX              float64 `json:"offset_x"`
 	OffsetY              float64 `json:"offset_y"`
 	X                    int
 	ZError               float64
-	Flip                 bool    `json:"flip"`
-	Slice                int     `json:"slice"`
-	RenderElevationAngle int     `json:"render_elevation"`
-	Joggle               float64 `json:"joggle"`
+	Flip                 bool          `json:"flip"`
+	FlipHorizontal       bool          `json:"flip_horizontal"`
+	Slice                int           `json:"slice"`
+	RenderElevationAngle int           `json:"render_elevation"`
+	Joggle               float64       `json:"joggle"`
+	Zoom                 float64       `json:"zoom"`
+	Type                 string        `json:"type"`
+	Slope                int           `json:"slope"`
+	VisibleLayers        []string      `json:"visible_layers"`
+	Object               string        `json:"object"`
+	ClipBoxes            []ClipBox     `json:"clip_boxes"`
+	CrossSection         *CrossSection `json:"cross_section"`
+	Supersample          int           `json:"supersample"`
+	Seed                 int           `json:"seed"`
+}
+
+// A box of voxels, inclusive of both corners, which is removed from the object before rendering
+type ClipBox struct {
+	From geometry.Point `json:"from"`
+	To   geometry.Point `json:"to"`
+}
+
+// Check if a voxel is inside the box
+func (b ClipBox) Contains(x, y, z int) bool {
+	return x >= b.From.X && x <= b.To.X && y >= b.From.Y && y <= b.To.Y && z >= b.From.Z && z <= b.To.Z
+}
+
+// A range of slices along one axis of the object, which are the only voxels rendered
+type CrossSection struct {
+	Axis string `json:"axis"`
+	From int    `json:"from"`
+	To   int    `json:"to"`
+
+	// Palette index used to hatch the surfaces where the object was cut. 0 turns hatching off.
+	Hatch byte `json:"hatch"`
+}
+
+// Get the last slice of the section, which is the first slice if none is set
+func (c CrossSection) GetTo() int {
+	return max(c.From, c.To)
+}
+
+// A gradient painted over the voxels using the palette indexes from Start to End, moving each
+// voxel's index by Bottom at the lowest of them up to Top at the highest
+type HeightGradient struct {
+	Start  byte    `json:"start"`
+	End    byte    `json:"end"`
+	Bottom float64 `json:"bottom"`
+	Top    float64 `json:"top"`
+}
+
+// Remap the brightness of voxel colours from the range the object was painted for onto the range
+// expected by the palette, with all values from 0 to 1. Colours stay in their own palette range,
+// so keep their hue.
+type BrightnessRemap struct {
+	SourceMin float64 `json:"source_min"`
+	SourceMax float64 `json:"source_max"`
+	TargetMin float64 `json:"target_min"`
+	TargetMax float64 `json:"target_max"`
 }
 
 type Manifest struct {
-	LightingAngle             int              `json:"lighting_angle"`
-	LightingElevation         int              `json:"lighting_elevation"`
-	Size                      geometry.Vector3 `json:"size"`
-	RenderElevationAngle      int              `json:"render_elevation"`
-	Sprites                   []Sprite         `json:"sprites"`
-	DepthInfluence            float64          `json:"depth_influence"`
-	TiledNormals              bool             `json:"tiled_normals"`
-	TilingMode                string           `json:"tiling_mode"`
-	SolidBase                 bool             `json:"solid_base"`
-	SoftenEdges               float64          `json:"soften_edges"`
-	Accuracy                  int              `json:"accuracy"`
-	Sampler                   string           `json:"sampler"`
-	Overlap                   float64          `json:"overlap"`
-	Brightness                float64          `json:"brightness"`
-	Contrast                  float64          `json:"contrast"`
-	DetailBoost               float64          `json:"detail_boost"`
-	FadeToBlack               bool             `json:"fade_to_black"`
-	EdgeThreshold             float64          `json:"alpha_edge_threshold"`
-	HardEdgeThreshold         float64          `json:"hard_edge_threshold"`
-	PadToFullLength           bool             `json:"pad_to_full_length"`
-	SliceThreshold            int              `json:"slice_threshold"`
-	SliceLength               int              `json:"slice_length"`
-	SliceOverlap              int              `json:"slice_overlap"`
-	Falloff                   float64          `json:"falloff_adjustment"`
-	RecoveredVoxelSuppression float64          `json:"recovered_voxel_suppression"`
-	Joggle                    float64          `json:"joggle"`
-	DitherFlatAreas           bool             `json:"dither_flat_areas"`
-	Fosterise                 bool             `json:"fosterise"`
-	NoEdgeFosterisation       bool             `json:"suppress_edge_fosterisation"`
-	SoftShadow                bool             `json:"soft_shadow"`
-	ShadowThreshold           float64          `json:"shadow_threshold"`
+	LightingAngle             int                    `json:"lighting_angle"`
+	LightingElevation         int                    `json:"lighting_elevation"`
+	Size                      geometry.Vector3       `json:"size"`
+	RenderElevationAngle      int                    `json:"render_elevation"`
+	Sprites                   []Sprite               `json:"sprites"`
+	Objects                   []Object               `json:"objects"`
+	DepthInfluence            float64                `json:"depth_influence"`
+	TiledNormals              bool                   `json:"tiled_normals"`
+	GradientNormals           bool                   `json:"gradient_normals"`
+	TilingMode                string                 `json:"tiling_mode"`
+	SolidBase                 bool                   `json:"solid_base"`
+	BrightnessRemap           *BrightnessRemap       `json:"brightness_remap"`
+	HeightGradients           []HeightGradient       `json:"height_gradients"`
+	SoftenEdges               float64                `json:"soften_edges"`
+	Accuracy                  int                    `json:"accuracy"`
+	AdaptiveThreshold         float64                `json:"adaptive_threshold"`
+	Sampler                   string                 `json:"sampler"`
+	Raycaster                 string                 `json:"raycaster"`
+	Overlap                   float64                `json:"overlap"`
+	Brightness                float64                `json:"brightness"`
+	Contrast                  float64                `json:"contrast"`
+	ToneMapping               string                 `json:"tone_mapping"`
+	Exposure                  float64                `json:"exposure"`
+	ColourTemperature         float64                `json:"colour_temperature"`
+	Tint                      float64                `json:"tint"`
+	DetailBoost               float64                `json:"detail_boost"`
+	DetailBoostRanges         []IndexRange           `json:"detail_boost_ranges"`
+	DetailBoostBoxes          []ClipBox              `json:"detail_boost_boxes"`
+	Noise                     float64                `json:"noise"`
+	Dirt                      float64                `json:"dirt"`
+	DirtTint                  byte                   `json:"dirt_tint"`
+	Sharpen                   float64                `json:"sharpen"`
+	SharpenRadius             int                    `json:"sharpen_radius"`
+	FadeToBlack               bool                   `json:"fade_to_black"`
+	FadeToColour              byte                   `json:"fade_to_colour"`
+	EdgeThreshold             float64                `json:"alpha_edge_threshold"`
+	HardEdgeThreshold         float64                `json:"hard_edge_threshold"`
+	TransparencyDither        string                 `json:"transparency_dither"`
+	PadToFullLength           bool                   `json:"pad_to_full_length"`
+	JoinOverlap               float64                `json:"join_overlap"`
+	SliceThreshold            int                    `json:"slice_threshold"`
+	SliceLength               int                    `json:"slice_length"`
+	SliceOverlap              int                    `json:"slice_overlap"`
+	Falloff                   float64                `json:"falloff_adjustment"`
+	RecoveredVoxelSuppression float64                `json:"recovered_voxel_suppression"`
+	RecoveredVoxels           *RecoveredVoxels       `json:"recovered_voxels"`
+	Joggle                    float64                `json:"joggle"`
+	NearClip                  float64                `json:"near_clip"`
+	FarClip                   float64                `json:"far_clip"`
+	MaxRayDistance            float64                `json:"max_ray_distance"`
+	DitherFlatAreas           bool                   `json:"dither_flat_areas"`
+	MaxColours                int                    `json:"max_colours"`
+	DespeckleThreshold        int                    `json:"despeckle_threshold"`
+	Fosterise                 bool                   `json:"fosterise"`
+	NoEdgeFosterisation       bool                   `json:"suppress_edge_fosterisation"`
+	SoftShadow                bool                   `json:"soft_shadow"`
+	ShadowThreshold           float64                `json:"shadow_threshold"`
+	SinglePassDither          bool                   `json:"single_pass_dither"`
+	CorrectCompanyColourBleed bool                   `json:"correct_company_colour_bleed"`
+	Animated                  bool                   `json:"animated"`
+	SpecialnessThreshold      float64                `json:"specialness_threshold"`
+	CoherentDither            bool                   `json:"coherent_dither"`
+	AlternateModal            string                 `json:"alternate_modal"`
+	TileableDither            bool                   `json:"tileable_dither"`
+	RenderSlopes              bool                   `json:"render_slopes"`
+	SlopeHeight               int                    `json:"slope_height"`
+	DropShadow                bool                   `json:"drop_shadow"`
+	DropShadowIndex           byte                   `json:"drop_shadow_index"`
+	Layers                    []Layer                `json:"layers"`
+	DepthBuffer               bool                   `json:"depth_buffer"`
+	GlossMap                  bool                   `json:"gloss_map"`
+	ColourBlindPreviews       []string               `json:"colour_blind_previews"`
+	SpriteFiles               bool                   `json:"sprite_files"`
+	Aseprite                  bool                   `json:"aseprite"`
+	NML                       bool                   `json:"nml"`
+	Atlas                     string                 `json:"atlas"`
+	Quality                   string                 `json:"quality"`
+	Camera                    string                 `json:"camera"`
+	Deduplicate               bool                   `json:"deduplicate"`
+	Template                  string                 `json:"template"`
+	AutoSize                  *AutoSize              `json:"auto_size"`
+	OutputFormats             map[string]string      `json:"output_formats"`
+	Targets                   map[string]Target      `json:"targets"`
+	Symmetric                 bool                   `json:"symmetric"`
+	Purchase                  *Purchase              `json:"purchase"`
+	Icon                      *Icon                  `json:"icon"`
+	FlatLighting              bool                   `json:"flat_lighting"`
+	Backdrop                  byte                   `json:"backdrop"`
+	ColourClasses             map[string]ColourClass `json:"colour_classes"`
+	Liveries                  []Livery               `json:"liveries"`
+	Night                     *Night                 `json:"night"`
+	Snow                      *Snow                  `json:"snow"`
 }
 
 func FromJson(handle io.Reader) (manifest Manifest, err error) {
@@ -72,6 +204,7 @@ func FromJson(handle io.Reader) (manifest Manifest, err error) {
 	manifest.Accuracy = 2
 	manifest.EdgeThreshold = 0.5
 	manifest.TilingMode = "normal"
+	manifest.DropShadowIndex = 1
 
 	data, err := io.ReadAll(handle)
 
@@ -79,7 +212,27 @@ func FromJson(handle io.Reader) (manifest Manifest, err error) {
 		return
 	}
 
+	// Apply any quality and camera presets first, so they can be overridden by individual settings
+	preset := struct {
+		Quality string `json:"quality"`
+		Camera  string `json:"camera"`
+	}{}
+
+	if err = json.Unmarshal(data, &preset); err != nil {
+		err = getJsonError(data, err)
+		return
+	}
+
+	if err = manifest.applyQualityPreset(preset.Quality); err != nil {
+		return
+	}
+
+	if err = manifest.applyCameraPreset(preset.Camera); err != nil {
+		return
+	}
+
 	if err = json.Unmarshal(data, &manifest); err != nil {
+		err = getJsonError(data, err)
 		return
 	}
 
@@ -88,7 +241,13 @@ func FromJson(handle io.Reader) (manifest Manifest, err error) {
 	manifest.Contrast += 1.0
 
 	// Set up sprite sizes
+	manifest.ExpandSlopes()
+	if err = manifest.applyTemplate(); err != nil {
+		return
+	}
+
 	manifest.SetSpriteSizes()
+	err = manifest.prepareObjectSprites()
 
 	return
 }
@@ -98,6 +257,57 @@ func (m *Manifest) GetFromReader(handle io.Reader) (err error) {
 	return err
 }
 
+// Get the size of the sprite's output in pixels at 1x scale. This is the canvas size if
+// one is set, otherwise the size of the object.
+func (s Sprite) GetCanvasSize() (width, height int) {
+	width, height = s.Width, s.Height
+	if s.CanvasWidth > 0 {
+		width = s.CanvasWidth
+	}
+
+	if s.CanvasHeight > 0 {
+		height = s.CanvasHeight
+	}
+
+	return
+}
+
+// How many times larger than its size the sprite is raycast and shaded before being reduced
+func (s Sprite) GetSupersample() int {
+	return max(s.Supersample, 1)
+}
+
+// Check if this sprite renders something other than the whole of the input file
+func (s Sprite) HasOwnObject() bool {
+	return s.Object != "" || len(s.VisibleLayers) > 0 || len(s.ClipBoxes) > 0 || s.CrossSection != nil
+}
+
+// Get a key identifying the object, combination of visible layers, clip boxes and cross section used by this sprite
+func (s Sprite) ObjectKey() string {
+	key := s.Object + "|" + strings.Join(s.VisibleLayers, ",")
+	for _, b := range s.ClipBoxes {
+		key += fmt.Sprintf("|%d,%d,%d-%d,%d,%d", b.From.X, b.From.Y, b.From.Z, b.To.X, b.To.Y, b.To.Z)
+	}
+
+	if c := s.CrossSection; c != nil {
+		key += fmt.Sprintf("|%s%d-%d#%d", c.Axis, c.From, c.GetTo(), c.Hatch)
+	}
+
+	return key
+}
+
+// Get the file and named node to render for this sprite. Objects are given as
+// "file.vox#node", where an empty file refers to the input file and an empty node
+// to the whole file.
+func (s Sprite) GetObjectSource(inputFilename string) (filename string, node string) {
+	filename, node, _ = strings.Cut(s.Object, "#")
+	if filename == "" {
+		filename = inputFilename
+	}
+
+	return
+}
+
 func (d *Definition) SoftenEdges() bool {
 	return d.Scale >= d.Manifest.SoftenEdges
 }
@@ -113,7 +323,7 @@ func (m *Manifest) SetSpriteSizes() {
 		}
 
 		if m.Sprites[i].RenderElevationAngle == 0 {
-			m.Sprites[i].RenderElevationAngle = m.RenderElevationAngle
+			m.Sprites[i].RenderElevationAngle = m.getDefaultSpriteElevation(m.Sprites[i])
 		}
 	}
 }
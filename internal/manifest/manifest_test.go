@@ -4,6 +4,7 @@ import (
 	"github.com/mattkimber/gorender/internal/geometry"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -16,6 +17,7 @@ func TestFromJson(t *testing.T) {
 		Contrast:          1.0,
 		EdgeThreshold:     0.5,
 		TilingMode:        "normal",
+		DropShadowIndex:   1,
 		Size: geometry.Vector3{
 			X: 20,
 			Y: 30,
@@ -41,3 +43,150 @@ func TestFromJson(t *testing.T) {
 		t.Errorf("Expected %v, got %v", expected, actual)
 	}
 }
+
+func TestSprite_GetObjectSource(t *testing.T) {
+	testCases := []struct {
+		object, filename, node string
+	}{
+		{"", "input.vox", ""},
+		{"#bogie", "input.vox", "bogie"},
+		{"train.vox#bogie", "train.vox", "bogie"},
+		{"train.vox", "train.vox", ""},
+	}
+
+	for _, testCase := range testCases {
+		spr := Sprite{Object: testCase.object}
+		if filename, node := spr.GetObjectSource("input.vox"); filename != testCase.filename || node != testCase.node {
+			t.Errorf("Object %s expected %s/%s, got %s/%s", testCase.object, testCase.filename, testCase.node, filename, node)
+		}
+	}
+}
+
+func TestFromJson_Quality(t *testing.T) {
+	testCases := []struct {
+		json             string
+		accuracy         int
+		overlap          float64
+		singlePassDither bool
+	}{
+		{`{}`, 2, 0, false},
+		{`{"quality":"draft"}`, 1, 0, true},
+		{`{"quality":"final"}`, 7, 0.05, false},
+		{`{"quality":"draft","accuracy":4}`, 4, 0, true},
+		{`{"accuracy":4,"quality":"final","single_pass_dither":true}`, 4, 0.05, true},
+	}
+
+	for _, testCase := range testCases {
+		m, err := FromJson(strings.NewReader(testCase.json))
+		if err != nil {
+			t.Fatalf("%s could not be read: %v", testCase.json, err)
+		}
+
+		if m.Accuracy != testCase.accuracy || m.Overlap != testCase.overlap || m.SinglePassDither != testCase.singlePassDither {
+			t.Errorf("%s expected accuracy %d, overlap %f, single pass dither %v, got %d, %f, %v", testCase.json,
+				testCase.accuracy, testCase.overlap, testCase.singlePassDither, m.Accuracy, m.Overlap, m.SinglePassDither)
+		}
+	}
+
+	if _, err := FromJson(strings.NewReader(`{"quality":"ultra"}`)); err == nil {
+		t.Errorf("expected error for unknown quality preset")
+	}
+}
+
+func TestFromJson_Camera(t *testing.T) {
+	testCases := []struct {
+		json       string
+		elevations []int
+	}{
+		{`{"sprites":[{"angle":0,"width":8},{"angle":45,"width":8}]}`, []int{0, 0}},
+		{`{"camera":"openttd","sprites":[{"angle":0,"width":8},{"angle":45,"width":8},{"angle":180,"width":8}]}`, []int{33, 30, 33}},
+		{`{"camera":"openttd","sprites":[{"angle":0,"width":8,"render_elevation":20}]}`, []int{20}},
+		{`{"camera":"isometric","sprites":[{"angle":0,"width":8},{"angle":45,"width":8}]}`, []int{45, 45}},
+		{`{"camera":"isometric","render_elevation":40,"sprites":[{"angle":45,"width":8}]}`, []int{40}},
+	}
+
+	for _, testCase := range testCases {
+		m, err := FromJson(strings.NewReader(testCase.json))
+		if err != nil {
+			t.Fatalf("%s could not be read: %v", testCase.json, err)
+		}
+
+		for i, expected := range testCase.elevations {
+			if result := m.Sprites[i].RenderElevationAngle; result != expected {
+				t.Errorf("%s sprite %d expected elevation %d, got %d", testCase.json, i, expected, result)
+			}
+		}
+	}
+
+	if _, err := FromJson(strings.NewReader(`{"camera":"fisheye"}`)); err == nil {
+		t.Errorf("expected error for unknown camera preset")
+	}
+}
+
+func TestSprite_GetCanvasSize(t *testing.T) {
+	testCases := []struct {
+		spr           Sprite
+		width, height int
+	}{
+		{Sprite{Width: 32, Height: 16}, 32, 16},
+		{Sprite{Width: 32, Height: 16, CanvasWidth: 48}, 48, 16},
+		{Sprite{Width: 32, Height: 16, CanvasHeight: 24}, 32, 24},
+	}
+
+	for _, testCase := range testCases {
+		if width, height := testCase.spr.GetCanvasSize(); width != testCase.width || height != testCase.height {
+			t.Errorf("sprite %v expected %dx%d, got %dx%d", testCase.spr, testCase.width, testCase.height, width, height)
+		}
+	}
+}
+
+func TestFromJson_Template(t *testing.T) {
+	m, err := FromJson(strings.NewReader(`{"template":"nml_vehicle","sprites":[{"width":10},{"width":20,"canvas_width":23}]}`))
+	if err != nil {
+		t.Fatalf("manifest could not be read: %v", err)
+	}
+
+	// Sprites take the size of their cell unless they set their own
+	if m.Sprites[0].CanvasWidth != 8 || m.Sprites[0].CanvasHeight != 24 {
+		t.Errorf("sprite 0 expected canvas 8x24, got %dx%d", m.Sprites[0].CanvasWidth, m.Sprites[0].CanvasHeight)
+	}
+
+	if m.Sprites[1].CanvasWidth != 23 || m.Sprites[1].CanvasHeight != 20 {
+		t.Errorf("sprite 1 expected canvas 23x20, got %dx%d", m.Sprites[1].CanvasWidth, m.Sprites[1].CanvasHeight)
+	}
+
+	if _, err := FromJson(strings.NewReader(`{"template":"tmpl_unknown"}`)); err == nil {
+		t.Errorf("expected error for unknown template")
+	}
+
+	// Sprite 1 is drawn 7 pixels into the cell of sprite 2
+	_, err = FromJson(strings.NewReader(`{"template":"nml_vehicle","sprites":[{"width":10},{"width":20,"canvas_width":30},{"width":20}]}`))
+	if expected := "sprite 1 overlaps the template cell of sprite 2 by 7 pixels"; err == nil || err.Error() != expected {
+		t.Errorf("expected error %q, got %v", expected, err)
+	}
+}
+
+func TestTemplate_GetFilename(t *testing.T) {
+	formats := map[string]string{"8bpp": "bmp", "32bpp": "tga"}
+
+	testCases := []struct {
+		template, key, expected string
+		formats                 map[string]string
+	}{
+		{"", "8bpp", "bus_8bpp.png", nil},
+		{"", "32bpp", "bus_32bpp.png", nil},
+		{"nml_vehicle", "8bpp", "bus.png", nil},
+		{"nml_vehicle", "mask", "bus_mask.png", nil},
+		{"simutrans", "8bpp", "bus.png", nil},
+		{"", "32bpp", "bus_32bpp.tga", formats},
+		{"", "mask", "bus_mask.png", formats},
+		{"nml_vehicle", "8bpp", "bus.bmp", formats},
+	}
+
+	for _, testCase := range testCases {
+		m := Manifest{Template: testCase.template, OutputFormats: testCase.formats}
+		if result := m.GetTemplate().GetFilename("bus", testCase.key); result != testCase.expected {
+			t.Errorf("template %q key %s expected %s, got %s", testCase.template, testCase.key, testCase.expected, result)
+		}
+	}
+}
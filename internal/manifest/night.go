@@ -0,0 +1,38 @@
+package manifest
+
+// Settings for rendering a night version of every sprite alongside the day sprites. Night
+// sprites are dimmed and lit with cooler light, and lit windows can be mapped to the
+// palette's animated light colours so they glow in the dark.
+type Night struct {
+	Exposure          float64 `json:"exposure"`
+	ColourTemperature float64 `json:"colour_temperature"`
+	Lights            []Remap `json:"lights"`
+}
+
+// How much darker than the day sprites night sprites are, in stops
+func (n Night) GetExposure() float64 {
+	if n.Exposure == 0 {
+		return -1.5
+	}
+
+	return n.Exposure
+}
+
+// The colour temperature of night lighting in Kelvin
+func (n Night) GetColourTemperature() float64 {
+	if n.ColourTemperature == 0 {
+		return 12000
+	}
+
+	return n.ColourTemperature
+}
+
+// Get a manifest for shading the night sprites
+func (m Manifest) GetNightManifest() Manifest {
+	n := m.Night
+
+	m.Exposure += n.GetExposure()
+	m.ColourTemperature = n.GetColourTemperature()
+	m.Night, m.Liveries, m.Snow = nil, nil, nil
+	return m
+}
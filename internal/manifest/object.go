@@ -0,0 +1,67 @@
+package manifest
+
+import "path/filepath"
+
+// An object rendered with the manifest's settings, so a family of objects can share one
+// manifest and one set of lighting parameters. Files are relative to the manifest, and
+// objects without sprites of their own use the manifest's sprites.
+type Object struct {
+	File    string   `json:"file"`
+	Sprites []Sprite `json:"sprites"`
+}
+
+// Get the voxel file of each of the manifest's objects
+func (m Manifest) GetObjectFilenames(manifestFilename string) (filenames []string) {
+	for _, o := range m.Objects {
+		filenames = append(filenames, filepath.Join(filepath.Dir(manifestFilename), o.File))
+	}
+
+	return
+}
+
+// Get the index of the manifest's object for a voxel file, or -1 if it has none
+func (m Manifest) GetObjectIndex(inputFilename, manifestFilename string) int {
+	for i, filename := range m.GetObjectFilenames(manifestFilename) {
+		if filename == filepath.Clean(inputFilename) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Get the manifest for rendering one of its objects, with the object's sprites in place of
+// the manifest's own. An index of -1 gets the manifest unchanged.
+func (m Manifest) GetObjectManifest(index int) Manifest {
+	if index < 0 || index >= len(m.Objects) {
+		return m
+	}
+
+	if sprites := m.Objects[index].Sprites; len(sprites) > 0 {
+		m.Sprites = sprites
+	}
+
+	m.Objects = nil
+	return m
+}
+
+// Set up the sprites of each object as FromJson does for the manifest's own sprites
+func (m *Manifest) prepareObjectSprites() error {
+	for i, o := range m.Objects {
+		if len(o.Sprites) == 0 {
+			continue
+		}
+
+		om := *m
+		om.Sprites, om.Objects = o.Sprites, nil
+		om.ExpandSlopes()
+		if err := om.applyTemplate(); err != nil {
+			return err
+		}
+
+		om.SetSpriteSizes()
+		m.Objects[i].Sprites = om.Sprites
+	}
+
+	return nil
+}
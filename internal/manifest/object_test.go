@@ -0,0 +1,46 @@
+package manifest
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const objectsJson = `{"size":{"x":10,"y":4,"z":8},"lighting_angle":60,"sprites":[{"angle":0,"width":8}],
+	"objects":[{"file":"bus.vox"},{"file":"lorry/lorry.vox","sprites":[{"angle":90,"width":12},{"angle":180,"width":4}]}]}`
+
+func TestManifest_GetObjectManifest(t *testing.T) {
+	m, err := FromJson(strings.NewReader(objectsJson))
+	if err != nil {
+		t.Fatalf("could not read manifest: %v", err)
+	}
+
+	manifestFilename := filepath.Join("vehicles", "family.json")
+
+	testCases := []struct {
+		input   string
+		index   int
+		sprites int
+	}{
+		{filepath.Join("vehicles", "bus.vox"), 0, 1},
+		{filepath.Join("vehicles", "lorry", "..", "lorry", "lorry.vox"), 1, 2},
+		{filepath.Join("vehicles", "tram.vox"), -1, 1},
+	}
+
+	for _, testCase := range testCases {
+		index := m.GetObjectIndex(testCase.input, manifestFilename)
+		if index != testCase.index {
+			t.Errorf("%s: expected object %d, got %d", testCase.input, testCase.index, index)
+		}
+
+		om := m.GetObjectManifest(index)
+		if len(om.Sprites) != testCase.sprites || om.LightingAngle != 60 {
+			t.Errorf("%s: expected %d sprites and shared settings, got %v", testCase.input, testCase.sprites, om)
+		}
+	}
+
+	// Object sprites are set up in the same way as the manifest's own
+	if spr := m.GetObjectManifest(1).Sprites[0]; spr.Height == 0 {
+		t.Errorf("expected object sprite to have its height set, got %v", spr)
+	}
+}
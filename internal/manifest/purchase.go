@@ -0,0 +1,52 @@
+package manifest
+
+// Settings for a purchase menu sprite, rendered alongside the object's other sprites with its
+// own camera, lighting and canvas
+type Purchase struct {
+	Angle                float64 `json:"angle"`
+	Width                int     `json:"width"`
+	Height               int     `json:"height"`
+	CanvasWidth          int     `json:"canvas_width"`
+	CanvasHeight         int     `json:"canvas_height"`
+	RenderElevationAngle int     `json:"render_elevation"`
+	Zoom                 float64 `json:"zoom"`
+	DirectionalLighting  bool    `json:"directional_lighting"`
+	Backdrop             byte    `json:"backdrop"`
+}
+
+// Get a manifest for rendering the purchase menu sprite
+func (m Manifest) GetPurchaseManifest() Manifest {
+	p := m.Purchase
+
+	m = m.getSingleSpriteManifest(Sprite{
+		Angle:        p.Angle,
+		Width:        p.Width,
+		Height:       p.Height,
+		CanvasWidth:  p.CanvasWidth,
+		CanvasHeight: p.CanvasHeight,
+		Zoom:         p.Zoom,
+	}, p.RenderElevationAngle)
+
+	m.FlatLighting = m.FlatLighting || !p.DirectionalLighting
+	m.Backdrop = p.Backdrop
+	return m
+}
+
+// Get a manifest with the same settings as this one for rendering a single extra sprite,
+// but none of the extra sprites or spritesheets
+func (m Manifest) getSingleSpriteManifest(spr Sprite, elevation int) Manifest {
+	m.Purchase, m.Icon = nil, nil
+
+	// The elevation is set for the whole manifest so it is also used for the sprite's height,
+	// and for the sprite so it isn't replaced by a camera preset's end-on elevation
+	if elevation != 0 {
+		m.RenderElevationAngle, spr.RenderElevationAngle = elevation, elevation
+	}
+
+	m.Sprites = []Sprite{spr}
+
+	m.Template, m.Layers, m.DropShadow, m.Deduplicate = "", nil, false, false
+
+	m.SetSpriteSizes()
+	return m
+}
@@ -0,0 +1,43 @@
+package manifest
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestManifest_GetPurchaseManifest(t *testing.T) {
+	m, err := FromJson(strings.NewReader(`{
+		"size": {"x": 16, "y": 8, "z": 8},
+		"render_elevation": 30,
+		"sprites": [{"angle": 0, "width": 8}, {"angle": 90, "width": 8}],
+		"layers": [{"name": "glass", "ranges": [{"start": 1, "end": 2}]}],
+		"drop_shadow": true,
+		"purchase": {"angle": 225, "width": 32, "canvas_width": 64, "render_elevation": 45, "backdrop": 5}
+	}`))
+
+	if err != nil {
+		t.Fatalf("error reading manifest: %v", err)
+	}
+
+	p := m.GetPurchaseManifest()
+
+	if len(p.Sprites) != 1 || p.Sprites[0].Angle != 225 || p.Sprites[0].Width != 32 || p.Sprites[0].CanvasWidth != 64 {
+		t.Fatalf("Purchase sprite not set from purchase block: %v", p.Sprites)
+	}
+
+	if p.Sprites[0].RenderElevationAngle != 45 || p.Sprites[0].Height == 0 {
+		t.Errorf("Purchase sprite elevation and height expected to be set, got %d and %d", p.Sprites[0].RenderElevationAngle, p.Sprites[0].Height)
+	}
+
+	if p.Purchase != nil || p.Layers != nil || p.DropShadow {
+		t.Errorf("Purchase manifest expected no purchase, layers or drop shadow, got %v %v %v", p.Purchase, p.Layers, p.DropShadow)
+	}
+
+	if !p.FlatLighting || p.Backdrop != 5 {
+		t.Errorf("Purchase manifest expected flat lighting and backdrop 5, got %v and %d", p.FlatLighting, p.Backdrop)
+	}
+
+	if len(m.Sprites) != 2 || m.Layers == nil || m.FlatLighting || m.RenderElevationAngle != 30 {
+		t.Errorf("Original manifest should not be changed")
+	}
+}
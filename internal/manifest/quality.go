@@ -0,0 +1,35 @@
+package manifest
+
+import "fmt"
+
+type qualityPreset struct {
+	Accuracy         int
+	Sampler          string
+	Overlap          float64
+	SinglePassDither bool
+}
+
+// Presets set the options which trade render time against quality. "final" matches the
+// settings of the default manifest.
+var qualityPresets = map[string]qualityPreset{
+	"draft":    {Accuracy: 1, Sampler: "square", Overlap: 0, SinglePassDither: true},
+	"standard": {Accuracy: 3, Sampler: "square", Overlap: 0.05},
+	"final":    {Accuracy: 7, Sampler: "square", Overlap: 0.05},
+}
+
+func (m *Manifest) applyQualityPreset(name string) error {
+	if name == "" {
+		return nil
+	}
+
+	preset, ok := qualityPresets[name]
+	if !ok {
+		return fmt.Errorf("unknown quality preset %s", name)
+	}
+
+	m.Accuracy = preset.Accuracy
+	m.Sampler = preset.Sampler
+	m.Overlap = preset.Overlap
+	m.SinglePassDither = preset.SinglePassDither
+	return nil
+}
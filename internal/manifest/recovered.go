@@ -0,0 +1,39 @@
+package manifest
+
+// Settings for recovering surface voxels. Rays which pass between voxels, such as through
+// thin details at an angle, can hit a voxel inside the object. The surface voxel nearest
+// to where they hit is searched for and drawn instead, which can give unexpected results
+// on some objects.
+type RecoveredVoxels struct {
+	Disabled      bool    `json:"disabled"`
+	Radius        int     `json:"radius"`
+	Suppression   float64 `json:"suppression"`
+	IgnoreNormals bool    `json:"ignore_normals"`
+}
+
+// The largest neighbourhood searched around a ray for a surface voxel
+const maxRecoveryRadius = 3
+
+// How many voxels to each side of the ray are searched for a surface voxel
+func (r RecoveredVoxels) GetRadius() int {
+	if r.Radius == 0 {
+		return 1
+	}
+
+	return r.Radius
+}
+
+// Get the settings for recovered voxels, which take their suppression from
+// recovered_voxel_suppression if it isn't set
+func (m Manifest) GetRecoveredVoxels() RecoveredVoxels {
+	var r RecoveredVoxels
+	if m.RecoveredVoxels != nil {
+		r = *m.RecoveredVoxels
+	}
+
+	if r.Suppression == 0 {
+		r.Suppression = m.RecoveredVoxelSuppression
+	}
+
+	return r
+}
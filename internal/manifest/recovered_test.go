@@ -0,0 +1,31 @@
+package manifest
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestManifest_GetRecoveredVoxels(t *testing.T) {
+	testCases := []struct {
+		json        string
+		radius      int
+		suppression float64
+	}{
+		{`{}`, 1, 0},
+		{`{"recovered_voxel_suppression": 0.5}`, 1, 0.5},
+		{`{"recovered_voxels": {"radius": 2}, "recovered_voxel_suppression": 0.5}`, 2, 0.5},
+		{`{"recovered_voxels": {"suppression": 0.25}, "recovered_voxel_suppression": 0.5}`, 1, 0.25},
+	}
+
+	for _, testCase := range testCases {
+		m, err := FromJson(strings.NewReader(testCase.json))
+		if err != nil {
+			t.Fatalf("error reading manifest: %v", err)
+		}
+
+		r := m.GetRecoveredVoxels()
+		if r.GetRadius() != testCase.radius || r.Suppression != testCase.suppression {
+			t.Errorf("%s: expected radius %d and suppression %f, got %d and %f", testCase.json, testCase.radius, testCase.suppression, r.GetRadius(), r.Suppression)
+		}
+	}
+}
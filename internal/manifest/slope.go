@@ -0,0 +1,97 @@
+package manifest
+
+import "math"
+
+// OpenTTD slope corner flags
+const (
+	slopeW     = 1
+	slopeS     = 2
+	slopeE     = 4
+	slopeN     = 8
+	slopeSteep = 16
+)
+
+// Corner order used for slope heights
+const (
+	CornerN = iota
+	CornerE
+	CornerS
+	CornerW
+)
+
+// The slopes in the order OpenTTD expects them in a ground sprite set: the 15
+// regular slopes followed by steep N, S, W and E.
+var SlopeSpriteOrder = []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 29, 23, 27, 30}
+
+// Get how many height levels each corner of an OpenTTD slope is raised by,
+// in N, E, S, W order
+func GetSlopeCornerLevels(slope int) (levels [4]int) {
+	flags := []int{slopeN, slopeE, slopeS, slopeW}
+
+	for i, f := range flags {
+		if slope&f != 0 {
+			levels[i] = 1
+		}
+	}
+
+	// Steep slopes raise the corner opposite the lowered one by a further level
+	if slope&slopeSteep != 0 {
+		for i := range levels {
+			if levels[i] == 0 {
+				levels[(i+2)%4] = 2
+			}
+		}
+	}
+
+	return
+}
+
+// Replace each tile sprite with the full set of slope variants, and make room
+// in the render area for raised corners
+func (m *Manifest) ExpandSlopes() {
+	if !m.usesSlopes() {
+		return
+	}
+
+	// Default to the height which raises a corner by 1/8 of the tile width when a
+	// square tile is viewed at 45 degrees, which is how OpenTTD draws slopes
+	if m.SlopeHeight == 0 {
+		m.SlopeHeight = int(math.Round(m.Size.X * math.Sqrt2 / 8))
+	}
+
+	if !m.RenderSlopes {
+		return
+	}
+
+	sprites := make([]Sprite, 0, len(m.Sprites))
+
+	for _, spr := range m.Sprites {
+		if spr.Type != "tile" {
+			sprites = append(sprites, spr)
+			continue
+		}
+
+		for _, slope := range SlopeSpriteOrder {
+			sloped := spr
+			sloped.Slope = slope
+			sprites = append(sprites, sloped)
+		}
+	}
+
+	m.Sprites = sprites
+	m.Size.Z += float64(m.SlopeHeight * 2)
+}
+
+func (m *Manifest) usesSlopes() bool {
+	if m.RenderSlopes {
+		return true
+	}
+
+	for _, spr := range m.Sprites {
+		if spr.Slope != 0 {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,56 @@
+package manifest
+
+import (
+	"github.com/mattkimber/gorender/internal/geometry"
+	"testing"
+)
+
+func TestGetSlopeCornerLevels(t *testing.T) {
+	testCases := []struct {
+		slope    int
+		expected [4]int
+	}{
+		{0, [4]int{0, 0, 0, 0}},
+		{1, [4]int{0, 0, 0, 1}},
+		{9, [4]int{1, 0, 0, 1}},
+		{14, [4]int{1, 1, 1, 0}},
+		{23, [4]int{0, 1, 2, 1}},
+		{27, [4]int{1, 0, 1, 2}},
+		{29, [4]int{2, 1, 0, 1}},
+		{30, [4]int{1, 2, 1, 0}},
+	}
+
+	for _, testCase := range testCases {
+		if result := GetSlopeCornerLevels(testCase.slope); result != testCase.expected {
+			t.Errorf("Slope %d expected corner levels %v, got %v", testCase.slope, testCase.expected, result)
+		}
+	}
+}
+
+func TestManifest_ExpandSlopes(t *testing.T) {
+	m := Manifest{
+		RenderSlopes: true,
+		Size:         geometry.Vector3{X: 64, Y: 64, Z: 8},
+		Sprites:      []Sprite{{Angle: 45, Width: 64, Type: "tile"}, {Angle: 90, Width: 32}},
+	}
+
+	m.ExpandSlopes()
+
+	if len(m.Sprites) != 20 {
+		t.Fatalf("Expected 20 sprites, got %d", len(m.Sprites))
+	}
+
+	for i, slope := range SlopeSpriteOrder {
+		if m.Sprites[i].Slope != slope || m.Sprites[i].Angle != 45 {
+			t.Errorf("Sprite %d expected slope %d, got %v", i, slope, m.Sprites[i])
+		}
+	}
+
+	if m.Sprites[19].Slope != 0 || m.Sprites[19].Angle != 90 {
+		t.Errorf("Non-tile sprite should not be expanded, got %v", m.Sprites[19])
+	}
+
+	if m.SlopeHeight != 11 || m.Size.Z != 30 {
+		t.Errorf("Expected slope height 11 and size 30, got %d and %f", m.SlopeHeight, m.Size.Z)
+	}
+}
@@ -0,0 +1,38 @@
+package manifest
+
+// Settings for rendering a snow-covered version of every sprite, for arctic climates. Snow
+// settles on voxels which face upwards, in the same places from every angle.
+type Snow struct {
+	Threshold float64 `json:"threshold"`
+	Coverage  float64 `json:"coverage"`
+	Noise     float64 `json:"noise"`
+	Index     byte    `json:"index"`
+}
+
+// How far upwards a voxel must face for snow to settle on it, from 0 (vertical) to 1 (flat)
+func (s Snow) GetThreshold() float64 {
+	if s.Threshold == 0 {
+		return 0.6
+	}
+
+	return s.Threshold
+}
+
+// The proportion of upward-facing voxels covered in snow
+func (s Snow) GetCoverage() float64 {
+	if s.Coverage == 0 {
+		return 1
+	}
+
+	return s.Coverage
+}
+
+// The palette index snow is drawn with. The default is the lightest grey but one of the
+// default palette, so lighting can make it whiter.
+func (s Snow) GetIndex() byte {
+	if s.Index == 0 {
+		return 14
+	}
+
+	return s.Index
+}
@@ -0,0 +1,85 @@
+package manifest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A named set of outputs, chosen with -target, so outputs such as sprites for the game and
+// larger previews can be rendered from one manifest
+type Target struct {
+	// Formats of spritesheets, used in place of the manifest's format for the same spritesheet
+	OutputFormats map[string]string `json:"output_formats"`
+
+	// Scales to render, in place of those given with -scale
+	Scales []float64 `json:"scales"`
+
+	// Names of the manifest's layers to output. All layers are output if this is not set, and
+	// none if it is an empty list.
+	Layers []string `json:"layers"`
+}
+
+// Get the manifest with a target's output formats and layers. An empty name gets the
+// manifest unchanged.
+func (m Manifest) GetTargetManifest(name string) (Manifest, error) {
+	if name == "" {
+		return m, nil
+	}
+
+	t, ok := m.Targets[name]
+	if !ok {
+		return m, fmt.Errorf("unknown target %s", name)
+	}
+
+	if len(t.OutputFormats) > 0 {
+		formats := make(map[string]string)
+		for key, format := range m.OutputFormats {
+			formats[key] = format
+		}
+
+		for key, format := range t.OutputFormats {
+			formats[key] = format
+		}
+
+		m.OutputFormats = formats
+	}
+
+	if t.Layers != nil {
+		layers := make([]Layer, 0, len(t.Layers))
+		for _, l := range m.Layers {
+			if t.hasLayer(l.Name) {
+				layers = append(layers, l)
+			}
+		}
+
+		m.Layers = layers
+	}
+
+	return m, nil
+}
+
+// Get the target's scales as they would be given to -scale, or an empty string if it
+// doesn't set any. Whole numbers keep one decimal place, as in "1.0,2.0".
+func (t Target) GetScales() string {
+	scales := make([]string, len(t.Scales))
+	for i, s := range t.Scales {
+		if s == float64(int(s)) {
+			scales[i] = strconv.FormatFloat(s, 'f', 1, 64)
+		} else {
+			scales[i] = strconv.FormatFloat(s, 'f', -1, 64)
+		}
+	}
+
+	return strings.Join(scales, ",")
+}
+
+func (t Target) hasLayer(name string) bool {
+	for _, l := range t.Layers {
+		if l == name {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,47 @@
+package manifest
+
+import (
+	"strings"
+	"testing"
+)
+
+const targetsJson = `{"output_formats":{"8bpp":"png","32bpp":"png"},"layers":[{"name":"lights"},{"name":"glass"}],
+	"targets":{"game":{"scales":[1,2,0.5],"layers":["glass"]},"preview":{"output_formats":{"32bpp":"webp"},"layers":[]}}}`
+
+func TestManifest_GetTargetManifest(t *testing.T) {
+	m, err := FromJson(strings.NewReader(targetsJson))
+	if err != nil {
+		t.Fatalf("could not read manifest: %v", err)
+	}
+
+	game, err := m.GetTargetManifest("game")
+	if err != nil {
+		t.Fatalf("could not get target: %v", err)
+	}
+
+	if len(game.Layers) != 1 || game.Layers[0].Name != "glass" || game.OutputFormats["32bpp"] != "png" {
+		t.Errorf("game: expected only the glass layer and the manifest's formats, got %v and %v", game.Layers, game.OutputFormats)
+	}
+
+	if scales := m.Targets["game"].GetScales(); scales != "1.0,2.0,0.5" {
+		t.Errorf("game: expected scales 1.0,2.0,0.5, got %s", scales)
+	}
+
+	preview, _ := m.GetTargetManifest("preview")
+	if len(preview.Layers) != 0 || preview.OutputFormats["32bpp"] != "webp" || preview.OutputFormats["8bpp"] != "png" {
+		t.Errorf("preview: expected no layers and webp 32bpp, got %v and %v", preview.Layers, preview.OutputFormats)
+	}
+
+	// The manifest's own settings are not changed
+	if len(m.Layers) != 2 || m.OutputFormats["32bpp"] != "png" {
+		t.Errorf("expected manifest to be unchanged, got %v and %v", m.Layers, m.OutputFormats)
+	}
+
+	if _, err := m.GetTargetManifest("debug"); err == nil {
+		t.Errorf("expected error for unknown target")
+	}
+
+	if unchanged, _ := m.GetTargetManifest(""); len(unchanged.Layers) != 2 {
+		t.Errorf("expected no target to leave the manifest unchanged, got %v", unchanged.Layers)
+	}
+}
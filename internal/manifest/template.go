@@ -0,0 +1,127 @@
+package manifest
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The position and size of a sprite in a spritesheet template, at 1x scale
+type TemplateCell struct {
+	X, Width, Height int
+}
+
+// A template places sprites and names spritesheets to match the sprite templates used
+// by existing projects, so output can be used without moving sprites or renaming files
+type Template struct {
+	Cells []TemplateCell
+
+	// File name suffixes for kinds of spritesheet which aren't named "_" followed by
+	// the kind, e.g. "_8bpp"
+	Suffixes map[string]string
+
+	// Simutrans direction of the sprite in each cell, for templates whose spritesheets
+	// are referenced from Simutrans .dat files
+	Directions []string
+
+	// Image formats of kinds of spritesheet which aren't written as PNG, from the manifest
+	Formats map[string]string
+}
+
+var templates = map[string]Template{
+	// tmpl_vehicle_basic from the NML tutorial, used by many road vehicle and train sets.
+	// The 8bpp spritesheet is the one referenced by the template, so has no suffix.
+	"nml_vehicle": {
+		Cells: []TemplateCell{
+			{0, 8, 24}, {9, 22, 20}, {32, 32, 16}, {65, 22, 20},
+			{88, 8, 24}, {97, 22, 20}, {120, 32, 16}, {153, 22, 20},
+		},
+		Suffixes: map[string]string{"8bpp": ""},
+	},
+	// Simutrans vehicle images, one 64x64 tile per direction in the order makeobj examples
+	// use (S, N, E, W, SE, NW, NE, SW). Sprites are still given in the usual order of 0 to
+	// 315 degrees, so each is placed in the tile for the direction it faces on screen.
+	// Used with the Simutrans palette, the 8bpp spritesheet is a pak-ready image.
+	"simutrans": {
+		Cells: []TemplateCell{
+			{320, 64, 64}, {64, 64, 64}, {384, 64, 64}, {128, 64, 64},
+			{256, 64, 64}, {0, 64, 64}, {448, 64, 64}, {192, 64, 64},
+		},
+		Suffixes:   map[string]string{"8bpp": ""},
+		Directions: []string{"NW", "N", "NE", "E", "SE", "S", "SW", "W"},
+	},
+}
+
+// Set the canvas size of sprites to the size of their cell in the template, unless the
+// sprite sets its own. Sprites can't be drawn outside their canvas, but a canvas wider
+// than the cell would overwrite the neighbouring cells, so is an error. Mismatched numbers
+// of sprites are left for Validate to report.
+func (m *Manifest) applyTemplate() error {
+	if m.Template == "" {
+		return nil
+	}
+
+	t, ok := templates[m.Template]
+	if !ok {
+		return fmt.Errorf("unknown template %s", m.Template)
+	}
+
+	for i := range m.Sprites {
+		if i >= len(t.Cells) {
+			break
+		}
+
+		if m.Sprites[i].CanvasWidth == 0 {
+			m.Sprites[i].CanvasWidth = t.Cells[i].Width
+		}
+
+		if m.Sprites[i].CanvasHeight == 0 {
+			m.Sprites[i].CanvasHeight = t.Cells[i].Height
+		}
+	}
+
+	return errors.Join(t.getOverlaps(m.Sprites)...)
+}
+
+// Get an error for each sprite whose canvas overlaps the cell of another sprite. Cells
+// are side by side, so only their horizontal extent can overlap.
+func (t Template) getOverlaps(sprites []Sprite) (errs []error) {
+	cells := t.Cells[:min(len(sprites), len(t.Cells))]
+	for i, cell := range cells {
+		width, _ := sprites[i].GetCanvasSize()
+		from, to := cell.X, cell.X+width
+
+		for j, c := range cells {
+			if overlap := min(to, c.X+c.Width) - max(from, c.X); j != i && overlap > 0 {
+				errs = append(errs, fmt.Errorf("sprite %d overlaps the template cell of sprite %d by %d pixels", i, j, overlap))
+			}
+		}
+	}
+
+	return
+}
+
+// Get the manifest's template, or an empty template if it doesn't use one, with the
+// manifest's output formats
+func (m Manifest) GetTemplate() Template {
+	t := templates[m.Template]
+	t.Formats = m.OutputFormats
+	return t
+}
+
+// Get the image format of a kind of spritesheet, e.g. "8bpp"
+func (t Template) GetFormat(key string) string {
+	if format, ok := t.Formats[key]; ok {
+		return format
+	}
+
+	return "png"
+}
+
+// Get the file name of a kind of spritesheet, e.g. "8bpp", with the given base name
+func (t Template) GetFilename(baseFilename string, key string) string {
+	if suffix, ok := t.Suffixes[key]; ok {
+		return baseFilename + suffix + "." + t.GetFormat(key)
+	}
+
+	return baseFilename + "_" + key + "." + t.GetFormat(key)
+}
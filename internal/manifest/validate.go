@@ -0,0 +1,445 @@
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/mattkimber/gorender/internal/colour"
+	"io"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Check a manifest for settings which are missing or out of range. All problems found
+// are returned rather than stopping at the first. Unknown settings are not errors, as
+// older manifests may contain settings which are no longer used, but are returned as
+// warnings since they are often misspelled.
+func Validate(handle io.Reader) (warnings []string, errs []error) {
+	data, err := io.ReadAll(handle)
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	m, err := FromJson(bytes.NewReader(data))
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	raw := struct {
+		Settings map[string]json.RawMessage
+		Sprites  []map[string]json.RawMessage `json:"sprites"`
+		Objects  []struct {
+			Sprites []map[string]json.RawMessage `json:"sprites"`
+		} `json:"objects"`
+	}{}
+
+	_ = json.Unmarshal(data, &raw.Settings)
+	_ = json.Unmarshal(data, &raw)
+
+	warnings = append(warnings, getUnknownFields(raw.Settings, Manifest{})...)
+
+	for i, spr := range raw.Sprites {
+		for _, unknown := range getUnknownFields(spr, Sprite{}) {
+			warnings = append(warnings, fmt.Sprintf("sprite %d: %s", i, unknown))
+		}
+	}
+
+	for i, o := range raw.Objects {
+		for j, spr := range o.Sprites {
+			for _, unknown := range getUnknownFields(spr, Sprite{}) {
+				warnings = append(warnings, fmt.Sprintf("object %d: sprite %d: %s", i, j, unknown))
+			}
+		}
+	}
+
+	return warnings, m.Validate()
+}
+
+// Get a warning for each unknown field, suggesting the closest known field if the
+// name looks like a misspelling of it
+func getUnknownFields(fields map[string]json.RawMessage, v interface{}) (unknown []string) {
+	known := make([]string, 0)
+	t := reflect.TypeOf(v)
+	for i := 0; i < t.NumField(); i++ {
+		if name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ","); name != "" && name != "-" {
+			known = append(known, name)
+		}
+	}
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		if !slices.Contains(known, name) {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		if suggestion := getSuggestion(name, known); suggestion != "" {
+			unknown = append(unknown, fmt.Sprintf("unknown setting %s (did you mean %s?)", name, suggestion))
+		} else {
+			unknown = append(unknown, fmt.Sprintf("unknown setting %s", name))
+		}
+	}
+
+	return
+}
+
+func (m Manifest) Validate() (errs []error) {
+	if m.Size.X <= 0 || m.Size.Y <= 0 || m.Size.Z <= 0 {
+		errs = append(errs, fmt.Errorf("size must be set in all dimensions"))
+	}
+
+	// A manifest's own sprites are only needed by objects which don't have sprites
+	needsSprites := len(m.Objects) == 0
+	for _, o := range m.Objects {
+		needsSprites = needsSprites || len(o.Sprites) == 0
+	}
+
+	if len(m.Sprites) == 0 && needsSprites {
+		errs = append(errs, fmt.Errorf("no sprites"))
+	}
+
+	if m.Accuracy < 1 {
+		errs = append(errs, fmt.Errorf("accuracy must be at least 1"))
+	}
+
+	if m.AdaptiveThreshold < 0 {
+		errs = append(errs, fmt.Errorf("adaptive threshold must not be negative"))
+	}
+
+	if m.NearClip < 0 || m.FarClip < 0 || m.MaxRayDistance < 0 {
+		errs = append(errs, fmt.Errorf("clipping distances must not be negative"))
+	}
+
+	if m.FarClip > 0 && m.FarClip <= m.NearClip {
+		errs = append(errs, fmt.Errorf("far clip must be further than near clip"))
+	}
+
+	if t := m.GetTemplate(); m.Template != "" {
+		if len(m.Sprites) != len(t.Cells) {
+			errs = append(errs, fmt.Errorf("template %s needs %d sprites, not %d", m.Template, len(t.Cells), len(m.Sprites)))
+		}
+
+		if m.Deduplicate {
+			errs = append(errs, fmt.Errorf("deduplicate cannot be used with a template, as sprites must stay in their place"))
+		}
+	}
+
+	for key, format := range m.OutputFormats {
+		if format != "png" && format != "tga" && format != "bmp" && format != "webp" {
+			errs = append(errs, fmt.Errorf("unknown output format %s for %s", format, key))
+		}
+	}
+
+	targetNames := make([]string, 0, len(m.Targets))
+	for name := range m.Targets {
+		targetNames = append(targetNames, name)
+	}
+
+	sort.Strings(targetNames)
+	for _, name := range targetNames {
+		t := m.Targets[name]
+		keys := make([]string, 0, len(t.OutputFormats))
+		for key := range t.OutputFormats {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+		for _, key := range keys {
+			if format := t.OutputFormats[key]; format != "png" && format != "tga" && format != "bmp" && format != "webp" {
+				errs = append(errs, fmt.Errorf("target %s: unknown output format %s for %s", name, format, key))
+			}
+		}
+
+		for _, s := range t.Scales {
+			if s <= 0 {
+				errs = append(errs, fmt.Errorf("target %s: scales must be greater than 0", name))
+				break
+			}
+		}
+
+		for _, l := range t.Layers {
+			if !slices.ContainsFunc(m.Layers, func(layer Layer) bool { return layer.Name == l }) {
+				errs = append(errs, fmt.Errorf("target %s: unknown layer %s", name, l))
+			}
+		}
+	}
+
+	if m.Atlas != "" && m.Atlas != "texturepacker" && m.Atlas != "godot" {
+		errs = append(errs, fmt.Errorf("unknown atlas format %s", m.Atlas))
+	}
+
+	if m.MaxColours < 0 {
+		errs = append(errs, fmt.Errorf("max_colours must not be negative"))
+	}
+
+	if m.DespeckleThreshold < 0 {
+		errs = append(errs, fmt.Errorf("despeckle_threshold must not be negative"))
+	}
+
+	for _, kind := range m.ColourBlindPreviews {
+		if !colour.IsColourBlindness(kind) {
+			errs = append(errs, fmt.Errorf("unknown colour blindness type %s", kind))
+		}
+	}
+
+	if m.Sampler != "" && m.Sampler != "square" && m.Sampler != "disc" {
+		errs = append(errs, fmt.Errorf("unknown sampler %s", m.Sampler))
+	}
+
+	if m.Raycaster != "" && m.Raycaster != "sampler" && m.Raycaster != "beam" {
+		errs = append(errs, fmt.Errorf("unknown raycaster %s", m.Raycaster))
+	}
+
+	if m.TransparencyDither != "" && m.TransparencyDither != "ordered" && m.TransparencyDither != "random" {
+		errs = append(errs, fmt.Errorf("unknown transparency dither %s", m.TransparencyDither))
+	}
+
+	if m.AlternateModal != "" && m.AlternateModal != "off" && m.AlternateModal != "same_range" && m.AlternateModal != "any" {
+		errs = append(errs, fmt.Errorf("unknown alternate modal %s", m.AlternateModal))
+	}
+
+	if m.ColourTemperature != 0 && (m.ColourTemperature < 1000 || m.ColourTemperature > 40000) {
+		errs = append(errs, fmt.Errorf("colour temperature must be between 1000 and 40000"))
+	}
+
+	if m.Tint < -1 || m.Tint > 1 {
+		errs = append(errs, fmt.Errorf("tint must be between -1 and 1"))
+	}
+
+	if m.Noise < 0 || m.Noise > 1 {
+		errs = append(errs, fmt.Errorf("noise must be between 0 and 1"))
+	}
+
+	if m.Dirt < 0 || m.Dirt > 1 {
+		errs = append(errs, fmt.Errorf("dirt must be between 0 and 1"))
+	}
+
+	if m.JoinOverlap < 0 {
+		errs = append(errs, fmt.Errorf("join overlap must not be negative"))
+	}
+
+	if m.Sharpen < 0 || m.SharpenRadius < 0 {
+		errs = append(errs, fmt.Errorf("sharpen and sharpen radius must not be negative"))
+	}
+
+	switch m.ToneMapping {
+	case "", "reinhard", "filmic", "aces":
+	default:
+		errs = append(errs, fmt.Errorf("unknown tone mapping %s", m.ToneMapping))
+	}
+
+	switch m.TilingMode {
+	case "normal", "repeat", "reflect", "reflect101":
+	default:
+		errs = append(errs, fmt.Errorf("unknown tiling mode %s", m.TilingMode))
+	}
+
+	for i, spr := range m.Sprites {
+		if spr.Width <= 0 {
+			errs = append(errs, fmt.Errorf("sprite %d: width must be set", i))
+		}
+
+		if spr.Height < 0 {
+			errs = append(errs, fmt.Errorf("sprite %d: height must not be negative", i))
+		}
+
+		if spr.CanvasWidth < 0 || spr.CanvasHeight < 0 {
+			errs = append(errs, fmt.Errorf("sprite %d: canvas size must not be negative", i))
+		}
+
+		if spr.Zoom < 0 {
+			errs = append(errs, fmt.Errorf("sprite %d: zoom must not be negative", i))
+		}
+
+		if spr.Supersample < 0 {
+			errs = append(errs, fmt.Errorf("sprite %d: supersample must not be negative", i))
+		}
+
+		for j, b := range spr.ClipBoxes {
+			if b.From.X > b.To.X || b.From.Y > b.To.Y || b.From.Z > b.To.Z {
+				errs = append(errs, fmt.Errorf("sprite %d: clip box %d: from must not be after to", i, j))
+			}
+		}
+
+		if c := spr.CrossSection; c != nil {
+			if c.Axis != "x" && c.Axis != "y" && c.Axis != "z" {
+				errs = append(errs, fmt.Errorf("sprite %d: unknown cross section axis %s", i, c.Axis))
+			}
+
+			if c.From < 0 || (c.To != 0 && c.To < c.From) {
+				errs = append(errs, fmt.Errorf("sprite %d: cross section must not be negative or end before it starts", i))
+			}
+		}
+	}
+
+	if p := m.Purchase; p != nil {
+		if p.Width <= 0 {
+			errs = append(errs, fmt.Errorf("purchase: width must be set"))
+		}
+
+		if p.Height < 0 || p.CanvasWidth < 0 || p.CanvasHeight < 0 || p.Zoom < 0 {
+			errs = append(errs, fmt.Errorf("purchase: height, canvas size and zoom must not be negative"))
+		}
+	}
+
+	if i := m.Icon; i != nil {
+		if i.Width <= 0 {
+			errs = append(errs, fmt.Errorf("icon: width must be set"))
+		}
+
+		if i.Height < 0 || i.Zoom < 0 || i.Supersample < 0 || i.Sharpen < 0 {
+			errs = append(errs, fmt.Errorf("icon: height, zoom, supersample and sharpen must not be negative"))
+		}
+	}
+
+	for _, layer := range m.Layers {
+		if layer.Name == "" {
+			errs = append(errs, fmt.Errorf("layer has no name"))
+		}
+
+		for _, r := range layer.Ranges {
+			if r.Start > r.End {
+				errs = append(errs, fmt.Errorf("layer %s: range %d-%d ends before it starts", layer.Name, r.Start, r.End))
+			}
+		}
+	}
+
+	if b := m.BrightnessRemap; b != nil {
+		for _, v := range []float64{b.SourceMin, b.SourceMax, b.TargetMin, b.TargetMax} {
+			if v < 0 || v > 1 {
+				errs = append(errs, fmt.Errorf("brightness remap: values must be between 0 and 1"))
+				break
+			}
+		}
+
+		if b.SourceMin >= b.SourceMax {
+			errs = append(errs, fmt.Errorf("brightness remap: source_min must be below source_max"))
+		}
+	}
+
+	for i, g := range m.HeightGradients {
+		if g.Start > g.End {
+			errs = append(errs, fmt.Errorf("height gradient %d: range %d-%d ends before it starts", i, g.Start, g.End))
+		}
+	}
+
+	liveryNames := make(map[string]bool)
+	for _, livery := range m.Liveries {
+		if livery.Name == "" {
+			errs = append(errs, fmt.Errorf("livery has no name"))
+		} else if liveryNames[livery.Name] {
+			errs = append(errs, fmt.Errorf("livery %s: name is used more than once", livery.Name))
+		}
+
+		liveryNames[livery.Name] = true
+
+		errs = append(errs, validateRemaps("livery "+livery.Name, livery.Remap)...)
+	}
+
+	if s := m.Snow; s != nil {
+		if s.Threshold < 0 || s.Threshold > 1 || s.Coverage < 0 || s.Coverage > 1 || s.Noise < 0 || s.Noise > 1 {
+			errs = append(errs, fmt.Errorf("snow: threshold, coverage and noise must be between 0 and 1"))
+		}
+	}
+
+	if a := m.AutoSize; a != nil && a.GetMargin() < 0 {
+		errs = append(errs, fmt.Errorf("auto size: margin cannot be negative"))
+	}
+
+	if m.FadeToBlack && m.FadeToColour != 0 {
+		errs = append(errs, fmt.Errorf("fade_to_black and fade_to_colour cannot both be set"))
+	}
+
+	for _, r := range m.DetailBoostRanges {
+		if r.Start > r.End {
+			errs = append(errs, fmt.Errorf("detail boost range %d-%d ends before it starts", r.Start, r.End))
+		}
+	}
+
+	for i, b := range m.DetailBoostBoxes {
+		if b.From.X > b.To.X || b.From.Y > b.To.Y || b.From.Z > b.To.Z {
+			errs = append(errs, fmt.Errorf("detail boost box %d: from must not be after to", i))
+		}
+	}
+
+	if r := m.RecoveredVoxels; r != nil {
+		if r.Radius < 0 || r.Radius > maxRecoveryRadius {
+			errs = append(errs, fmt.Errorf("recovered voxels: radius must be between 0 and %d", maxRecoveryRadius))
+		}
+
+		if r.Suppression < 0 || r.Suppression > 1 {
+			errs = append(errs, fmt.Errorf("recovered voxels: suppression must be between 0 and 1"))
+		}
+	}
+
+	if n := m.Night; n != nil {
+		if n.ColourTemperature != 0 && (n.ColourTemperature < 1000 || n.ColourTemperature > 40000) {
+			errs = append(errs, fmt.Errorf("night: colour temperature must be between 1000 and 40000"))
+		}
+
+		errs = append(errs, validateRemaps("night lights", n.Lights)...)
+	}
+
+	classNames := make([]string, 0, len(m.ColourClasses))
+	for name := range m.ColourClasses {
+		classNames = append(classNames, name)
+	}
+
+	sort.Strings(classNames)
+	for _, name := range classNames {
+		if b := m.ColourClasses[name].Brightness; b < -1 || b > 1 {
+			errs = append(errs, fmt.Errorf("colour class %s: brightness must be between -1 and 1", name))
+		}
+
+		if g := m.ColourClasses[name].Gloss; g < 0 || g > 1 {
+			errs = append(errs, fmt.Errorf("colour class %s: gloss must be between 0 and 1", name))
+		}
+	}
+
+	errs = append(errs, m.validateObjects(errs)...)
+	return
+}
+
+// Check each object rendered with the manifest, reporting problems with its sprites which
+// are not already in the errors found for the manifest
+func (m Manifest) validateObjects(manifestErrs []error) (errs []error) {
+	found := make(map[string]bool)
+	for _, err := range manifestErrs {
+		found[err.Error()] = true
+	}
+
+	for i, o := range m.Objects {
+		if o.File == "" {
+			errs = append(errs, fmt.Errorf("object %d has no file", i))
+			continue
+		}
+
+		if len(o.Sprites) == 0 {
+			continue
+		}
+
+		for _, err := range m.GetObjectManifest(i).Validate() {
+			if !found[err.Error()] {
+				errs = append(errs, fmt.Errorf("object %s: %v", o.File, err))
+			}
+		}
+	}
+
+	return
+}
+
+func validateRemaps(name string, remaps []Remap) (errs []error) {
+	for _, r := range remaps {
+		if r.Start > r.End {
+			errs = append(errs, fmt.Errorf("%s: remap %d-%d ends before it starts", name, r.Start, r.End))
+		} else if int(r.To)+int(r.End-r.Start) > 255 {
+			errs = append(errs, fmt.Errorf("%s: remap %d-%d to %d runs past the end of the palette", name, r.Start, r.End, r.To))
+		}
+	}
+
+	return
+}
@@ -0,0 +1,98 @@
+package manifest
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		json                             string
+		expectedWarnings, expectedErrors int
+	}{
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}]}`, 0, 0},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8,"angel":45}],"lighting_angel":60}`, 2, 0},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"accuracy":"high"}`, 0, 1},
+		{`{"sprites":[]}`, 0, 2},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":0},{"width":8,"height":-1}]}`, 0, 2},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8,"zoom":-1}]}`, 0, 1},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8,"canvas_width":-1}]}`, 0, 1},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"sampler":"hexagon","tiling_mode":"wrap"}`, 0, 2},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"raycaster":"beam"}`, 0, 0},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"raycaster":"cone"}`, 0, 1},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"adaptive_threshold":-0.1}`, 0, 1},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"tone_mapping":"aces","exposure":-1}`, 0, 0},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"tone_mapping":"hdr"}`, 0, 1},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"colour_temperature":100,"tint":2}`, 0, 2},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"sharpen":0.5,"sharpen_radius":2}`, 0, 0},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"sharpen":-1}`, 0, 1},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8,"seed":3},{"width":8,"seed":-1}],"noise":0.5}`, 0, 0},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"noise":2}`, 0, 1},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"night":{"exposure":-2,"lights":[{"start":100,"end":103,"to":232}]}}`, 0, 0},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"night":{"colour_temperature":100,"lights":[{"start":100,"end":90}]}}`, 0, 2},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"snow":{"threshold":0.5,"coverage":0.9,"noise":0.3}}`, 0, 0},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"snow":{"coverage":1.5}}`, 0, 1},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"recovered_voxels":{"radius":2,"suppression":0.5,"ignore_normals":true}}`, 0, 0},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"recovered_voxels":{"radius":4,"suppression":-1}}`, 0, 2},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"fade_to_colour":74}`, 0, 0},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"fade_to_black":true,"fade_to_colour":74}`, 0, 1},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"transparency_dither":"ordered"}`, 0, 0},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"transparency_dither":"checkerboard"}`, 0, 1},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"alternate_modal":"any"}`, 0, 0},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"alternate_modal":"always"}`, 0, 1},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"detail_boost":1,"detail_boost_ranges":[{"start":16,"end":23}],"detail_boost_boxes":[{"from":{"x":0,"y":0,"z":0},"to":{"x":1,"y":1,"z":1}}]}`, 0, 0},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"detail_boost":1,"detail_boost_ranges":[{"start":23,"end":16}],"detail_boost_boxes":[{"from":{"x":2,"y":0,"z":0},"to":{"x":1,"y":1,"z":1}}]}`, 0, 2},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"dirt":1.5}`, 0, 1},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"auto_size":{"margin":2}}`, 0, 0},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"auto_size":{"margin":-1}}`, 0, 1},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"join_overlap":0.5}`, 0, 0},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"join_overlap":-1}`, 0, 1},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"liveries":[{"name":"red","remap":[{"start":198,"end":205,"to":180}]},{"name":"blue"}]}`, 0, 0},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"liveries":[{"name":"red","remap":[{"start":205,"end":198},{"start":10,"end":20,"to":250}]},{"name":"red"},{}]}`, 0, 4},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8,"supersample":4},{"width":8,"supersample":-2}]}`, 0, 1},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8,"clip_boxes":[{"from":{"x":0,"y":0,"z":0},"to":{"x":4,"y":2,"z":8}}]}]}`, 0, 0},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8,"clip_boxes":[{"from":{"x":5,"y":0,"z":0},"to":{"x":4,"y":2,"z":8}}]}]}`, 0, 1},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8,"cross_section":{"axis":"y","from":4,"hatch":15}}]}`, 0, 0},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8,"cross_section":{"axis":"w","from":4}}]}`, 0, 1},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8,"cross_section":{"axis":"x","from":4,"to":2}}]}`, 0, 1},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"height_gradients":[{"start":80,"end":87,"top":2}]}`, 0, 0},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"height_gradients":[{"start":87,"end":80,"top":2}]}`, 0, 1},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"brightness_remap":{"source_min":0.1,"source_max":0.7,"target_max":1}}`, 0, 0},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"brightness_remap":{"source_min":0.7,"source_max":0.1,"target_max":2}}`, 0, 2},
+		{`{"size":{"x":1,"y":1,"z":1},"objects":[{"file":"a.vox","sprites":[{"width":8}]},{"file":"b.vox","sprites":[{"width":8,"wdth":4}]}]}`, 1, 0},
+		{`{"size":{"x":1,"y":1,"z":1},"objects":[{"file":"a.vox","sprites":[{"width":8}]},{"file":"b.vox"}]}`, 0, 1},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"layers":[{"name":"lights"}],"targets":{"game":{"scales":[1,2],"layers":["lights"]},"preview":{"output_formats":{"32bpp":"webp"},"layers":[]}}}`, 0, 0},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"targets":{"game":{"scales":[0],"layers":["lights"],"output_formats":{"8bpp":"gif"}}}}`, 0, 3},
+		{`{"size":{"x":1,"y":1,"z":1},"objects":[{"sprites":[{"width":8}]},{"file":"b.vox","sprites":[{"width":-8}]}]}`, 0, 3},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"template":"nml_vehicle","deduplicate":true}`, 0, 2},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"atlas":"godot"}`, 0, 0},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"colour_blind_previews":["deuteranopia","tritanopia"]}`, 0, 0},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"colour_blind_previews":["monochrome"]}`, 0, 1},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"max_colours":16}`, 0, 0},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"max_colours":-1}`, 0, 1},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"despeckle_threshold":3}`, 0, 0},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"despeckle_threshold":-3}`, 0, 1},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"atlas":"unity"}`, 0, 1},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"output_formats":{"8bpp":"bmp","32bpp":"tga","mask":"webp"}}`, 0, 0},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"output_formats":{"32bpp":"jpeg"}}`, 0, 1},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"purchase":{"angle":225,"width":32}}`, 0, 0},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"purchase":{"height":-1}}`, 0, 2},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"icon":{"width":24,"supersample":-2}}`, 0, 1},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"near_clip":-1,"far_clip":2}`, 0, 1},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"near_clip":4,"far_clip":2}`, 0, 1},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"layers":[{"ranges":[{"start":10,"end":5}]}]}`, 0, 2},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"colour_classes":{"glow":{"unlit":true,"brightness":0.5},"dim":{"brightness":-2}}}`, 0, 1},
+		{`{"size":{"x":1,"y":1,"z":1},"sprites":[{"width":8}],"gloss_map":true,"colour_classes":{"chrome":{"gloss":0.8},"wet":{"gloss":1.5}}}`, 0, 1},
+	}
+
+	for _, testCase := range testCases {
+		warnings, errs := Validate(strings.NewReader(testCase.json))
+		if len(warnings) != testCase.expectedWarnings {
+			t.Errorf("%s expected %d warnings, got %v", testCase.json, testCase.expectedWarnings, warnings)
+		}
+
+		if len(errs) != testCase.expectedErrors {
+			t.Errorf("%s expected %d errors, got %v", testCase.json, testCase.expectedErrors, errs)
+		}
+	}
+}
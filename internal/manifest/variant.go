@@ -0,0 +1,56 @@
+package manifest
+
+// A variant renders every sprite again with different colours or lighting, shading the same
+// raycast output as the main sprites
+type Variant struct {
+	Suffix   string
+	Manifest Manifest
+	Remap    *[256]byte
+	Snow     *Snow
+}
+
+// Get the variants to render after the main sprites: each livery, then if the manifest has
+// snow settings a snow-covered version, and if it has night settings a night version, of the
+// main sprites and of each livery
+func (m Manifest) GetVariants() (variants []Variant) {
+	liveries := []Variant{{Manifest: m}}
+	for _, livery := range m.Liveries {
+		liveries = append(liveries, Variant{Suffix: "_" + livery.Name, Manifest: m, Remap: livery.GetRemap()})
+	}
+
+	for _, livery := range liveries {
+		climates := []Variant{livery}
+		if m.Snow != nil {
+			snowy := livery
+			snowy.Suffix, snowy.Snow = livery.Suffix+"_snow", m.Snow
+			climates = append(climates, snowy)
+		}
+
+		for _, v := range climates {
+			// The main sprites are not a variant
+			if v.Suffix != "" {
+				variants = append(variants, v)
+			}
+
+			if m.Night != nil {
+				variants = append(variants, v.getNightVariant(m))
+			}
+		}
+	}
+
+	return
+}
+
+func (v Variant) getNightVariant(m Manifest) Variant {
+	// Lights are mapped after the livery, so they apply to the livery's own colours
+	lights := Livery{Remap: m.Night.Lights}.GetRemap()
+	remap := lights
+	if v.Remap != nil {
+		remap = new([256]byte)
+		for j, index := range v.Remap {
+			remap[j] = lights[index]
+		}
+	}
+
+	return Variant{Suffix: v.Suffix + "_night", Manifest: m.GetNightManifest(), Remap: remap, Snow: v.Snow}
+}
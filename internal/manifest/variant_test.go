@@ -0,0 +1,86 @@
+package manifest
+
+import "testing"
+
+func TestManifest_GetVariants(t *testing.T) {
+	m := Manifest{
+		Exposure: 0.5,
+		Liveries: []Livery{{Name: "red", Remap: []Remap{{Start: 10, End: 11, To: 20}}}},
+		Night:    &Night{Lights: []Remap{{Start: 5, End: 5, To: 230}, {Start: 21, End: 21, To: 231}}},
+	}
+
+	variants := m.GetVariants()
+
+	expectedSuffixes := []string{"_night", "_red", "_red_night"}
+	if len(variants) != len(expectedSuffixes) {
+		t.Fatalf("expected %d variants, got %d", len(expectedSuffixes), len(variants))
+	}
+
+	for i, suffix := range expectedSuffixes {
+		if variants[i].Suffix != suffix {
+			t.Errorf("variant %d expected suffix %s, got %s", i, suffix, variants[i].Suffix)
+		}
+	}
+
+	testCases := []struct {
+		variant         int
+		index, expected byte
+	}{
+		{0, 5, 230},
+		{0, 10, 10},
+		{0, 11, 11},
+		{1, 5, 5},
+		{1, 11, 21},
+		{2, 5, 230},
+		{2, 10, 20},
+		{2, 11, 231},
+	}
+
+	for _, testCase := range testCases {
+		def := Definition{Remap: variants[testCase.variant].Remap}
+		if result := def.GetRemappedIndex(testCase.index); result != testCase.expected {
+			t.Errorf("variant %s: index %d expected %d, got %d", variants[testCase.variant].Suffix, testCase.index, testCase.expected, result)
+		}
+	}
+
+	night := variants[0].Manifest
+	if night.Exposure != -1 || night.ColourTemperature != 12000 || night.Night != nil || night.Liveries != nil {
+		t.Errorf("unexpected night manifest exposure %f, colour temperature %f", night.Exposure, night.ColourTemperature)
+	}
+
+	if day := variants[1].Manifest; day.Exposure != 0.5 {
+		t.Errorf("expected livery to keep day exposure 0.5, got %f", day.Exposure)
+	}
+}
+
+func TestManifest_GetVariants_Snow(t *testing.T) {
+	m := Manifest{
+		Liveries: []Livery{{Name: "red"}},
+		Night:    &Night{},
+		Snow:     &Snow{},
+	}
+
+	expected := []struct {
+		suffix string
+		snow   bool
+	}{
+		{"_night", false},
+		{"_snow", true},
+		{"_snow_night", true},
+		{"_red", false},
+		{"_red_night", false},
+		{"_red_snow", true},
+		{"_red_snow_night", true},
+	}
+
+	variants := m.GetVariants()
+	if len(variants) != len(expected) {
+		t.Fatalf("expected %d variants, got %d", len(expected), len(variants))
+	}
+
+	for i, e := range expected {
+		if variants[i].Suffix != e.suffix || (variants[i].Snow != nil) != e.snow {
+			t.Errorf("variant %d expected suffix %s with snow %v, got %s with snow %v", i, e.suffix, e.snow, variants[i].Suffix, variants[i].Snow != nil)
+		}
+	}
+}
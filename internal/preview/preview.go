@@ -0,0 +1,451 @@
+package preview
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"github.com/mattkimber/gorender/internal/spritesheet"
+	"github.com/mattkimber/gorender/internal/utils/timingutils"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// A preview server renders single sprites on request, so an object can be viewed from
+// any angle in a web browser without writing files.
+type Server struct {
+	Definition manifest.Definition
+
+	// If set, the manifest is reloaded from this file whenever it changes
+	ManifestFilename string
+	LoadManifest     func() (manifest.Manifest, error)
+
+	// Get the definition (including processed voxel objects) for a reloaded manifest
+	Process func(m manifest.Manifest) (manifest.Definition, error)
+
+	mutex    sync.Mutex
+	modTime  time.Time
+	version  int
+	gbuffers map[float64]spritesheet.GBuffer
+	status   Status
+
+	// Stages run when reloading, reported along with the next render
+	reloadStages []timingutils.Stage
+}
+
+type Status struct {
+	Version int                 `json:"version"`
+	Error   string              `json:"error"`
+	Stages  []timingutils.Stage `json:"stages"`
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.handlePage)
+	mux.HandleFunc("/sprite.png", s.handleSprite)
+	mux.HandleFunc("/status", s.handleStatus)
+	return mux
+}
+
+func (s *Server) ListenAndServe(addr string) error {
+	return http.ListenAndServe(addr, s.Handler())
+}
+
+func (s *Server) handlePage(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = fmt.Fprint(w, page)
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.reload()
+	s.status.Version = s.version
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(s.status)
+}
+
+func (s *Server) handleSprite(w http.ResponseWriter, r *http.Request) {
+	angle, err := strconv.ParseFloat(r.URL.Query().Get("angle"), 64)
+	if err != nil {
+		http.Error(w, "invalid angle", http.StatusBadRequest)
+		return
+	}
+
+	depth := r.URL.Query().Get("depth")
+	if depth != "8bpp" && depth != "32bpp" && depth != "mask" {
+		http.Error(w, "invalid depth", http.StatusBadRequest)
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.reload()
+
+	if len(s.Definition.Manifest.Sprites) == 0 {
+		http.Error(w, "manifest has no sprites", http.StatusInternalServerError)
+		return
+	}
+
+	sheets := s.render(angle, depth == "8bpp")
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	// Spritesheets are drawn as they are encoded
+	recorder := &timingutils.Recorder{Stages: s.status.Stages}
+	recorder.Time("PNG output", false, func() {
+		err = sheets.Data[depth].OutputToWriter(w)
+	})
+	s.status.Stages = recorder.Stages
+	sheets.Release()
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Render a sprite, re-using the raycast output from a previous render at the same angle
+// if the manifest has not changed anything which affects raycasting
+func (s *Server) render(angle float64, only8bpp bool) (sheets spritesheet.Spritesheets) {
+	if s.gbuffers == nil {
+		s.gbuffers = make(map[float64]spritesheet.GBuffer)
+	}
+
+	def := s.Definition
+	def.Manifest.Sprites = []manifest.Sprite{getSpriteForAngle(def.Manifest.Sprites, angle)}
+	def.Debug = false
+	def.Only8bpp = only8bpp
+	def.OutputGBuffer = true
+	def.Timings = &timingutils.Recorder{Stages: s.reloadStages}
+	s.reloadStages = nil
+
+	if gbuffer, ok := s.gbuffers[angle]; ok {
+		var err error
+		if sheets, err = spritesheet.GetRelitSpritesheets(def, gbuffer); err == nil {
+			s.status.Stages = def.Timings.Stages
+			return
+		}
+	}
+
+	sheets = spritesheet.GetSpritesheets(def)
+	s.gbuffers[angle] = sheets.GBuffer
+	s.status.Stages = def.Timings.Stages
+	return
+}
+
+// Reload the manifest if it has changed, discarding any stages affected by the change
+func (s *Server) reload() {
+	if s.ManifestFilename == "" || s.LoadManifest == nil || s.Process == nil {
+		return
+	}
+
+	stat, err := os.Stat(s.ManifestFilename)
+	if err != nil || !stat.ModTime().After(s.modTime) {
+		return
+	}
+
+	if s.modTime.IsZero() {
+		// First check, the definition is already up to date
+		s.modTime = stat.ModTime()
+		return
+	}
+
+	s.modTime = stat.ModTime()
+	s.version++
+
+	m, err := s.LoadManifest()
+	if err != nil {
+		s.status.Error = fmt.Sprintf("could not load manifest: %v", err)
+		return
+	}
+
+	s.status.Error = ""
+	previous := s.Definition.Manifest
+
+	if getObjectKey(m) != getObjectKey(previous) {
+		recorder := &timingutils.Recorder{}
+		var def manifest.Definition
+		recorder.Time("Voxel processing", false, func() {
+			def, err = s.Process(m)
+		})
+
+		if err != nil {
+			s.status.Error = fmt.Sprintf("could not process objects: %v", err)
+			return
+		}
+
+		s.Definition = def
+		s.gbuffers = nil
+		s.reloadStages = recorder.Stages
+		return
+	}
+
+	if getRaycastKey(m) != getRaycastKey(previous) {
+		s.gbuffers = nil
+	}
+
+	s.Definition.Manifest = m
+}
+
+// The first stage of rendering which uses each manifest field. Changing a field means
+// re-running its stage and every stage after it. Fields not listed are treated as
+// affecting voxel processing, so are never wrongly cached.
+type stage int
+
+const (
+	stageObject stage = iota
+	stageRaycast
+	stageShading
+)
+
+var fieldStages = map[string]stage{
+	"sprites":          stageObject,
+	"objects":          stageObject,
+	"tiled_normals":    stageObject,
+	"gradient_normals": stageObject,
+	"tiling_mode":      stageObject,
+	"solid_base":       stageObject,
+	"brightness_remap": stageObject,
+	"height_gradients": stageObject,
+	"render_slopes":    stageObject,
+	"slope_height":     stageObject,
+
+	"lighting_angle":              stageRaycast,
+	"lighting_elevation":          stageRaycast,
+	"size":                        stageRaycast,
+	"render_elevation":            stageRaycast,
+	"accuracy":                    stageRaycast,
+	"adaptive_threshold":          stageRaycast,
+	"sampler":                     stageRaycast,
+	"raycaster":                   stageRaycast,
+	"overlap":                     stageRaycast,
+	"pad_to_full_length":          stageRaycast,
+	"join_overlap":                stageRaycast,
+	"slice_threshold":             stageRaycast,
+	"slice_length":                stageRaycast,
+	"slice_overlap":               stageRaycast,
+	"falloff_adjustment":          stageRaycast,
+	"recovered_voxel_suppression": stageRaycast,
+	"recovered_voxels":            stageRaycast,
+	"joggle":                      stageRaycast,
+	"near_clip":                   stageRaycast,
+	"far_clip":                    stageRaycast,
+	"max_ray_distance":            stageRaycast,
+	"soft_shadow":                 stageRaycast,
+	"shadow_threshold":            stageRaycast,
+	"drop_shadow":                 stageRaycast,
+	"symmetric":                   stageRaycast,
+	"quality":                     stageRaycast,
+	"camera":                      stageRaycast,
+	"auto_size":                   stageRaycast,
+
+	"depth_influence":              stageShading,
+	"soften_edges":                 stageShading,
+	"brightness":                   stageShading,
+	"contrast":                     stageShading,
+	"tone_mapping":                 stageShading,
+	"exposure":                     stageShading,
+	"colour_temperature":           stageShading,
+	"tint":                         stageShading,
+	"detail_boost":                 stageShading,
+	"detail_boost_ranges":          stageShading,
+	"detail_boost_boxes":           stageShading,
+	"noise":                        stageShading,
+	"dirt":                         stageShading,
+	"dirt_tint":                    stageShading,
+	"sharpen":                      stageShading,
+	"sharpen_radius":               stageShading,
+	"fade_to_black":                stageShading,
+	"fade_to_colour":               stageShading,
+	"alpha_edge_threshold":         stageShading,
+	"hard_edge_threshold":          stageShading,
+	"transparency_dither":          stageShading,
+	"dither_flat_areas":            stageShading,
+	"max_colours":                  stageShading,
+	"despeckle_threshold":          stageShading,
+	"fosterise":                    stageShading,
+	"suppress_edge_fosterisation":  stageShading,
+	"single_pass_dither":           stageShading,
+	"correct_company_colour_bleed": stageShading,
+	"animated":                     stageShading,
+	"specialness_threshold":        stageShading,
+	"coherent_dither":              stageShading,
+	"alternate_modal":              stageShading,
+	"tileable_dither":              stageShading,
+	"drop_shadow_index":            stageShading,
+	"layers":                       stageShading,
+	"depth_buffer":                 stageShading,
+	"gloss_map":                    stageShading,
+	"colour_blind_previews":        stageShading,
+	"sprite_files":                 stageShading,
+	"aseprite":                     stageShading,
+	"nml":                          stageShading,
+	"atlas":                        stageShading,
+	"deduplicate":                  stageShading,
+	"template":                     stageShading,
+	"output_formats":               stageShading,
+	"targets":                      stageShading,
+	"purchase":                     stageShading,
+	"icon":                         stageShading,
+	"flat_lighting":                stageShading,
+	"backdrop":                     stageShading,
+	"colour_classes":               stageShading,
+	"liveries":                     stageShading,
+	"night":                        stageShading,
+	"snow":                         stageShading,
+}
+
+// Get a key representing the manifest fields which affect a stage of rendering or any
+// stage before it
+func getStageKey(m manifest.Manifest, s stage) string {
+	data, _ := json.Marshal(m)
+
+	var fields map[string]json.RawMessage
+	_ = json.Unmarshal(data, &fields)
+
+	for name := range fields {
+		if fieldStage, ok := fieldStages[name]; ok && fieldStage > s {
+			delete(fields, name)
+		}
+	}
+
+	key, _ := json.Marshal(fields)
+	return string(key)
+}
+
+// Get a key representing the settings which affect voxel object processing
+func getObjectKey(m manifest.Manifest) string {
+	return getStageKey(m, stageObject)
+}
+
+// Get a key representing the settings which affect raycasting. Anything else only needs
+// the lighting, shading and dithering stages to be re-run.
+func getRaycastKey(m manifest.Manifest) string {
+	return getStageKey(m, stageRaycast)
+}
+
+// Get the manifest sprite closest to an angle, rotated to that angle so the output
+// keeps the sprite's size and settings
+func getSpriteForAngle(sprites []manifest.Sprite, angle float64) manifest.Sprite {
+	best, bestDistance := 0, 360.0
+
+	for i, spr := range sprites {
+		distance := angleDistance(spr.Angle, angle)
+		if distance < bestDistance {
+			best, bestDistance = i, distance
+		}
+	}
+
+	result := sprites[best]
+	result.Angle = angle
+	return result
+}
+
+func angleDistance(a, b float64) float64 {
+	d := a - b
+	for d < 0 {
+		d += 360
+	}
+	for d >= 360 {
+		d -= 360
+	}
+
+	if d > 180 {
+		return 360 - d
+	}
+
+	return d
+}
+
+const page = `<!DOCTYPE html>
+<html>
+<head>
+<title>GoRender preview</title>
+<style>
+body { font-family: sans-serif; background: #666; color: #fff; }
+#sprite { image-rendering: pixelated; margin: 1em; }
+#error { color: #f88; }
+</style>
+</head>
+<body>
+<div>
+<label>Angle <input id="angle" type="range" min="0" max="359" value="0"> <span id="angleValue">0</span></label>
+<label>Zoom <input id="zoom" type="range" min="1" max="16" value="4"> <span id="zoomValue">4</span>x</label>
+<select id="depth"><option>8bpp</option><option>32bpp</option><option>mask</option></select>
+</div>
+<div>Drag the sprite to orbit, scroll to zoom.</div>
+<img id="sprite" draggable="false">
+<div id="error"></div>
+<pre id="timings"></pre>
+<script>
+const img = document.getElementById("sprite");
+const angle = document.getElementById("angle");
+const zoom = document.getElementById("zoom");
+const depth = document.getElementById("depth");
+let loading = false, pending = false;
+
+function render() {
+	document.getElementById("angleValue").textContent = angle.value;
+	if (loading) { pending = true; return; }
+	loading = true;
+	img.src = "sprite.png?angle=" + angle.value + "&depth=" + depth.value + "&t=" + Date.now();
+}
+
+function resize() {
+	document.getElementById("zoomValue").textContent = zoom.value;
+	img.style.width = (img.naturalWidth * zoom.value) + "px";
+}
+
+let version = null;
+function updateStatus() {
+	fetch("status").then(r => r.json()).then(status => {
+		document.getElementById("error").textContent = status.error;
+		document.getElementById("timings").textContent =
+			(status.stages || []).map(s => s.name + ": " + s.ms + "ms").join("\n");
+		if (version !== null && status.version !== version) render();
+		version = status.version;
+	});
+}
+
+img.onload = img.onerror = function () {
+	loading = false;
+	resize();
+	updateStatus();
+	if (pending) { pending = false; render(); }
+};
+
+let dragX = null;
+img.onmousedown = function (e) { dragX = e.clientX; };
+window.onmouseup = function () { dragX = null; };
+window.onmousemove = function (e) {
+	if (dragX === null) return;
+	angle.value = (((+angle.value + e.clientX - dragX) % 360) + 360) % 360;
+	dragX = e.clientX;
+	render();
+};
+img.onwheel = function (e) {
+	e.preventDefault();
+	zoom.value = +zoom.value + (e.deltaY < 0 ? 1 : -1);
+	resize();
+};
+
+angle.oninput = render;
+depth.onchange = render;
+zoom.oninput = resize;
+render();
+setInterval(updateStatus, 1000);
+</script>
+</body>
+</html>
+`
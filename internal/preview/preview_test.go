@@ -0,0 +1,150 @@
+package preview
+
+import (
+	"github.com/mattkimber/gorender/internal/colour"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"github.com/mattkimber/gorender/internal/spritesheet"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_getSpriteForAngle(t *testing.T) {
+	sprites := []manifest.Sprite{{Angle: 0, Width: 10}, {Angle: 90, Width: 20}, {Angle: 270, Width: 30}}
+
+	testCases := []struct {
+		angle         float64
+		expectedWidth int
+	}{
+		{0, 10},
+		{30, 10},
+		{60, 20},
+		{200, 30},
+		{350, 10},
+	}
+
+	for _, testCase := range testCases {
+		result := getSpriteForAngle(sprites, testCase.angle)
+		if result.Width != testCase.expectedWidth || result.Angle != testCase.angle {
+			t.Errorf("Angle %f expected sprite of width %d, got %d at angle %f", testCase.angle, testCase.expectedWidth, result.Width, result.Angle)
+		}
+	}
+}
+
+func TestServer_Handler(t *testing.T) {
+	s := Server{Definition: manifest.Definition{
+		Palette: colour.Palette{Entries: []colour.PaletteEntry{{R: 0, G: 0, B: 0}, {R: 255, G: 255, B: 255}}},
+		Scale:   1.0,
+		Manifest: manifest.Manifest{
+			Accuracy: 1,
+			Sprites:  []manifest.Sprite{{Angle: 0, Width: 8, Height: 8}},
+		},
+	}}
+
+	testCases := []struct {
+		url          string
+		expectedCode int
+	}{
+		{"/", http.StatusOK},
+		{"/sprite.png?angle=45&depth=8bpp", http.StatusOK},
+		{"/sprite.png?angle=45&depth=32bpp", http.StatusOK},
+		{"/sprite.png?angle=a&depth=8bpp", http.StatusBadRequest},
+		{"/sprite.png?angle=45&depth=16bpp", http.StatusBadRequest},
+		{"/status", http.StatusOK},
+		{"/missing", http.StatusNotFound},
+	}
+
+	for _, testCase := range testCases {
+		recorder := httptest.NewRecorder()
+		s.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", testCase.url, nil))
+		if recorder.Code != testCase.expectedCode {
+			t.Errorf("%s expected status %d, got %d", testCase.url, testCase.expectedCode, recorder.Code)
+		}
+	}
+}
+
+func TestServer_reload(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "manifest.json")
+	if err := os.WriteFile(filename, []byte("{}"), 0644); err != nil {
+		t.Fatalf("could not write manifest: %v", err)
+	}
+
+	initial := manifest.Manifest{Accuracy: 1, LightingAngle: 60, Sprites: []manifest.Sprite{{Width: 8, Height: 8}}}
+	processed := 0
+
+	testCases := []struct {
+		manifest          manifest.Manifest
+		expectedProcessed int
+		expectedCleared   bool
+	}{
+		{initial, 0, false},
+		{manifest.Manifest{Accuracy: 1, LightingAngle: 60, Contrast: 1.5, Sprites: initial.Sprites}, 0, false},
+		{manifest.Manifest{Accuracy: 1, LightingAngle: 90, Sprites: initial.Sprites}, 0, true},
+		{manifest.Manifest{Accuracy: 1, LightingAngle: 60, JoinOverlap: 2, Sprites: initial.Sprites}, 0, true},
+		{manifest.Manifest{Accuracy: 1, LightingAngle: 60, SolidBase: true, Sprites: initial.Sprites}, 1, true},
+		{manifest.Manifest{Accuracy: 1, LightingAngle: 90, Sprites: []manifest.Sprite{{Width: 16, Height: 8}}}, 1, true},
+	}
+
+	for i, testCase := range testCases {
+		next := testCase.manifest
+		s := Server{
+			Definition:       manifest.Definition{Manifest: initial},
+			ManifestFilename: filename,
+			LoadManifest:     func() (manifest.Manifest, error) { return next, nil },
+			Process: func(m manifest.Manifest) (manifest.Definition, error) {
+				processed++
+				return manifest.Definition{Manifest: m}, nil
+			},
+		}
+
+		processed = 0
+		s.reload()
+		s.gbuffers = map[float64]spritesheet.GBuffer{0: {}}
+
+		modTime := time.Now().Add(time.Duration(i+1) * time.Second)
+		if err := os.Chtimes(filename, modTime, modTime); err != nil {
+			t.Fatalf("could not touch manifest: %v", err)
+		}
+		s.reload()
+
+		if processed != testCase.expectedProcessed {
+			t.Errorf("Case %d expected %d object processing runs, got %d", i, testCase.expectedProcessed, processed)
+		}
+
+		if cleared := s.gbuffers == nil; cleared != testCase.expectedCleared {
+			t.Errorf("Case %d expected cleared raycast cache %v, got %v", i, testCase.expectedCleared, cleared)
+		}
+
+		if s.version != 1 || s.Definition.Manifest.Contrast != next.Contrast {
+			t.Errorf("Case %d expected manifest to be reloaded", i)
+		}
+	}
+}
+
+// Every manifest field must be assigned to the stage of rendering it affects, so new
+// settings are not wrongly cached by the preview
+func Test_fieldStages(t *testing.T) {
+	fields := make(map[string]bool)
+	manifestType := reflect.TypeOf(manifest.Manifest{})
+
+	for i := 0; i < manifestType.NumField(); i++ {
+		name, _, _ := strings.Cut(manifestType.Field(i).Tag.Get("json"), ",")
+		fields[name] = true
+
+		if _, ok := fieldStages[name]; !ok {
+			t.Errorf("manifest field %s is not assigned to a stage", name)
+		}
+	}
+
+	for name := range fieldStages {
+		if !fields[name] {
+			t.Errorf("stage assigned to unknown manifest field %s", name)
+		}
+	}
+}
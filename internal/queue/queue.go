@@ -0,0 +1,187 @@
+package queue
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/mattkimber/gorender/internal/report"
+	"github.com/mattkimber/gorender/internal/spritesheet"
+	"io"
+	"net"
+	"sync"
+)
+
+// A task renders one voxel file at one scale. Everything needed is sent along with
+// the task, so workers do not need access to the coordinator's files.
+type Task struct {
+	ID       int               `json:"id"`
+	Input    string            `json:"input"`
+	Files    map[string][]byte `json:"files"`
+	Manifest []byte            `json:"manifest"`
+	Palette  []byte            `json:"palette"`
+	Scale    float64           `json:"scale"`
+	Fast     bool              `json:"fast"`
+	Debug    bool              `json:"debug"`
+	Only8bpp bool              `json:"only_8bpp"`
+
+	// Render as symmetric even if the manifest doesn't say so
+	Symmetric bool `json:"symmetric"`
+
+	// The manifest object the input is rendered as, counting from 1, or 0 for none
+	Object int `json:"object"`
+
+	// The named target of the manifest to render
+	Target string `json:"target"`
+}
+
+// The spritesheets (as PNG data), report and, for deduplicated spritesheets, layout for a task
+type Result struct {
+	ID     int                `json:"id"`
+	Sheets map[string][]byte  `json:"sheets"`
+	Report report.Report      `json:"report"`
+	Layout spritesheet.Layout `json:"layout,omitempty"`
+	Error  string             `json:"error,omitempty"`
+}
+
+type RenderFunc func(t Task) Result
+
+// A coordinator hands out tasks to workers connecting over TCP and collects the results.
+// Tasks held by a worker which disconnects are handed to the next worker.
+type Coordinator struct {
+	// Called with each result as it arrives. Calls are never concurrent.
+	Handle func(t *Task, r Result)
+
+	// Called when a worker connects or disconnects, if set
+	Log func(format string, args ...interface{})
+
+	mutex     sync.Mutex
+	queue     chan *Task
+	remaining int
+	done      chan struct{}
+}
+
+// Run the coordinator on the listener until all tasks have a result
+func (c *Coordinator) Run(listener net.Listener, tasks []*Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	c.queue = make(chan *Task, len(tasks))
+	c.remaining = len(tasks)
+	c.done = make(chan struct{})
+
+	for _, t := range tasks {
+		c.queue <- t
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			go c.serve(conn)
+		}
+	}()
+
+	select {
+	case <-c.done:
+		return nil
+	case err := <-errs:
+		return err
+	}
+}
+
+func (c *Coordinator) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+
+	c.log("worker %s connected", conn.RemoteAddr())
+	encoder, decoder := json.NewEncoder(conn), json.NewDecoder(conn)
+
+	for {
+		var t *Task
+		select {
+		case t = <-c.queue:
+		case <-c.done:
+			return
+		}
+
+		var r Result
+		err := encoder.Encode(t)
+		if err == nil {
+			err = decoder.Decode(&r)
+		}
+
+		if err != nil {
+			c.log("worker %s disconnected, task %d will be retried", conn.RemoteAddr(), t.ID)
+			c.queue <- t
+			return
+		}
+
+		c.complete(t, r)
+	}
+}
+
+func (c *Coordinator) complete(t *Task, r Result) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.Handle != nil {
+		c.Handle(t, r)
+	}
+
+	c.remaining--
+	if c.remaining == 0 {
+		close(c.done)
+	}
+}
+
+func (c *Coordinator) log(format string, args ...interface{}) {
+	if c.Log != nil {
+		c.Log(format, args...)
+	}
+}
+
+// Connect to a coordinator and render tasks until it has no more
+func Work(addr string, render RenderFunc) error {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = conn.Close() }()
+	return work(conn, render)
+}
+
+func work(conn io.ReadWriter, render RenderFunc) error {
+	encoder, decoder := json.NewEncoder(conn), json.NewDecoder(conn)
+
+	for {
+		var t Task
+		if err := decoder.Decode(&t); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		r := safeRender(t, render)
+		r.ID = t.ID
+
+		if err := encoder.Encode(r); err != nil {
+			return err
+		}
+	}
+}
+
+// Render a task, reporting a panic as an error so one bad file doesn't stop the worker
+func safeRender(t Task, render RenderFunc) (r Result) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			r = Result{Error: fmt.Sprintf("render failed: %v", rec)}
+		}
+	}()
+
+	return render(t)
+}
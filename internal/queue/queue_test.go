@@ -0,0 +1,104 @@
+package queue
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+)
+
+func getTasks(n int) (tasks []*Task) {
+	for i := 0; i < n; i++ {
+		tasks = append(tasks, &Task{ID: i, Input: fmt.Sprintf("%d.vox", i)})
+	}
+
+	return
+}
+
+func echoRender(t Task) Result {
+	return Result{Sheets: map[string][]byte{"8bpp": []byte(t.Input)}}
+}
+
+func TestCoordinator_Run(t *testing.T) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+
+	results := make(map[string]string)
+	c := Coordinator{Handle: func(task *Task, r Result) {
+		results[task.Input] = string(r.Sheets["8bpp"])
+	}}
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			if err := Work(listener.Addr().String(), echoRender); err != nil {
+				t.Errorf("worker failed: %v", err)
+			}
+			wg.Done()
+		}()
+	}
+
+	if err := c.Run(listener, getTasks(20)); err != nil {
+		t.Fatalf("coordinator failed: %v", err)
+	}
+
+	// Workers still waiting to be accepted are told there are no more tasks
+	wg.Wait()
+	_ = listener.Close()
+
+	if len(results) != 20 {
+		t.Errorf("expected 20 results, got %d", len(results))
+	}
+
+	for input, output := range results {
+		if input != output {
+			t.Errorf("result for %s: expected %s, got %s", input, input, output)
+		}
+	}
+}
+
+func TestCoordinator_Run_Retry(t *testing.T) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+
+	count := 0
+	c := Coordinator{Handle: func(task *Task, r Result) { count++ }}
+
+	// Take a task and disconnect without returning a result
+	go func() {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			t.Errorf("could not connect: %v", err)
+			return
+		}
+
+		buf := make([]byte, 1)
+		_, _ = conn.Read(buf)
+		_ = conn.Close()
+
+		_ = Work(listener.Addr().String(), echoRender)
+	}()
+
+	if err := c.Run(listener, getTasks(5)); err != nil {
+		t.Fatalf("coordinator failed: %v", err)
+	}
+
+	_ = listener.Close()
+
+	if count != 5 {
+		t.Errorf("expected 5 results, got %d", count)
+	}
+}
+
+func TestWork_Panic(t *testing.T) {
+	r := safeRender(Task{}, func(t Task) Result { panic("bad voxel file") })
+
+	if expected := "render failed: bad voxel file"; r.Error != expected {
+		t.Errorf("expected error %s, got %s", expected, r.Error)
+	}
+}
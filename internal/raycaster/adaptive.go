@@ -0,0 +1,91 @@
+package raycaster
+
+import (
+	"github.com/mattkimber/gorender/internal/manifest"
+	"github.com/mattkimber/gorender/internal/sampler"
+	"github.com/mattkimber/gorender/internal/voxelobject"
+)
+
+// Raycast with the coarse samples first, then raycast again with the fine samples only
+// for pixels whose coarse samples vary by more than the threshold. Most pixels of a
+// sprite are flat areas of one colour which gain nothing from extra samples. Pixels
+// left at the coarse samples are copied into the output, so the result can be shaded
+// like any other raycast output.
+func (b *OutputBuffer) GetAdaptiveRaycastOutput(object voxelobject.ProcessedVoxelObject, m manifest.Manifest, spr manifest.Sprite, coarse, fine sampler.Samples, threshold float64) RenderOutput {
+	if b.coarse == nil {
+		b.coarse = &OutputBuffer{}
+	}
+
+	first := b.coarse.GetRaycastOutput(object, m, spr, coarse)
+
+	algorithm := GetAlgorithm(m.Raycaster)
+	w, h := fine.Width(), fine.Height()
+	refine := make([][]bool, w)
+	for x := range refine {
+		refine[x] = make([]bool, h)
+		for y := range refine[x] {
+			refine[x][y] = GetVariance(first[x][y]) > threshold
+		}
+	}
+
+	result := b.getOutput(w, h, func(x, y int) int {
+		if refine[x][y] {
+			return algorithm.OutputSize(fine[x][y])
+		}
+		return len(first[x][y])
+	})
+
+	for x := range result {
+		for y := range result[x] {
+			if !refine[x][y] {
+				copy(result[x][y], first[x][y])
+			}
+		}
+	}
+
+	raycast(object, m, spr, fine, result, func(x, y int) bool { return refine[x][y] })
+	return result
+}
+
+// Get how much the samples of a pixel vary. This is the variance of coverage, plus for
+// samples which hit the object the variance of lighting and the proportion which are not
+// the most common colour. Each part is between 0 and 1, and a pixel where every sample
+// hits the same colour at the same lighting (or every sample misses) has no variance.
+func GetVariance(info RenderInfo) float64 {
+	var counts [256]int
+	hits, total, modal := 0, 0, 0
+	light, lightSquared := 0.0, 0.0
+
+	// Samples which hit the same voxel as the previous sample are merged into it, so
+	// each sample is weighted by its count
+	for _, s := range info {
+		total += s.Count
+		if !s.Collision {
+			continue
+		}
+
+		count := float64(s.Count)
+		hits += s.Count
+		light += s.LightAmount * count
+		lightSquared += s.LightAmount * s.LightAmount * count
+
+		counts[s.Index] += s.Count
+		modal = max(modal, counts[s.Index])
+	}
+
+	if total == 0 {
+		return 0
+	}
+
+	coverage := float64(hits) / float64(total)
+	variance := coverage * (1 - coverage) * 4
+
+	if hits > 0 {
+		fHits := float64(hits)
+		mean := light / fHits
+		variance += lightSquared/fHits - mean*mean
+		variance += 1 - float64(modal)/fHits
+	}
+
+	return variance
+}
@@ -0,0 +1,78 @@
+package raycaster
+
+import (
+	"github.com/mattkimber/gorender/internal/manifest"
+	"github.com/mattkimber/gorender/internal/sampler"
+	"math"
+	"testing"
+)
+
+func TestGetVariance(t *testing.T) {
+	hit := func(index byte, light float64) RenderSample {
+		return RenderSample{Collision: true, Index: index, LightAmount: light, Count: 1}
+	}
+	miss := RenderSample{Count: 1}
+
+	testCases := []struct {
+		name     string
+		info     RenderInfo
+		expected float64
+	}{
+		{"empty", RenderInfo{}, 0},
+		{"all miss", RenderInfo{miss, miss}, 0},
+		{"all hit", RenderInfo{hit(5, 0.5), hit(5, 0.5)}, 0},
+		{"half coverage", RenderInfo{hit(5, 0.5), miss}, 1},
+		{"two colours", RenderInfo{hit(5, 0.5), hit(6, 0.5)}, 0.5},
+		{"lighting", RenderInfo{hit(5, 0), hit(5, 1)}, 0.25},
+		{"merged samples", RenderInfo{{Collision: true, Index: 5, Count: 3}, {Count: 0}, {Count: 0}, hit(6, 0)}, 0.25},
+	}
+
+	for _, testCase := range testCases {
+		if result := GetVariance(testCase.info); math.Abs(result-testCase.expected) > 1e-9 {
+			t.Errorf("%s: expected variance %v, got %v", testCase.name, testCase.expected, result)
+		}
+	}
+}
+
+func TestOutputBuffer_GetAdaptiveRaycastOutput(t *testing.T) {
+	object := getObject("cone.vox", t)
+	m := manifest.Manifest{
+		LightingAngle:        45,
+		LightingElevation:    50,
+		Size:                 object.Size.ToVector3(),
+		RenderElevationAngle: 30,
+		Sprites:              []manifest.Sprite{{Angle: 45, Width: 10, Height: 10}},
+	}
+
+	coarse, fine := sampler.Square(10, 10, 2, 0, 1), sampler.Square(10, 10, 4, 0, 1)
+	coarseOutput := GetRaycastOutput(object, m, m.Sprites[0], coarse)
+	fineOutput := GetRaycastOutput(object, m, m.Sprites[0], fine)
+
+	buffer := OutputBuffer{}
+	output := buffer.GetAdaptiveRaycastOutput(object, m, m.Sprites[0], coarse, fine, 0)
+
+	refined := 0
+	for x := range output {
+		for y := range output[x] {
+			expected := coarseOutput[x][y]
+			if GetVariance(coarseOutput[x][y]) > 0 {
+				expected = fineOutput[x][y]
+				refined++
+			}
+
+			if len(output[x][y]) != len(expected) {
+				t.Fatalf("pixel %d,%d expected %d samples, got %d", x, y, len(expected), len(output[x][y]))
+			}
+
+			for i := range output[x][y] {
+				if output[x][y][i] != expected[i] {
+					t.Fatalf("sample at %d,%d,%d expected %v, got %v", x, y, i, expected[i], output[x][y][i])
+				}
+			}
+		}
+	}
+
+	if refined == 0 || refined == 100 {
+		t.Errorf("expected some but not all pixels to be refined, got %d", refined)
+	}
+}
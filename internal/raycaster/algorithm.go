@@ -0,0 +1,80 @@
+package raycaster
+
+import (
+	"github.com/mattkimber/gorender/internal/sampler"
+)
+
+// A way of raycasting the samples of an output pixel. Different objects suit different
+// algorithms, so the algorithm is chosen per manifest.
+type Algorithm interface {
+	// Get the number of output samples a pixel with these samples needs
+	OutputSize(samples sampler.SampleList) int
+
+	castPixel(sc *scene, samples sampler.SampleList, output RenderInfo)
+}
+
+func GetAlgorithm(name string) Algorithm {
+	switch name {
+	case "sampler":
+		return samplerAlgorithm{}
+	case "beam":
+		return beamAlgorithm{}
+	default:
+		return samplerAlgorithm{}
+	}
+}
+
+// Cast a ray for every sample, so coverage comes from the proportion of samples which hit
+type samplerAlgorithm struct{}
+
+func (samplerAlgorithm) OutputSize(samples sampler.SampleList) int {
+	return len(samples)
+}
+
+func (samplerAlgorithm) castPixel(sc *scene, samples sampler.SampleList, output RenderInfo) {
+	px, py, pz, pi := 0, 0, 0, -1
+
+	for i := range samples {
+		output[i].Count = 1
+	}
+
+	for i, s := range samples {
+		output[i].Cast = true
+
+		r := sc.cast(s.Location)
+
+		// Samples passing a translucent voxel show what is behind it, or see through the
+		// object if there is nothing. Only the nearest translucent voxel is passed.
+		seesThrough := false
+		if sc.isHit(r) && !r.isCut && passesTranslucentVoxel(i, sc.getTransparency(r)) {
+			if behind := sc.castPast(r); sc.isHit(behind) {
+				r = behind
+			} else {
+				seesThrough = true
+			}
+		}
+
+		if sc.isHit(r) {
+			// Speed up for cases where we already encountered this voxel - reduce the amount of sampling needed
+			// later
+			if pi != -1 && r.result.X == px && r.result.Y == py && r.result.Z == pz && output[pi].IsSeeThrough == seesThrough {
+				output[pi].Influence += s.Influence
+				output[pi].Count++
+
+				// Set the count for this element to 0, keeping what differs between samples
+				// hitting the same voxel so mirrored output can be merged in a different order
+				output[i].Count = 0
+				output[i].Depth, output[i].ViewDepth, output[i].IsRecovered = r.result.Depth, sc.getViewDepth(r), r.result.IsRecovered
+				continue
+			} else {
+				px = r.result.X
+				py = r.result.Y
+				pz = r.result.Z
+				pi = i
+			}
+
+			sc.setSample(&output[i], r, s.Influence)
+			output[i].IsSeeThrough = seesThrough
+		}
+	}
+}
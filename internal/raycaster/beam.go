@@ -0,0 +1,190 @@
+package raycaster
+
+import (
+	"github.com/mattkimber/gorender/internal/geometry"
+	"github.com/mattkimber/gorender/internal/sampler"
+	"math"
+)
+
+// Cast a single beam the width of the pixel through its centre, and work out how much of
+// the pixel the object covers from how far its edges are from the centre. This gives
+// smooth edges at any accuracy, but loses details smaller than a pixel which the samples
+// would otherwise pick up.
+//
+// The output has one sample for the voxel the beam hits (or passes closest to) and one for
+// the part of the pixel it doesn't cover. Their influence is split by the coverage, and
+// their counts by the same proportion of the pixel's samples, so both soft and hard edges
+// follow the coverage.
+type beamAlgorithm struct{}
+
+func (beamAlgorithm) OutputSize(samples sampler.SampleList) int {
+	return 2
+}
+
+func (beamAlgorithm) castPixel(sc *scene, samples sampler.SampleList, output RenderInfo) {
+	centre, influence := geometry.Vector2{}, 0.0
+	for _, s := range samples {
+		centre = centre.Add(s.Location)
+		influence += s.Influence
+	}
+
+	n := len(samples)
+	centre = geometry.Vector2{X: centre.X / float64(n), Y: centre.Y / float64(n)}
+
+	output[0].Cast = true
+	output[1].Count = n
+
+	r := sc.cast(centre)
+	var coverage float64
+
+	if sc.isHit(r) {
+		coverage = sc.getHitCoverage(centre)
+	} else {
+		// A straight edge touching the centre covers half the pixel, falling to nothing
+		// when it is half a pixel away
+		var gap float64
+		var ok bool
+		if r, gap, ok = sc.getNearestVoxel(r, sc.pixelSize/2); !ok {
+			return
+		}
+		coverage = math.Min(math.Max(0.5-gap/sc.pixelSize, 0), 1)
+	}
+
+	sc.setSample(&output[0], r, influence*coverage)
+
+	hits := int(math.Round(coverage * float64(n)))
+	output[0].Count, output[1].Count = hits, n-hits
+	output[1].Influence = influence * (1 - coverage)
+
+	// The shader reduces the influence of samples behind the closest one, so the uncovered
+	// part is placed at the same depth to keep the proportions the coverage gives
+	output[1].Depth = output[0].Depth
+}
+
+// Get how much of a pixel whose centre hits the object is covered. Along each axis of the
+// pixel the object covers from its edge on one side to its edge on the other, each found
+// by casting a ray at the side of the pixel and measuring how closely it passes the
+// object if it misses. Multiplying the two gives the coverage of straight edges exactly,
+// and of corners and details thinner than the pixel approximately.
+func (sc *scene) getHitCoverage(centre geometry.Vector2) float64 {
+	coverage := 1.0
+
+	for _, offset := range []geometry.Vector2{{X: sc.pixel.X / 2}, {Y: sc.pixel.Y / 2}} {
+		extent := sc.getEdgeDistance(centre.Add(offset)) + sc.getEdgeDistance(centre.Add(geometry.Vector2{X: -offset.X, Y: -offset.Y}))
+		coverage *= extent / sc.pixelSize
+	}
+
+	return math.Min(math.Max(coverage, 0), 1)
+}
+
+// Get the distance from the centre of a pixel whose centre hits the object to its edge,
+// towards a location on the side of the pixel. This is at most half a pixel, when the
+// object covers the pixel all the way to that side.
+func (sc *scene) getEdgeDistance(location geometry.Vector2) float64 {
+	half := sc.pixelSize / 2
+
+	r := sc.cast(location)
+	if sc.isHit(r) {
+		return half
+	}
+
+	if _, gap, ok := sc.getNearestVoxel(r, half); ok {
+		return math.Max(half-gap, 0)
+	}
+
+	return 0
+}
+
+// Find the filled voxel which passes closest to a ray that missed the object, and the gap
+// between them. Returns false if there is no voxel within the radius.
+func (sc *scene) getNearestVoxel(r sceneRay, radius float64) (nearest sceneRay, gap float64, ok bool) {
+	if radius <= 0 {
+		return
+	}
+
+	object, ray := sc.object, sc.ray
+	reach := int(math.Ceil(radius)) + 1
+
+	// Search within reach of the bounding volume, as voxels on its edges can be close to
+	// rays which never enter it
+	margin := geometry.Vector3{X: float64(reach), Y: float64(reach), Z: float64(reach)}
+	limits := sc.limits.Add(margin).Add(margin)
+
+	maxComponent := math.Max(math.Abs(ray.X), math.Max(math.Abs(ray.Y), math.Abs(ray.Z)))
+	closest := radius
+
+	for t := math.Max(r.start, getBoundsEntryDistance(r.loc0.Add(margin), ray, limits)); ; t++ {
+		loc := r.loc0.Add(ray.MultiplyByConstant(t))
+		if canTerminateRay(loc.Add(margin), ray, limits) {
+			break
+		}
+
+		if !isInsideBoundingVolume(loc.Add(margin), limits) {
+			continue
+		}
+
+		// Every voxel closer than the empty distance is empty, so skip ahead until filled
+		// voxels could be within reach
+		if isInsideBoundingVolume(loc, sc.limits) && maxComponent > 0 {
+			d := int(object.Elements[object.GetSliceX(loc.X)][sc.getElementY(int(loc.Y))][int(loc.Z)].EmptyDistance)
+			if skip := math.Floor(float64(d-reach-2) / maxComponent); skip > 0 {
+				t += skip - 1
+				continue
+			}
+		}
+
+		cx, cy, cz := int(math.Floor(loc.X)), int(math.Floor(loc.Y)), int(math.Floor(loc.Z))
+
+		for x := cx - reach; x <= cx+reach; x++ {
+			for y := cy - reach; y <= cy+reach; y++ {
+				for z := cz - reach; z <= cz+reach; z++ {
+					centre := geometry.Vector3{X: float64(x) + 0.5, Y: float64(y) + 0.5, Z: float64(z) + 0.5}
+					if !isInsideBoundingVolume(centre, sc.limits) {
+						continue
+					}
+
+					lx, ly := object.GetSliceX(centre.X), sc.getElementY(y)
+					if lx < sc.minX || lx > sc.maxX || object.Elements[lx][ly][z].Index == 0 {
+						continue
+					}
+
+					// Distance from the ray to the edge of the voxel, taking the voxel as
+					// a sphere of the same width
+					v := centre.Subtract(r.loc0)
+					distance := v.Dot(ray)
+					edge := v.Subtract(ray.MultiplyByConstant(distance)).Length() - 0.5
+
+					if edge >= closest || distance < r.start || !sc.clip.isVisible(distance, r.start, r.loc0, sc.midpoint, ray, sc.limits) {
+						continue
+					}
+
+					closest = edge
+					nearest = sceneRay{
+						loc0:  r.loc0,
+						start: r.start,
+						result: RayResult{
+							X:           lx,
+							Y:           ly,
+							Z:           z,
+							HasGeometry: true,
+							Depth:       int(distance),
+							Distance:    distance,
+						},
+					}
+					ok = true
+				}
+			}
+		}
+	}
+
+	return nearest, closest, ok
+}
+
+// Get the Y index of an element from a Y location, which is flipped for flipped sprites
+func (sc *scene) getElementY(y int) int {
+	if sc.spr.Flip {
+		return sc.object.Size.Y - 1 - y
+	}
+
+	return y
+}
@@ -0,0 +1,109 @@
+package raycaster
+
+import (
+	"github.com/mattkimber/gorender/internal/manifest"
+	"github.com/mattkimber/gorender/internal/sampler"
+	"math"
+	"testing"
+)
+
+func Test_beamAlgorithm(t *testing.T) {
+	object := getObject("cone.vox", t)
+	m := manifest.Manifest{
+		LightingAngle:        45,
+		LightingElevation:    50,
+		Size:                 object.Size.ToVector3(),
+		RenderElevationAngle: 30,
+		Sprites:              []manifest.Sprite{{Angle: 45, Width: 20, Height: 20, RenderElevationAngle: 30}},
+	}
+
+	smp := sampler.Square(20, 20, 4, 0, 1)
+	sampled := GetRaycastOutput(object, m, m.Sprites[0], smp)
+
+	m.Raycaster = "beam"
+	beam := GetRaycastOutput(object, m, m.Sprites[0], smp)
+
+	sampledCoverage, beamCoverage, partial := 0.0, 0.0, 0
+	for x := range beam {
+		for y := range beam[x] {
+			info := beam[x][y]
+			if len(info) != 2 {
+				t.Fatalf("pixel %d,%d: expected 2 samples, got %d", x, y, len(info))
+			}
+
+			if count := info[0].Count + info[1].Count; count != len(smp[x][y]) {
+				t.Errorf("pixel %d,%d: expected count %d, got %d", x, y, len(smp[x][y]), count)
+			}
+
+			coverage := getBeamCoverage(info)
+			if coverage > 0 && coverage < 1 {
+				partial++
+			}
+			beamCoverage += coverage
+
+			for _, s := range sampled[x][y] {
+				if s.Collision {
+					sampledCoverage += float64(s.Count) / float64(len(smp[x][y]))
+				}
+			}
+		}
+	}
+
+	if partial == 0 {
+		t.Errorf("expected edge pixels to be partly covered")
+	}
+
+	// The beam should cover about as much of the sprite as the samples do
+	if math.Abs(beamCoverage-sampledCoverage) > sampledCoverage*0.02 {
+		t.Errorf("expected coverage close to %f, got %f", sampledCoverage, beamCoverage)
+	}
+}
+
+func Test_beamAlgorithm_accuracy(t *testing.T) {
+	object := getObject("cone.vox", t)
+	m := manifest.Manifest{
+		Size:                 object.Size.ToVector3(),
+		RenderElevationAngle: 30,
+		Raycaster:            "beam",
+		Sprites:              []manifest.Sprite{{Angle: 45, Width: 20, Height: 20, RenderElevationAngle: 30}},
+	}
+
+	// Coverage is calculated rather than sampled, so is the same at any accuracy other than
+	// where the centre of a pixel only just hits or misses the object
+	low := GetRaycastOutput(object, m, m.Sprites[0], sampler.Square(20, 20, 1, 0, 1))
+	high := GetRaycastOutput(object, m, m.Sprites[0], sampler.Square(20, 20, 4, 0, 1))
+
+	for x := range low {
+		for y := range low[x] {
+			lowCoverage, highCoverage := getBeamCoverage(low[x][y]), getBeamCoverage(high[x][y])
+			if math.Abs(lowCoverage-highCoverage) > 0.02 {
+				t.Errorf("pixel %d,%d: expected coverage %f, got %f", x, y, highCoverage, lowCoverage)
+			}
+		}
+	}
+}
+
+func getBeamCoverage(info RenderInfo) float64 {
+	if !info[0].Collision {
+		return 0
+	}
+
+	return info[0].Influence / (info[0].Influence + info[1].Influence)
+}
+
+func TestGetAlgorithm(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expected Algorithm
+	}{
+		{"", samplerAlgorithm{}},
+		{"sampler", samplerAlgorithm{}},
+		{"beam", beamAlgorithm{}},
+	}
+
+	for _, testCase := range testCases {
+		if result := GetAlgorithm(testCase.name); result != testCase.expected {
+			t.Errorf("%s: expected %T, got %T", testCase.name, testCase.expected, result)
+		}
+	}
+}
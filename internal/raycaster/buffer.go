@@ -0,0 +1,47 @@
+package raycaster
+
+// Raycast output has an entry for every sample of every pixel, so is allocated in one
+// piece rather than pixel by pixel. An output buffer can be used for several raycasts in
+// turn (such as the bands of a sprite rendered under a memory limit) to reuse the memory,
+// in which case each raycast overwrites the output of the last. Not safe for concurrent use.
+type OutputBuffer struct {
+	samples []RenderSample
+	pixels  []RenderInfo
+
+	// The first pass of an adaptive raycast, which is still read while the output is written
+	coarse *OutputBuffer
+}
+
+// Get output for w by h pixels, with the given number of samples for each pixel
+func (b *OutputBuffer) getOutput(w, h int, samples func(x, y int) int) RenderOutput {
+	n := 0
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			n += samples(x, y)
+		}
+	}
+
+	if cap(b.samples) < n {
+		b.samples = make([]RenderSample, n)
+	} else {
+		b.samples = b.samples[:n]
+		clear(b.samples)
+	}
+
+	if cap(b.pixels) < w*h {
+		b.pixels = make([]RenderInfo, w*h)
+	}
+
+	output := make(RenderOutput, w)
+	s, p := b.samples, b.pixels
+
+	for x := 0; x < w; x++ {
+		output[x], p = p[:h:h], p[h:]
+		for y := 0; y < h; y++ {
+			k := samples(x, y)
+			output[x][y], s = s[:k:k], s[k:]
+		}
+	}
+
+	return output
+}
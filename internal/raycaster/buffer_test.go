@@ -0,0 +1,40 @@
+package raycaster
+
+import (
+	"github.com/mattkimber/gorender/internal/manifest"
+	"github.com/mattkimber/gorender/internal/sampler"
+	"testing"
+)
+
+func TestOutputBuffer_GetRaycastOutput(t *testing.T) {
+	object := getObject("cone.vox", t)
+	m := manifest.Manifest{
+		LightingAngle:        45,
+		LightingElevation:    50,
+		Size:                 object.Size.ToVector3(),
+		RenderElevationAngle: 30,
+		Sprites:              []manifest.Sprite{{Angle: 45, Width: 10, Height: 10}, {Angle: 135, Width: 8, Height: 10}},
+	}
+
+	// Raycast a larger sprite into the buffer first, so the second raycast reuses its memory
+	buffer := OutputBuffer{}
+	_ = buffer.GetRaycastOutput(object, m, m.Sprites[0], sampler.Square(10, 10, 2, 0, 1))
+
+	smp := sampler.Square(8, 10, 2, 0, 1)
+	output := buffer.GetRaycastOutput(object, m, m.Sprites[1], smp)
+	expected := GetRaycastOutput(object, m, m.Sprites[1], smp)
+
+	if len(output) != len(expected) {
+		t.Fatalf("expected width %d, got %d", len(expected), len(output))
+	}
+
+	for x := range output {
+		for y := range output[x] {
+			for i := range output[x][y] {
+				if output[x][y][i] != expected[x][y][i] {
+					t.Fatalf("sample at %d,%d,%d expected %v, got %v", x, y, i, expected[x][y][i], output[x][y][i])
+				}
+			}
+		}
+	}
+}
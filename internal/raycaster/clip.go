@@ -0,0 +1,111 @@
+package raycaster
+
+import (
+	"github.com/mattkimber/gorender/internal/geometry"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"math"
+)
+
+// Clipping planes and maximum ray distance for a sprite. The planes are perpendicular to
+// the view direction, and given as distances along it from the viewport midpoint.
+type clipping struct {
+	near, far   float64
+	maxDistance float64
+
+	// The normal of surfaces cut open by the near plane, facing the camera. This is in
+	// the object's space, so Y is flipped for flipped sprites.
+	normal geometry.Vector3
+}
+
+func getClipping(m manifest.Manifest, spr manifest.Sprite, limits, midpoint, ray geometry.Vector3) clipping {
+	clip := clipping{near: math.Inf(-1), far: math.Inf(1), maxDistance: math.Inf(1)}
+
+	clip.normal = geometry.Zero().Subtract(ray)
+	if spr.Flip {
+		clip.normal.Y = -clip.normal.Y
+	}
+
+	// Clipping distances in the manifest are measured from the front of the bounding box
+	front := math.Inf(1)
+	for _, corner := range []geometry.Vector3{
+		{}, {X: limits.X}, {Y: limits.Y}, {Z: limits.Z},
+		{X: limits.X, Y: limits.Y}, {X: limits.X, Z: limits.Z}, {Y: limits.Y, Z: limits.Z}, limits,
+	} {
+		front = math.Min(front, corner.Subtract(midpoint).Dot(ray))
+	}
+
+	if m.NearClip > 0 {
+		clip.near = front + m.NearClip
+	}
+
+	if m.FarClip > 0 {
+		clip.far = front + m.FarClip
+	}
+
+	if m.MaxRayDistance > 0 {
+		clip.maxDistance = m.MaxRayDistance
+	}
+
+	return clip
+}
+
+func (c clipping) isNearClipped() bool {
+	return !math.IsInf(c.near, -1)
+}
+
+// Get the distance along the ray from loc0 at which to start casting, which is where the
+// ray crosses the near plane if that is further than loc
+func (c clipping) getStartDistance(loc0, loc, midpoint, ray geometry.Vector3) (distance float64, clipped bool) {
+	distance = loc.Subtract(loc0).Length()
+
+	if near := c.near - loc0.Subtract(midpoint).Dot(ray); near > distance {
+		return near, true
+	}
+
+	return distance, false
+}
+
+// Check whether a collision at the given distance along the ray from loc0 is visible.
+// The maximum distance is measured from where the ray enters the bounding box, or from
+// the near plane if that is further.
+func (c clipping) isVisible(distance, start float64, loc0, midpoint, ray, limits geometry.Vector3) bool {
+	if loc0.Subtract(midpoint).Dot(ray)+distance > c.far {
+		return false
+	}
+
+	if !math.IsInf(c.maxDistance, 1) {
+		return distance-math.Max(start, getBoundsEntryDistance(loc0, ray, limits)) <= c.maxDistance
+	}
+
+	return true
+}
+
+// Get the distance along a shadow ray at which it leaves the space between the planes,
+// beyond which geometry has been clipped away and can't cast a shadow. depth is the
+// distance of the shadow ray's start along the view direction, and shadowVec and ray
+// must be in the same space.
+func (c clipping) getShadowDistance(depth float64, shadowVec, ray geometry.Vector3) float64 {
+	rate := shadowVec.Dot(ray)
+
+	if rate < 0 && c.isNearClipped() {
+		return (c.near - depth) / rate
+	} else if rate > 0 && !math.IsInf(c.far, 1) {
+		return (c.far - depth) / rate
+	}
+
+	return math.Inf(1)
+}
+
+// Get the distance along the ray from loc at which it enters the bounding volume
+func getBoundsEntryDistance(loc, ray, limits geometry.Vector3) float64 {
+	entry := 0.0
+
+	for _, axis := range [][3]float64{{loc.X, ray.X, limits.X}, {loc.Y, ray.Y, limits.Y}, {loc.Z, ray.Z, limits.Z}} {
+		if axis[1] != 0 {
+			t0, t1 := -axis[0]/axis[1], (axis[2]-axis[0])/axis[1]
+			entry = math.Max(entry, math.Min(t0, t1))
+		}
+	}
+
+	return entry
+}
@@ -0,0 +1,110 @@
+package raycaster
+
+import (
+	"github.com/mattkimber/gorender/internal/geometry"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"github.com/mattkimber/gorender/internal/sampler"
+	"math"
+	"testing"
+)
+
+func Test_getBoundsEntryDistance(t *testing.T) {
+	limits := geometry.Vector3{X: 10, Y: 10, Z: 10}
+
+	testCases := []struct {
+		loc, ray geometry.Vector3
+		expected float64
+	}{
+		{geometry.Vector3{X: -5, Y: 5, Z: 5}, geometry.UnitX(), 5},
+		{geometry.Vector3{X: 5, Y: 5, Z: 5}, geometry.UnitX(), 0},
+		{geometry.Vector3{X: 5, Y: 5, Z: 20}, geometry.Vector3{Z: -1}, 10},
+		{geometry.Vector3{X: -2, Y: -4, Z: 5}, geometry.Vector3{X: 1, Y: 1}, 4},
+	}
+
+	for _, testCase := range testCases {
+		if result := getBoundsEntryDistance(testCase.loc, testCase.ray, limits); math.Abs(result-testCase.expected) > 1e-9 {
+			t.Errorf("ray from %v along %v expected to enter at %v, got %v", testCase.loc, testCase.ray, testCase.expected, result)
+		}
+	}
+}
+
+func Test_clipping_getShadowDistance(t *testing.T) {
+	clip := clipping{near: 2, far: 10}
+	ray := geometry.UnitX()
+
+	testCases := []struct {
+		shadowVec geometry.Vector3
+		expected  float64
+	}{
+		{geometry.Vector3{X: -1}, 3},
+		{geometry.Vector3{X: -0.5, Z: 0.5}, 6},
+		{geometry.Vector3{X: 1}, 5},
+		{geometry.UnitZ(), math.Inf(1)},
+	}
+
+	for _, testCase := range testCases {
+		if result := clip.getShadowDistance(5, testCase.shadowVec, ray); result != testCase.expected {
+			t.Errorf("shadow along %v expected to leave at %v, got %v", testCase.shadowVec, testCase.expected, result)
+		}
+	}
+}
+
+func TestGetRaycastOutput_Clipping(t *testing.T) {
+	object := getObject("cone.vox", t)
+	m := manifest.Manifest{
+		LightingAngle:        45,
+		LightingElevation:    50,
+		Size:                 object.Size.ToVector3(),
+		RenderElevationAngle: 30,
+		Sprites:              []manifest.Sprite{{Angle: 45, Width: 10, Height: 10}},
+	}
+
+	smp := sampler.Square(10, 10, 2, 0, 1)
+	getHits := func(m manifest.Manifest) (hits int, minDepth, maxDepth float64) {
+		minDepth, maxDepth = math.Inf(1), math.Inf(-1)
+		for _, col := range GetRaycastOutput(object, m, m.Sprites[0], smp) {
+			for _, info := range col {
+				for _, s := range info {
+					if s.Collision {
+						hits++
+						minDepth, maxDepth = math.Min(minDepth, s.ViewDepth), math.Max(maxDepth, s.ViewDepth)
+					}
+				}
+			}
+		}
+		return
+	}
+
+	hits, minDepth, maxDepth := getHits(m)
+	if hits == 0 {
+		t.Fatalf("expected unclipped raycast to hit the object")
+	}
+
+	// Clip at the middle of the visible depth, measured from the front of the bounding box
+	withNear := m
+	withNear.NearClip = 1
+	front := getClipping(withNear, m.Sprites[0], m.Size, getViewportMidpoint(m, 0, object.Size), geometry.Zero().Subtract(getRenderDirection(45, float64(m.Sprites[0].RenderElevationAngle)))).near - 1
+	plane := (minDepth + maxDepth) / 2
+
+	clipped := m
+	clipped.NearClip = plane - front
+	nearHits, clippedMin, _ := getHits(clipped)
+
+	// Geometry cut open by the near plane lies on it, give or take rounding
+	if nearHits == 0 || clippedMin < plane-1e-9 {
+		t.Errorf("expected near clip to remove geometry in front of depth %v, got %d hits with nearest at %v", plane, nearHits, clippedMin)
+	}
+
+	clipped = m
+	clipped.FarClip = plane - front
+	farHits, _, clippedMax := getHits(clipped)
+	if farHits == 0 || farHits >= hits || clippedMax > plane {
+		t.Errorf("expected far clip to remove geometry behind depth %v, got %d of %d hits with furthest at %v", plane, farHits, hits, clippedMax)
+	}
+
+	clipped = m
+	clipped.MaxRayDistance = 0.5
+	if distanceHits, _, _ := getHits(clipped); distanceHits >= hits {
+		t.Errorf("expected maximum ray distance to remove geometry, got %d of %d hits", distanceHits, hits)
+	}
+}
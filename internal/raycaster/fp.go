@@ -4,18 +4,36 @@ import (
 	"github.com/mattkimber/gorender/internal/geometry"
 	"github.com/mattkimber/gorender/internal/voxelobject"
 	"math"
+	"sort"
 )
 
-func castFpRay(object voxelobject.ProcessedVoxelObject, loc0 geometry.Vector3, loc geometry.Vector3, ray geometry.Vector3, limits geometry.Vector3, flipY bool) (result RayResult) {
-	if collision, loc, approachedBB := castRayToCandidate(object, loc, ray, limits, flipY); collision {
-		lx, ly, lz, isRecovered := recoverNonSurfaceVoxel(object, loc, ray, limits, flipY)
+// The rounding error allowed for when taking the whole distance a ray travelled
+const depthTolerance = 1e-9
+
+// Cast a ray into the object. Rays which hit a voxel inside the object recover the nearest
+// surface voxel within the recovery radius of the ray, or keep the voxel they hit if the
+// radius is 0.
+func castFpRay(object voxelobject.ProcessedVoxelObject, loc0 geometry.Vector3, loc geometry.Vector3, ray geometry.Vector3, limits geometry.Vector3, flipY bool, recovery int) (result RayResult) {
+	return castFpRayPast(object, loc0, loc, ray, limits, flipY, recovery, 0)
+}
+
+// Cast a ray into the object as castFpRay does, treating voxels of the colour index it is
+// passing as empty
+func castFpRayPast(object voxelobject.ProcessedVoxelObject, loc0 geometry.Vector3, loc geometry.Vector3, ray geometry.Vector3, limits geometry.Vector3, flipY bool, recovery int, passing byte) (result RayResult) {
+	if collision, loc, approachedBB := castRayToCandidate(object, loc, ray, limits, flipY, passing); collision {
+		lx, ly, lz, isRecovered := recoverNonSurfaceVoxel(object, loc, ray, limits, flipY, recovery)
+
+		// Rays which reach a whole distance by a different route, such as mirrored rays,
+		// can fall just short of it, so rounding errors are allowed for
+		distance := loc0.Subtract(loc).Length()
 		return RayResult{
 			X:                     lx,
 			Y:                     ly,
 			Z:                     lz,
 			IsRecovered:           isRecovered,
 			HasGeometry:           true,
-			Depth:                 int(loc0.Subtract(loc).Length()),
+			Depth:                 int(distance + depthTolerance),
+			Distance:              distance,
 			ApproachedBoundingBox: approachedBB,
 		}
 	} else if approachedBB {
@@ -25,12 +43,15 @@ func castFpRay(object voxelobject.ProcessedVoxelObject, loc0 geometry.Vector3, l
 	return
 }
 
-func castRayToCandidate(object voxelobject.ProcessedVoxelObject, loc geometry.Vector3, ray geometry.Vector3, limits geometry.Vector3, flipY bool) (bool, geometry.Vector3, bool) {
+func castRayToCandidate(object voxelobject.ProcessedVoxelObject, loc geometry.Vector3, ray geometry.Vector3, limits geometry.Vector3, flipY bool, passing byte) (bool, geometry.Vector3, bool) {
 	i, fi := 0, 0.0
 	bSizeY := object.Size.Y - 1
 	loc0 := loc
 	approachedBB := false
 
+	// The largest distance moved along any one axis for each unit step of the ray
+	maxComponent := math.Max(math.Abs(ray.X), math.Max(math.Abs(ray.Y), math.Abs(ray.Z)))
+
 	for {
 		// CanTerminate is an expensive check but we don't need to run it every cycle
 		if i%4 == 0 && canTerminateRay(loc, ray, limits) {
@@ -39,15 +60,23 @@ func castRayToCandidate(object voxelobject.ProcessedVoxelObject, loc geometry.Ve
 
 		if isInsideBoundingVolume(loc, limits) {
 			approachedBB = true
-			lx, ly, lz := int(loc.X), int(loc.Y), int(loc.Z)
+			lx, ly, lz := object.GetSliceX(loc.X), int(loc.Y), int(loc.Z)
 
 			if flipY {
 				ly = bSizeY - ly
 			}
 
-			if object.Elements[lx][ly][lz].Index != 0 {
+			if index := object.Elements[lx][ly][lz].Index; index != 0 && index != passing {
 				return true, loc, approachedBB
 			}
+
+			// Skip through empty space: every voxel closer than the empty distance is
+			// known to be empty, so we can move up to that far along each axis.
+			if d := object.Elements[lx][ly][lz].EmptyDistance; d > 1 && maxComponent > 0 {
+				if skip := math.Floor(float64(d-1)/maxComponent) - 1; skip > 0 {
+					fi += skip
+				}
+			}
 		} else if !approachedBB && isNearlyInsideBoundingVolume(loc, limits) {
 			approachedBB = true
 		}
@@ -62,11 +91,15 @@ func castRayToCandidate(object voxelobject.ProcessedVoxelObject, loc geometry.Ve
 
 // Attempt to recover a non-surface voxel by taking a more DDA-like approach where we trace backward up the ray
 // starting with X, then Y, then Z, then repeat until we find a surface voxel or bail.
-func recoverNonSurfaceVoxel(object voxelobject.ProcessedVoxelObject, loc geometry.Vector3, ray geometry.Vector3, limits geometry.Vector3, flipY bool) (lx int, ly int, lz int, isRecovered bool) {
+func recoverNonSurfaceVoxel(object voxelobject.ProcessedVoxelObject, loc geometry.Vector3, ray geometry.Vector3, limits geometry.Vector3, flipY bool, radius int) (lx int, ly int, lz int, isRecovered bool) {
 
 	bSizeY := object.Size.Y - 1
 
-	lx, ly, lz = int(loc.X), int(loc.Y), int(loc.Z)
+	// Voxels around the ray are checked against the object's size rather than the limits,
+	// which include any stretched end slices
+	size := geometry.Vector3{X: float64(object.Size.X), Y: float64(object.Size.Y), Z: float64(object.Size.Z)}
+
+	lx, ly, lz = object.GetSliceX(loc.X), int(loc.Y), int(loc.Z)
 	if flipY {
 		ly = bSizeY - ly
 	}
@@ -78,15 +111,26 @@ func recoverNonSurfaceVoxel(object voxelobject.ProcessedVoxelObject, loc geometr
 	// Signify this voxel was recovered
 	isRecovered = true
 
-	// Check always checks a 9 voxel "halo"
-	check := make([]geometry.Point, 9)
-	checkOrder := []int{4, 1, 7, 3, 5, 0, 2, 6, 8}
+	if radius == 0 {
+		return
+	}
+
+	// Check a "halo" of voxels around the ray, nearest first
+	halo := getRecoveryHalo(radius)
+	check := make([]geometry.Point, len(halo))
+
+	// Offsets across Y follow the direction of the ray through the object, so a mirrored
+	// ray checks the mirrored voxels in the same order
+	dy := 1
+	if (ray.Y < 0) != flipY {
+		dy = -1
+	}
 
 	loc0 := loc
 	x, y, z := ray.X, ray.Y, ray.Z
 
 	for i := 0; i < 10; i++ {
-		lx, ly, lz = int(loc.X), int(loc.Y), int(loc.Z)
+		lx, ly, lz = int(loc.X-object.EndExtension), int(loc.Y), int(loc.Z)
 		if flipY {
 			ly = bSizeY - ly
 		}
@@ -96,36 +140,35 @@ func recoverNonSurfaceVoxel(object voxelobject.ProcessedVoxelObject, loc geometr
 			if math.Abs(x) > math.Abs(y) && math.Abs(x) > math.Abs(z) {
 				// X-major
 
-				for k := 0; k < 9; k++ {
-					check[k] = geometry.Point{X: lx, Y: ly - 1 + (k % 3), Z: lz - 1 + (k / 3)}
+				for k, h := range halo {
+					check[k] = geometry.Point{X: lx, Y: ly + dy*h.a, Z: lz + h.b}
 				}
 
 				x = 0
 			} else if math.Abs(y) > math.Abs(x) && math.Abs(y) > math.Abs(z) {
 				// Y-major
 
-				for k := 0; k < 9; k++ {
-					check[k] = geometry.Point{X: lx - 1 + (k % 3), Y: ly, Z: lz - 1 + (k / 3)}
+				for k, h := range halo {
+					check[k] = geometry.Point{X: lx + h.a, Y: ly, Z: lz + h.b}
 				}
 
 				y = 0
 			} else if math.Abs(z) > math.Abs(x) && math.Abs(z) > math.Abs(y) {
 				// Z-major
 
-				for k := 0; k < 9; k++ {
-					check[k] = geometry.Point{X: lx - 1 + (k % 3), Y: ly - 1 + (k / 3), Z: lz}
+				for k, h := range halo {
+					check[k] = geometry.Point{X: lx + h.a, Y: ly + dy*h.b, Z: lz}
 				}
 
 				z = 0
 			}
 
-			for k := 0; k < 9; k++ {
-				point := check[checkOrder[k]]
+			for _, point := range check {
 				pointF := geometry.Vector3{X: float64(point.X), Y: float64(point.Y), Z: float64(point.Z)}
 
 				lx, ly, lz = point.X, point.Y, point.Z
 
-				if isInsideBoundingVolume(pointF, limits) {
+				if isInsideBoundingVolume(pointF, size) {
 					if object.Elements[lx][ly][lz].IsSurface {
 						return
 					}
@@ -140,7 +183,7 @@ func recoverNonSurfaceVoxel(object voxelobject.ProcessedVoxelObject, loc geometr
 		loc = loc.Subtract(ray.Normalise())
 	}
 
-	lx, ly, lz = int(loc0.X), int(loc0.Y), int(loc0.Z)
+	lx, ly, lz = object.GetSliceX(loc0.X), int(loc0.Y), int(loc0.Z)
 
 	if flipY {
 		ly = bSizeY - ly
@@ -149,6 +192,44 @@ func recoverNonSurfaceVoxel(object voxelobject.ProcessedVoxelObject, loc geometr
 	return
 }
 
+// The position of a voxel in the halo around a ray, along the two axes across the ray
+type haloOffset struct {
+	a, b int
+}
+
+var recoveryHalos = [][]haloOffset{nil, makeRecoveryHalo(1), makeRecoveryHalo(2), makeRecoveryHalo(3)}
+
+func getRecoveryHalo(radius int) []haloOffset {
+	if radius < len(recoveryHalos) {
+		return recoveryHalos[radius]
+	}
+
+	return makeRecoveryHalo(radius)
+}
+
+// Get the voxels within a radius of the ray, ordered by how far they are from it and then
+// along each axis
+func makeRecoveryHalo(radius int) []haloOffset {
+	var halo []haloOffset
+	for b := -radius; b <= radius; b++ {
+		for a := -radius; a <= radius; a++ {
+			halo = append(halo, haloOffset{a, b})
+		}
+	}
+
+	abs := func(v int) int { return max(v, -v) }
+	sort.SliceStable(halo, func(i, j int) bool {
+		di, dj := abs(halo[i].a)+abs(halo[i].b), abs(halo[j].a)+abs(halo[j].b)
+		if di != dj {
+			return di < dj
+		}
+
+		return abs(halo[i].a) < abs(halo[j].a)
+	})
+
+	return halo
+}
+
 func getIntersectionWithBounds(loc, ray, limits geometry.Vector3) geometry.Vector3 {
 	if canTerminateRay(loc, ray, limits) {
 		return loc
@@ -160,18 +241,21 @@ func getIntersectionWithBounds(loc, ray, limits geometry.Vector3) geometry.Vecto
 	return loc
 }
 
+// Get the vector along the ray to the side of the bounds it enters through in one dimension,
+// if it is outside them. Rays heading the opposite way along an axis enter through opposite
+// sides, so mirrored rays start in mirrored places.
 func getIntersectionVector(rayDimension, locDimension, limitDimension float64, ray geometry.Vector3) geometry.Vector3 {
 	dist := -1.0
 
 	if rayDimension > 0.1 {
-		dist = -locDimension
+		dist = -locDimension / rayDimension
 	}
 	if rayDimension < -0.1 {
-		dist = limitDimension - locDimension
+		dist = (limitDimension - locDimension) / rayDimension
 	}
 
 	if dist > 0 {
-		return ray.MultiplyByConstant(dist / rayDimension)
+		return ray.MultiplyByConstant(dist)
 	}
 
 	return geometry.Zero()
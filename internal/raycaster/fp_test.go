@@ -2,6 +2,8 @@ package raycaster
 
 import (
 	"github.com/mattkimber/gorender/internal/geometry"
+	"github.com/mattkimber/gorender/internal/voxelobject"
+	"slices"
 	"testing"
 )
 
@@ -51,11 +53,41 @@ func Test_castFpRay(t *testing.T) {
 	ray := geometry.Vector3{X: -1, Y: 0, Z: -0.125}.Normalise()
 	loc := geometry.Vector3{X: 8, Y: 2, Z: 3}
 
-	testFpResult(t, castFpRay(object, loc, loc, ray, limits, false), 2)
-	testFpResult(t, castFpRay(object, loc, loc, ray, limits, true), 1)
+	testFpResult(t, castFpRay(object, loc, loc, ray, limits, false, 1), 2)
+	testFpResult(t, castFpRay(object, loc, loc, ray, limits, true, 1), 1)
 
 }
 
+func Test_castFpRay_EndExtension(t *testing.T) {
+	object := voxelobject.ProcessedVoxelObject{
+		Elements: [][][]voxelobject.ProcessedElement{{{{Index: 1, IsSurface: true}}}},
+		Size:     geometry.Point{X: 1, Y: 1, Z: 1},
+	}
+
+	ray := geometry.Vector3{Z: -1}
+
+	testCases := []struct {
+		x, extension float64
+		expected     bool
+	}{
+		{0.5, 0, true},
+		{1.25, 0, false},
+		{0.25, 0.5, true},
+		{1.75, 0.5, true},
+		{2.25, 0.5, false},
+	}
+
+	for _, testCase := range testCases {
+		object.EndExtension = testCase.extension
+		loc := geometry.Vector3{X: testCase.x, Y: 0.5, Z: 0.5}
+
+		result := castFpRay(object, loc, loc, ray, getLimits(object), false, 1)
+		if result.HasGeometry != testCase.expected || (result.HasGeometry && result.X != 0) {
+			t.Errorf("ray at x=%g with end extension %g expected geometry %v, got %v at x=%d", testCase.x, testCase.extension, testCase.expected, result.HasGeometry, result.X)
+		}
+	}
+}
+
 func testFpResult(t *testing.T, result RayResult, expectedY int) {
 	if !result.HasGeometry {
 		t.Errorf("did not find geometry")
@@ -69,3 +101,58 @@ func testFpResult(t *testing.T, result RayResult, expectedY int) {
 		t.Errorf("incorrect depth - expected 5, got %d", result.Depth)
 	}
 }
+
+func Test_castFpRay_Recovery(t *testing.T) {
+	// A voxel inside the object, with a surface voxel beyond the default radius of the search
+	elements := make([][][]voxelobject.ProcessedElement, 7)
+	for x := range elements {
+		elements[x] = [][]voxelobject.ProcessedElement{make([]voxelobject.ProcessedElement, 1)}
+	}
+
+	elements[2][0][0] = voxelobject.ProcessedElement{Index: 1}
+	elements[6][0][0] = voxelobject.ProcessedElement{Index: 1, IsSurface: true}
+
+	object := voxelobject.ProcessedVoxelObject{Elements: elements, Size: geometry.Point{X: 7, Y: 1, Z: 1}}
+	ray := geometry.Vector3{Z: -1}
+	loc := geometry.Vector3{X: 2.5, Y: 0.5, Z: 0.5}
+
+	testCases := []struct {
+		recovery, expected int
+	}{
+		{0, 2},
+		{1, 2},
+		{2, 6},
+	}
+
+	for _, testCase := range testCases {
+		result := castFpRay(object, loc, loc, ray, getLimits(object), false, testCase.recovery)
+		if !result.HasGeometry || !result.IsRecovered || result.X != testCase.expected {
+			t.Errorf("recovery %d: expected recovered voxel at x=%d, got %v", testCase.recovery, testCase.expected, result)
+		}
+	}
+}
+
+func Test_getRecoveryHalo(t *testing.T) {
+	// The nearest voxels are checked first, then those at the corners
+	expected := []haloOffset{{0, 0}, {0, -1}, {0, 1}, {-1, 0}, {1, 0}, {-1, -1}, {1, -1}, {-1, 1}, {1, 1}}
+	if result := getRecoveryHalo(1); !slices.Equal(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+
+	for radius := 1; radius <= 4; radius++ {
+		halo := getRecoveryHalo(radius)
+		if len(halo) != (2*radius+1)*(2*radius+1) {
+			t.Errorf("radius %d: expected %d voxels, got %d", radius, (2*radius+1)*(2*radius+1), len(halo))
+		}
+
+		for i := 1; i < len(halo); i++ {
+			if distance(halo[i]) < distance(halo[i-1]) {
+				t.Errorf("radius %d: %v is checked after %v, which is further away", radius, halo[i], halo[i-1])
+			}
+		}
+	}
+}
+
+func distance(h haloOffset) int {
+	return max(h.a, -h.a) + max(h.b, -h.b)
+}
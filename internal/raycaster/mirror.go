@@ -0,0 +1,167 @@
+package raycaster
+
+import (
+	"github.com/mattkimber/gorender/internal/manifest"
+	"github.com/mattkimber/gorender/internal/sampler"
+	"github.com/mattkimber/gorender/internal/voxelobject"
+	"math"
+	"sync"
+)
+
+// Get the output for a sprite by mirroring the output of the sprite at the opposite angle,
+// for objects which are symmetric about their long axis. Each sample takes the voxel hit by
+// the mirrored sample of the mirrored pixel, with its coordinates and normals mirrored, then
+// is lit and shadowed for the new angle as if it had been raycast. Every sample must have a
+// mirrored counterpart, so false is returned for samples which aren't symmetric.
+func Mirror(output RenderOutput, object voxelobject.ProcessedVoxelObject, m manifest.Manifest, spr manifest.Sprite, smp sampler.Samples) (RenderOutput, bool) {
+	w, h := smp.Width(), smp.Height()
+
+	// Which samples pass translucent voxels depends on their order within the pixel, which
+	// mirroring doesn't keep
+	if object.Palette != nil && object.Palette.HasTransparency() {
+		return nil, false
+	}
+
+	sources, ok := getMirroredSamples(smp)
+	if !ok {
+		return nil, false
+	}
+
+	sc := getScene(object, m, spr, w, h)
+	result := (&OutputBuffer{}).getOutput(w, h, func(x, y int) int { return len(smp[x][y]) })
+
+	wg := sync.WaitGroup{}
+	wg.Add(w)
+
+	for x := 0; x < w; x++ {
+		thisX := x
+		go func() {
+			defer wg.Done()
+			for y := 0; y < h; y++ {
+				hits := getSampleHits(output[w-1-thisX][y], smp[w-1-thisX][y])
+				sc.mirrorPixel(hits, sources[thisX][y], smp[thisX][y], result[thisX][y])
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return result, true
+}
+
+// Check raycast output can be mirrored. Only output raycast one sample at a time can be, as
+// beams are cast through the centre of each pixel and keep nothing of the samples.
+func CanMirror(m manifest.Manifest) bool {
+	_, ok := GetAlgorithm(m.Raycaster).(samplerAlgorithm)
+	return ok
+}
+
+// Get the sample of the mirrored pixel which is the mirror image of each sample of each
+// pixel. Locations are across the whole sprite, so are mirrored about its centre.
+func getMirroredSamples(smp sampler.Samples) (sources [][][]int, ok bool) {
+	w := smp.Width()
+	sources = make([][][]int, w)
+
+	for x := range smp {
+		sources[x] = make([][]int, len(smp[x]))
+
+		for y, samples := range smp[x] {
+			mirrored := smp[w-1-x][y]
+			if len(mirrored) != len(samples) {
+				return nil, false
+			}
+
+			sources[x][y] = make([]int, len(samples))
+			for i, s := range samples {
+				sources[x][y][i] = -1
+				for j, ms := range mirrored {
+					if math.Abs(1-ms.Location.X-s.Location.X) < 1e-9 && math.Abs(ms.Location.Y-s.Location.Y) < 1e-9 && math.Abs(ms.Influence-s.Influence) < 1e-9 {
+						sources[x][y][i] = j
+						break
+					}
+				}
+
+				if sources[x][y][i] == -1 {
+					return nil, false
+				}
+			}
+		}
+	}
+
+	return sources, true
+}
+
+// Get the voxel hit by each sample of a pixel. Raycasting merges samples which hit the same
+// voxel as the sample before into that sample, keeping only their depth and whether they were
+// recovered, so they are separated again here.
+func getSampleHits(output RenderInfo, samples sampler.SampleList) []RenderSample {
+	hits := make([]RenderSample, len(output))
+	last := -1
+
+	for i, s := range output {
+		switch {
+		case s.Collision:
+			hits[i], last = s, i
+		case s.Count == 0 && last != -1:
+			hits[i] = output[last]
+			hits[i].Depth, hits[i].ViewDepth, hits[i].IsRecovered = s.Depth, s.ViewDepth, s.IsRecovered
+		default:
+			hits[i] = s
+		}
+
+		hits[i].Count = 1
+		if hits[i].Collision {
+			hits[i].Influence = samples[i].Influence
+		}
+	}
+
+	return hits
+}
+
+// Fill the output for a pixel from the mirrored voxel hits of the mirrored pixel, merging
+// samples which hit the same voxel in turn as raycasting does
+func (sc *scene) mirrorPixel(hits []RenderSample, sources []int, samples sampler.SampleList, output RenderInfo) {
+	pi := -1
+
+	for i, source := range sources {
+		hit := hits[source]
+		output[i] = RenderSample{Count: 1, Cast: hit.Cast}
+
+		if !hit.Collision {
+			continue
+		}
+
+		hit.Y = int16(sc.object.Size.Y-1) - hit.Y
+		if pi != -1 && output[pi].X == hit.X && output[pi].Y == hit.Y && output[pi].Z == hit.Z {
+			output[pi].Influence += samples[i].Influence
+			output[pi].Count++
+			output[i].Count = 0
+			output[i].Depth, output[i].ViewDepth, output[i].IsRecovered = hit.Depth, hit.ViewDepth, hit.IsRecovered
+			continue
+		}
+
+		// The mirrored voxel may have been processed differently, so its own details are used
+		element := sc.object.Elements[hit.X][hit.Y][hit.Z]
+		hit.Index, hit.Occlusion, hit.Detail = element.Index, element.Occlusion, element.Detail
+
+		hit.Influence = samples[i].Influence
+		hit.Normal.Y = -hit.Normal.Y
+		hit.AveragedNormal.Y = -hit.AveragedNormal.Y
+		hit.LightAmount = getLightingValue(hit.AveragedNormal, sc.lighting)
+
+		shadowLength := sc.getShadowLength(int(hit.X), int(hit.Y), int(hit.Z), hit.AveragedNormal, hit.ViewDepth)
+		hit.Shadowing = getShadowing(shadowLength, hit.LightAmount, sc.m)
+
+		output[i], pi = hit, i
+	}
+}
+
+// Get the angle a sprite of a symmetric object can be mirrored from
+func GetMirrorAngle(angle float64) float64 {
+	mirror := math.Mod(360-angle, 360)
+	if mirror < 0 {
+		mirror += 360
+	}
+
+	return mirror
+}
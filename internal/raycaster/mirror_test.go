@@ -0,0 +1,145 @@
+package raycaster
+
+import (
+	gandalfgeo "github.com/mattkimber/gandalf/geometry"
+	"github.com/mattkimber/gandalf/magica"
+	"github.com/mattkimber/gorender/internal/colour"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"github.com/mattkimber/gorender/internal/sampler"
+	"github.com/mattkimber/gorender/internal/voxelobject"
+	"math"
+	"testing"
+)
+
+func TestGetMirrorAngle(t *testing.T) {
+	testCases := []struct {
+		angle, expected float64
+	}{
+		{0, 0},
+		{45, 315},
+		{90, 270},
+		{180, 180},
+		{225, 135},
+		{315, 45},
+		{-45, 45},
+	}
+
+	for _, testCase := range testCases {
+		if result := GetMirrorAngle(testCase.angle); result != testCase.expected {
+			t.Errorf("angle %g: expected %g, got %g", testCase.angle, testCase.expected, result)
+		}
+	}
+}
+
+func TestMirror(t *testing.T) {
+	object := getSymmetricObject()
+	m := manifest.Manifest{LightingAngle: 60, LightingElevation: 30, Size: object.Size.ToVector3(), Accuracy: 2}
+	smp := sampler.Square(40, 40, 2, 0, 1)
+
+	for _, angle := range []float64{45, 135} {
+		src := manifest.Sprite{Angle: angle, Width: 40, Height: 40, RenderElevationAngle: 30}
+		dst := src
+		dst.Angle = GetMirrorAngle(angle)
+
+		expected := GetRaycastOutput(object, m, dst, smp)
+		result, ok := Mirror(GetRaycastOutput(object, m, src, smp), object, m, dst, smp)
+		if !ok {
+			t.Fatalf("angle %g: expected output to be mirrored", angle)
+		}
+
+		// Mirrored output is the same as raycast output, other than rounding
+		differences := 0
+		for x := range expected {
+			for y := range expected[x] {
+				for i, e := range expected[x][y] {
+					r := result[x][y][i]
+					if math.Abs(r.ViewDepth-e.ViewDepth) < 1e-9 {
+						r.ViewDepth = e.ViewDepth
+					}
+
+					if math.Abs(r.Normal.Y-e.Normal.Y) < 1e-9 && math.Abs(r.AveragedNormal.Y-e.AveragedNormal.Y) < 1e-9 {
+						r.Normal.Y, r.AveragedNormal.Y = e.Normal.Y, e.AveragedNormal.Y
+					}
+
+					if r != e {
+						differences++
+					}
+				}
+			}
+		}
+
+		if differences != 0 {
+			t.Errorf("angle %g: expected mirrored output to match raycast output, %d samples differ", angle, differences)
+		}
+	}
+}
+
+func TestMirror_AsymmetricSamples(t *testing.T) {
+	object := getSymmetricObject()
+	m := manifest.Manifest{Size: object.Size.ToVector3()}
+	spr := manifest.Sprite{Angle: 315, Width: 8, Height: 8}
+
+	// Samples which overlap the next pixel are not mirror images of each other
+	smp := sampler.Square(8, 8, 2, 0.5, 1)
+	if _, ok := Mirror(GetRaycastOutput(object, m, spr, smp), object, m, spr, smp); ok {
+		t.Errorf("expected asymmetric samples not to be mirrored")
+	}
+}
+
+func TestMirror_Translucent(t *testing.T) {
+	object := getSymmetricObject()
+	translucent := colour.Palette{Entries: make([]colour.PaletteEntry, 256)}
+	translucent.SetRanges([]colour.PaletteRange{{Start: 0, End: 254}, {Start: 255, End: 255, Transparency: 0.5}})
+	object.Palette = &translucent
+
+	m := manifest.Manifest{Size: object.Size.ToVector3()}
+	spr := manifest.Sprite{Angle: 315, Width: 8, Height: 8}
+	smp := sampler.Square(8, 8, 2, 0, 1)
+
+	// Which samples pass translucent voxels depends on their order within the pixel
+	if _, ok := Mirror(GetRaycastOutput(object, m, spr, smp), object, m, spr, smp); ok {
+		t.Errorf("expected an object with translucent colours not to be mirrored")
+	}
+}
+
+func TestCanMirror(t *testing.T) {
+	testCases := []struct {
+		raycaster string
+		expected  bool
+	}{
+		{"", true},
+		{"sampler", true},
+		{"beam", false},
+	}
+
+	for _, testCase := range testCases {
+		if result := CanMirror(manifest.Manifest{Raycaster: testCase.raycaster}); result != testCase.expected {
+			t.Errorf("raycaster %q: expected %v, got %v", testCase.raycaster, testCase.expected, result)
+		}
+	}
+}
+
+// Get a body with a cab and a post on each side, symmetric in Y, so the posts and cab
+// shadow the body differently on each side
+func getSymmetricObject() voxelobject.ProcessedVoxelObject {
+	v := magica.VoxelObject{Size: gandalfgeo.Point{X: 24, Y: 12, Z: 12}}
+	v.Voxels = make([][][]byte, v.Size.X)
+	for x := range v.Voxels {
+		v.Voxels[x] = make([][]byte, v.Size.Y)
+		for y := range v.Voxels[x] {
+			v.Voxels[x][y] = make([]byte, v.Size.Z)
+			for z := range v.Voxels[x][y] {
+				body := x >= 2 && x < 22 && y >= 2 && y < 10 && z < 5
+				cab := x >= 14 && x < 20 && y >= 3 && y < 9 && z < 10
+				post := (x == 5 || x == 6) && (y == 1 || y == 10) && z < 9
+				if body || cab || post {
+					v.Voxels[x][y][z] = byte(1 + min(y, 11-y)%3)
+				}
+			}
+		}
+	}
+
+	palette := colour.Palette{Entries: make([]colour.PaletteEntry, 256)}
+	palette.SetRanges([]colour.PaletteRange{{Start: 0, End: 255}})
+	return voxelobject.GetProcessedVoxelObject(v, &palette, false, "normal", false, false)
+}
@@ -5,7 +5,9 @@ import (
 	"github.com/mattkimber/gorender/internal/manifest"
 	"github.com/mattkimber/gorender/internal/sampler"
 	"github.com/mattkimber/gorender/internal/voxelobject"
+	"math"
 	"sync"
+	"unsafe"
 )
 
 type RenderInfo []RenderSample
@@ -13,6 +15,7 @@ type RenderInfo []RenderSample
 type RenderSample struct {
 	Collision              bool
 	Index                  byte
+	X, Y, Z                int16
 	Normal, AveragedNormal geometry.Vector3
 	Depth, Occlusion       int
 	LightAmount            float64
@@ -21,19 +24,67 @@ type RenderSample struct {
 	Detail                 float64
 	Count                  int
 	IsRecovered            bool
+	Cast                   bool
+	ViewDepth              float64
+
+	// Whether the sample passed a translucent voxel with nothing behind it
+	IsSeeThrough bool
 }
 
 type RayResult struct {
 	X, Y, Z               int
 	HasGeometry           bool
 	Depth                 int
+	Distance              float64
 	IsRecovered           bool
 	ApproachedBoundingBox bool
 }
 
 type RenderOutput [][]RenderInfo
 
+// Get the memory in bytes taken by the raycast output for one pixel with these samples
+func GetOutputSize(samples sampler.SampleList, algorithm Algorithm) int64 {
+	return int64(unsafe.Sizeof(RenderInfo{})) + int64(algorithm.OutputSize(samples))*int64(unsafe.Sizeof(RenderSample{}))
+}
+
 func GetRaycastOutput(object voxelobject.ProcessedVoxelObject, m manifest.Manifest, spr manifest.Sprite, sampler sampler.Samples) RenderOutput {
+	return (&OutputBuffer{}).GetRaycastOutput(object, m, spr, sampler)
+}
+
+// Raycast into the buffer, overwriting the output of the previous call
+func (b *OutputBuffer) GetRaycastOutput(object voxelobject.ProcessedVoxelObject, m manifest.Manifest, spr manifest.Sprite, sampler sampler.Samples) RenderOutput {
+	algorithm := GetAlgorithm(m.Raycaster)
+	w, h := sampler.Width(), sampler.Height()
+	result := b.getOutput(w, h, func(x, y int) int { return algorithm.OutputSize(sampler[x][y]) })
+	raycast(object, m, spr, sampler, result, nil)
+
+	return result
+}
+
+// The view of an object for one sprite, which is shared by every ray cast for the sprite
+type scene struct {
+	object                voxelobject.ProcessedVoxelObject
+	m                     manifest.Manifest
+	spr                   manifest.Sprite
+	viewport              geometry.Plane
+	midpoint, ray, limits geometry.Vector3
+	lighting              geometry.Vector3
+	shadows               *voxelobject.ShadowMap
+	clip                  clipping
+	minX, maxX            int
+	joggle                float64
+
+	// How far around rays which hit inside the object to search for a surface voxel, and
+	// whether the voxels found are lit as if they faced the camera
+	recovery      int
+	ignoreNormals bool
+
+	// The size of an output pixel within the viewport, and its width measured in voxels
+	pixel     geometry.Vector2
+	pixelSize float64
+}
+
+func getScene(object voxelobject.ProcessedVoxelObject, m manifest.Manifest, spr manifest.Sprite, w, h int) *scene {
 	size := object.Size
 
 	// Handle slicing functionality
@@ -55,132 +106,266 @@ func GetRaycastOutput(object voxelobject.ProcessedVoxelObject, m manifest.Manife
 		}
 	}
 
-	limits := geometry.Vector3{X: float64(size.X), Y: float64(size.Y), Z: float64(size.Z)}
+	object.EndExtension = m.JoinOverlap
+	limits := getLimits(object)
 
-	viewport := getViewportPlane(spr.Angle, m, spr.ZError, size, float64(spr.RenderElevationAngle))
+	viewport := getViewportPlane(spr.Angle, m, spr.ZError, size, float64(spr.RenderElevationAngle), getViewportScale(spr))
+	midpoint := getViewportMidpoint(m, spr.ZError, size)
 	ray := geometry.Zero().Subtract(getRenderDirection(spr.Angle, float64(spr.RenderElevationAngle)))
 
+	// Pixels are usually square, but take the average of both sides in case they are not
+	pixel := geometry.Vector2{X: 1 / float64(w), Y: 1 / float64(h)}
+	origin := viewport.BiLerpWithinPlane(0, 0)
+	pixelSize := (viewport.BiLerpWithinPlane(pixel.X, 0).Subtract(origin).Length() +
+		viewport.BiLerpWithinPlane(0, pixel.Y).Subtract(origin).Length()) / 2
+
 	lighting := getLightingDirection(spr.Angle+float64(m.LightingAngle), float64(m.LightingElevation), spr.Flip)
-	result := make(RenderOutput, len(sampler))
 
-	wg := sync.WaitGroup{}
-	wg.Add(sampler.Width())
+	recovered, recovery := m.GetRecoveredVoxels(), 0
+	if !recovered.Disabled {
+		recovery = recovered.GetRadius()
+	}
 
-	joggle := spr.Joggle + m.Joggle
+	return &scene{
+		object:    object,
+		m:         m,
+		spr:       spr,
+		viewport:  viewport,
+		midpoint:  midpoint,
+		ray:       ray,
+		limits:    limits,
+		lighting:  lighting,
+		shadows:   object.GetShadowMap(lighting),
+		clip:      getClipping(m, spr, limits, midpoint, ray),
+		minX:      minX,
+		maxX:      maxX,
+		joggle:    spr.Joggle + m.Joggle,
+		pixel:     pixel,
+		pixelSize: pixelSize,
+
+		recovery:      recovery,
+		ignoreNormals: recovered.IgnoreNormals,
+	}
+}
 
+// Raycast the samples into the result, which must have room for them. If include is
+// set, only the pixels it returns true for are raycast.
+func raycast(object voxelobject.ProcessedVoxelObject, m manifest.Manifest, spr manifest.Sprite, sampler sampler.Samples, result RenderOutput, include func(x, y int) bool) {
 	w, h := sampler.Width(), sampler.Height()
+	sc := getScene(object, m, spr, w, h)
+	algorithm := GetAlgorithm(m.Raycaster)
+
+	wg := sync.WaitGroup{}
+	wg.Add(sampler.Width())
 
 	for x := 0; x < w; x++ {
 		thisX := x
 		go func() {
-			result[thisX] = make([]RenderInfo, h)
 			for y := 0; y < h; y++ {
-				samples := sampler[thisX][y]
-				result[thisX][y] = make(RenderInfo, len(samples))
-				raycastSamples(viewport, &samples, ray, limits, object, m, spr, lighting, result, thisX, y, minX, maxX, joggle)
+				if include != nil && !include(thisX, y) {
+					continue
+				}
+
+				algorithm.castPixel(sc, sampler[thisX][y], result[thisX][y])
 			}
 			wg.Done()
 		}()
 	}
 
 	wg.Wait()
+}
 
-	return result
+// A ray cast into the scene from a point on the viewport
+type sceneRay struct {
+	loc0   geometry.Vector3
+	start  float64
+	result RayResult
+
+	// Whether the ray hit geometry cut open by the near plane
+	isCut bool
 }
 
-func raycastSamples(
-	viewport geometry.Plane,
-	samples *sampler.SampleList,
-	ray geometry.Vector3,
-	limits geometry.Vector3,
-	object voxelobject.ProcessedVoxelObject,
-	m manifest.Manifest,
-	spr manifest.Sprite,
-	lighting geometry.Vector3,
-	result RenderOutput,
-	thisX int,
-	y int,
-	minX int,
-	maxX int,
-	joggle float64) {
-
-	px, py, pz, pi := 0, 0, 0, 0
-
-	for i := range *samples {
-		result[thisX][y][i].Count = 1
-	}
-
-	for i, s := range *samples {
-		loc0 := viewport.BiLerpWithinPlane(s.Location.X, s.Location.Y)
-		loc0.Z += joggle
-		loc := getIntersectionWithBounds(loc0, ray, limits)
-
-		rayResult := castFpRay(object, loc0, loc, ray, limits, spr.Flip)
-
-		if rayResult.HasGeometry && rayResult.X >= minX && rayResult.X <= maxX {
-			// Speed up for cases where we already encountered this voxel - reduce the amount of sampling needed
-			// later
-			if rayResult.X == px && rayResult.Y == py && rayResult.Z == pz {
-				result[thisX][y][pi].Influence += s.Influence
-				result[thisX][y][pi].Count++
-
-				// Set the count for this element to 0
-				result[thisX][y][i].Count = 0
-				continue
-			} else {
-				px = rayResult.X
-				py = rayResult.Y
-				pz = rayResult.Z
-				pi = i
-			}
+// Cast a ray from a location within the viewport
+func (sc *scene) cast(location geometry.Vector2) (r sceneRay) {
+	r.loc0 = sc.viewport.BiLerpWithinPlane(location.X, location.Y)
+	r.loc0.Z += sc.joggle
+	loc := getIntersectionWithBounds(r.loc0, sc.ray, sc.limits)
 
-			shadowResult := 0
-			if getLightingValue(object.Elements[rayResult.X][rayResult.Y][rayResult.Z].AveragedNormal, lighting) > m.ShadowThreshold {
-				resultVec := geometry.Vector3{X: float64(rayResult.X), Y: float64(rayResult.Y), Z: float64(rayResult.Z)}
-				shadowLoc := resultVec
+	start, clipped := sc.clip.getStartDistance(r.loc0, loc, sc.midpoint, sc.ray)
+	if clipped {
+		loc = r.loc0.Add(sc.ray.MultiplyByConstant(start))
+	}
+	r.start = start
 
-				shadowVec := geometry.Zero().Subtract(lighting).Normalise()
+	r.result = castFpRay(sc.object, r.loc0, loc, sc.ray, sc.limits, sc.spr.Flip, sc.recovery)
+	if r.result.HasGeometry && !sc.clip.isVisible(r.result.Distance, start, r.loc0, sc.midpoint, sc.ray, sc.limits) {
+		r.result.HasGeometry = false
+	}
 
-				for {
-					sx, sy, sz := int(shadowLoc.X), int(shadowLoc.Y), int(shadowLoc.Z)
+	// Geometry within a voxel of the near plane has been cut open, so is drawn as it is
+	// rather than recovered from the surface, and faces the camera
+	r.isCut = clipped && r.result.HasGeometry && r.result.Distance-start < 1
+	if r.isCut {
+		hit := r.loc0.Add(sc.ray.MultiplyByConstant(r.result.Distance))
+		r.result.X, r.result.Y, r.result.Z, r.result.IsRecovered = sc.object.GetSliceX(hit.X), int(hit.Y), int(hit.Z), false
+		if sc.spr.Flip {
+			r.result.Y = sc.object.Size.Y - 1 - r.result.Y
+		}
+	}
 
-					if sx != rayResult.X || sy != rayResult.Y || sz != rayResult.Z {
-						break
-					}
+	return
+}
 
-					shadowLoc = shadowLoc.Add(shadowVec)
-				}
+// Check whether the ray hit geometry within the slice being rendered
+// Samples pass a translucent voxel when their threshold is below its transparency, so a
+// pixel shows what is behind the voxel in proportion to its transparency. Thresholds are
+// spread evenly over any number of samples by stepping through them by the golden ratio.
+const translucentThresholdStep = 0.6180339887498949
 
-				// Don't flip Y when calculating shadows, as it has been pre-flipped on input.
-				shadowResult = castFpRay(object, shadowLoc, shadowLoc, shadowVec, limits, false).Depth
-			}
-			setResult(&result[thisX][y][i], object.Elements[rayResult.X][rayResult.Y][rayResult.Z], lighting, rayResult.Depth, shadowResult, s.Influence, rayResult.IsRecovered, m)
-		} else if !rayResult.ApproachedBoundingBox {
-			// Optimise the outside-bounding-box cases by skipping all further samples
-			break
+// Whether the sample at this index within a pixel passes a voxel of this transparency
+func passesTranslucentVoxel(i int, transparency float64) bool {
+	return math.Mod(0.5+float64(i)*translucentThresholdStep, 1) < transparency
+}
+
+// Get the transparency of the voxel a ray hit
+func (sc *scene) getTransparency(r sceneRay) float64 {
+	if sc.object.Palette == nil {
+		return 0
+	}
+
+	return sc.object.Palette.GetTransparency(sc.object.Elements[r.result.X][r.result.Y][r.result.Z].Index)
+}
+
+// Continue a ray which hit a translucent voxel to whatever is behind it, passing any voxels
+// of the same colour
+func (sc *scene) castPast(r sceneRay) sceneRay {
+	passing := sc.object.Elements[r.result.X][r.result.Y][r.result.Z].Index
+	loc := r.loc0.Add(sc.ray.MultiplyByConstant(r.result.Distance))
+
+	behind := r
+	behind.result = castFpRayPast(sc.object, r.loc0, loc, sc.ray, sc.limits, sc.spr.Flip, sc.recovery, passing)
+	if behind.result.HasGeometry && !sc.clip.isVisible(behind.result.Distance, r.start, r.loc0, sc.midpoint, sc.ray, sc.limits) {
+		behind.result.HasGeometry = false
+	}
+
+	// Recovering the nearest surface can lead back to the voxel passed, which leaves nothing
+	// behind it to see
+	if behind.result.HasGeometry && sc.object.Elements[behind.result.X][behind.result.Y][behind.result.Z].Index == passing {
+		behind.result.HasGeometry = false
+	}
+
+	// Samples seen through the voxel take its depth, so they aren't weighted down against
+	// the samples which hit it for being further away
+	behind.result.Depth = r.result.Depth
+	return behind
+}
+
+func (sc *scene) isHit(r sceneRay) bool {
+	return r.result.HasGeometry && r.result.X >= sc.minX && r.result.X <= sc.maxX
+}
+
+// Set the output sample for a ray which hit the object
+func (sc *scene) setSample(output *RenderSample, r sceneRay, influence float64) {
+	object, clip, lighting := sc.object, sc.clip, sc.lighting
+	rayResult := r.result
+
+	element := object.Elements[rayResult.X][rayResult.Y][rayResult.Z]
+	if !r.isCut {
+		if normal, ok := object.GetNormalAt(sc.getObjectLocation(r.loc0.Add(sc.ray.MultiplyByConstant(rayResult.Distance)))); ok {
+			element.Normal, element.AveragedNormal = normal, normal
 		}
 	}
+
+	if r.isCut || (rayResult.IsRecovered && sc.ignoreNormals) {
+		element.Normal, element.AveragedNormal = clip.normal, clip.normal
+	} else if clip.isNearClipped() && element.AveragedNormal.Dot(clip.normal) < 0 {
+		// Surfaces seen from behind through the cut, such as the inside of a wall,
+		// are lit as if they faced the camera
+		element.Normal = geometry.Zero().Subtract(element.Normal)
+		element.AveragedNormal = geometry.Zero().Subtract(element.AveragedNormal)
+	}
+
+	viewDepth := sc.getViewDepth(r)
+	shadowResult := sc.getShadowLength(rayResult.X, rayResult.Y, rayResult.Z, element.AveragedNormal, viewDepth)
+	setResult(output, element, lighting, rayResult.Depth, shadowResult, influence, rayResult.IsRecovered, sc.m)
+	output.ViewDepth = viewDepth
+	output.X, output.Y, output.Z = int16(rayResult.X), int16(rayResult.Y), int16(rayResult.Z)
 }
 
-func setResult(result *RenderSample, element voxelobject.ProcessedElement, lighting geometry.Vector3, depth int, shadowLength int, influence float64, isRecovered bool, m manifest.Manifest) {
+// Get the distance behind the centre of the object a ray hit, measured along the view direction
+func (sc *scene) getViewDepth(r sceneRay) float64 {
+	return r.loc0.Subtract(sc.midpoint).Dot(sc.ray) + r.result.Distance
+}
 
-	if shadowLength > 0 && shadowLength < 10 {
-		result.Shadowing = 1.0
-	} else if shadowLength > 0 && shadowLength < 80 {
-		result.Shadowing = float64(70-(shadowLength-10)) / 80.0
+// Get the distance from a voxel seen at a depth to whatever shadows it, or 0 if it is not
+// shadowed. Surfaces facing away from the light are never shadowed.
+func (sc *scene) getShadowLength(x, y, z int, normal geometry.Vector3, viewDepth float64) int {
+	if getLightingValue(normal, sc.lighting) <= sc.m.ShadowThreshold {
+		return 0
+	}
+
+	shadowVec := geometry.Zero().Subtract(sc.lighting).Normalise()
+	shadowResult := sc.shadows.Get(x, y, z, func() int {
+		return sc.castShadow(x, y, z, shadowVec)
+	})
+
+	// Geometry beyond the clipping planes has been cut away, so casts no shadow
+	viewShadowVec := shadowVec
+	if sc.spr.Flip {
+		viewShadowVec.Y = -viewShadowVec.Y
+	}
+
+	if float64(shadowResult) > sc.clip.getShadowDistance(viewDepth, viewShadowVec, sc.ray) {
+		return 0
+	}
+
+	return shadowResult
+}
+
+// Get the distance from a voxel to whatever shadows it. This only depends on the voxel and
+// the lighting, so is kept in the shadow map for every sprite lit the same way.
+func (sc *scene) castShadow(x, y, z int, shadowVec geometry.Vector3) int {
+	object := sc.object
+	shadowLoc := geometry.Vector3{X: float64(x) + object.EndExtension, Y: float64(y), Z: float64(z)}
+
+	for {
+		sx, sy, sz := int(shadowLoc.X-object.EndExtension), int(shadowLoc.Y), int(shadowLoc.Z)
+
+		if sx != x || sy != y || sz != z {
+			break
+		}
+
+		shadowLoc = shadowLoc.Add(shadowVec)
+	}
+
+	// Don't flip Y when calculating shadows, as it has been pre-flipped on input. Only the
+	// depth is needed, so there is no need to recover a surface voxel.
+	return castFpRay(object, shadowLoc, shadowLoc, shadowVec, sc.limits, false, 0).Depth
+}
+
+// Get a location in the object's space, where its voxels are at their own coordinates, from
+// a location in the space rays are cast through
+func (sc *scene) getObjectLocation(loc geometry.Vector3) geometry.Vector3 {
+	loc.X = math.Min(math.Max(loc.X-sc.object.EndExtension, 0), float64(sc.object.Size.X))
+	if sc.spr.Flip {
+		loc.Y = float64(sc.object.Size.Y) - loc.Y
 	}
 
+	return loc
+}
+
+// Get the size of the space the object occupies, including any stretched end slices
+func getLimits(object voxelobject.ProcessedVoxelObject) geometry.Vector3 {
+	size := object.Size
+	return geometry.Vector3{X: float64(size.X) + object.EndExtension*2, Y: float64(size.Y), Z: float64(size.Z)}
+}
+
+func setResult(result *RenderSample, element voxelobject.ProcessedElement, lighting geometry.Vector3, depth int, shadowLength int, influence float64, isRecovered bool, m manifest.Manifest) {
 	result.Collision = true
 	result.Index = element.Index
 	result.Depth = depth
 	result.LightAmount = getLightingValue(element.AveragedNormal, lighting)
-	if result.LightAmount > m.ShadowThreshold {
-		if m.SoftShadow {
-			result.Shadowing = result.Shadowing * (result.LightAmount - m.ShadowThreshold) / (1.0 - m.ShadowThreshold)
-		}
-	} else {
-		result.Shadowing = 0.0
-	}
+	result.Shadowing = getShadowing(shadowLength, result.LightAmount, m)
 	result.Normal = element.Normal
 	result.Occlusion = element.Occlusion
 	result.AveragedNormal = element.AveragedNormal
@@ -190,6 +375,43 @@ func setResult(result *RenderSample, element voxelobject.ProcessedElement, light
 	result.IsRecovered = isRecovered
 }
 
+// Get how shadowed a surface is from the distance to whatever shadows it and the light it
+// faces. Shadows fade out with distance, and soft shadows fade out on surfaces facing away
+// from the light.
+func getShadowing(shadowLength int, lightAmount float64, m manifest.Manifest) (shadowing float64) {
+	if lightAmount <= m.ShadowThreshold {
+		return 0
+	}
+
+	if shadowLength > 0 && shadowLength < 10 {
+		shadowing = 1.0
+	} else if shadowLength > 0 && shadowLength < 80 {
+		shadowing = float64(70-(shadowLength-10)) / 80.0
+	}
+
+	if m.SoftShadow {
+		shadowing = shadowing * (lightAmount - m.ShadowThreshold) / (1.0 - m.ShadowThreshold)
+	}
+
+	return
+}
+
+// Recalculate lighting for previously raycast output, e.g. when lighting angles have changed.
+// Shadows are not recalculated as this needs the voxel object.
+func Relight(output RenderOutput, m manifest.Manifest, spr manifest.Sprite) {
+	lighting := getLightingDirection(spr.Angle+float64(m.LightingAngle), float64(m.LightingElevation), spr.Flip)
+
+	for x := range output {
+		for y := range output[x] {
+			for i := range output[x][y] {
+				if output[x][y][i].Collision {
+					output[x][y][i].LightAmount = getLightingValue(output[x][y][i].AveragedNormal, lighting)
+				}
+			}
+		}
+	}
+}
+
 func getLightingValue(normal, lighting geometry.Vector3) float64 {
 	return normal.Dot(lighting)
 }
@@ -1,12 +1,14 @@
 package raycaster
 
 import (
+	gandalfgeo "github.com/mattkimber/gandalf/geometry"
 	"github.com/mattkimber/gandalf/magica"
 	"github.com/mattkimber/gorender/internal/colour"
 	"github.com/mattkimber/gorender/internal/geometry"
 	"github.com/mattkimber/gorender/internal/manifest"
 	"github.com/mattkimber/gorender/internal/sampler"
 	"github.com/mattkimber/gorender/internal/voxelobject"
+	"math"
 	"testing"
 )
 
@@ -61,7 +63,7 @@ func getObject(filename string, t *testing.T) voxelobject.ProcessedVoxelObject {
 
 	pal.SetRanges([]colour.PaletteRange{{Start: 0, End: 255}})
 
-	v := voxelobject.GetProcessedVoxelObject(mv, &pal, false, "normal", false)
+	v := voxelobject.GetProcessedVoxelObject(mv, &pal, false, "normal", false, false)
 	return v
 }
 
@@ -74,7 +76,7 @@ func Benchmark_castFpRay(b *testing.B) {
 	loc := geometry.Vector3{X: 80, Y: 20, Z: 30}
 
 	for i := 0; i < b.N; i++ {
-		_ = castFpRay(object, loc, loc, ray, limits, false)
+		_ = castFpRay(object, loc, loc, ray, limits, false, 1)
 	}
 }
 
@@ -104,6 +106,256 @@ func getObjectForBenchmark(filename string, b *testing.B) voxelobject.ProcessedV
 		b.Fatalf("error loading test file: %v", err)
 	}
 
-	v := voxelobject.GetProcessedVoxelObject(mv, &colour.Palette{}, false, "normal", false)
+	v := voxelobject.GetProcessedVoxelObject(mv, &colour.Palette{}, false, "normal", false, false)
 	return v
 }
+
+func Test_GetShadowOutput(t *testing.T) {
+	object := getObject("cone.vox", t)
+	m := manifest.Manifest{
+		LightingAngle:        45,
+		LightingElevation:    50,
+		Size:                 object.Size.ToVector3(),
+		RenderElevationAngle: 30,
+		Sprites:              []manifest.Sprite{{Angle: 45, Width: 20, Height: 20, RenderElevationAngle: 30}},
+	}
+
+	smp := sampler.Square(20, 20, 2, 0, 1)
+	shadow := GetShadowOutput(object, m, m.Sprites[0], smp)
+
+	total := 0.0
+	for x := range shadow {
+		for y := range shadow[x] {
+			if shadow[x][y] < 0 || shadow[x][y] > 1 {
+				t.Errorf("Shadow coverage at %d,%d out of range: %f", x, y, shadow[x][y])
+			}
+			total += shadow[x][y]
+		}
+	}
+
+	if total == 0 {
+		t.Errorf("Object did not cast a shadow")
+	}
+}
+
+func Test_GetShadowOutput_Flip(t *testing.T) {
+	// A pillar near one side of the object, and the same object mirrored in Y
+	getPillar := func(y int) voxelobject.ProcessedVoxelObject {
+		v := magica.VoxelObject{Size: gandalfgeo.Point{X: 8, Y: 12, Z: 8}}
+		v.Voxels = make([][][]byte, v.Size.X)
+		for x := range v.Voxels {
+			v.Voxels[x] = make([][]byte, v.Size.Y)
+			for j := range v.Voxels[x] {
+				v.Voxels[x][j] = make([]byte, v.Size.Z)
+				if x >= 3 && x <= 4 && (j == y || j == y+1) {
+					for z := range v.Voxels[x][j] {
+						v.Voxels[x][j][z] = 1
+					}
+				}
+			}
+		}
+
+		pal := colour.Palette{Entries: make([]colour.PaletteEntry, 256)}
+		pal.SetRanges([]colour.PaletteRange{{Start: 0, End: 255}})
+		return voxelobject.GetProcessedVoxelObject(v, &pal, false, "normal", false, false)
+	}
+
+	object, mirrored := getPillar(1), getPillar(9)
+	m := manifest.Manifest{LightingAngle: 60, LightingElevation: 30, Size: object.Size.ToVector3()}
+	spr := manifest.Sprite{Angle: 30, Width: 20, Height: 20, RenderElevationAngle: 30}
+	smp := sampler.Square(20, 20, 2, 0, 1)
+
+	expected := GetShadowOutput(mirrored, m, spr, smp)
+	spr.Flip = true
+	result := GetShadowOutput(object, m, spr, smp)
+
+	// Voxel edges fall slightly differently when flipped, so allow small differences
+	total, difference := 0.0, 0.0
+	for x := range expected {
+		for y := range expected[x] {
+			total += expected[x][y]
+			difference += math.Abs(expected[x][y] - result[x][y])
+		}
+	}
+
+	if total == 0 || difference > total*0.1 {
+		t.Errorf("expected the flipped shadow to match the mirrored object's, total %f differs by %f", total, difference)
+	}
+}
+
+func Test_Relight(t *testing.T) {
+	object := getObject("cone.vox", t)
+	m := manifest.Manifest{
+		LightingAngle:        45,
+		LightingElevation:    50,
+		Size:                 object.Size.ToVector3(),
+		RenderElevationAngle: 30,
+		Sprites:              []manifest.Sprite{{Angle: 45, Width: 10, Height: 10, RenderElevationAngle: 30}},
+	}
+
+	smp := sampler.Square(10, 10, 2, 0, 1)
+	output := GetRaycastOutput(object, m, m.Sprites[0], smp)
+	expected := GetRaycastOutput(object, m, m.Sprites[0], smp)
+
+	// Relighting with the same settings leaves lighting unchanged
+	Relight(output, m, m.Sprites[0])
+	for x := range output {
+		for y := range output[x] {
+			for i := range output[x][y] {
+				if output[x][y][i].LightAmount != expected[x][y][i].LightAmount {
+					t.Fatalf("Relit sample at %d,%d,%d expected %f, got %f", x, y, i, expected[x][y][i].LightAmount, output[x][y][i].LightAmount)
+				}
+			}
+		}
+	}
+
+	m.LightingAngle = 225
+	Relight(output, m, m.Sprites[0])
+	changed := false
+	for x := range output {
+		for y := range output[x] {
+			for i := range output[x][y] {
+				if output[x][y][i].LightAmount != expected[x][y][i].LightAmount {
+					changed = true
+				}
+			}
+		}
+	}
+
+	if !changed {
+		t.Errorf("Relighting with a different angle did not change lighting")
+	}
+}
+
+func Test_raycaster_IgnoreRecoveredNormals(t *testing.T) {
+	object := getObject("cone.vox", t)
+	m := manifest.Manifest{
+		LightingAngle:        45,
+		LightingElevation:    50,
+		Size:                 object.Size.ToVector3(),
+		RenderElevationAngle: 30,
+		RecoveredVoxels:      &manifest.RecoveredVoxels{IgnoreNormals: true},
+		Sprites:              []manifest.Sprite{{Angle: 45, Width: 20, Height: 20, RenderElevationAngle: 30}},
+	}
+
+	output := GetRaycastOutput(object, m, m.Sprites[0], sampler.Square(20, 20, 4, 0, 1))
+	facing := getRenderDirection(45, 30)
+
+	recovered := 0
+	for x := range output {
+		for y := range output[x] {
+			for _, s := range output[x][y] {
+				if s.Collision && s.IsRecovered {
+					recovered++
+					if s.AveragedNormal.Subtract(facing).Length() > 1e-9 {
+						t.Errorf("pixel %d,%d: expected recovered voxel to face the camera, got normal %v", x, y, s.AveragedNormal)
+					}
+				}
+			}
+		}
+	}
+
+	if recovered == 0 {
+		t.Errorf("expected some voxels to be recovered")
+	}
+}
+
+func Test_raycaster_Translucency(t *testing.T) {
+	// A glass box around a solid core
+	v := magica.VoxelObject{Size: gandalfgeo.Point{X: 12, Y: 12, Z: 12}}
+	v.Voxels = make([][][]byte, v.Size.X)
+	for x := range v.Voxels {
+		v.Voxels[x] = make([][]byte, v.Size.Y)
+		for y := range v.Voxels[x] {
+			v.Voxels[x][y] = make([]byte, v.Size.Z)
+			for z := range v.Voxels[x][y] {
+				// Voxel values are 2 above their colour index
+				v.Voxels[x][y][z] = 4
+				if x >= 4 && x < 8 && y >= 4 && y < 8 && z >= 4 && z < 8 {
+					v.Voxels[x][y][z] = 3
+				}
+			}
+		}
+	}
+
+	testCases := []struct {
+		transparency            float64
+		expectCore, expectGlass bool
+	}{
+		{0, false, true},
+		{0.5, true, true},
+		{1, true, false},
+	}
+
+	for _, testCase := range testCases {
+		pal := colour.Palette{Entries: make([]colour.PaletteEntry, 256)}
+		pal.SetRanges([]colour.PaletteRange{{Start: 0, End: 1}, {Start: 2, End: 2, Transparency: testCase.transparency}, {Start: 3, End: 255}})
+		object := voxelobject.GetProcessedVoxelObject(v, &pal, false, "normal", false, false)
+
+		m := manifest.Manifest{LightingAngle: 45, LightingElevation: 50, Size: object.Size.ToVector3()}
+		spr := manifest.Sprite{Angle: 45, Width: 20, Height: 20, RenderElevationAngle: 30}
+		output := GetRaycastOutput(object, m, spr, sampler.Square(20, 20, 4, 0, 1))
+
+		core, glass, seeThrough := 0, 0, 0
+		for x := range output {
+			for y := range output[x] {
+				for _, s := range output[x][y] {
+					if !s.Collision || s.Count == 0 {
+						continue
+					}
+
+					if s.Index == 1 {
+						core++
+					} else if s.IsSeeThrough {
+						seeThrough++
+					} else {
+						glass++
+					}
+				}
+			}
+		}
+
+		if (core > 0) != testCase.expectCore {
+			t.Errorf("transparency %g: expected core seen %v, got %d samples", testCase.transparency, testCase.expectCore, core)
+		}
+
+		if (glass > 0) != testCase.expectGlass {
+			t.Errorf("transparency %g: expected glass seen %v, got %d samples", testCase.transparency, testCase.expectGlass, glass)
+		}
+
+		// Samples which pass the glass around the core's silhouette have nothing behind them
+		if expected := testCase.transparency > 0; (seeThrough > 0) != expected {
+			t.Errorf("transparency %g: expected samples seeing through %v, got %d samples", testCase.transparency, expected, seeThrough)
+		}
+	}
+}
+
+func Test_passesTranslucentVoxel(t *testing.T) {
+	testCases := []struct {
+		transparency float64
+		samples      int
+	}{
+		{0, 16},
+		{0.25, 16},
+		{0.5, 16},
+		{0.75, 16},
+		{1, 16},
+		{0.3, 9},
+		{0.5, 1},
+		{0.6, 1},
+	}
+
+	for _, testCase := range testCases {
+		passed := 0
+		for i := 0; i < testCase.samples; i++ {
+			if passesTranslucentVoxel(i, testCase.transparency) {
+				passed++
+			}
+		}
+
+		// Any number of samples pass in proportion to the transparency, to within a sample
+		if expected := testCase.transparency * float64(testCase.samples); math.Abs(float64(passed)-expected) > 1 {
+			t.Errorf("transparency %g over %d samples: expected about %g to pass, got %d", testCase.transparency, testCase.samples, expected, passed)
+		}
+	}
+}
@@ -6,8 +6,22 @@ import (
 	"math"
 )
 
+// Get the cosine and sine of a view angle. Angles are taken within 180 degrees either side of
+// 0 first, so mirrored angles such as 45 and 315 give exactly mirrored results.
+func getViewCosSin(angle float64) (cos, sin float64) {
+	angle = math.Mod(angle, 360)
+	if angle > 180 {
+		angle -= 360
+	} else if angle <= -180 {
+		angle += 360
+	}
+
+	return math.Cos(geometry.DegToRad(angle)), math.Sin(geometry.DegToRad(angle))
+}
+
 func getRenderDirection(angle float64, elevationAngle float64) geometry.Vector3 {
-	x, y, z := -math.Cos(geometry.DegToRad(angle)), math.Sin(geometry.DegToRad(angle)), math.Sin(geometry.DegToRad(elevationAngle))
+	cos, sin := getViewCosSin(angle)
+	x, y, z := -cos, sin, math.Sin(geometry.DegToRad(elevationAngle))
 	return geometry.Vector3{X: x, Y: y, Z: z}.Normalise()
 }
 
@@ -19,30 +33,21 @@ func getLightingDirection(angle float64, elevation float64, flipY bool) geometry
 	return geometry.Zero().Subtract(geometry.Vector3{X: x, Y: y, Z: z}).Normalise()
 }
 
-func getViewportPlane(angle float64, m manifest.Manifest, zError float64, size geometry.Point, elevationAngle float64) geometry.Plane {
-	cos, sin := math.Cos(geometry.DegToRad(angle)), math.Sin(geometry.DegToRad(angle))
-
-	midpointX := float64(size.X) / 2.0
-	if m.PadToFullLength {
-		midpointX -= ((m.Size.X) - float64(size.X)) / 2.0
-	}
+// Get the plane rays are cast from. The plane is sized to fit the object, then scaled
+// about its centre (see getViewportScale).
+func getViewportPlane(angle float64, m manifest.Manifest, zError float64, size geometry.Point, elevationAngle float64, scale geometry.Vector2) geometry.Plane {
+	cos, sin := getViewCosSin(angle)
 
-	midpoint := geometry.Vector3{X: midpointX, Y: float64(size.Y) / 2.0, Z: (m.Size.Z - zError) / 2.0}
+	midpoint := getViewportMidpoint(m, zError, size)
 
 	direction := getRenderDirection(angle, elevationAngle)
 	viewpoint := midpoint.Add(direction.MultiplyByConstant(m.Size.X))
 
-	planeNormalXComponent := math.Abs(((m.Size.X) / 2.0) * cos * math.Sin(geometry.DegToRad(elevationAngle)))
-	planeNormalYComponent := math.Abs(((m.Size.Y) / 2.0) * sin * math.Sin(geometry.DegToRad(elevationAngle)))
-	planeNormalZComponent := m.Size.Z / 2.0
-
-	constant := planeNormalXComponent + planeNormalYComponent + planeNormalZComponent
-	constant = constant * (1.0 + zError)
-	planeNormal := geometry.UnitZ().MultiplyByConstant(constant)
+	planeNormal := geometry.UnitZ().MultiplyByConstant(getViewportHalfHeight(angle, m, zError, elevationAngle) * scale.Y)
 
 	renderNormalXComponent := math.Abs(((m.Size.X) / 2.0) * sin)
 	renderNormalYComponent := math.Abs(((m.Size.Y) / 2.0) * cos)
-	renderNormal := getRenderNormal(angle).MultiplyByConstant(renderNormalXComponent + renderNormalYComponent)
+	renderNormal := getRenderNormal(angle).MultiplyByConstant((renderNormalXComponent + renderNormalYComponent) * scale.X)
 
 	a := viewpoint.Subtract(renderNormal).Subtract(planeNormal)
 	b := viewpoint.Add(renderNormal).Subtract(planeNormal)
@@ -52,7 +57,97 @@ func getViewportPlane(angle float64, m manifest.Manifest, zError float64, size g
 	return geometry.Plane{A: a, B: b, C: c, D: d}
 }
 
+// Get half the height of the plane rays are cast from, before it is scaled, so the object fits
+func getViewportHalfHeight(angle float64, m manifest.Manifest, zError float64, elevationAngle float64) float64 {
+	cos, sin := getViewCosSin(angle)
+
+	planeNormalXComponent := math.Abs(((m.Size.X) / 2.0) * cos * math.Sin(geometry.DegToRad(elevationAngle)))
+	planeNormalYComponent := math.Abs(((m.Size.Y) / 2.0) * sin * math.Sin(geometry.DegToRad(elevationAngle)))
+	planeNormalZComponent := m.Size.Z / 2.0
+
+	constant := planeNormalXComponent + planeNormalYComponent + planeNormalZComponent
+	return constant * (1.0 + zError)
+}
+
+// Get the pixel of a sprite at a scale where a point in an object of the given size is drawn
+func ProjectPoint(m manifest.Manifest, spr manifest.Sprite, size geometry.Point, p geometry.Vector3, scale float64) (x, y float64) {
+	width, height := spr.GetCanvasSize()
+	fw, fh := float64(int(float64(width)*scale)), float64(int(float64(height)*scale))
+
+	cos, sin := getViewCosSin(spr.Angle)
+	viewportScale := getViewportScale(spr)
+	halfWidth := (math.Abs((m.Size.X/2.0)*sin) + math.Abs((m.Size.Y/2.0)*cos)) * viewportScale.X
+	halfHeight := getViewportHalfHeight(spr.Angle, m, spr.ZError, float64(spr.RenderElevationAngle)) * viewportScale.Y
+
+	// Follow the ray through the point back to the viewport, which is upright
+	direction := getRenderDirection(spr.Angle, float64(spr.RenderElevationAngle))
+	horizontal := geometry.Vector3{X: direction.X, Y: direction.Y}
+	relative := p.Subtract(getViewportMidpoint(m, spr.ZError, size))
+
+	u := relative.Dot(getRenderNormal(spr.Angle)) / halfWidth
+	v := (relative.Z - relative.Dot(horizontal)*direction.Z/horizontal.Dot(horizontal)) / halfHeight
+
+	// Offsets move the object up and left within the sprite, and flipped sprites are
+	// mirrored after they are moved
+	x, y = fw/2*(1+u), fh/2*(1-v)-float64(int(spr.OffsetY*scale))
+	if spr.FlipHorizontal {
+		x = fw - x
+	}
+
+	x -= float64(int(spr.OffsetX * scale))
+	return
+}
+
+// Get the pixel of a sprite at a scale where the ground under the centre of the object is
+// drawn. The viewport is centred on the middle of the object, so this is in the middle of
+// the sprite horizontally and below its middle by the object's height above the ground.
+// Games such as OpenTTD position sprites by their offset from this point.
+func GetGroundPoint(m manifest.Manifest, spr manifest.Sprite, scale float64) (x, y float64) {
+	midpoint := getViewportMidpoint(m, spr.ZError, geometry.Point{})
+	return ProjectPoint(m, spr, geometry.Point{}, geometry.Vector3{X: midpoint.X, Y: midpoint.Y}, scale)
+}
+
+// Get the zoom for a sprite, where 0 (unset) is no zoom
+func getZoom(spr manifest.Sprite) float64 {
+	if spr.Zoom <= 0 {
+		return 1
+	}
+
+	return spr.Zoom
+}
+
+// Get how much larger than the object the viewport is in each direction. A canvas larger
+// than the sprite's width and height extends the viewport so the object is drawn at the
+// same size in the middle of the canvas, and zoom scales the object within the canvas.
+func getViewportScale(spr manifest.Sprite) geometry.Vector2 {
+	zoom := getZoom(spr)
+	scale := geometry.Vector2{X: 1 / zoom, Y: 1 / zoom}
+
+	width, height := spr.GetCanvasSize()
+	if spr.Width > 0 {
+		scale.X *= float64(width) / float64(spr.Width)
+	}
+
+	if spr.Height > 0 {
+		scale.Y *= float64(height) / float64(spr.Height)
+	}
+
+	return scale
+}
+
+// Get the point in the object the viewport is centred on. The object is moved along X by
+// the length its end slices are stretched, so stays centred.
+func getViewportMidpoint(m manifest.Manifest, zError float64, size geometry.Point) geometry.Vector3 {
+	midpointX := float64(size.X)/2.0 + m.JoinOverlap
+	if m.PadToFullLength {
+		midpointX -= ((m.Size.X) - float64(size.X)) / 2.0
+	}
+
+	return geometry.Vector3{X: midpointX, Y: float64(size.Y) / 2.0, Z: (m.Size.Z - zError) / 2.0}
+}
+
 func getRenderNormal(angle float64) geometry.Vector3 {
-	x, y := -math.Cos(geometry.DegToRad(angle)), math.Sin(geometry.DegToRad(angle))
+	cos, sin := getViewCosSin(angle)
+	x, y := -cos, sin
 	return geometry.Vector3{X: y, Y: -x}.Normalise()
 }
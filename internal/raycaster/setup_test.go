@@ -3,6 +3,7 @@ package raycaster
 import (
 	"github.com/mattkimber/gorender/internal/geometry"
 	"github.com/mattkimber/gorender/internal/manifest"
+	"math"
 	"testing"
 )
 
@@ -59,8 +60,106 @@ func TestGetViewportPlane(t *testing.T) {
 		size := geometry.Point{X: testCase.x, Y: testCase.y, Z: testCase.y}
 		mSize := geometry.Vector3{X: float64(testCase.x), Y: float64(testCase.y), Z: float64(testCase.y)}
 		m := manifest.Manifest{Size: mSize}
-		if result := getViewportPlane(testCase.angle, m, 0, size, 0); !result.Equals(testCase.expected) {
+		if result := getViewportPlane(testCase.angle, m, 0, size, 0, geometry.Vector2{X: 1, Y: 1}); !result.Equals(testCase.expected) {
 			t.Errorf("Angle %f expected viewport plane %v, got %v", testCase.angle, testCase.expected, result)
 		}
 	}
 }
+
+func TestGetViewportPlane_Scale(t *testing.T) {
+	m := manifest.Manifest{Size: geometry.Vector3{X: 126, Y: 40, Z: 40}}
+	size := geometry.Point{X: 126, Y: 40, Z: 40}
+
+	// Scaling makes the plane larger about the same centre, so the object is drawn smaller
+	expected := geometry.Plane{
+		A: geometry.Vector3{X: -63, Y: -20, Z: -20},
+		B: geometry.Vector3{X: -63, Y: 60, Z: -20},
+		C: geometry.Vector3{X: -63, Y: 60, Z: 60},
+		D: geometry.Vector3{X: -63, Y: -20, Z: 60},
+	}
+
+	if result := getViewportPlane(0, m, 0, size, 0, geometry.Vector2{X: 2, Y: 2}); !result.Equals(expected) {
+		t.Errorf("scale 2 expected viewport plane %v, got %v", expected, result)
+	}
+}
+
+func TestGetZoom(t *testing.T) {
+	testCases := []struct {
+		zoom, expected float64
+	}{
+		{0, 1},
+		{0.5, 0.5},
+		{2, 2},
+	}
+
+	for _, testCase := range testCases {
+		if result := getZoom(manifest.Sprite{Zoom: testCase.zoom}); result != testCase.expected {
+			t.Errorf("sprite zoom %v expected %v, got %v", testCase.zoom, testCase.expected, result)
+		}
+	}
+}
+
+func TestGetViewportScale(t *testing.T) {
+	testCases := []struct {
+		spr      manifest.Sprite
+		expected geometry.Vector2
+	}{
+		{manifest.Sprite{Width: 32, Height: 16}, geometry.Vector2{X: 1, Y: 1}},
+		{manifest.Sprite{Width: 32, Height: 16, CanvasWidth: 64}, geometry.Vector2{X: 2, Y: 1}},
+		{manifest.Sprite{Width: 32, Height: 16, CanvasWidth: 48, CanvasHeight: 32}, geometry.Vector2{X: 1.5, Y: 2}},
+		{manifest.Sprite{Width: 32, Height: 16, CanvasWidth: 64, Zoom: 0.5}, geometry.Vector2{X: 4, Y: 2}},
+	}
+
+	for _, testCase := range testCases {
+		if result := getViewportScale(testCase.spr); result != testCase.expected {
+			t.Errorf("sprite %v expected scale %v, got %v", testCase.spr, testCase.expected, result)
+		}
+	}
+}
+
+func TestGetGroundPoint(t *testing.T) {
+	m := manifest.Manifest{Size: geometry.Vector3{X: 126, Y: 40, Z: 40}}
+
+	testCases := []struct {
+		spr   manifest.Sprite
+		scale float64
+		x, y  float64
+	}{
+		// With no elevation the ground is the bottom of the viewport
+		{manifest.Sprite{Width: 32, Height: 16}, 1, 16, 16},
+		{manifest.Sprite{Width: 32, Height: 16}, 2, 32, 32},
+		{manifest.Sprite{Width: 32, Height: 16, CanvasHeight: 32}, 1, 16, 24},
+		{manifest.Sprite{Width: 32, Height: 16, OffsetX: 2, OffsetY: 1}, 1, 14, 15},
+		// Looking down, the ground under the centre is in front of the bottom of the object
+		{manifest.Sprite{Angle: 90, Width: 32, Height: 16, RenderElevationAngle: 30}, 1, 16, 8 * (1 + 20.0/30)},
+	}
+
+	for _, testCase := range testCases {
+		if x, y := GetGroundPoint(m, testCase.spr, testCase.scale); math.Abs(x-testCase.x) > 1e-9 || math.Abs(y-testCase.y) > 1e-9 {
+			t.Errorf("sprite %v at scale %f expected ground point %f,%f, got %f,%f", testCase.spr, testCase.scale, testCase.x, testCase.y, x, y)
+		}
+	}
+}
+
+func TestProjectPoint(t *testing.T) {
+	m := manifest.Manifest{Size: geometry.Vector3{X: 126, Y: 40, Z: 40}}
+	size := geometry.Point{X: 126, Y: 40, Z: 40}
+
+	testCases := []struct {
+		spr   manifest.Sprite
+		point geometry.Vector3
+		x, y  float64
+	}{
+		// The corners of the viewport plane are the corners of the sprite
+		{manifest.Sprite{Width: 32, Height: 16}, geometry.Vector3{Y: 40, Z: 40}, 32, 0},
+		{manifest.Sprite{Width: 32, Height: 16}, geometry.Vector3{X: 126}, 0, 16},
+		{manifest.Sprite{Width: 32, Height: 16, FlipHorizontal: true}, geometry.Vector3{X: 126}, 32, 16},
+		{manifest.Sprite{Width: 32, Height: 16, CanvasWidth: 64, OffsetX: 4}, geometry.Vector3{Y: 40, Z: 20}, 44, 8},
+	}
+
+	for _, testCase := range testCases {
+		if x, y := ProjectPoint(m, testCase.spr, size, testCase.point, 1); math.Abs(x-testCase.x) > 1e-9 || math.Abs(y-testCase.y) > 1e-9 {
+			t.Errorf("sprite %v point %v expected pixel %f,%f, got %f,%f", testCase.spr, testCase.point, testCase.x, testCase.y, x, y)
+		}
+	}
+}
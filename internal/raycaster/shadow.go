@@ -0,0 +1,71 @@
+package raycaster
+
+import (
+	"github.com/mattkimber/gorender/internal/geometry"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"github.com/mattkimber/gorender/internal/sampler"
+	"github.com/mattkimber/gorender/internal/voxelobject"
+	"sync"
+)
+
+// Proportion of each output pixel covered by the object's shadow on the ground
+type ShadowOutput [][]float64
+
+// Get the shadow the object casts on the ground plane (z=0) for a sprite, using
+// the same viewport as the object so the two line up.
+func GetShadowOutput(object voxelobject.ProcessedVoxelObject, m manifest.Manifest, spr manifest.Sprite, sampler sampler.Samples) ShadowOutput {
+	size := object.Size
+	object.EndExtension = m.JoinOverlap
+	limits := getLimits(object)
+
+	viewport := getViewportPlane(spr.Angle, m, spr.ZError, size, float64(spr.RenderElevationAngle), getViewportScale(spr))
+	ray := geometry.Zero().Subtract(getRenderDirection(spr.Angle, float64(spr.RenderElevationAngle)))
+
+	// Shadows are cast from the ground in view space, and castFpRay mirrors flipped objects
+	// itself, so the light must not be mirrored as well
+	lighting := getLightingDirection(spr.Angle+float64(m.LightingAngle), float64(m.LightingElevation), false)
+	shadowVec := geometry.Zero().Subtract(lighting).Normalise()
+
+	joggle := spr.Joggle + m.Joggle
+	w, h := sampler.Width(), sampler.Height()
+	result := make(ShadowOutput, w)
+
+	wg := sync.WaitGroup{}
+	wg.Add(w)
+
+	for x := 0; x < w; x++ {
+		thisX := x
+		go func() {
+			defer wg.Done()
+			result[thisX] = make([]float64, h)
+
+			for y := 0; y < h; y++ {
+				total, shadowed := 0.0, 0.0
+
+				for _, s := range sampler[thisX][y] {
+					total += s.Influence
+
+					loc0 := viewport.BiLerpWithinPlane(s.Location.X, s.Location.Y)
+					loc0.Z += joggle
+					if ray.Z >= 0 {
+						continue
+					}
+
+					// Find where the view ray meets the ground, then look towards the light
+					ground := loc0.Add(ray.MultiplyByConstant(-loc0.Z / ray.Z))
+					if castFpRay(object, ground, ground, shadowVec, limits, spr.Flip, 0).HasGeometry {
+						shadowed += s.Influence
+					}
+				}
+
+				if total > 0 {
+					result[thisX][y] = shadowed / total
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return result
+}
@@ -0,0 +1,77 @@
+package raycaster
+
+import (
+	"fmt"
+	"github.com/mattkimber/gorender/internal/geometry"
+	"github.com/mattkimber/gorender/internal/manifest"
+)
+
+// Get the height in voxels of each corner of the object for the sprite's slope, in
+// the order (0,0), (max,0), (max,max), (0,max). The object corners are matched to
+// the N, E, S and W corners of the slope as they appear from the sprite's angle.
+func GetObjectCornerHeights(spr manifest.Sprite, m manifest.Manifest, size geometry.Point) (heights [4]int) {
+	levels := manifest.GetSlopeCornerLevels(spr.Slope)
+
+	direction := getRenderDirection(spr.Angle, float64(spr.RenderElevationAngle))
+	right := getRenderNormal(spr.Angle)
+
+	hx, hy := float64(size.X)/2.0, float64(size.Y)/2.0
+	corners := []geometry.Vector2{{X: -hx, Y: -hy}, {X: hx, Y: -hy}, {X: hx, Y: hy}, {X: -hx, Y: hy}}
+
+	depth, lateral := make([]float64, 4), make([]float64, 4)
+	for i, c := range corners {
+		// Flipped objects are mirrored in Y when rendered
+		if spr.Flip {
+			c.Y = -c.Y
+		}
+
+		depth[i] = c.Dot(geometry.Vector2{X: direction.X, Y: direction.Y})
+		lateral[i] = c.Dot(geometry.Vector2{X: right.X, Y: right.Y})
+	}
+
+	// The corner closest to the camera is south, the furthest is north
+	south, north := 0, 0
+	for i := range corners {
+		if depth[i] > depth[south] {
+			south = i
+		}
+		if depth[i] < depth[north] {
+			north = i
+		}
+	}
+
+	// Of the remaining two, the one furthest right is east
+	east, west := -1, -1
+	for i := range corners {
+		if i == south || i == north {
+			continue
+		}
+
+		if east == -1 {
+			east = i
+		} else if lateral[i] > lateral[east] {
+			east, west = i, east
+		} else {
+			west = i
+		}
+	}
+
+	heights[north] = levels[manifest.CornerN] * m.SlopeHeight
+	heights[east] = levels[manifest.CornerE] * m.SlopeHeight
+	heights[south] = levels[manifest.CornerS] * m.SlopeHeight
+	heights[west] = levels[manifest.CornerW] * m.SlopeHeight
+
+	return
+}
+
+// Get a key identifying the sloped object drawn by a sprite, from the object it uses and
+// the heights of its corners
+func GetSlopedObjectKey(spr manifest.Sprite, m manifest.Manifest, size geometry.Point) string {
+	return spr.ObjectKey() + fmt.Sprint(GetObjectCornerHeights(spr, m, size))
+}
+
+// Get the height of one voxel in output pixels for a sprite of the given height
+func GetVoxelHeightInPixels(spr manifest.Sprite, m manifest.Manifest, height int) float64 {
+	viewport := getViewportPlane(spr.Angle, m, spr.ZError, geometry.Point{}, float64(spr.RenderElevationAngle), getViewportScale(spr))
+	return float64(height) / viewport.D.Subtract(viewport.A).Length()
+}
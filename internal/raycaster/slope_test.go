@@ -0,0 +1,43 @@
+package raycaster
+
+import (
+	"github.com/mattkimber/gorender/internal/geometry"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"math"
+	"testing"
+)
+
+func TestGetObjectCornerHeights(t *testing.T) {
+	m := manifest.Manifest{SlopeHeight: 10}
+	size := geometry.Point{X: 64, Y: 64, Z: 8}
+
+	testCases := []struct {
+		spr      manifest.Sprite
+		expected [4]int
+	}{
+		{manifest.Sprite{Angle: 45, Slope: 0}, [4]int{0, 0, 0, 0}},
+		{manifest.Sprite{Angle: 45, Slope: 8}, [4]int{0, 10, 0, 0}},
+		{manifest.Sprite{Angle: 45, Slope: 2}, [4]int{0, 0, 0, 10}},
+		{manifest.Sprite{Angle: 45, Slope: 29}, [4]int{10, 20, 10, 0}},
+		{manifest.Sprite{Angle: 45, Slope: 8, Flip: true}, [4]int{0, 0, 10, 0}},
+	}
+
+	for _, testCase := range testCases {
+		if result := GetObjectCornerHeights(testCase.spr, m, size); result != testCase.expected {
+			t.Errorf("Sprite %v expected corner heights %v, got %v", testCase.spr, testCase.expected, result)
+		}
+	}
+}
+
+func TestGetVoxelHeightInPixels(t *testing.T) {
+	m := manifest.Manifest{Size: geometry.Vector3{X: 64, Y: 64, Z: 22}, RenderElevationAngle: 30}
+	m.Sprites = []manifest.Sprite{{Angle: 45, Width: 64}}
+	m.SetSpriteSizes()
+	spr := m.Sprites[0]
+
+	// Pixels are square when using automatic heights, so this is the same as the horizontal scale
+	expected := 64.0 / (64.0 * math.Sqrt2)
+	if result := GetVoxelHeightInPixels(spr, m, spr.Height); math.Abs(result-expected) > 0.0001 {
+		t.Errorf("Expected voxel height %f, got %f", expected, result)
+	}
+}
@@ -0,0 +1,282 @@
+package renderer
+
+import (
+	"fmt"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"github.com/mattkimber/gorender/internal/report"
+	"github.com/mattkimber/gorender/internal/spritesheet"
+	"github.com/mattkimber/gorender/internal/utils/fileutils"
+	"github.com/mattkimber/gorender/internal/utils/timingutils"
+	"path/filepath"
+	"strings"
+)
+
+// Added to the output filename for purchase menu sprites and icons
+const PurchaseSuffix, IconSuffix = "_purchase", "_icon"
+
+// An output renders the spritesheets for a definition and writes them to files, along
+// with the purchase menu sprites, icon, variants and everything else the manifest asks for
+type Output struct {
+	// Write a JSON report alongside each set of spritesheets
+	Report bool
+
+	// Write the raycast output to a G-buffer file, or shade the sprites from a previously
+	// written one instead of raycasting
+	GBuffer, Relight bool
+
+	// Called with each set of spritesheets before it is written. Returning an error stops
+	// the spritesheets being written.
+	OnSheets func(outputFilename string, sheets *spritesheet.Spritesheets) error
+
+	// Called with each spritesheet or sprite file written, and the kind of output it holds
+	OnFile func(filename string, key string) error
+}
+
+// Render all output for a definition at its scale
+func (o Output) Render(def manifest.Definition, outputFilename string) error {
+	m := def.Manifest
+	if err := o.renderSprites(def, outputFilename); err != nil {
+		return err
+	}
+
+	if m.Purchase != nil {
+		def.Manifest = m.GetPurchaseManifest()
+		if err := o.renderSprites(def, outputFilename+PurchaseSuffix); err != nil {
+			return err
+		}
+	}
+
+	if m.Icon != nil {
+		def.Manifest = m
+		if err := o.renderIcon(def, outputFilename+IconSuffix); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (o Output) renderSprites(def manifest.Definition, outputFilename string) error {
+	m := def.Manifest
+	gbufferFilename := outputFilename + "_gbuffer.gz"
+
+	// Keep the raycast output for variants to be shaded from, unless it must be raycast in bands
+	variants := m.GetVariants()
+	def.OutputGBuffer = (o.GBuffer && !o.Relight) || (len(variants) > 0 && def.MaxMemory == 0)
+
+	var sheets spritesheet.Spritesheets
+	var gbuffer *spritesheet.GBuffer
+	if o.Relight {
+		gbuffer = &spritesheet.GBuffer{}
+		if err := fileutils.InstantiateFromFile(gbufferFilename, gbuffer); err != nil {
+			return fmt.Errorf("could not read G-buffer: %v", err)
+		}
+
+		var err error
+		if sheets, err = spritesheet.GetRelitSpritesheets(def, *gbuffer); err != nil {
+			return fmt.Errorf("%s: %v", gbufferFilename, err)
+		}
+	} else {
+		sheets = spritesheet.GetSpritesheets(def)
+		if def.OutputGBuffer {
+			gbuffer = &sheets.GBuffer
+		}
+	}
+
+	defer sheets.Release()
+
+	if err := o.save(outputFilename, m, &sheets, def.Time); err != nil {
+		return err
+	}
+
+	if o.GBuffer && !o.Relight {
+		var err error
+		timingutils.Time("G-buffer output", def.Time, func() {
+			err = fileutils.WriteToFile(gbufferFilename, &sheets.GBuffer)
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, v := range variants {
+		if err := o.renderVariant(def, outputFilename+v.Suffix, v, gbuffer); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Render the sprites again as a variant, shading them from the raycast output of the first
+// render if it was kept
+func (o Output) renderVariant(def manifest.Definition, outputFilename string, v manifest.Variant, gbuffer *spritesheet.GBuffer) error {
+	def.Manifest, def.Remap, def.Snow = v.Manifest, v.Remap, v.Snow
+	def.OutputGBuffer = false
+
+	var sheets spritesheet.Spritesheets
+	if gbuffer != nil {
+		var err error
+		if sheets, err = spritesheet.GetRelitSpritesheets(def, *gbuffer); err != nil {
+			return fmt.Errorf("%s: %v", outputFilename, err)
+		}
+	} else {
+		sheets = spritesheet.GetSpritesheets(def)
+	}
+
+	defer sheets.Release()
+
+	return o.save(outputFilename, def.Manifest, &sheets, def.Time)
+}
+
+// Render the manifest's icon. Icons are small, so are always rendered in full rather than
+// relit from a G-buffer.
+func (o Output) renderIcon(def manifest.Definition, outputFilename string) error {
+	m := def.Manifest
+
+	saveIcon := func(outputFilename string) error {
+		sheets := spritesheet.GetIconSpritesheets(def)
+		defer sheets.Release()
+
+		return o.save(outputFilename, m.GetIconManifest(), &sheets, def.Time)
+	}
+
+	if err := saveIcon(outputFilename); err != nil {
+		return err
+	}
+
+	for _, v := range m.GetVariants() {
+		def.Manifest, def.Remap, def.Snow = v.Manifest, v.Remap, v.Snow
+		if err := saveIcon(outputFilename + v.Suffix); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Get the output filenames of the sprites and each variant of them
+func GetVariantFilenames(outputFilename string, m manifest.Manifest) []string {
+	filenames := []string{outputFilename}
+	for _, v := range m.GetVariants() {
+		filenames = append(filenames, outputFilename+v.Suffix)
+	}
+
+	return filenames
+}
+
+// Write the spritesheets and everything output alongside them
+func (o Output) save(outputFilename string, m manifest.Manifest, sheets *spritesheet.Spritesheets, outputTime bool) (err error) {
+	if o.OnSheets != nil {
+		if err = o.OnSheets(outputFilename, sheets); err != nil {
+			return
+		}
+	}
+
+	timingutils.Time("PNG output", outputTime, func() {
+		err = sheets.SaveAll(outputFilename)
+	})
+
+	if err != nil {
+		return
+	}
+
+	for key := range sheets.Data {
+		if err = o.addFile(sheets.GetFilename(outputFilename, key), key); err != nil {
+			return
+		}
+	}
+
+	if m.SpriteFiles {
+		filenames, err := sheets.SaveSpriteFiles(outputFilename)
+		if err != nil {
+			return err
+		}
+
+		for filename, key := range filenames {
+			if err := o.addFile(filename, key); err != nil {
+				return err
+			}
+		}
+	}
+
+	if m.Aseprite {
+		filename := outputFilename + ".aseprite"
+		if err = sheets.SaveAseprite(filename); err != nil {
+			return
+		}
+
+		if err = o.addFile(filename, "aseprite"); err != nil {
+			return
+		}
+	}
+
+	if err = saveLayout(outputFilename, m, sheets.Layout); err != nil {
+		return
+	}
+
+	return o.saveReport(outputFilename, sheets.Report)
+}
+
+func (o Output) addFile(filename string, key string) error {
+	if o.OnFile == nil {
+		return nil
+	}
+
+	return o.OnFile(filename, key)
+}
+
+// Sprites no longer follow on from each other in deduplicated spritesheets, so output
+// their positions
+func saveLayout(outputFilename string, m manifest.Manifest, layout spritesheet.Layout) error {
+	if m.Deduplicate {
+		if err := fileutils.WriteToFile(outputFilename+"_layout.json", &layout); err != nil {
+			return err
+		}
+	}
+
+	if m.NML {
+		t := spritesheet.NMLTemplate{Name: spritesheet.GetNMLTemplateName(filepath.Base(outputFilename)), Layout: layout}
+		if err := fileutils.WriteToFile(outputFilename+".nml", t); err != nil {
+			return err
+		}
+	}
+
+	// Atlases reference the 32bpp spritesheet, as game engines expect full colour images
+	if m.Atlas != "" {
+		name := filepath.Base(outputFilename)
+		image := filepath.Base(m.GetTemplate().GetFilename(outputFilename, "32bpp"))
+
+		var err error
+		switch m.Atlas {
+		case "texturepacker":
+			err = fileutils.WriteToFile(outputFilename+"_atlas.json", spritesheet.TexturePackerAtlas{Name: name, Image: image, Layout: layout})
+		case "godot":
+			err = fileutils.WriteToFile(outputFilename+".tres", spritesheet.GodotSpriteFrames{Image: image, Layout: layout})
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	// Simutrans references images by file name without the extension, relative to the .dat file
+	if template := m.GetTemplate(); len(template.Directions) > 0 {
+		filename := filepath.Base(template.GetFilename(outputFilename, "8bpp"))
+		name := strings.TrimSuffix(filename, filepath.Ext(filename))
+		if err := fileutils.WriteToFile(outputFilename+"_images.dat", spritesheet.SimutransImages{Name: name, Template: template}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (o Output) saveReport(outputFilename string, r report.Report) error {
+	if !o.Report {
+		return nil
+	}
+
+	return fileutils.WriteToFile(outputFilename+"_report.json", &r)
+}
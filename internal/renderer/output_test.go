@@ -0,0 +1,75 @@
+package renderer
+
+import (
+	"bytes"
+	"github.com/mattkimber/gandalf/magica"
+	"github.com/mattkimber/gorender/internal/colour"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"github.com/mattkimber/gorender/internal/spritesheet"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestOutput_Render(t *testing.T) {
+	palette, err := colour.FromFile("../../files/ttd_palette.json")
+	if err != nil {
+		t.Fatalf("could not open palette: %v", err)
+	}
+
+	object, err := magica.FromFile("../raycaster/testdata/testcube")
+	if err != nil {
+		t.Fatalf("could not open voxel object: %v", err)
+	}
+
+	m, err := manifest.FromJson(bytes.NewReader([]byte(`{"size":{"x":32,"y":32,"z":32},"render_elevation":30,"nml":true,
+		"sprites":[{"angle":0,"width":8,"height":16},{"angle":45,"width":26,"height":16}],
+		"purchase":{"angle":45,"width":26,"height":16},"icon":{"angle":45,"width":8,"height":8}}`)))
+	if err != nil {
+		t.Fatalf("could not read manifest: %v", err)
+	}
+
+	def, err := GetDefinition("", object, m, palette, false)
+	if err != nil {
+		t.Fatalf("could not process object: %v", err)
+	}
+	def.Scale = 1.0
+
+	dir := t.TempDir()
+	var sheets, files []string
+	output := Output{
+		Report: true,
+		OnSheets: func(outputFilename string, _ *spritesheet.Spritesheets) error {
+			sheets = append(sheets, filepath.Base(outputFilename))
+			return nil
+		},
+		OnFile: func(filename string, key string) error {
+			files = append(files, filepath.Base(filename))
+			return nil
+		},
+	}
+
+	if err := output.Render(def, filepath.Join(dir, "cube")); err != nil {
+		t.Fatalf("could not render: %v", err)
+	}
+
+	expectedSheets := []string{"cube", "cube_purchase", "cube_icon"}
+	if strings.Join(sheets, ",") != strings.Join(expectedSheets, ",") {
+		t.Errorf("expected spritesheets %v, got %v", expectedSheets, sheets)
+	}
+
+	sort.Strings(files)
+	expectedFiles := []string{"cube_32bpp.png", "cube_8bpp.png", "cube_icon_32bpp.png", "cube_icon_8bpp.png", "cube_icon_mask.png",
+		"cube_mask.png", "cube_purchase_32bpp.png", "cube_purchase_8bpp.png", "cube_purchase_mask.png"}
+	if strings.Join(files, ",") != strings.Join(expectedFiles, ",") {
+		t.Errorf("expected files %v, got %v", expectedFiles, files)
+	}
+
+	for _, name := range []string{"cube_report.json", "cube.nml", "cube_purchase_report.json", "cube_icon_report.json"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("expected %s to be written: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package renderer
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/mattkimber/gandalf/magica"
+	"github.com/mattkimber/gorender/internal/colour"
+	"github.com/mattkimber/gorender/internal/geometry"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"github.com/mattkimber/gorender/internal/raycaster"
+	"github.com/mattkimber/gorender/internal/spritesheet"
+	"github.com/mattkimber/gorender/internal/utils/timingutils"
+	"github.com/mattkimber/gorender/internal/voxelobject"
+	"image"
+	"image/draw"
+)
+
+// A renderer renders sprites entirely in memory, without access to the file system.
+// The palette, manifest and voxel object must all be loaded before rendering.
+type Renderer struct {
+	palette  *colour.Palette
+	manifest *manifest.Manifest
+	object   *magica.VoxelObject
+
+	// Processed objects are kept until the palette, manifest or object changes
+	def *manifest.Definition
+}
+
+func (r *Renderer) LoadPalette(data []byte) error {
+	palette, err := colour.FromJson(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("could not read palette: %v", err)
+	}
+
+	r.palette, r.def = &palette, nil
+	return nil
+}
+
+func (r *Renderer) LoadManifest(data []byte) error {
+	m, err := manifest.FromJson(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("could not read manifest: %v", err)
+	}
+
+	for _, spr := range m.Sprites {
+		if spr.HasOwnObject() {
+			return fmt.Errorf("sprites with their own object, visible layers, clip boxes or cross section are not supported")
+		}
+	}
+
+	r.manifest, r.def = &m, nil
+	return nil
+}
+
+func (r *Renderer) LoadVox(data []byte) error {
+	object, err := magica.GetFromReader(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("could not read voxel object: %v", err)
+	}
+
+	r.object, r.def = &object, nil
+	return nil
+}
+
+func (r *Renderer) NumSprites() int {
+	if r.manifest == nil {
+		return 0
+	}
+
+	return len(r.manifest.Sprites)
+}
+
+// Render a single sprite from the manifest at the given scale. Depth is one of
+// "8bpp", "32bpp" or "mask".
+func (r *Renderer) RenderSprite(index int, scale float64, depth string) (*image.RGBA, error) {
+	if r.palette == nil || r.manifest == nil || r.object == nil {
+		return nil, fmt.Errorf("palette, manifest and voxel object must be loaded before rendering")
+	}
+
+	if index < 0 || index >= len(r.manifest.Sprites) {
+		return nil, fmt.Errorf("sprite %d not in manifest", index)
+	}
+
+	if depth != "8bpp" && depth != "32bpp" && depth != "mask" {
+		return nil, fmt.Errorf("invalid depth %s", depth)
+	}
+
+	if r.def == nil {
+		// Sprites can't have their own objects, so no files are read
+		def, err := GetDefinition("", *r.object, *r.manifest, *r.palette, false)
+		if err != nil {
+			return nil, err
+		}
+
+		r.def = &def
+	}
+
+	spr := r.manifest.Sprites[index]
+
+	def := *r.def
+	def.Scale = scale
+	def.Only8bpp = depth == "8bpp"
+	def.Manifest.Sprites = []manifest.Sprite{spr}
+
+	sheets := spritesheet.GetSpritesheets(def)
+
+	// Crop the spacing from the single sprite on the sheet. The layout has the sprite's
+	// size after any auto sizing.
+	result := image.NewRGBA(image.Rect(0, 0, sheets.Layout[0].Width, sheets.Layout[0].Height))
+	draw.Draw(result, result.Bounds(), sheets.Data[depth].Image, image.Point{}, draw.Src)
+	sheets.Release()
+	return result, nil
+}
+
+// Get the definition for rendering a voxel object with a manifest, with the object, the
+// objects of sprites which have their own and the objects of sloped sprites all painted and
+// processed. The object itself is not changed, so can be processed again for another manifest.
+func GetDefinition(inputFilename string, object magica.VoxelObject, m manifest.Manifest, palette colour.Palette, outputTime bool) (def manifest.Definition, err error) {
+	def = manifest.Definition{Manifest: m, Palette: palette, Time: outputTime}
+	painted := GetPaintedObject(object, m, &palette)
+
+	timingutils.Time("Voxel processing", outputTime, func() {
+		def.Object = voxelobject.GetProcessedVoxelObject(painted, &palette, m.TiledNormals, m.TilingMode, m.SolidBase, m.GradientNormals)
+	})
+
+	spriteObjects, err := GetSpriteObjects(inputFilename, m, &palette)
+	if err != nil {
+		return
+	}
+
+	def.SpriteObjects = GetProcessedSpriteObjects(spriteObjects, m, &palette)
+
+	if m.RenderSlopes {
+		timingutils.Time("Slope processing", outputTime, func() {
+			def.SlopedObjects = GetSlopedObjects(painted, spriteObjects, m, def.Object.Size, &palette)
+		})
+	}
+
+	return
+}
+
+// Get the object with the manifest's brightness remap applied and height gradients painted on.
+// The object is copied, so the original can be processed again with different settings.
+func GetPaintedObject(object magica.VoxelObject, m manifest.Manifest, palette *colour.Palette) magica.VoxelObject {
+	if len(m.HeightGradients) == 0 && m.BrightnessRemap == nil {
+		return object
+	}
+
+	painted := object.Copy()
+
+	// Remap brightness first, as gradients are painted in terms of the palette being rendered with
+	if b := m.BrightnessRemap; b != nil {
+		remap := palette.GetBrightnessRemap(b.SourceMin, b.SourceMax, b.TargetMin, b.TargetMax)
+		painted.Iterate(func(x, y, z int) {
+			painted.Voxels[x][y][z] = remap[painted.Voxels[x][y][z]]
+		})
+	}
+
+	for _, g := range m.HeightGradients {
+		voxelobject.PaintHeightGradient(painted, g.Start, g.End, g.Bottom, g.Top)
+	}
+
+	return painted
+}
+
+// Get the object drawn by each sprite with its own object, combination of visible layers, clip
+// boxes or cross section, painted in the same way as the main object
+func GetSpriteObjects(inputFilename string, m manifest.Manifest, palette *colour.Palette) (map[string]magica.VoxelObject, error) {
+	result := make(map[string]magica.VoxelObject)
+
+	for _, spr := range m.Sprites {
+		if !spr.HasOwnObject() {
+			continue
+		}
+
+		key := spr.ObjectKey()
+		if _, ok := result[key]; !ok {
+			filename, node := spr.GetObjectSource(inputFilename)
+			object, err := voxelobject.FromFileWithSelection(filename, node, spr.VisibleLayers)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", filename, err)
+			}
+
+			object = GetPaintedObject(object, m, palette)
+			for _, b := range spr.ClipBoxes {
+				voxelobject.ClearBox(object, b.From, b.To)
+			}
+
+			if c := spr.CrossSection; c != nil {
+				voxelobject.CutSection(object, c.Axis, c.From, c.GetTo(), c.Hatch)
+			}
+
+			result[key] = object
+		}
+	}
+
+	return result, nil
+}
+
+// Get a processed voxel object for each of the sprite objects
+func GetProcessedSpriteObjects(objects map[string]magica.VoxelObject, m manifest.Manifest, palette *colour.Palette) map[string]voxelobject.ProcessedVoxelObject {
+	result := make(map[string]voxelobject.ProcessedVoxelObject)
+	for key, object := range objects {
+		result[key] = voxelobject.GetProcessedVoxelObject(object, palette, m.TiledNormals, m.TilingMode, m.SolidBase, m.GradientNormals)
+	}
+
+	return result
+}
+
+// Get a processed voxel object for each combination of object and corner heights needed by the
+// sloped sprites. Sprites with their own object are sloped from it rather than the main object.
+func GetSlopedObjects(object magica.VoxelObject, spriteObjects map[string]magica.VoxelObject, m manifest.Manifest, size geometry.Point, palette *colour.Palette) map[string]voxelobject.ProcessedVoxelObject {
+	result := make(map[string]voxelobject.ProcessedVoxelObject)
+
+	for _, spr := range m.Sprites {
+		if spr.Slope == 0 {
+			continue
+		}
+
+		key := raycaster.GetSlopedObjectKey(spr, m, size)
+		if _, ok := result[key]; !ok {
+			source := object
+			if spr.HasOwnObject() {
+				source = spriteObjects[spr.ObjectKey()]
+			}
+
+			sloped := voxelobject.GetSlopedVoxelObject(source, raycaster.GetObjectCornerHeights(spr, m, size))
+			result[key] = voxelobject.GetProcessedVoxelObject(sloped, palette, m.TiledNormals, m.TilingMode, m.SolidBase, m.GradientNormals)
+		}
+	}
+
+	return result
+}
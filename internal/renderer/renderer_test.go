@@ -0,0 +1,135 @@
+package renderer
+
+import (
+	gandalfgeo "github.com/mattkimber/gandalf/geometry"
+	"github.com/mattkimber/gandalf/magica"
+	"github.com/mattkimber/gorender/internal/colour"
+	"github.com/mattkimber/gorender/internal/geometry"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"github.com/mattkimber/gorender/internal/raycaster"
+	"os"
+	"testing"
+)
+
+func getRenderer(t *testing.T) *Renderer {
+	r := &Renderer{}
+
+	palette, err := os.ReadFile("../../files/ttd_palette.json")
+	if err != nil {
+		t.Fatalf("could not open palette: %v", err)
+	}
+
+	object, err := os.ReadFile("../raycaster/testdata/testcube")
+	if err != nil {
+		t.Fatalf("could not open voxel object: %v", err)
+	}
+
+	if err := r.LoadPalette(palette); err != nil {
+		t.Fatalf("could not load palette: %v", err)
+	}
+
+	if err := r.LoadVox(object); err != nil {
+		t.Fatalf("could not load voxel object: %v", err)
+	}
+
+	if err := r.LoadManifest([]byte(`{"size":{"x":32,"y":32,"z":32},"render_elevation":30,"sprites":[{"angle":0,"width":8,"height":16},{"angle":45,"width":26,"height":16}]}`)); err != nil {
+		t.Fatalf("could not load manifest: %v", err)
+	}
+
+	return r
+}
+
+func TestRenderer_RenderSprite(t *testing.T) {
+	r := getRenderer(t)
+
+	if r.NumSprites() != 2 {
+		t.Fatalf("expected 2 sprites, got %d", r.NumSprites())
+	}
+
+	testCases := []struct {
+		index         int
+		scale         float64
+		depth         string
+		width, height int
+	}{
+		{0, 1.0, "8bpp", 8, 16},
+		{1, 1.0, "32bpp", 26, 16},
+		{1, 2.0, "mask", 52, 32},
+	}
+
+	for _, testCase := range testCases {
+		img, err := r.RenderSprite(testCase.index, testCase.scale, testCase.depth)
+		if err != nil {
+			t.Fatalf("sprite %d could not be rendered: %v", testCase.index, err)
+		}
+
+		if size := img.Bounds().Size(); size.X != testCase.width || size.Y != testCase.height {
+			t.Errorf("sprite %d at %fx expected size %dx%d, got %dx%d", testCase.index, testCase.scale, testCase.width, testCase.height, size.X, size.Y)
+		}
+	}
+}
+
+func TestRenderer_Errors(t *testing.T) {
+	empty := &Renderer{}
+	if _, err := empty.RenderSprite(0, 1.0, "8bpp"); err == nil {
+		t.Errorf("expected error when rendering before loading")
+	}
+
+	r := getRenderer(t)
+	if _, err := r.RenderSprite(2, 1.0, "8bpp"); err == nil {
+		t.Errorf("expected error for sprite not in manifest")
+	}
+
+	if _, err := r.RenderSprite(0, 1.0, "16bpp"); err == nil {
+		t.Errorf("expected error for invalid depth")
+	}
+
+	if err := r.LoadManifest([]byte(`{"sprites":[{"object":"train.vox"}]}`)); err == nil {
+		t.Errorf("expected error for sprite with its own object")
+	}
+
+	if err := r.LoadVox([]byte("not a voxel file")); err == nil {
+		t.Errorf("expected error for invalid voxel data")
+	}
+}
+
+func TestGetSlopedObjects(t *testing.T) {
+	handle, err := os.Open("../../files/ttd_palette.json")
+	if err != nil {
+		t.Fatalf("could not open palette: %v", err)
+	}
+	defer func() { _ = handle.Close() }()
+
+	palette, err := colour.FromJson(handle)
+	if err != nil {
+		t.Fatalf("could not load palette: %v", err)
+	}
+
+	size := geometry.Point{X: 8, Y: 8, Z: 4}
+	object := magica.NewVoxelObject(gandalfgeo.Point{X: 8, Y: 8, Z: 4}, nil)
+	object.Iterate(func(x, y, z int) { object.Voxels[x][y][z] = 1 })
+
+	// The sprite's visible layers only contain a single voxel
+	layer := magica.NewVoxelObject(object.Size, nil)
+	layer.Voxels[2][3][1] = 1
+
+	spr := manifest.Sprite{Angle: 45, Slope: 8, VisibleLayers: []string{"layer"}}
+	m := manifest.Manifest{SlopeHeight: 2, Sprites: []manifest.Sprite{spr, {Angle: 45, Slope: 8}}}
+	spriteObjects := map[string]magica.VoxelObject{spr.ObjectKey(): layer}
+
+	result := GetSlopedObjects(object, spriteObjects, m, size, &palette)
+	if len(result) != 2 {
+		t.Fatalf("expected 2 sloped objects, got %d", len(result))
+	}
+
+	sloped := result[raycaster.GetSlopedObjectKey(spr, m, size)]
+	from, to, ok := sloped.GetOccupiedBounds()
+	if !ok || from.X != 2 || from.Y != 3 || to.X != 3 || to.Y != 4 {
+		t.Errorf("expected sloped object with only the visible layer, got bounds %v-%v", from, to)
+	}
+
+	sloped = result[raycaster.GetSlopedObjectKey(m.Sprites[1], m, size)]
+	if from, to, _ := sloped.GetOccupiedBounds(); from.X != 0 || from.Y != 0 || to.X != 8 || to.Y != 8 {
+		t.Errorf("expected sloped object with the whole object, got bounds %v-%v", from, to)
+	}
+}
@@ -0,0 +1,28 @@
+package report
+
+import (
+	"github.com/mattkimber/gorender/internal/colour"
+	"github.com/mattkimber/gorender/internal/sprite"
+	"image"
+)
+
+const CategoryUnexpectedAnimation = "unexpected_animation"
+
+// Warn about animated palette indexes in the output of a sprite which
+// isn't expected to have animated content
+func (r *Report) CheckAnimatedPixels(spriteIndex int, info sprite.ShaderOutput, bounds image.Rectangle, palette *colour.Palette) {
+	animated := make([]image.Point, 0)
+
+	for x := bounds.Min.X; x < bounds.Max.X; x++ {
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			index := info[x][y].DitheredIndex
+			if info[x][y].IsAnimated || (int(index) < len(palette.Entries) && palette.Entries[index].Range != nil && palette.Entries[index].Range.IsAnimatedLight) {
+				animated = append(animated, image.Point{X: x, Y: y})
+			}
+		}
+	}
+
+	if len(animated) > 0 {
+		r.AddWarning(CategoryUnexpectedAnimation, spriteIndex, "%d unexpected animated pixels at %s", len(animated), formatPoints(animated))
+	}
+}
@@ -0,0 +1,27 @@
+package report
+
+import (
+	"image"
+	"testing"
+)
+
+func TestReport_CheckAnimatedPixels(t *testing.T) {
+	palette := getPalette()
+	info := getShaderOutput(1, 6, 3, 1)
+	info[3][0].IsAnimated = true
+
+	r := Report{}
+	r.CheckAnimatedPixels(2, info, image.Rect(0, 0, 4, 1), &palette)
+
+	if len(r.Warnings) != 1 {
+		t.Fatalf("Expected 1 warning, got %d", len(r.Warnings))
+	}
+
+	if expected := "2 unexpected animated pixels at (1,0) (3,0)"; r.Warnings[0].Message != expected {
+		t.Errorf("Expected warning %q, got %q", expected, r.Warnings[0].Message)
+	}
+
+	if !r.HasWarnings(CategoryUnexpectedAnimation) || r.Warnings[0].Sprite != 2 {
+		t.Errorf("Unexpected warning %v", r.Warnings[0])
+	}
+}
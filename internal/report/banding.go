@@ -0,0 +1,89 @@
+package report
+
+import (
+	"github.com/mattkimber/gorender/internal/sprite"
+	"image"
+	"sort"
+)
+
+const CategoryBanding = "banding"
+
+// Areas of one palette index smaller than this are too small to be seen as bands
+const bandingMinPixels = 16
+
+// The brightness of the shaded colours under an area of one palette index must vary by
+// more than this (out of 65535) for it to be a band, as flat surfaces are meant to be one colour
+const bandingMinGradient = 0.1 * 65535
+
+// An area of one palette index covering a gradient in the shaded colours, seen as a band
+type Band struct {
+	X      int  `json:"x"`
+	Y      int  `json:"y"`
+	Width  int  `json:"width"`
+	Height int  `json:"height"`
+	Pixels int  `json:"pixels"`
+	Index  byte `json:"index"`
+}
+
+// Find bands, where dithering has flattened a gradient into a large area of one palette
+// index, and warn about them. Bands are listed largest first.
+func (r *Report) CheckBanding(spriteIndex int, info sprite.ShaderOutput, bounds image.Rectangle) {
+	bands := GetBands(info, bounds)
+	if len(bands) == 0 {
+		return
+	}
+
+	if spriteIndex < len(r.Sprites) {
+		r.Sprites[spriteIndex].Bands = bands
+	}
+
+	r.AddWarning(CategoryBanding, spriteIndex, "%d bands of one colour over gradients, the largest %d pixels at (%d,%d)",
+		len(bands), bands[0].Pixels, bands[0].X, bands[0].Y)
+}
+
+func GetBands(info sprite.ShaderOutput, bounds image.Rectangle) (bands []Band) {
+	visited := make(map[image.Point]bool)
+
+	for x := bounds.Min.X; x < bounds.Max.X; x++ {
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			start := image.Point{X: x, Y: y}
+			index := info[x][y].DitheredIndex
+			if index == 0 || visited[start] {
+				continue
+			}
+
+			// Fill the area of this index
+			area, minBrightness, maxBrightness := image.Rectangle{}, 65535.0, 0.0
+			pixels := 0
+			stack := []image.Point{start}
+			visited[start] = true
+
+			for len(stack) > 0 {
+				p := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+
+				pixels++
+				area = area.Union(image.Rect(p.X, p.Y, p.X+1, p.Y+1))
+
+				c := info[p.X][p.Y].Colour
+				brightness := 0.299*c.R + 0.587*c.G + 0.114*c.B
+				minBrightness, maxBrightness = min(minBrightness, brightness), max(maxBrightness, brightness)
+
+				for _, n := range []image.Point{{X: p.X - 1, Y: p.Y}, {X: p.X + 1, Y: p.Y}, {X: p.X, Y: p.Y - 1}, {X: p.X, Y: p.Y + 1}} {
+					if n.In(bounds) && !visited[n] && info[n.X][n.Y].DitheredIndex == index {
+						visited[n] = true
+						stack = append(stack, n)
+					}
+				}
+			}
+
+			if pixels >= bandingMinPixels && maxBrightness-minBrightness > bandingMinGradient {
+				bands = append(bands, Band{X: area.Min.X, Y: area.Min.Y, Width: area.Dx(), Height: area.Dy(), Pixels: pixels, Index: index})
+			}
+		}
+	}
+
+	// Largest first, then in order of position, so the order is repeatable
+	sort.SliceStable(bands, func(i, j int) bool { return bands[i].Pixels > bands[j].Pixels })
+	return
+}
@@ -0,0 +1,55 @@
+package report
+
+import (
+	"github.com/mattkimber/gorender/internal/colour"
+	"github.com/mattkimber/gorender/internal/sprite"
+	"image"
+	"testing"
+)
+
+func getBandingShaderOutput(width, height int) sprite.ShaderOutput {
+	output := make(sprite.ShaderOutput, width)
+	for x := range output {
+		output[x] = make([]sprite.ShaderInfo, height)
+	}
+	return output
+}
+
+func TestGetBands(t *testing.T) {
+	info := getBandingShaderOutput(10, 4)
+
+	// A gradient flattened into one index across the top two rows
+	for x := 0; x < 10; x++ {
+		for y := 0; y < 2; y++ {
+			v := float64(x) * 4000
+			info[x][y] = sprite.ShaderInfo{DitheredIndex: 5, Colour: colour.RGB{R: v, G: v, B: v}}
+		}
+	}
+
+	// A flat area of another index, which is not a band
+	for x := 0; x < 10; x++ {
+		for y := 2; y < 4; y++ {
+			info[x][y] = sprite.ShaderInfo{DitheredIndex: 6, Colour: colour.RGB{R: 30000, G: 30000, B: 30000}}
+		}
+	}
+
+	bands := GetBands(info, image.Rect(0, 0, 10, 4))
+	expected := Band{X: 0, Y: 0, Width: 10, Height: 2, Pixels: 20, Index: 5}
+
+	if len(bands) != 1 || bands[0] != expected {
+		t.Fatalf("Expected %v, got %v", expected, bands)
+	}
+
+	r := Report{Sprites: []Sprite{{}}}
+	r.CheckBanding(0, info, image.Rect(0, 0, 10, 4))
+
+	if !r.HasWarnings(CategoryBanding) || len(r.Sprites[0].Bands) != 1 {
+		t.Errorf("Expected a banding warning, got %v", r.Warnings)
+	}
+
+	// Too small to be seen as a band
+	bands = GetBands(info, image.Rect(0, 0, 5, 2))
+	if len(bands) != 0 {
+		t.Errorf("Expected no bands, got %v", bands)
+	}
+}
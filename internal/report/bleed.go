@@ -0,0 +1,51 @@
+package report
+
+import (
+	"fmt"
+	"github.com/mattkimber/gorender/internal/colour"
+	"github.com/mattkimber/gorender/internal/sprite"
+	"image"
+	"strings"
+)
+
+const CategoryCompanyColourBleed = "company_colour_bleed"
+
+// Only list this many pixel locations in a warning
+const maxListedPixels = 10
+
+// Warn about pixels where the company colour status of the output doesn't
+// match the source colour, including any which have been auto-corrected
+func (r *Report) CheckCompanyColourBleed(spriteIndex int, info sprite.ShaderOutput, bounds image.Rectangle, palette *colour.Palette) {
+	bled, corrected := make([]image.Point, 0), 0
+
+	for x := bounds.Min.X; x < bounds.Max.X; x++ {
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			if info[x][y].IsBleedCorrected {
+				corrected++
+			} else if sprite.IsCompanyColourBleed(&info[x][y], palette) {
+				bled = append(bled, image.Point{X: x, Y: y})
+			}
+		}
+	}
+
+	if len(bled) > 0 {
+		r.AddWarning(CategoryCompanyColourBleed, spriteIndex, "%d pixels of company colour bleed at %s", len(bled), formatPoints(bled))
+	}
+
+	if corrected > 0 {
+		r.AddWarning(CategoryCompanyColourBleed, spriteIndex, "%d pixels of company colour bleed were corrected", corrected)
+	}
+}
+
+func formatPoints(points []image.Point) string {
+	formatted := make([]string, 0, maxListedPixels)
+	for i, p := range points {
+		if i == maxListedPixels {
+			formatted = append(formatted, "...")
+			break
+		}
+		formatted = append(formatted, fmt.Sprintf("(%d,%d)", p.X, p.Y))
+	}
+
+	return strings.Join(formatted, " ")
+}
@@ -0,0 +1,39 @@
+package report
+
+import (
+	"image"
+	"testing"
+)
+
+func TestReport_CheckCompanyColourBleed(t *testing.T) {
+	palette := getPalette()
+	info := getShaderOutput(1, 3, 1)
+	info[0][0].ModalIndex = 1
+	info[1][0].ModalIndex = 1
+	info[2][0].ModalIndex = 1
+	info[2][0].IsBleedCorrected = true
+
+	r := Report{}
+	r.CheckCompanyColourBleed(0, info, image.Rect(0, 0, 3, 1), &palette)
+
+	if len(r.Warnings) != 2 {
+		t.Fatalf("Expected 2 warnings, got %d", len(r.Warnings))
+	}
+
+	if expected := "1 pixels of company colour bleed at (1,0)"; r.Warnings[0].Message != expected {
+		t.Errorf("Expected warning %q, got %q", expected, r.Warnings[0].Message)
+	}
+
+	if expected := "1 pixels of company colour bleed were corrected"; r.Warnings[1].Message != expected {
+		t.Errorf("Expected warning %q, got %q", expected, r.Warnings[1].Message)
+	}
+}
+
+func TestFormatPoints(t *testing.T) {
+	points := make([]image.Point, maxListedPixels+1)
+	expected := "(0,0) (0,0) (0,0) (0,0) (0,0) (0,0) (0,0) (0,0) (0,0) (0,0) ..."
+
+	if result := formatPoints(points); result != expected {
+		t.Errorf("Expected %q, got %q", expected, result)
+	}
+}
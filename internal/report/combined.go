@@ -0,0 +1,36 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// A combined report holds the reports for many output files, which may be added concurrently
+type Combined struct {
+	sync.Mutex
+	Outputs  map[string]Report `json:"outputs"`
+	Warnings int               `json:"warnings"`
+}
+
+func (c *Combined) Add(outputFilename string, r Report) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.Outputs == nil {
+		c.Outputs = make(map[string]Report)
+	}
+
+	c.Outputs[outputFilename] = r
+	c.Warnings += len(r.Warnings)
+}
+
+func (c *Combined) OutputToWriter(w io.Writer) (err error) {
+	c.Lock()
+	defer c.Unlock()
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(c)
+	return
+}
@@ -0,0 +1,36 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+	"testing"
+)
+
+func TestCombined_Add(t *testing.T) {
+	c := Combined{}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() { c.Add("a", Report{Warnings: []Warning{{Category: "test"}}}); wg.Done() }()
+	go func() { c.Add("b", Report{Sprites: []Sprite{{Angle: 45}}}); wg.Done() }()
+	wg.Wait()
+
+	buf := bytes.Buffer{}
+	if err := c.OutputToWriter(&buf); err != nil {
+		t.Fatalf("could not write combined report: %v", err)
+	}
+
+	result := struct {
+		Outputs  map[string]Report `json:"outputs"`
+		Warnings int               `json:"warnings"`
+	}{}
+
+	if err := json.Unmarshal(buf.Bytes(), &result); err != nil {
+		t.Fatalf("could not read combined report: %v", err)
+	}
+
+	if len(result.Outputs) != 2 || result.Warnings != 1 || result.Outputs["b"].Sprites[0].Angle != 45 {
+		t.Errorf("expected 2 outputs with 1 warning, got %v", result)
+	}
+}
@@ -0,0 +1,44 @@
+package report
+
+import (
+	"github.com/mattkimber/gorender/internal/sprite"
+	"image"
+	"strings"
+)
+
+const CategoryEdgePixels = "edge_pixels"
+
+// Warn about visible pixels on the edge of a sprite, which usually means the size or
+// offsets of the sprite have cut off part of the object, such as buffers or pantographs
+func (r *Report) CheckEdgePixels(spriteIndex int, info sprite.ShaderOutput, bounds image.Rectangle) {
+	touching := make([]image.Point, 0)
+	var left, right, top, bottom bool
+
+	for x := bounds.Min.X; x < bounds.Max.X; x++ {
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			onEdge := x == bounds.Min.X || x == bounds.Max.X-1 || y == bounds.Min.Y || y == bounds.Max.Y-1
+			if !onEdge || info[x][y].Alpha == 0 {
+				continue
+			}
+
+			touching = append(touching, image.Point{X: x, Y: y})
+			left = left || x == bounds.Min.X
+			right = right || x == bounds.Max.X-1
+			top = top || y == bounds.Min.Y
+			bottom = bottom || y == bounds.Max.Y-1
+		}
+	}
+
+	if len(touching) == 0 {
+		return
+	}
+
+	edges := make([]string, 0, 4)
+	for i, touched := range []bool{left, right, top, bottom} {
+		if touched {
+			edges = append(edges, []string{"left", "right", "top", "bottom"}[i])
+		}
+	}
+
+	r.AddWarning(CategoryEdgePixels, spriteIndex, "%d visible pixels touch the %s edge of the sprite at %s", len(touching), strings.Join(edges, ", "), formatPoints(touching))
+}
@@ -0,0 +1,39 @@
+package report
+
+import (
+	"github.com/mattkimber/gorender/internal/sprite"
+	"image"
+	"strings"
+	"testing"
+)
+
+func TestReport_CheckEdgePixels(t *testing.T) {
+	info := make(sprite.ShaderOutput, 4)
+	for x := range info {
+		info[x] = make([]sprite.ShaderInfo, 4)
+	}
+
+	info[1][1].Alpha = 1
+	info[2][2].Alpha = 1
+
+	r := Report{}
+	r.CheckEdgePixels(0, info, image.Rect(0, 0, 4, 4))
+
+	if len(r.Warnings) != 0 {
+		t.Errorf("Expected no warnings, got %v", r.Warnings)
+	}
+
+	info[3][1].Alpha = 0.5
+	info[3][3].Alpha = 1
+
+	r = Report{}
+	r.CheckEdgePixels(1, info, image.Rect(0, 0, 4, 4))
+
+	if len(r.Warnings) != 1 || !r.HasWarnings(CategoryEdgePixels) || r.Warnings[0].Sprite != 1 {
+		t.Fatalf("Expected 1 edge pixels warning, got %v", r.Warnings)
+	}
+
+	if expected := "2 visible pixels touch the right, bottom edge of the sprite at (3,1) (3,3)"; !strings.Contains(r.Warnings[0].Message, expected) {
+		t.Errorf("expected %s, got %s", expected, r.Warnings[0].Message)
+	}
+}
@@ -0,0 +1,22 @@
+package report
+
+import (
+	"github.com/mattkimber/gorender/internal/sprite"
+	"image"
+)
+
+const CategoryEmptySprite = "empty_sprite"
+
+// Warn about a sprite with no visible pixels, which usually means the object is
+// outside the sprite or hidden by clipping or layers
+func (r *Report) CheckEmpty(spriteIndex int, info sprite.ShaderOutput, bounds image.Rectangle) {
+	for x := bounds.Min.X; x < bounds.Max.X; x++ {
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			if info[x][y].Alpha > 0 {
+				return
+			}
+		}
+	}
+
+	r.AddWarning(CategoryEmptySprite, spriteIndex, "sprite has no visible pixels")
+}
@@ -0,0 +1,26 @@
+package report
+
+import (
+	"image"
+	"testing"
+)
+
+func TestReport_CheckEmpty(t *testing.T) {
+	info := getShaderOutput(0, 0, 0, 0)
+
+	r := Report{}
+	r.CheckEmpty(1, info, image.Rect(0, 0, 4, 1))
+
+	if len(r.Warnings) != 1 || !r.HasWarnings(CategoryEmptySprite) || r.Warnings[0].Sprite != 1 {
+		t.Fatalf("Expected 1 empty sprite warning, got %v", r.Warnings)
+	}
+
+	info[2][0].Alpha = 0.5
+
+	r = Report{}
+	r.CheckEmpty(1, info, image.Rect(0, 0, 4, 1))
+
+	if len(r.Warnings) != 0 {
+		t.Errorf("Expected no warnings, got %v", r.Warnings)
+	}
+}
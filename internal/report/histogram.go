@@ -0,0 +1,22 @@
+package report
+
+import (
+	"github.com/mattkimber/gorender/internal/sprite"
+	"image"
+)
+
+// Count how many times each palette index appears in the final 8bpp
+// output, ignoring transparent pixels
+func GetIndexHistogram(info sprite.ShaderOutput, bounds image.Rectangle) (histogram map[int]int) {
+	histogram = make(map[int]int)
+
+	for x := bounds.Min.X; x < bounds.Max.X; x++ {
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			if index := info[x][y].DitheredIndex; index != 0 {
+				histogram[int(index)]++
+			}
+		}
+	}
+
+	return
+}
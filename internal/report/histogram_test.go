@@ -0,0 +1,18 @@
+package report
+
+import (
+	"image"
+	"reflect"
+	"testing"
+)
+
+func TestGetIndexHistogram(t *testing.T) {
+	info := getShaderOutput(0, 0, 1, 7, 7, 255)
+	bounds := image.Rect(0, 0, len(info), 1)
+
+	expected := map[int]int{1: 1, 7: 2, 255: 1}
+
+	if result := GetIndexHistogram(info, bounds); !reflect.DeepEqual(result, expected) {
+		t.Errorf("Index histogram expected %v, got %v", expected, result)
+	}
+}
@@ -0,0 +1,13 @@
+package report
+
+import "math"
+
+const CategoryOversizeSprite = "oversize_sprite"
+
+// Warn about a sprite where the object extends past the edge by at least a
+// pixel, so part of it is cut off
+func (r *Report) CheckOversize(spriteIndex int, overflow float64) {
+	if overflow >= 1 {
+		r.AddWarning(CategoryOversizeSprite, spriteIndex, "object extends %d pixels beyond the edge of the sprite", int(math.Floor(overflow)))
+	}
+}
@@ -0,0 +1,29 @@
+package report
+
+import "testing"
+
+func TestReport_CheckOversize(t *testing.T) {
+	testCases := []struct {
+		overflow float64
+		expected string
+	}{
+		{0, ""},
+		{0.9, ""},
+		{1, "object extends 1 pixels beyond the edge of the sprite"},
+		{3.7, "object extends 3 pixels beyond the edge of the sprite"},
+	}
+
+	for _, testCase := range testCases {
+		r := Report{}
+		r.CheckOversize(2, testCase.overflow)
+
+		message := ""
+		if len(r.Warnings) > 0 {
+			message = r.Warnings[0].Message
+		}
+
+		if message != testCase.expected {
+			t.Errorf("Overflow %g expected warning %q, got %q", testCase.overflow, testCase.expected, message)
+		}
+	}
+}
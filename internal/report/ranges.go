@@ -0,0 +1,91 @@
+package report
+
+import (
+	"github.com/mattkimber/gorender/internal/colour"
+	"github.com/mattkimber/gorender/internal/sprite"
+	"image"
+)
+
+// Sprites with company colour coverage outside this factor of the
+// average across all sprites are flagged
+const companyColourDeviation = 3.0
+
+const CategoryCompanyColourCoverage = "company_colour_coverage"
+
+type RangeStatistics struct {
+	Regular                int `json:"regular"`
+	PrimaryCompanyColour   int `json:"primary_company_colour"`
+	SecondaryCompanyColour int `json:"secondary_company_colour"`
+	Animated               int `json:"animated"`
+}
+
+func GetRangeStatistics(info sprite.ShaderOutput, bounds image.Rectangle, palette *colour.Palette) (stats RangeStatistics) {
+	for x := bounds.Min.X; x < bounds.Max.X; x++ {
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			index := info[x][y].DitheredIndex
+			if index == 0 {
+				continue
+			}
+
+			var rng *colour.PaletteRange
+			if int(index) < len(palette.Entries) {
+				rng = palette.Entries[index].Range
+			}
+
+			if rng != nil && rng.IsPrimaryCompanyColour {
+				stats.PrimaryCompanyColour++
+			} else if rng != nil && rng.IsSecondaryCompanyColour {
+				stats.SecondaryCompanyColour++
+			} else if rng != nil && rng.IsAnimatedLight {
+				stats.Animated++
+			} else {
+				stats.Regular++
+			}
+		}
+	}
+
+	return
+}
+
+func (s RangeStatistics) Total() int {
+	return s.Regular + s.PrimaryCompanyColour + s.SecondaryCompanyColour + s.Animated
+}
+
+// Flag sprites where the proportion of company colour pixels is very different
+// to the other angles, which usually means a company colour region is missing
+// or has bled into its surroundings
+func (r *Report) CheckCompanyColourCoverage() {
+	r.checkCoverage("primary", func(s RangeStatistics) int { return s.PrimaryCompanyColour })
+	r.checkCoverage("secondary", func(s RangeStatistics) int { return s.SecondaryCompanyColour })
+}
+
+func (r *Report) checkCoverage(name string, getCount func(RangeStatistics) int) {
+	coverage := make([]float64, len(r.Sprites))
+	total, count := 0.0, 0
+
+	for i, s := range r.Sprites {
+		if s.Ranges.Total() == 0 {
+			continue
+		}
+
+		coverage[i] = float64(getCount(s.Ranges)) / float64(s.Ranges.Total())
+		total += coverage[i]
+		count++
+	}
+
+	if count == 0 || total == 0 {
+		return
+	}
+
+	mean := total / float64(count)
+
+	for i, s := range r.Sprites {
+		if s.Ranges.Total() == 0 {
+			continue
+		}
+
+		if coverage[i] < mean/companyColourDeviation || coverage[i] > mean*companyColourDeviation {
+			r.AddWarning(CategoryCompanyColourCoverage, i, "%s company colour coverage at angle %g is %.1f%%, average is %.1f%%", name, s.Angle, coverage[i]*100, mean*100)
+		}
+	}
+}
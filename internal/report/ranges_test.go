@@ -0,0 +1,57 @@
+package report
+
+import (
+	"github.com/mattkimber/gorender/internal/colour"
+	"github.com/mattkimber/gorender/internal/sprite"
+	"image"
+	"testing"
+)
+
+func getPalette() colour.Palette {
+	palette := colour.Palette{Entries: make([]colour.PaletteEntry, 8)}
+	palette.SetRanges([]colour.PaletteRange{
+		{Start: 1, End: 2},
+		{Start: 3, End: 4, IsPrimaryCompanyColour: true},
+		{Start: 5, End: 5, IsSecondaryCompanyColour: true},
+		{Start: 6, End: 7, IsAnimatedLight: true},
+	})
+	return palette
+}
+
+func getShaderOutput(indexes ...byte) sprite.ShaderOutput {
+	output := make(sprite.ShaderOutput, len(indexes))
+	for i, idx := range indexes {
+		output[i] = []sprite.ShaderInfo{{DitheredIndex: idx}}
+	}
+	return output
+}
+
+func TestGetRangeStatistics(t *testing.T) {
+	palette := getPalette()
+	info := getShaderOutput(0, 1, 2, 3, 5, 6, 7, 7)
+	bounds := image.Rect(0, 0, len(info), 1)
+
+	expected := RangeStatistics{Regular: 2, PrimaryCompanyColour: 1, SecondaryCompanyColour: 1, Animated: 3}
+
+	if result := GetRangeStatistics(info, bounds, &palette); result != expected {
+		t.Errorf("Range statistics expected %v, got %v", expected, result)
+	}
+}
+
+func TestReport_CheckCompanyColourCoverage(t *testing.T) {
+	r := Report{Sprites: []Sprite{
+		{Angle: 0, Ranges: RangeStatistics{Regular: 8, PrimaryCompanyColour: 2}},
+		{Angle: 45, Ranges: RangeStatistics{Regular: 7, PrimaryCompanyColour: 3}},
+		{Angle: 90, Ranges: RangeStatistics{Regular: 10}},
+	}}
+
+	r.CheckCompanyColourCoverage()
+
+	if len(r.Warnings) != 1 {
+		t.Fatalf("Expected 1 warning, got %d", len(r.Warnings))
+	}
+
+	if r.Warnings[0].Sprite != 2 || r.Warnings[0].Category != CategoryCompanyColourCoverage {
+		t.Errorf("Unexpected warning %v", r.Warnings[0])
+	}
+}
@@ -0,0 +1,89 @@
+package report
+
+import (
+	"github.com/mattkimber/gorender/internal/colour"
+	"github.com/mattkimber/gorender/internal/sprite"
+	"image"
+	"math"
+	"sort"
+)
+
+// A region of similar colours found when dithering a sprite
+type Region struct {
+	ID     int `json:"id"`
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	Pixels int `json:"pixels"`
+
+	// First and last palette index of the range the region's colours are from
+	Range [2]int `json:"range"`
+
+	// Average of the region's 32bpp colours, as [r, g, b]
+	AverageColour [3]int `json:"average_colour"`
+}
+
+// Get the bounding box, size, palette range and average colour of each region in a sprite,
+// in order of ID. Positions are in pixels of the sprite, as for other pixel locations in
+// the report.
+func GetRegions(info sprite.ShaderOutput, bounds image.Rectangle, palette *colour.Palette) []Region {
+	type regionTotals struct {
+		bounds image.Rectangle
+		pixels int
+		index  byte
+		colour colour.RGB
+	}
+
+	totals := make(map[int]*regionTotals)
+
+	for x := bounds.Min.X; x < bounds.Max.X; x++ {
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			s := &info[x][y]
+			if s.Region == 0 || s.ModalIndex == 0 {
+				continue
+			}
+
+			pixel := image.Rect(x, y, x+1, y+1)
+			t, ok := totals[s.Region]
+			if !ok {
+				t = &regionTotals{bounds: pixel, index: s.ModalIndex}
+				totals[s.Region] = t
+			}
+
+			t.bounds = t.bounds.Union(pixel)
+			t.pixels++
+			t.colour = t.colour.Add(colour.PermissiveClampRGB(s.Colour))
+		}
+	}
+
+	regions := make([]Region, 0, len(totals))
+	for id, t := range totals {
+		r := Region{
+			ID:     id,
+			X:      t.bounds.Min.X,
+			Y:      t.bounds.Min.Y,
+			Width:  t.bounds.Dx(),
+			Height: t.bounds.Dy(),
+			Pixels: t.pixels,
+		}
+
+		if int(t.index) < len(palette.Entries) && palette.Entries[t.index].Range != nil {
+			rng := palette.Entries[t.index].Range
+			r.Range = [2]int{int(rng.Start), int(rng.End)}
+		}
+
+		average := t.colour.MultiplyBy(1 / float64(t.pixels))
+		r.AverageColour = [3]int{toByte(average.R), toByte(average.G), toByte(average.B)}
+
+		regions = append(regions, r)
+	}
+
+	sort.Slice(regions, func(i, j int) bool { return regions[i].ID < regions[j].ID })
+	return regions
+}
+
+// Convert a 16-bit colour channel to 8 bits
+func toByte(c float64) int {
+	return int(math.Round(c / 257))
+}
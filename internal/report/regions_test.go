@@ -0,0 +1,30 @@
+package report
+
+import (
+	"github.com/mattkimber/gorender/internal/colour"
+	"github.com/mattkimber/gorender/internal/sprite"
+	"image"
+	"reflect"
+	"testing"
+)
+
+func TestGetRegions(t *testing.T) {
+	palette := getPalette()
+
+	// Two regions in a 3x2 sprite, with an empty pixel and one outside any region
+	info := sprite.ShaderOutput{
+		{{Region: 4, ModalIndex: 1, Colour: colour.RGB{R: 65535}}, {Region: 4, ModalIndex: 1, Colour: colour.RGB{G: 65535}}},
+		{{Region: 1, ModalIndex: 3, Colour: colour.RGB{B: 65535}}, {}},
+		{{Region: 1, ModalIndex: 3, Colour: colour.RGB{B: 65535}}, {ModalIndex: 2}},
+	}
+	bounds := image.Rect(0, 0, 3, 2)
+
+	expected := []Region{
+		{ID: 1, X: 1, Y: 0, Width: 2, Height: 1, Pixels: 2, Range: [2]int{3, 4}, AverageColour: [3]int{0, 0, 255}},
+		{ID: 4, X: 0, Y: 0, Width: 1, Height: 2, Pixels: 2, Range: [2]int{1, 2}, AverageColour: [3]int{128, 128, 0}},
+	}
+
+	if result := GetRegions(info, bounds, &palette); !reflect.DeepEqual(result, expected) {
+		t.Errorf("Regions expected %v, got %v", expected, result)
+	}
+}
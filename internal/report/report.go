@@ -0,0 +1,84 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/mattkimber/gorender/internal/colour"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"github.com/mattkimber/gorender/internal/sprite"
+	"image"
+	"io"
+)
+
+// All categories of warning, in the order they are checked
+var Categories = []string{
+	CategoryEmptySprite,
+	CategoryOversizeSprite,
+	CategoryEdgePixels,
+	CategoryUnmappedColour,
+	CategoryCompanyColourBleed,
+	CategoryUnexpectedAnimation,
+	CategoryCompanyColourCoverage,
+	CategoryBanding,
+}
+
+type Report struct {
+	Sprites    []Sprite    `json:"sprites"`
+	Warnings   []Warning   `json:"warnings,omitempty"`
+	Duplicates []Duplicate `json:"duplicates,omitempty"`
+}
+
+type Sprite struct {
+	Angle     float64         `json:"angle"`
+	Ranges    RangeStatistics `json:"ranges"`
+	Histogram map[int]int     `json:"histogram"`
+	Regions   []Region        `json:"regions,omitempty"`
+	Bands     []Band          `json:"bands,omitempty"`
+	Log       []string        `json:"log,omitempty"`
+}
+
+// A sprite which is pixel-identical to an earlier sprite
+type Duplicate struct {
+	Sprite int `json:"sprite"`
+	Of     int `json:"of"`
+}
+
+type Warning struct {
+	Category string `json:"category"`
+	Sprite   int    `json:"sprite"`
+	Message  string `json:"message"`
+}
+
+func (r *Report) AddSprite(spr manifest.Sprite, info sprite.ShaderOutput, bounds image.Rectangle, palette *colour.Palette) {
+	r.Sprites = append(r.Sprites, Sprite{
+		Angle:     spr.Angle,
+		Ranges:    GetRangeStatistics(info, bounds, palette),
+		Histogram: GetIndexHistogram(info, bounds),
+		Regions:   GetRegions(info, bounds, palette),
+	})
+}
+
+func (r *Report) AddWarning(category string, sprite int, format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, Warning{Category: category, Sprite: sprite, Message: fmt.Sprintf(format, args...)})
+}
+
+func (r *Report) HasWarnings(category string) bool {
+	for _, w := range r.Warnings {
+		if w.Category == category {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("sprite %d: %s", w.Sprite, w.Message)
+}
+
+func (r *Report) OutputToWriter(w io.Writer) (err error) {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(r)
+	return
+}
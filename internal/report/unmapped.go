@@ -0,0 +1,45 @@
+package report
+
+import (
+	"fmt"
+	"github.com/mattkimber/gorender/internal/colour"
+	"github.com/mattkimber/gorender/internal/sprite"
+	"image"
+	"sort"
+	"strings"
+)
+
+const CategoryUnmappedColour = "unmapped_colour"
+
+// Warn about voxel colours which aren't in any range of the palette, as these
+// can't be shaded and are output as they are
+func (r *Report) CheckUnmappedColours(spriteIndex int, info sprite.ShaderOutput, bounds image.Rectangle, palette *colour.Palette) {
+	unmapped := make(map[byte]int)
+
+	for x := bounds.Min.X; x < bounds.Max.X; x++ {
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			index := info[x][y].ModalIndex
+			if info[x][y].Alpha > 0 && index != 0 && (int(index) >= len(palette.Entries) || palette.Entries[index].Range == nil) {
+				unmapped[index]++
+			}
+		}
+	}
+
+	if len(unmapped) == 0 {
+		return
+	}
+
+	indexes, pixels := make([]int, 0, len(unmapped)), 0
+	for index, count := range unmapped {
+		indexes = append(indexes, int(index))
+		pixels += count
+	}
+
+	sort.Ints(indexes)
+	formatted := make([]string, len(indexes))
+	for i, index := range indexes {
+		formatted[i] = fmt.Sprintf("%d", index)
+	}
+
+	r.AddWarning(CategoryUnmappedColour, spriteIndex, "%d pixels use colours outside the palette ranges: %s", pixels, strings.Join(formatted, " "))
+}
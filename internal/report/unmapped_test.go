@@ -0,0 +1,39 @@
+package report
+
+import (
+	"image"
+	"testing"
+)
+
+func TestReport_CheckUnmappedColours(t *testing.T) {
+	palette := getPalette()
+	info := getShaderOutput(0, 0, 0, 0, 0)
+	for i, index := range []byte{0, 1, 9, 200, 9} {
+		info[i][0].ModalIndex = index
+		info[i][0].Alpha = 1
+	}
+
+	r := Report{}
+	r.CheckUnmappedColours(3, info, image.Rect(0, 0, 5, 1), &palette)
+
+	if len(r.Warnings) != 1 {
+		t.Fatalf("Expected 1 warning, got %d", len(r.Warnings))
+	}
+
+	if expected := "3 pixels use colours outside the palette ranges: 9 200"; r.Warnings[0].Message != expected {
+		t.Errorf("Expected warning %q, got %q", expected, r.Warnings[0].Message)
+	}
+
+	if !r.HasWarnings(CategoryUnmappedColour) || r.Warnings[0].Sprite != 3 {
+		t.Errorf("Unexpected warning %v", r.Warnings[0])
+	}
+
+	info[2][0].Alpha, info[3][0].Alpha, info[4][0].Alpha = 0, 0, 0
+
+	r = Report{}
+	r.CheckUnmappedColours(3, info, image.Rect(0, 0, 5, 1), &palette)
+
+	if len(r.Warnings) != 0 {
+		t.Errorf("Expected no warnings for transparent pixels, got %v", r.Warnings)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"image/draw"
 	"math"
 	"math/rand"
+	"sync"
 )
 
 type Sample struct {
@@ -26,6 +27,17 @@ func (s Samples) Height() int {
 	return len(s[0])
 }
 
+// Get the samples for a band of rows. Sample locations are unchanged, so the band is
+// raycast exactly as it would be as part of the whole sprite.
+func (s Samples) Rows(start, end int) Samples {
+	result := make(Samples, len(s))
+	for x := range s {
+		result[x] = s[x][start:end]
+	}
+
+	return result
+}
+
 func (s Samples) GetImage() (img *image.RGBA) {
 	rect := image.Rect(0, 0, 200, 200)
 	img = image.NewRGBA(rect)
@@ -108,6 +120,7 @@ func Square(width, height int, accuracy int, overlap float64, falloff float64) (
 const discs = 10
 
 var discCache [][]geometry.Vector2
+var discCacheLock sync.Mutex
 
 func Disc(width, height int, accuracy int, overlap float64, falloff float64) (result Samples) {
 	radiusSquared := (0.5 + overlap) * (0.5 + overlap)
@@ -144,6 +157,9 @@ func Disc(width, height int, accuracy int, overlap float64, falloff float64) (re
 
 // Get a poisson disc using the naive/slow dart throwing algorithm
 func getPoissonDisc(accuracy int, overlap float64) []geometry.Vector2 {
+	discCacheLock.Lock()
+	defer discCacheLock.Unlock()
+
 	if discCache == nil {
 		discCache = make([][]geometry.Vector2, discs)
 	}
@@ -0,0 +1,163 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"github.com/mattkimber/gorender/internal/server/renderpb"
+	"github.com/mattkimber/gorender/internal/spritesheet"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"image"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// A process function gets the definition, including processed voxel objects, needed
+// to render the input voxel file
+type ProcessFunc func(inputFilename string, m manifest.Manifest, scale float64) (manifest.Definition, error)
+
+type grpcService struct {
+	renderpb.UnimplementedRendererServer
+	server *Server
+}
+
+// Register the gRPC render service. Jobs share the REST API's MaxJobs limit.
+func (s *Server) RegisterGrpc(g *grpc.Server) {
+	renderpb.RegisterRendererServer(g, &grpcService{server: s})
+}
+
+func (s *Server) ListenAndServeGrpc(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	g := grpc.NewServer()
+	s.RegisterGrpc(g)
+	return g.Serve(listener)
+}
+
+func (g *grpcService) Render(req *renderpb.RenderRequest, stream renderpb.Renderer_RenderServer) (err error) {
+	m, err := manifest.FromJson(bytes.NewReader(req.Manifest))
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "could not read manifest: %v", err)
+	}
+
+	scale := req.Scale
+	if scale == 0 {
+		scale = 1.0
+	} else if scale < 0 {
+		return status.Errorf(codes.InvalidArgument, "invalid scale %f", scale)
+	}
+
+	if len(req.Files) == 0 {
+		return status.Error(codes.InvalidArgument, "no voxel files supplied")
+	}
+
+	dir, err := os.MkdirTemp(g.server.Dir, "gorender-job")
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	job := &Job{dir: dir, assets: make(map[string]bool), input: req.Input}
+	for _, f := range req.Files {
+		if err := job.addAsset(f.Name, bytes.NewReader(f.Data)); err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+
+	if job.input == "" {
+		job.input = filepath.Base(req.Files[0].Name)
+	}
+
+	if err := job.resolveFiles(&m); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	g.server.acquire()
+	defer g.server.release()
+
+	// Don't let a bad job bring down the whole server
+	defer func() {
+		if r := recover(); r != nil {
+			err = status.Errorf(codes.Internal, "render failed: %v", r)
+		}
+	}()
+
+	if err := sendProgress(stream, "Voxel processing", 0, 1); err != nil {
+		return err
+	}
+
+	def, err := g.server.Process(filepath.Join(dir, job.input), m, scale)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "could not process objects: %v", err)
+	}
+
+	if err := sendProgress(stream, "Voxel processing", 1, 1); err != nil {
+		return err
+	}
+
+	// Render the whole spritesheet at once, so sprites are placed and mirrored as they would be
+	// in a spritesheet, and send each sprite as soon as it is ready
+	var mutex sync.Mutex
+	var sendErr error
+	completed, total := 0, len(def.Manifest.Sprites)
+
+	sheets := spritesheet.GetStreamedSpritesheets(def, func(index int, images map[string]image.Image) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if sendErr != nil {
+			return
+		}
+
+		if err := stream.Context().Err(); err != nil {
+			sendErr = status.FromContextError(err).Err()
+			return
+		}
+
+		result, err := getSpriteResult(images)
+		if err != nil {
+			sendErr = status.Error(codes.Internal, err.Error())
+			return
+		}
+
+		result.Index, result.Angle = int32(index), def.Manifest.Sprites[index].Angle
+		if sendErr = stream.Send(&renderpb.RenderUpdate{Update: &renderpb.RenderUpdate_Sprite{Sprite: result}}); sendErr != nil {
+			return
+		}
+
+		completed++
+		sendErr = sendProgress(stream, "Rendering", completed, total)
+	})
+	sheets.Release()
+
+	return sendErr
+}
+
+func sendProgress(stream renderpb.Renderer_RenderServer, stage string, completed, total int) error {
+	return stream.Send(&renderpb.RenderUpdate{Update: &renderpb.RenderUpdate_Progress{Progress: &renderpb.Progress{
+		Stage:     stage,
+		Completed: int32(completed),
+		Total:     int32(total),
+	}}})
+}
+
+func getSpriteResult(images map[string]image.Image) (*renderpb.SpriteResult, error) {
+	data := make(map[string][]byte)
+
+	for _, depth := range []string{"8bpp", "32bpp", "mask"} {
+		buf := bytes.Buffer{}
+		if err := (spritesheet.Spritesheet{Image: images[depth]}).OutputToWriter(&buf); err != nil {
+			return nil, fmt.Errorf("could not encode %s image: %v", depth, err)
+		}
+		data[depth] = buf.Bytes()
+	}
+
+	return &renderpb.SpriteResult{Image_8Bpp: data["8bpp"], Image_32Bpp: data["32bpp"], Mask: data["mask"]}, nil
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"github.com/mattkimber/gandalf/geometry"
+	"github.com/mattkimber/gandalf/magica"
+	"github.com/mattkimber/gorender/internal/colour"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"github.com/mattkimber/gorender/internal/server/renderpb"
+	"github.com/mattkimber/gorender/internal/voxelobject"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"image/png"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"testing"
+)
+
+func getGrpcClient(t *testing.T, s *Server) renderpb.RendererClient {
+	listener := bufconn.Listen(1 << 20)
+	g := grpc.NewServer()
+	s.RegisterGrpc(g)
+	go func() { _ = g.Serve(listener) }()
+	t.Cleanup(g.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return listener.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("could not connect: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return renderpb.NewRendererClient(conn)
+}
+
+func processCube(inputFilename string, m manifest.Manifest, scale float64) (manifest.Definition, error) {
+	object := magica.VoxelObject{Size: geometry.Point{X: 4, Y: 4, Z: 4}}
+	object.Voxels = make([][][]byte, 4)
+	for x := range object.Voxels {
+		object.Voxels[x] = make([][]byte, 4)
+		for y := range object.Voxels[x] {
+			object.Voxels[x][y] = []byte{20, 20, 20, 20}
+		}
+	}
+
+	file, err := os.Open("../../files/ttd_palette.json")
+	if err != nil {
+		return manifest.Definition{}, err
+	}
+	defer func() { _ = file.Close() }()
+
+	palette, err := colour.FromJson(file)
+	if err != nil {
+		return manifest.Definition{}, err
+	}
+
+	return manifest.Definition{
+		Object:   voxelobject.GetProcessedVoxelObject(object, &palette, false, "normal", false, false),
+		Manifest: m,
+		Palette:  palette,
+		Scale:    scale,
+	}, nil
+}
+
+func TestGrpcService_Render(t *testing.T) {
+	client := getGrpcClient(t, &Server{Dir: t.TempDir(), Process: processCube})
+
+	stream, err := client.Render(context.Background(), &renderpb.RenderRequest{
+		Manifest: []byte(`{"size":{"x":4,"y":4,"z":4},"symmetric":true,"sprites":[{"angle":0,"width":8,"height":8},{"angle":90,"width":8,"height":8},{"angle":270,"width":8,"height":8}]}`),
+		Files:    []*renderpb.File{{Name: "cube.vox", Data: []byte("VOX ")}},
+	})
+	if err != nil {
+		t.Fatalf("could not start render: %v", err)
+	}
+
+	var sprites []*renderpb.SpriteResult
+	var progress []*renderpb.Progress
+	for {
+		update, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("render failed: %v", err)
+		}
+
+		if update.GetSprite() != nil {
+			sprites = append(sprites, update.GetSprite())
+		} else {
+			progress = append(progress, update.GetProgress())
+		}
+	}
+
+	// Sprites are sent in the order they finish shading
+	sort.Slice(sprites, func(i, j int) bool { return sprites[i].Index < sprites[j].Index })
+	if len(sprites) != 3 || sprites[1].Index != 1 || sprites[1].Angle != 90 || sprites[2].Angle != 270 {
+		t.Fatalf("expected 3 sprite results, got %v", sprites)
+	}
+
+	for _, spr := range sprites {
+		if len(spr.Image_8Bpp) == 0 || len(spr.Image_32Bpp) == 0 || len(spr.Mask) == 0 {
+			t.Errorf("sprite %d expected all images, got %d/%d/%d bytes", spr.Index, len(spr.Image_8Bpp), len(spr.Image_32Bpp), len(spr.Mask))
+		}
+
+		// Images are the size of the sprite, without the spacing between sprites in a spritesheet
+		img, err := png.Decode(bytes.NewReader(spr.Image_32Bpp))
+		if err != nil {
+			t.Fatalf("sprite %d could not decode image: %v", spr.Index, err)
+		}
+
+		if size := img.Bounds().Size(); size.X != 8 || size.Y != 8 {
+			t.Errorf("sprite %d expected 8x8 image, got %dx%d", spr.Index, size.X, size.Y)
+		}
+	}
+
+	last := progress[len(progress)-1]
+	if last.Stage != "Rendering" || last.Completed != 3 || last.Total != 3 {
+		t.Errorf("expected final progress of 3/3 sprites rendered, got %v", last)
+	}
+}
+
+func TestGrpcService_InvalidRequests(t *testing.T) {
+	client := getGrpcClient(t, &Server{Dir: t.TempDir(), Process: processCube})
+
+	testCases := []struct {
+		name string
+		req  *renderpb.RenderRequest
+	}{
+		{"no files", &renderpb.RenderRequest{Manifest: []byte(`{}`)}},
+		{"bad manifest", &renderpb.RenderRequest{Manifest: []byte(`{`), Files: []*renderpb.File{{Name: "a.vox"}}}},
+		{"not voxel", &renderpb.RenderRequest{Manifest: []byte(`{}`), Files: []*renderpb.File{{Name: "a.png"}}}},
+		{"missing input", &renderpb.RenderRequest{Manifest: []byte(`{}`), Files: []*renderpb.File{{Name: "a.vox"}}, Input: "b.vox"}},
+	}
+
+	for _, testCase := range testCases {
+		stream, err := client.Render(context.Background(), testCase.req)
+		if err == nil {
+			_, err = stream.Recv()
+		}
+
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("%s expected %v, got %v", testCase.name, codes.InvalidArgument, err)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.4.0
+// - protoc             (unknown)
+// source: render.proto
+
+package renderpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	Renderer_Render_FullMethodName = "/gorender.Renderer/Render"
+)
+
+// RendererClient is the client API for Renderer service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Renders voxel objects to sprites
+type RendererClient interface {
+	// Render a voxel object. Progress is streamed as the object is processed and
+	// each sprite rendered, with the result for each sprite sent as soon as it is
+	// available.
+	Render(ctx context.Context, in *RenderRequest, opts ...grpc.CallOption) (Renderer_RenderClient, error)
+}
+
+type rendererClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewRendererClient(cc grpc.ClientConnInterface) RendererClient {
+	return &rendererClient{cc}
+}
+
+func (c *rendererClient) Render(ctx context.Context, in *RenderRequest, opts ...grpc.CallOption) (Renderer_RenderClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Renderer_ServiceDesc.Streams[0], Renderer_Render_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &rendererRenderClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Renderer_RenderClient interface {
+	Recv() (*RenderUpdate, error)
+	grpc.ClientStream
+}
+
+type rendererRenderClient struct {
+	grpc.ClientStream
+}
+
+func (x *rendererRenderClient) Recv() (*RenderUpdate, error) {
+	m := new(RenderUpdate)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// RendererServer is the server API for Renderer service.
+// All implementations must embed UnimplementedRendererServer
+// for forward compatibility
+//
+// Renders voxel objects to sprites
+type RendererServer interface {
+	// Render a voxel object. Progress is streamed as the object is processed and
+	// each sprite rendered, with the result for each sprite sent as soon as it is
+	// available.
+	Render(*RenderRequest, Renderer_RenderServer) error
+	mustEmbedUnimplementedRendererServer()
+}
+
+// UnimplementedRendererServer must be embedded to have forward compatible implementations.
+type UnimplementedRendererServer struct {
+}
+
+func (UnimplementedRendererServer) Render(*RenderRequest, Renderer_RenderServer) error {
+	return status.Errorf(codes.Unimplemented, "method Render not implemented")
+}
+func (UnimplementedRendererServer) mustEmbedUnimplementedRendererServer() {}
+
+// UnsafeRendererServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to RendererServer will
+// result in compilation errors.
+type UnsafeRendererServer interface {
+	mustEmbedUnimplementedRendererServer()
+}
+
+func RegisterRendererServer(s grpc.ServiceRegistrar, srv RendererServer) {
+	s.RegisterService(&Renderer_ServiceDesc, srv)
+}
+
+func _Renderer_Render_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(RenderRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RendererServer).Render(m, &rendererRenderServer{ServerStream: stream})
+}
+
+type Renderer_RenderServer interface {
+	Send(*RenderUpdate) error
+	grpc.ServerStream
+}
+
+type rendererRenderServer struct {
+	grpc.ServerStream
+}
+
+func (x *rendererRenderServer) Send(m *RenderUpdate) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Renderer_ServiceDesc is the grpc.ServiceDesc for Renderer service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Renderer_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "gorender.Renderer",
+	HandlerType: (*RendererServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Render",
+			Handler:       _Renderer_Render_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "render.proto",
+}
@@ -0,0 +1,420 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/mattkimber/gorender/internal/checksum"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusQueued  Status = "queued"
+	StatusRunning Status = "running"
+	StatusDone    Status = "done"
+	StatusFailed  Status = "failed"
+)
+
+const maxUploadSize = 64 << 20
+
+// A render function renders the input voxel file, writing all output files to
+// the same directory
+type RenderFunc func(inputFilename string, m manifest.Manifest, scale float64) error
+
+type Job struct {
+	ID     string   `json:"id"`
+	Status Status   `json:"status"`
+	Error  string   `json:"error,omitempty"`
+	Files  []string `json:"files,omitempty"`
+
+	// SHA256 checksum of each output file
+	Checksums map[string]string `json:"checksums,omitempty"`
+
+	dir    string
+	input  string
+	assets map[string]bool
+}
+
+// A server accepts render jobs over HTTP or gRPC, running at most MaxJobs at a time
+type Server struct {
+	Render  RenderFunc
+	Process ProcessFunc
+	MaxJobs int
+
+	// Directory to keep job files in, or the system temporary directory if empty
+	Dir string
+
+	// Time to keep finished jobs and their files for, or until they are deleted if zero
+	Expiry time.Duration
+
+	mutex  sync.Mutex
+	jobs   map[string]*Job
+	nextID int
+	slots  chan struct{}
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/jobs", s.handleJobs)
+	mux.HandleFunc("/jobs/", s.handleJob)
+	return mux
+}
+
+func (s *Server) ListenAndServe(addr string) error {
+	return http.ListenAndServe(addr, s.Handler())
+}
+
+func (s *Server) handleJobs(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.mutex.Lock()
+		jobs := make([]Job, 0, len(s.jobs))
+		for _, job := range s.jobs {
+			jobs = append(jobs, *job)
+		}
+		s.mutex.Unlock()
+
+		sort.Slice(jobs, func(i, j int) bool { return jobs[i].ID < jobs[j].ID })
+		writeJson(w, http.StatusOK, jobs)
+	case http.MethodPost:
+		job, err := s.createJob(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		s.mutex.Lock()
+		result := *job
+		s.mutex.Unlock()
+
+		w.Header().Set("Location", "/jobs/"+job.ID)
+		writeJson(w, http.StatusAccepted, result)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleJob(w http.ResponseWriter, r *http.Request) {
+	// Paths are either /jobs/{id} or /jobs/{id}/files/{name}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/jobs/"), "/")
+
+	if r.Method == http.MethodDelete && len(parts) == 1 {
+		s.handleDelete(w, r, parts[0])
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.mutex.Lock()
+	job, ok := s.jobs[parts[0]]
+	var result Job
+	if ok {
+		result = *job
+	}
+	s.mutex.Unlock()
+
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch {
+	case len(parts) == 1:
+		writeJson(w, http.StatusOK, result)
+	case len(parts) == 3 && parts[1] == "files" && result.hasFile(parts[2]):
+		http.ServeFile(w, r, filepath.Join(result.dir, parts[2]))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request, id string) {
+	found, err := s.removeJob(id)
+	switch {
+	case !found:
+		http.NotFound(w, r)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// Remove a finished job and its files. Jobs which are queued or running can't be removed.
+func (s *Server) removeJob(id string) (found bool, err error) {
+	s.mutex.Lock()
+	job, found := s.jobs[id]
+	if found && (job.Status == StatusQueued || job.Status == StatusRunning) {
+		s.mutex.Unlock()
+		return true, fmt.Errorf("job %s has not finished", id)
+	}
+	delete(s.jobs, id)
+	s.mutex.Unlock()
+
+	if found {
+		_ = os.RemoveAll(job.dir)
+	}
+
+	return
+}
+
+func (j Job) hasFile(name string) bool {
+	for _, f := range j.Files {
+		if f == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Create a job from a multipart form containing the manifest, voxel files and
+// optionally the input file name and scale, then queue it for rendering
+func (s *Server) createJob(r *http.Request) (*Job, error) {
+	if err := r.ParseMultipartForm(maxUploadSize); err != nil {
+		return nil, fmt.Errorf("could not read form: %v", err)
+	}
+
+	scale := 1.0
+	if value := r.FormValue("scale"); value != "" {
+		var err error
+		if scale, err = strconv.ParseFloat(value, 64); err != nil || scale <= 0 {
+			return nil, fmt.Errorf("invalid scale %s", value)
+		}
+	}
+
+	m, err := getManifest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp(s.Dir, "gorender-job")
+	if err != nil {
+		return nil, err
+	}
+
+	job := &Job{Status: StatusQueued, dir: dir, assets: make(map[string]bool)}
+	if err := job.saveAssets(r.MultipartForm.File["files"]); err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+
+	job.input = r.FormValue("input")
+	if job.input == "" && len(r.MultipartForm.File["files"]) > 0 {
+		job.input = filepath.Base(r.MultipartForm.File["files"][0].Filename)
+	}
+
+	if err := job.resolveFiles(&m); err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	if s.jobs == nil {
+		s.jobs = make(map[string]*Job)
+	}
+	s.nextID++
+	job.ID = strconv.Itoa(s.nextID)
+	s.jobs[job.ID] = job
+	s.mutex.Unlock()
+
+	go s.run(job, m, scale)
+	return job, nil
+}
+
+// Wait until fewer than MaxJobs jobs are running, and take a slot for a new one
+func (s *Server) acquire() {
+	s.mutex.Lock()
+	if s.slots == nil {
+		maxJobs := s.MaxJobs
+		if maxJobs < 1 {
+			maxJobs = 1
+		}
+		s.slots = make(chan struct{}, maxJobs)
+	}
+	slots := s.slots
+	s.mutex.Unlock()
+
+	slots <- struct{}{}
+}
+
+func (s *Server) release() {
+	<-s.slots
+}
+
+func getManifest(r *http.Request) (manifest.Manifest, error) {
+	var reader io.Reader
+	if file, _, err := r.FormFile("manifest"); err == nil {
+		defer func() { _ = file.Close() }()
+		reader = file
+	} else if value := r.FormValue("manifest"); value != "" {
+		reader = strings.NewReader(value)
+	} else {
+		return manifest.Manifest{}, fmt.Errorf("no manifest supplied")
+	}
+
+	m, err := manifest.FromJson(reader)
+	if err != nil {
+		return m, fmt.Errorf("could not read manifest: %v", err)
+	}
+
+	return m, nil
+}
+
+func (j *Job) saveAssets(files []*multipart.FileHeader) error {
+	if len(files) == 0 {
+		return fmt.Errorf("no voxel files supplied")
+	}
+
+	for _, header := range files {
+		in, err := header.Open()
+		if err != nil {
+			return err
+		}
+
+		err = j.addAsset(header.Filename, in)
+		_ = in.Close()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Save a voxel file to the job directory, using only the base of its name
+func (j *Job) addAsset(name string, in io.Reader) error {
+	name = filepath.Base(name)
+	if !strings.HasSuffix(name, ".vox") {
+		return fmt.Errorf("file %s does not have .vox extension", name)
+	}
+
+	out, err := os.Create(filepath.Join(j.dir, name))
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+
+	j.assets[name] = true
+	return out.Close()
+}
+
+// Check the input and all objects used by sprites were uploaded, and point them
+// at the job directory
+func (j *Job) resolveFiles(m *manifest.Manifest) error {
+	if !j.assets[j.input] {
+		return fmt.Errorf("input file %s was not supplied", j.input)
+	}
+
+	for i, spr := range m.Sprites {
+		filename, node, _ := strings.Cut(spr.Object, "#")
+		if filename == "" {
+			continue
+		}
+
+		if !j.assets[filename] {
+			return fmt.Errorf("object file %s was not supplied", filename)
+		}
+
+		m.Sprites[i].Object = filepath.Join(j.dir, filename)
+		if node != "" {
+			m.Sprites[i].Object += "#" + node
+		}
+	}
+
+	return nil
+}
+
+func (s *Server) run(job *Job, m manifest.Manifest, scale float64) {
+	s.acquire()
+	defer s.release()
+
+	// Don't let a bad job bring down the whole server
+	defer func() {
+		if r := recover(); r != nil {
+			s.setStatus(job, StatusFailed, fmt.Errorf("render failed: %v", r))
+		}
+	}()
+
+	s.setStatus(job, StatusRunning, nil)
+
+	if err := s.Render(filepath.Join(job.dir, job.input), m, scale); err != nil {
+		s.setStatus(job, StatusFailed, err)
+		return
+	}
+
+	entries, err := os.ReadDir(job.dir)
+	if err != nil {
+		s.setStatus(job, StatusFailed, err)
+		return
+	}
+
+	var files []string
+	checksums := make(map[string]string)
+	for _, entry := range entries {
+		if job.assets[entry.Name()] {
+			continue
+		}
+
+		if checksums[entry.Name()], err = checksum.File(filepath.Join(job.dir, entry.Name())); err != nil {
+			s.setStatus(job, StatusFailed, err)
+			return
+		}
+		files = append(files, entry.Name())
+	}
+
+	s.mutex.Lock()
+	job.Files, job.Checksums = files, checksums
+	s.mutex.Unlock()
+
+	s.setStatus(job, StatusDone, nil)
+}
+
+func (s *Server) setStatus(job *Job, status Status, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	job.Status = status
+	if err != nil {
+		job.Error = err.Error()
+	}
+
+	if status != StatusDone && status != StatusFailed {
+		return
+	}
+
+	// Failed jobs have no files to download, so only their status is kept
+	if status == StatusFailed {
+		_ = os.RemoveAll(job.dir)
+	}
+
+	if s.Expiry > 0 {
+		id := job.ID
+		time.AfterFunc(s.Expiry, func() { _, _ = s.removeJob(id) })
+	}
+}
+
+func writeJson(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,215 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func getJobRequest(t *testing.T, manifestJson string, files ...string) *http.Request {
+	body := &bytes.Buffer{}
+	form := multipart.NewWriter(body)
+
+	if err := form.WriteField("manifest", manifestJson); err != nil {
+		t.Fatalf("could not write manifest: %v", err)
+	}
+
+	for _, f := range files {
+		w, err := form.CreateFormFile("files", f)
+		if err != nil {
+			t.Fatalf("could not write file: %v", err)
+		}
+		_, _ = w.Write([]byte("VOX "))
+	}
+
+	_ = form.Close()
+
+	r := httptest.NewRequest("POST", "/jobs", body)
+	r.Header.Set("Content-Type", form.FormDataContentType())
+	return r
+}
+
+func TestServer_Jobs(t *testing.T) {
+	s := Server{
+		Dir: t.TempDir(),
+		Render: func(inputFilename string, m manifest.Manifest, scale float64) error {
+			if len(m.Sprites) > 0 && m.Sprites[0].Object != filepath.Join(filepath.Dir(inputFilename), "cargo.vox#bogie") {
+				return fmt.Errorf("unexpected object %s", m.Sprites[0].Object)
+			}
+			return os.WriteFile(strings.TrimSuffix(inputFilename, ".vox")+"_8bpp.png", []byte("png"), 0644)
+		},
+	}
+
+	recorder := httptest.NewRecorder()
+	s.Handler().ServeHTTP(recorder, getJobRequest(t, `{"sprites":[{"width":8,"object":"cargo.vox#bogie"}]}`, "test.vox", "cargo.vox"))
+	if recorder.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusAccepted, recorder.Code, recorder.Body.String())
+	}
+
+	job := Job{}
+	for i := 0; i < 100 && job.Status != StatusDone && job.Status != StatusFailed; i++ {
+		time.Sleep(10 * time.Millisecond)
+		recorder = httptest.NewRecorder()
+		s.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/jobs/1", nil))
+		if err := json.NewDecoder(recorder.Body).Decode(&job); err != nil {
+			t.Fatalf("could not decode job: %v", err)
+		}
+	}
+
+	if job.Status != StatusDone || len(job.Files) != 1 || job.Files[0] != "test_8bpp.png" {
+		t.Fatalf("expected done job with output file, got %v", job)
+	}
+
+	if sum := job.Checksums["test_8bpp.png"]; sum != "8f8cbb7dcf46e0bc7d53265749a6c17d116093a6ba95e442764060c76fd4a86c" {
+		t.Errorf("expected checksum of output file, got %s", sum)
+	}
+
+	testCases := []struct {
+		url          string
+		expectedCode int
+	}{
+		{"/jobs", http.StatusOK},
+		{"/jobs/1/files/test_8bpp.png", http.StatusOK},
+		{"/jobs/1/files/test.vox", http.StatusNotFound},
+		{"/jobs/2", http.StatusNotFound},
+	}
+
+	for _, testCase := range testCases {
+		recorder := httptest.NewRecorder()
+		s.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", testCase.url, nil))
+		if recorder.Code != testCase.expectedCode {
+			t.Errorf("%s expected status %d, got %d", testCase.url, testCase.expectedCode, recorder.Code)
+		}
+	}
+}
+
+func TestServer_InvalidJobs(t *testing.T) {
+	s := Server{Dir: t.TempDir(), Render: func(string, manifest.Manifest, float64) error { return nil }}
+
+	testCases := []struct {
+		name     string
+		manifest string
+		files    []string
+	}{
+		{"no files", `{}`, nil},
+		{"no manifest", ``, []string{"test.vox"}},
+		{"bad manifest", `{`, []string{"test.vox"}},
+		{"not voxel", `{}`, []string{"test.png"}},
+		{"missing object", `{"sprites":[{"object":"cargo.vox"}]}`, []string{"test.vox"}},
+	}
+
+	for _, testCase := range testCases {
+		recorder := httptest.NewRecorder()
+		s.Handler().ServeHTTP(recorder, getJobRequest(t, testCase.manifest, testCase.files...))
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("%s expected status %d, got %d", testCase.name, http.StatusBadRequest, recorder.Code)
+		}
+	}
+}
+
+func waitForJob(t *testing.T, s *Server, id string) (job Job) {
+	for i := 0; i < 100 && job.Status != StatusDone && job.Status != StatusFailed; i++ {
+		time.Sleep(10 * time.Millisecond)
+		recorder := httptest.NewRecorder()
+		s.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/jobs/"+id, nil))
+		if err := json.NewDecoder(recorder.Body).Decode(&job); err != nil {
+			t.Fatalf("could not decode job: %v", err)
+		}
+	}
+
+	return
+}
+
+func TestServer_DeleteJob(t *testing.T) {
+	dir := t.TempDir()
+	release := make(chan struct{})
+	s := Server{
+		Dir:     dir,
+		MaxJobs: 2,
+		Render: func(inputFilename string, m manifest.Manifest, scale float64) error {
+			if strings.HasSuffix(inputFilename, "slow.vox") {
+				<-release
+			}
+			if strings.HasSuffix(inputFilename, "bad.vox") {
+				return fmt.Errorf("render failed")
+			}
+			return os.WriteFile(strings.TrimSuffix(inputFilename, ".vox")+"_8bpp.png", []byte("png"), 0644)
+		},
+	}
+
+	for _, f := range []string{"test.vox", "slow.vox", "bad.vox"} {
+		s.Handler().ServeHTTP(httptest.NewRecorder(), getJobRequest(t, `{}`, f))
+	}
+
+	if job := waitForJob(t, &s, "1"); job.Status != StatusDone {
+		t.Fatalf("expected done job, got %v", job)
+	}
+
+	// Failed jobs keep their status, but not their files
+	if job := waitForJob(t, &s, "3"); job.Status != StatusFailed {
+		t.Fatalf("expected failed job, got %v", job)
+	}
+
+	testCases := []struct {
+		url          string
+		expectedCode int
+	}{
+		{"/jobs/1", http.StatusNoContent},
+		{"/jobs/1", http.StatusNotFound},
+		{"/jobs/2", http.StatusConflict},
+		{"/jobs/3", http.StatusNoContent},
+		{"/jobs/4", http.StatusNotFound},
+	}
+
+	for _, testCase := range testCases {
+		recorder := httptest.NewRecorder()
+		s.Handler().ServeHTTP(recorder, httptest.NewRequest("DELETE", testCase.url, nil))
+		if recorder.Code != testCase.expectedCode {
+			t.Errorf("DELETE %s expected status %d, got %d", testCase.url, testCase.expectedCode, recorder.Code)
+		}
+	}
+
+	close(release)
+	waitForJob(t, &s, "2")
+
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("expected only the unfinished job's directory to remain, got %d directories", len(entries))
+	}
+}
+
+func TestServer_Expiry(t *testing.T) {
+	dir := t.TempDir()
+	s := Server{
+		Dir:    dir,
+		Expiry: 10 * time.Millisecond,
+		Render: func(string, manifest.Manifest, float64) error { return nil },
+	}
+
+	s.Handler().ServeHTTP(httptest.NewRecorder(), getJobRequest(t, `{}`, "test.vox"))
+
+	for i := 0; i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+		if entries, _ := os.ReadDir(dir); len(entries) == 0 {
+			break
+		}
+	}
+
+	recorder := httptest.NewRecorder()
+	s.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/jobs/1", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("expected expired job to be removed, got status %d", recorder.Code)
+	}
+
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("expected expired job's files to be removed, got %d directories", len(entries))
+	}
+}
@@ -0,0 +1,34 @@
+package sprite
+
+import "github.com/mattkimber/gorender/internal/colour"
+
+// A pixel has "bled" if its source colour and output colour disagree on whether
+// they are company colours, e.g. a regular colour dithered into a company colour index.
+func IsCompanyColourBleed(s *ShaderInfo, palette *colour.Palette) bool {
+	if s.ModalIndex == 0 || s.DitheredIndex == 0 {
+		return false
+	}
+
+	return palette.IsCompanyColour(s.ModalIndex) != palette.IsCompanyColour(s.DitheredIndex)
+}
+
+// Replace company colour indexes which came from non-company colour sources
+// with the nearest regular palette index
+func CorrectCompanyColourBleed(output ShaderOutput, palette *colour.Palette, regularPalette []colour.RGB) (corrected int) {
+	for x := range output {
+		for y := range output[x] {
+			s := &output[x][y]
+
+			if !IsCompanyColourBleed(s, palette) || !palette.IsCompanyColour(s.DitheredIndex) {
+				continue
+			}
+
+			s.DitheredIndex = getBestIndex(s.Colour, regularPalette)
+			s.IsMaskColour = palette.IsSpecialColour(s.DitheredIndex)
+			s.IsBleedCorrected = true
+			corrected++
+		}
+	}
+
+	return
+}
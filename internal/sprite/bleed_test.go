@@ -0,0 +1,59 @@
+package sprite
+
+import (
+	"github.com/mattkimber/gorender/internal/colour"
+	"testing"
+)
+
+func getBleedPalette() colour.Palette {
+	palette := colour.Palette{Entries: []colour.PaletteEntry{{}, {R: 255}, {R: 128}, {B: 255}, {B: 128}}}
+	palette.SetRanges([]colour.PaletteRange{
+		{Start: 1, End: 2},
+		{Start: 3, End: 4, IsPrimaryCompanyColour: true},
+	})
+	return palette
+}
+
+func TestIsCompanyColourBleed(t *testing.T) {
+	palette := getBleedPalette()
+
+	testCases := []struct {
+		modal, dithered byte
+		expected        bool
+	}{
+		{0, 3, false},
+		{1, 0, false},
+		{1, 2, false},
+		{3, 4, false},
+		{1, 3, true},
+		{3, 1, true},
+	}
+
+	for _, testCase := range testCases {
+		info := ShaderInfo{ModalIndex: testCase.modal, DitheredIndex: testCase.dithered}
+		if result := IsCompanyColourBleed(&info, &palette); result != testCase.expected {
+			t.Errorf("Bleed for modal %d dithered %d expected %v, got %v", testCase.modal, testCase.dithered, testCase.expected, result)
+		}
+	}
+}
+
+func TestCorrectCompanyColourBleed(t *testing.T) {
+	palette := getBleedPalette()
+	output := ShaderOutput{{
+		{ModalIndex: 1, DitheredIndex: 3, Colour: colour.RGB{R: 30000}, IsMaskColour: true},
+		{ModalIndex: 3, DitheredIndex: 1},
+		{ModalIndex: 1, DitheredIndex: 1},
+	}}
+
+	if corrected := CorrectCompanyColourBleed(output, &palette, palette.GetRegularPalette()); corrected != 1 {
+		t.Errorf("Expected 1 pixel corrected, got %d", corrected)
+	}
+
+	if output[0][0].DitheredIndex != 2 || output[0][0].IsMaskColour || !output[0][0].IsBleedCorrected {
+		t.Errorf("Bleed pixel not corrected to nearest regular colour: %v", output[0][0])
+	}
+
+	if output[0][1].DitheredIndex != 1 || output[0][1].IsBleedCorrected {
+		t.Errorf("Company colour source pixel should not be corrected: %v", output[0][1])
+	}
+}
@@ -0,0 +1,32 @@
+package sprite
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// Depth buffer values are linear, with the centre of the object at the midpoint of the
+// 16-bit range and each voxel of distance along the view direction worth this many units.
+// 0 is 256 voxels in front of the centre, 65535 is 256 voxels behind it (and empty pixels).
+const (
+	DepthBufferCentre = 32768
+	DepthBufferScale  = 128
+)
+
+func GetDepthBufferValue(s *ShaderInfo) uint16 {
+	if s.Alpha == 0 || math.IsInf(s.ViewDepth, 0) {
+		return math.MaxUint16
+	}
+
+	value := math.Round(DepthBufferCentre + s.ViewDepth*DepthBufferScale)
+	return uint16(math.Max(0, math.Min(math.MaxUint16, value)))
+}
+
+func ApplyDepthBufferSprite(img *image.Gray16, bounds image.Rectangle, loc image.Point, info ShaderOutput) {
+	for x := bounds.Min.X; x < bounds.Max.X; x++ {
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			img.SetGray16(x+loc.X, y+loc.Y, color.Gray16{Y: GetDepthBufferValue(&info[x][y])})
+		}
+	}
+}
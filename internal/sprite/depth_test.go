@@ -0,0 +1,27 @@
+package sprite
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGetDepthBufferValue(t *testing.T) {
+	testCases := []struct {
+		info     ShaderInfo
+		expected uint16
+	}{
+		{ShaderInfo{}, 65535},
+		{ShaderInfo{Alpha: 1, ViewDepth: math.Inf(1)}, 65535},
+		{ShaderInfo{Alpha: 1, ViewDepth: 0}, 32768},
+		{ShaderInfo{Alpha: 1, ViewDepth: -2.5}, 32448},
+		{ShaderInfo{Alpha: 0.5, ViewDepth: 10}, 34048},
+		{ShaderInfo{Alpha: 1, ViewDepth: -1000}, 0},
+		{ShaderInfo{Alpha: 1, ViewDepth: 1000}, 65535},
+	}
+
+	for _, testCase := range testCases {
+		if result := GetDepthBufferValue(&testCase.info); result != testCase.expected {
+			t.Errorf("Depth buffer value for alpha %f depth %f expected %d, got %d", testCase.info.Alpha, testCase.info.ViewDepth, testCase.expected, result)
+		}
+	}
+}
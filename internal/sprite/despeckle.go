@@ -0,0 +1,95 @@
+package sprite
+
+import (
+	"github.com/mattkimber/gorender/internal/colour"
+	"github.com/mattkimber/gorender/internal/manifest"
+)
+
+// Replace isolated pixels, whose index differs from all four neighbours by more than the
+// threshold, with the most common of their neighbours' indexes. Indexes in different
+// ranges always differ by more than the threshold. Only pixels surrounded by the object
+// are changed, so outlines are kept, and colours kept as they are (such as animated
+// lights) are never changed.
+func Despeckle(output ShaderOutput, def *manifest.Definition, threshold int) (removed int) {
+	type change struct {
+		x, y  int
+		index byte
+	}
+
+	var changes []change
+	palette := &def.Palette
+
+	for x := 1; x < len(output)-1; x++ {
+		for y := 1; y < len(output[x])-1; y++ {
+			index := output[x][y].DitheredIndex
+			if index == 0 || isKeptColour(def, index) {
+				continue
+			}
+
+			neighbours := [4]byte{output[x-1][y].DitheredIndex, output[x+1][y].DitheredIndex, output[x][y-1].DitheredIndex, output[x][y+1].DitheredIndex}
+
+			isolated := true
+			for _, n := range neighbours {
+				if n == 0 || getIndexDifference(palette, index, n) <= threshold {
+					isolated = false
+					break
+				}
+			}
+
+			if isolated {
+				changes = append(changes, change{x, y, getReplacementIndex(palette, index, neighbours)})
+			}
+		}
+	}
+
+	// Changes are made once every pixel has been checked, so removing one pixel doesn't
+	// affect whether its neighbours are isolated
+	for _, c := range changes {
+		s := &output[c.x][c.y]
+		s.DitheredIndex = c.index
+		s.IsMaskColour = palette.IsSpecialColour(c.index)
+	}
+
+	return len(changes)
+}
+
+func isKeptColour(def *manifest.Definition, index byte) bool {
+	rng := def.Palette.Entries[index].Range
+	return (rng != nil && rng.IsAnimatedLight) || keepsIndex(def, index)
+}
+
+// Get how far apart two indexes are, where indexes in different ranges are as far apart
+// as possible
+func getIndexDifference(palette *colour.Palette, a, b byte) int {
+	if palette.Entries[a].Range != palette.Entries[b].Range {
+		return 256
+	}
+
+	return max(int(a), int(b)) - min(int(a), int(b))
+}
+
+// Get the most common neighbouring index. When neighbours are equally common, the one
+// closest in colour to the pixel being replaced is used.
+func getReplacementIndex(palette *colour.Palette, index byte, neighbours [4]byte) byte {
+	original := palette.Entries[index].GetRGB()
+	distance := func(i byte) float64 {
+		c := palette.Entries[i].GetRGB()
+		return squareDiff(c.R, original.R) + squareDiff(c.G, original.G) + squareDiff(c.B, original.B)
+	}
+
+	best, bestCount := neighbours[0], 0
+	for _, n := range neighbours {
+		count := 0
+		for _, m := range neighbours {
+			if m == n {
+				count++
+			}
+		}
+
+		if count > bestCount || (count == bestCount && distance(n) < distance(best)) {
+			best, bestCount = n, count
+		}
+	}
+
+	return best
+}
@@ -0,0 +1,44 @@
+package sprite
+
+import (
+	"github.com/mattkimber/gorender/internal/colour"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"testing"
+)
+
+func TestDespeckle(t *testing.T) {
+	palette := colour.Palette{Entries: make([]colour.PaletteEntry, 32)}
+	for i := range palette.Entries {
+		palette.Entries[i] = colour.PaletteEntry{R: byte(i * 8), G: byte(i * 8), B: byte(i * 8)}
+	}
+	palette.SetRanges([]colour.PaletteRange{{Start: 1, End: 15}, {Start: 16, End: 23}, {Start: 24, End: 31, IsAnimatedLight: true}})
+
+	testCases := []struct {
+		name       string
+		centre     byte
+		neighbours [4]byte
+		expected   byte
+	}{
+		{"similar", 7, [4]byte{5, 5, 6, 8}, 7},
+		{"speckle", 12, [4]byte{5, 5, 6, 4}, 5},
+		{"tie chooses closest colour", 12, [4]byte{4, 4, 7, 7}, 7},
+		{"other range", 18, [4]byte{5, 5, 5, 5}, 5},
+		{"next to similar pixel", 12, [4]byte{5, 5, 5, 11}, 12},
+		{"next to transparency", 12, [4]byte{5, 5, 5, 0}, 12},
+		{"animated", 26, [4]byte{5, 5, 5, 5}, 26},
+	}
+
+	for _, testCase := range testCases {
+		def := manifest.Definition{Palette: palette}
+		output := NewShaderOutput(3, 3)
+		output[1][1].DitheredIndex = testCase.centre
+		output[0][1].DitheredIndex, output[2][1].DitheredIndex = testCase.neighbours[0], testCase.neighbours[1]
+		output[1][0].DitheredIndex, output[1][2].DitheredIndex = testCase.neighbours[2], testCase.neighbours[3]
+
+		Despeckle(output, &def, 3)
+
+		if index := output[1][1].DitheredIndex; index != testCase.expected {
+			t.Errorf("%s: expected index %d, got %d", testCase.name, testCase.expected, index)
+		}
+	}
+}
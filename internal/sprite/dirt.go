@@ -0,0 +1,37 @@
+package sprite
+
+import (
+	"github.com/mattkimber/gorender/internal/colour"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"github.com/mattkimber/gorender/internal/raycaster"
+)
+
+// The most a dirt setting of 1 darkens the most occluded voxels
+const maxDirtDarkening = 0.5
+
+// Darken and desaturate a sample's colour by how occluded it is, so grime collects in
+// recesses. Dirty colours fade towards the dirt tint if there is one, otherwise to grey.
+// Company colours are only darkened, as the game colours them after they are drawn.
+func applyDirt(c colour.RGB, smp raycaster.RenderSample, d *manifest.Definition) colour.RGB {
+	if d.Manifest.Dirt == 0 || smp.Occlusion <= 0 {
+		return c
+	}
+
+	amount := d.Manifest.Dirt * float64(min(smp.Occlusion, 10)) / 10
+
+	if !d.Palette.IsSpecialColour(smp.Index) {
+		luminance := getLuminance(c)
+		grime := colour.RGB{R: luminance, G: luminance, B: luminance}
+		if tint := d.Palette.GetRGB(d.Manifest.DirtTint, false); d.Manifest.DirtTint != 0 && getLuminance(tint) > 0 {
+			grime = tint.MultiplyBy(luminance / getLuminance(tint))
+		}
+
+		c = c.MultiplyBy(1 - amount).Add(grime.MultiplyBy(amount))
+	}
+
+	return c.MultiplyBy(1 - amount*maxDirtDarkening)
+}
+
+func getLuminance(c colour.RGB) float64 {
+	return 0.2126*c.R + 0.7152*c.G + 0.0722*c.B
+}
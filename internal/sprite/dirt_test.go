@@ -0,0 +1,40 @@
+package sprite
+
+import (
+	"github.com/mattkimber/gorender/internal/colour"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"github.com/mattkimber/gorender/internal/raycaster"
+	"testing"
+)
+
+func Test_applyDirt(t *testing.T) {
+	palette := colour.Palette{Entries: []colour.PaletteEntry{{}, {R: 255}, {R: 128, G: 64}, {B: 255}}}
+	palette.SetRanges([]colour.PaletteRange{{Start: 1, End: 2}, {Start: 3, End: 3, IsPrimaryCompanyColour: true}})
+
+	red := colour.RGB{R: 40000, G: 20000, B: 20000}
+
+	testCases := []struct {
+		dirt      float64
+		tint      byte
+		index     byte
+		occlusion int
+		expected  colour.RGB
+	}{
+		{0, 0, 1, 10, red},
+		{1, 0, 1, 0, red},
+		{0.5, 0, 1, 10, red.MultiplyBy(0.5).Add(colour.RGB{R: 24252, G: 24252, B: 24252}.MultiplyBy(0.5)).MultiplyBy(0.75)},
+		{1, 0, 1, 20, colour.RGB{R: 24252, G: 24252, B: 24252}.MultiplyBy(0.5)},
+		{1, 2, 1, 10, colour.RGB{R: 128 * 255, G: 64 * 255}.MultiplyBy(24252 / (0.2126*128*255 + 0.7152*64*255)).MultiplyBy(0.5)},
+		{1, 0, 3, 10, red.MultiplyBy(0.5)},
+	}
+
+	for _, testCase := range testCases {
+		def := manifest.Definition{Palette: palette, Manifest: manifest.Manifest{Dirt: testCase.dirt, DirtTint: testCase.tint}}
+		smp := raycaster.RenderSample{Index: testCase.index, Occlusion: testCase.occlusion}
+
+		result := applyDirt(red, smp, &def)
+		if d := result.Subtract(testCase.expected); d.R*d.R+d.G*d.G+d.B*d.B > 1 {
+			t.Errorf("Dirt %f with tint %d on index %d and occlusion %d expected %v, got %v", testCase.dirt, testCase.tint, testCase.index, testCase.occlusion, testCase.expected, result)
+		}
+	}
+}
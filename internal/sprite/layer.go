@@ -0,0 +1,20 @@
+package sprite
+
+import "github.com/mattkimber/gorender/internal/manifest"
+
+// Get a copy of the shader output containing only the pixels whose modal (source) colour
+// is in the layer. Offsets are unchanged so the layers can be drawn over each other.
+func GetLayerOutput(output ShaderOutput, layer manifest.Layer) ShaderOutput {
+	result := make(ShaderOutput, len(output))
+
+	for x := range output {
+		result[x] = make([]ShaderInfo, len(output[x]))
+		for y := range output[x] {
+			if layer.Contains(output[x][y].ModalIndex) {
+				result[x][y] = output[x][y]
+			}
+		}
+	}
+
+	return result
+}
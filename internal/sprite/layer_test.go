@@ -0,0 +1,29 @@
+package sprite
+
+import (
+	"github.com/mattkimber/gorender/internal/manifest"
+	"testing"
+)
+
+func TestGetLayerOutput(t *testing.T) {
+	output := ShaderOutput{
+		{{ModalIndex: 0}, {ModalIndex: 10, DitheredIndex: 11, Alpha: 1}},
+		{{ModalIndex: 20, DitheredIndex: 20, Alpha: 1}, {ModalIndex: 12, DitheredIndex: 30, Alpha: 1}},
+	}
+
+	layer := manifest.Layer{Name: "glass", Ranges: []manifest.IndexRange{{Start: 10, End: 12}}}
+	result := GetLayerOutput(output, layer)
+
+	expected := [][]byte{{0, 11}, {0, 30}}
+	for x := range expected {
+		for y := range expected[x] {
+			if result[x][y].DitheredIndex != expected[x][y] {
+				t.Errorf("Layer pixel at %d,%d expected index %d, got %d", x, y, expected[x][y], result[x][y].DitheredIndex)
+			}
+		}
+	}
+
+	if output[1][0].DitheredIndex != 20 {
+		t.Errorf("Source output was modified")
+	}
+}
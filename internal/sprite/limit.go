@@ -0,0 +1,129 @@
+package sprite
+
+import (
+	"github.com/mattkimber/gorender/internal/colour"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"sort"
+)
+
+// Colours left out of a palette are set to this, which getBestIndex skips
+var excludedColour = colour.RGB{R: 65535, G: 0, B: 65535}
+
+// The palettes a sprite is dithered with, limited to the colours chosen for it
+type limitedPalettes struct {
+	allowed                     [256]bool
+	primary, secondary, regular []colour.RGB
+	any                         []colour.RGB
+}
+
+// Choose the colours a sprite is limited to: the most used of the palette indexes nearest
+// to each pixel's colour before dithering. Colours kept as they are, such as animated
+// lights, count towards the limit.
+func getLimitedPalettes(output ShaderOutput, def *manifest.Definition, primary, secondary, regular []colour.RGB) (l limitedPalettes) {
+	counts := make(map[byte]int)
+	for x := range output {
+		for y := range output[x] {
+			if index := getNearestIndex(def, &output[x][y], x, y, primary, secondary, regular); index != 0 {
+				counts[index]++
+			}
+		}
+	}
+
+	indexes := make([]byte, 0, len(counts))
+	for index := range counts {
+		indexes = append(indexes, index)
+	}
+
+	// Most used first, with lower indexes first when used equally so the choice is repeatable
+	sort.Slice(indexes, func(i, j int) bool {
+		if counts[indexes[i]] != counts[indexes[j]] {
+			return counts[indexes[i]] > counts[indexes[j]]
+		}
+		return indexes[i] < indexes[j]
+	})
+
+	for i, index := range indexes {
+		if i < def.Manifest.MaxColours {
+			l.allowed[index] = true
+		}
+	}
+
+	// Transparency doesn't count as a colour
+	l.allowed[0] = true
+
+	l.any = make([]colour.RGB, len(def.Palette.Entries))
+	for i, e := range def.Palette.Entries {
+		l.any[i] = excludedColour
+		if i != 0 && l.allowed[i] {
+			l.any[i] = colour.FromPaletteEntry(e)
+		}
+	}
+
+	l.primary, l.secondary, l.regular = l.limit(primary), l.limit(secondary), l.limit(regular)
+	return
+}
+
+// Leave the colours which weren't chosen out of a palette. If none of the palette's colours
+// were chosen, pixels dithered with it use the nearest of any chosen colour instead.
+func (l limitedPalettes) limit(palette []colour.RGB) []colour.RGB {
+	result := make([]colour.RGB, len(palette))
+	found := false
+
+	for i, c := range palette {
+		result[i] = excludedColour
+		if i != 0 && l.allowed[i] && c != excludedColour {
+			result[i] = c
+			found = true
+		}
+	}
+
+	if !found {
+		return l.any
+	}
+
+	return result
+}
+
+// Replace any colours which weren't chosen with the nearest chosen colour. Passes after the
+// first dither, such as fosterisation, can move pixels to neighbouring palette indexes.
+func (l limitedPalettes) apply(output ShaderOutput, palette *colour.Palette) {
+	for x := range output {
+		for y := range output[x] {
+			index := output[x][y].DitheredIndex
+			if l.allowed[index] {
+				continue
+			}
+
+			target := l.regular
+			if rng := palette.Entries[index].Range; rng != nil && rng.IsPrimaryCompanyColour {
+				target = l.primary
+			} else if rng != nil && rng.IsSecondaryCompanyColour {
+				target = l.secondary
+			}
+
+			output[x][y].DitheredIndex = getBestIndex(palette.Entries[index].GetRGB(), target)
+		}
+	}
+}
+
+// Get the palette index nearest to a pixel's colour, as chosen by the first dither pass
+// without any error carried from other pixels
+func getNearestIndex(def *manifest.Definition, s *ShaderInfo, x, y int, primary, secondary, regular []colour.RGB) byte {
+	rng := def.Palette.Entries[s.ModalIndex].Range
+	if rng == nil {
+		rng = &colour.PaletteRange{}
+	}
+
+	switch {
+	case isTransparent(def, s, x, y):
+		return 0
+	case rng.IsPrimaryCompanyColour:
+		return getBestIndex(s.SpecialColour, primary)
+	case rng.IsSecondaryCompanyColour:
+		return getBestIndex(s.SpecialColour, secondary)
+	case rng.IsAnimatedLight || keepsIndex(def, s.ModalIndex):
+		return s.ModalIndex
+	default:
+		return getBestIndex(s.Colour, regular)
+	}
+}
@@ -0,0 +1,61 @@
+package sprite
+
+import (
+	"github.com/mattkimber/gorender/internal/colour"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"testing"
+)
+
+func TestDitherShaderOutput_MaxColours(t *testing.T) {
+	// A grey ramp, and a company colour ramp which must keep its own colours
+	palette := colour.Palette{Entries: make([]colour.PaletteEntry, 16)}
+	for i := 1; i < 16; i++ {
+		palette.Entries[i] = colour.PaletteEntry{R: byte(i * 16), G: byte(i * 16), B: byte(i * 16)}
+	}
+	palette.SetRanges([]colour.PaletteRange{{Start: 1, End: 11, MaxGapInRegion: 6}, {Start: 12, End: 15, IsPrimaryCompanyColour: true, MaxGapInRegion: 6}})
+
+	testCases := []struct {
+		maxColours int
+		expected   int
+	}{
+		{0, 11},
+		{3, 3},
+		{1, 1},
+	}
+
+	for _, testCase := range testCases {
+		def := manifest.Definition{Palette: palette, Manifest: manifest.Manifest{MaxColours: testCase.maxColours, Fosterise: true}}
+
+		// A gradient across the grey ramp, with a column of company colour
+		output := NewShaderOutput(12, 4)
+		for x := range output {
+			for y := range output[x] {
+				s := &output[x][y]
+				s.Alpha = 1
+				if x == 11 {
+					s.ModalIndex, s.SpecialColour = 13, colour.RGB{R: 13 * 16 * 257, G: 13 * 16 * 257, B: 13 * 16 * 257}
+				} else {
+					v := float64(x*20+y*5+16) * 257
+					s.ModalIndex, s.Colour = byte(1+x), colour.RGB{R: v, G: v, B: v}
+				}
+			}
+		}
+
+		DitherShaderOutput(output, manifest.Sprite{}, &def)
+
+		used := make(map[byte]bool)
+		for x := range output {
+			for y := range output[x] {
+				if index := output[x][y].DitheredIndex; index != 0 {
+					used[index] = true
+				}
+			}
+		}
+
+		if testCase.maxColours == 0 && len(used) < testCase.expected {
+			t.Errorf("without a limit, expected at least %d colours, got %d", testCase.expected, len(used))
+		} else if testCase.maxColours > 0 && len(used) > testCase.expected {
+			t.Errorf("max colours %d: expected at most %d colours, got %d (%v)", testCase.maxColours, testCase.expected, len(used), used)
+		}
+	}
+}
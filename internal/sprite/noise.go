@@ -0,0 +1,22 @@
+package sprite
+
+import "github.com/mattkimber/gorender/internal/raycaster"
+
+// The most a noise setting of 1 moves a voxel's lighting
+const maxNoiseLighting = 0.2
+
+// Get a random value between -1 and 1 for the voxel a sample hit. The value only depends on
+// the voxel and the seed, so a voxel has the same value from every angle and sprites sharing
+// a seed get the same pattern, while sprites with different seeds get different patterns.
+func getVoxelNoise(smp raycaster.RenderSample, seed int) float64 {
+	h := uint64(seed)*0x9e3779b97f4a7c15 ^ uint64(smp.X)*0xbf58476d1ce4e5b9 ^ uint64(smp.Y)*0x94d049bb133111eb ^ uint64(smp.Z)*0xd6e8feb86659fd93
+
+	// SplitMix64 finaliser, so neighbouring voxels and seeds give unrelated values
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	h ^= h >> 31
+
+	return float64(h>>11)/(1<<52) - 1
+}
@@ -0,0 +1,42 @@
+package sprite
+
+import (
+	"github.com/mattkimber/gorender/internal/raycaster"
+	"testing"
+)
+
+func Test_getVoxelNoise(t *testing.T) {
+	smp := raycaster.RenderSample{X: 3, Y: 4, Z: 5}
+
+	if getVoxelNoise(smp, 1) != getVoxelNoise(smp, 1) {
+		t.Errorf("expected the same voxel and seed to give the same noise")
+	}
+
+	if getVoxelNoise(smp, 1) == getVoxelNoise(smp, 2) {
+		t.Errorf("expected different seeds to give different noise")
+	}
+
+	total, differing := 0.0, 0
+	for x := int16(0); x < 32; x++ {
+		for y := int16(0); y < 32; y++ {
+			n := getVoxelNoise(raycaster.RenderSample{X: x, Y: y, Z: 1}, 0)
+			if n < -1 || n >= 1 {
+				t.Fatalf("noise at %d,%d expected to be between -1 and 1, got %f", x, y, n)
+			}
+
+			if n != getVoxelNoise(raycaster.RenderSample{X: x + 1, Y: y, Z: 1}, 0) {
+				differing++
+			}
+
+			total += n
+		}
+	}
+
+	if differing != 32*32 {
+		t.Errorf("expected neighbouring voxels to differ, %d of %d did", differing, 32*32)
+	}
+
+	if mean := total / (32 * 32); mean < -0.1 || mean > 0.1 {
+		t.Errorf("expected noise to average near 0, got %f", mean)
+	}
+}
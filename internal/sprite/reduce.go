@@ -0,0 +1,144 @@
+package sprite
+
+import (
+	"github.com/mattkimber/gorender/internal/colour"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"math"
+)
+
+// Reduce shader output rendered at factor times the size to width by height pixels. Colours
+// are averaged by coverage, and each pixel's index is the closest colour to the average
+// (sharpened, if sharpen is above 0) from the palette ranges of the pixels it covers, so
+// reduction never introduces colours from unrelated ranges. Pixels mostly of a special colour
+// keep that colour's index, so company colours and animated lights stay in the mask.
+func ReduceShaderOutput(hi ShaderOutput, factor, width, height int, def *manifest.Definition, sharpen float64) (output ShaderOutput) {
+	output = NewShaderOutput(width, height)
+
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			output[x][y] = reducePixel(hi, x*factor, y*factor, factor, &def.Palette)
+		}
+	}
+
+	SharpenShaderOutput(output, sharpen, 1)
+
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			setReducedIndex(&output[x][y], hi, x*factor, y*factor, factor, def)
+		}
+	}
+
+	return
+}
+
+// Average the block of pixels with its top left corner at x, y
+func reducePixel(hi ShaderOutput, minX, minY, factor int, palette *colour.Palette) (output ShaderInfo) {
+	var values indexValues
+	var alpha, translucency float64
+	count := 0
+	channels := getAveragedChannels(&output)
+
+	forBlock(hi, minX, minY, factor, func(s *ShaderInfo) {
+		count++
+		alpha += s.Alpha
+		output.SampleCount += s.SampleCount
+		output.RaysCast += s.RaysCast
+		output.RaysHit += s.RaysHit
+
+		if s.Alpha == 0 {
+			return
+		}
+
+		for i, c := range getAveragedChannels(s) {
+			*channels[i] = channels[i].Add(c.MultiplyBy(s.Alpha))
+		}
+
+		translucency += s.Translucency * s.Alpha
+
+		if s.DitheredIndex != 0 {
+			values.add(s.DitheredIndex, s.Alpha)
+		}
+
+		if output.ViewDepth == 0 || s.ViewDepth < output.ViewDepth {
+			output.ViewDepth = s.ViewDepth
+		}
+
+		if output.Region == 0 {
+			output.Region = s.Region
+		}
+	})
+
+	if count == 0 || alpha == 0 {
+		return
+	}
+
+	output.Alpha = alpha / float64(count)
+	for _, c := range channels {
+		c.DivideAndClamp(alpha)
+	}
+
+	output.Translucency = translucency / alpha
+	output.ModalIndex, _ = getModalIndexes(&values, true, palette)
+
+	return
+}
+
+// Get the colours which are averaged when reducing, including those only shown in debug output
+func getAveragedChannels(s *ShaderInfo) []*colour.RGB {
+	return []*colour.RGB{&s.Colour, &s.SpecialColour, &s.Lighting, &s.Normal, &s.AveragedNormal, &s.Depth, &s.Occlusion, &s.Shadowing, &s.Detail, &s.Transparency, &s.Gloss}
+}
+
+// Choose the index of a reduced pixel from the palette ranges of the block it covers
+func setReducedIndex(s *ShaderInfo, hi ShaderOutput, minX, minY, factor int, def *manifest.Definition) {
+	if isTransparent(def, s, minX/factor, minY/factor) {
+		s.DitheredIndex = 0
+		return
+	}
+
+	if def.Palette.IsSpecialColour(s.ModalIndex) {
+		s.DitheredIndex = s.ModalIndex
+		s.Specialness, s.IsMaskColour = 1, true
+
+		if rng := def.Palette.Entries[s.ModalIndex].Range; rng != nil && rng.IsAnimatedLight {
+			s.IsAnimated = true
+		}
+
+		return
+	}
+
+	var ranges []*colour.PaletteRange
+	forBlock(hi, minX, minY, factor, func(h *ShaderInfo) {
+		rng := def.Palette.Entries[h.DitheredIndex].Range
+		if h.DitheredIndex == 0 || rng == nil || def.Palette.IsSpecialColour(h.DitheredIndex) {
+			return
+		}
+
+		for _, r := range ranges {
+			if r == rng {
+				return
+			}
+		}
+
+		ranges = append(ranges, rng)
+	})
+
+	s.DitheredIndex = s.ModalIndex
+	bestSum := math.MaxFloat64
+
+	for _, rng := range ranges {
+		for i := int(rng.Start); i <= int(rng.End); i++ {
+			p := colour.FromPaletteEntry(def.Palette.Entries[i])
+			if sum := squareDiff(s.Colour.R, p.R) + squareDiff(s.Colour.G, p.G) + squareDiff(s.Colour.B, p.B); sum < bestSum {
+				s.DitheredIndex, bestSum = byte(i), sum
+			}
+		}
+	}
+}
+
+func forBlock(hi ShaderOutput, minX, minY, factor int, fn func(s *ShaderInfo)) {
+	for x := minX; x < min(minX+factor, len(hi)); x++ {
+		for y := minY; y < min(minY+factor, len(hi[x])); y++ {
+			fn(&hi[x][y])
+		}
+	}
+}
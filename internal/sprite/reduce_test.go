@@ -0,0 +1,51 @@
+package sprite
+
+import (
+	"github.com/mattkimber/gorender/internal/colour"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"testing"
+)
+
+func getReducePalette() colour.Palette {
+	palette := colour.Palette{Entries: []colour.PaletteEntry{{}, {R: 255}, {R: 128}, {G: 255}, {G: 128}, {B: 255}}}
+	palette.SetRanges([]colour.PaletteRange{
+		{Start: 1, End: 2},
+		{Start: 3, End: 4},
+		{Start: 5, End: 5, IsPrimaryCompanyColour: true},
+	})
+	return palette
+}
+
+func TestReduceShaderOutput(t *testing.T) {
+	def := manifest.Definition{Palette: getReducePalette(), Manifest: manifest.Manifest{EdgeThreshold: 0.5}}
+
+	full := func(index byte, c colour.RGB) ShaderInfo {
+		return ShaderInfo{Alpha: 1, DitheredIndex: index, ModalIndex: index, Colour: c}
+	}
+
+	// Columns of 2x2 blocks: dark and bright red averaging to a red between them, red with
+	// a pixel of company colour, mostly company colour, and mostly empty
+	hi := ShaderOutput{
+		{full(1, colour.RGB{R: 65535}), full(2, colour.RGB{R: 32896}), full(1, colour.RGB{R: 65535}), full(5, colour.RGB{B: 65535}), full(5, colour.RGB{B: 65535}), full(5, colour.RGB{B: 65535}), {}, {}},
+		{full(2, colour.RGB{R: 32896}), full(2, colour.RGB{R: 32896}), full(1, colour.RGB{R: 65535}), full(1, colour.RGB{R: 65535}), full(5, colour.RGB{B: 65535}), full(1, colour.RGB{R: 65535}), {}, full(3, colour.RGB{G: 65535})},
+	}
+
+	output := ReduceShaderOutput(hi, 2, 1, 4, &def, 0)
+
+	testCases := []struct {
+		expectedIndex, expectedMask byte
+		expectedAlpha               float64
+	}{
+		{2, 0, 1},
+		{1, 0, 1},
+		{5, 5, 1},
+		{0, 0, 0.25},
+	}
+
+	for y, testCase := range testCases {
+		s := &output[0][y]
+		if s.DitheredIndex != testCase.expectedIndex || GetMaskIndex(s) != testCase.expectedMask || s.Alpha != testCase.expectedAlpha {
+			t.Errorf("Reduced pixel %d expected index %d, mask %d and alpha %f, got %d, %d and %f", y, testCase.expectedIndex, testCase.expectedMask, testCase.expectedAlpha, s.DitheredIndex, GetMaskIndex(s), s.Alpha)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package sprite
+
+import (
+	"github.com/mattkimber/gorender/internal/colour"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"sync"
+)
+
+// The pixels of a sprite joined into regions. Each pixel is numbered by its position in
+// getRegionID's scan, and each set of joined pixels has the lowest numbered pixel in it
+// as its root, so the sets are the same whatever order pixels are joined in.
+type regionSet struct {
+	parent []int
+
+	// The lowest numbered pixel with a colour in the set of each root, or -1 if there is
+	// none, which is the pixel the region takes its ID from
+	seed []int
+}
+
+// Set the region of each pixel of the output and mark the left and bottom edges of each
+// region, returning the regions found. A region is the area reached from a pixel through
+// neighbours in the same palette range whose indexes are no further apart than the range's
+// max gap, so depends only on which neighbours are joined. Columns are joined in parallel,
+// then pairs of neighbouring blocks of columns are joined in parallel until the whole
+// sprite is joined.
+func getRegions(output ShaderOutput, def *manifest.Definition) map[int]RegionInfo {
+	width, height := len(output), len(output[0])
+	rs := regionSet{parent: make([]int, width*height), seed: make([]int, width*height)}
+
+	forEachColumn(width, func(x int) {
+		for y := 0; y < height; y++ {
+			p := x*height + y
+			rs.parent[p], rs.seed[p] = p, -1
+			if output[x][y].ModalIndex != 0 {
+				rs.seed[p] = p
+			}
+
+			if y > 0 && isSameRegion(&output[x][y-1], &output[x][y], &def.Palette) {
+				rs.join(p-1, p)
+			}
+		}
+	})
+
+	// Joins between two blocks only change pixels within them, so the pairs of blocks
+	// at each size can be joined at the same time
+	for size := 1; size < width; size *= 2 {
+		wg := sync.WaitGroup{}
+
+		for left := 0; left+size < width; left += size * 2 {
+			thisX := left + size
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for y := 0; y < height; y++ {
+					if isSameRegion(&output[thisX-1][y], &output[thisX][y], &def.Palette) {
+						rs.join((thisX-1)*height+y, thisX*height+y)
+					}
+				}
+			}()
+		}
+
+		wg.Wait()
+	}
+
+	forEachColumn(width, func(x int) {
+		for y := 0; y < height; y++ {
+			if seed := rs.seed[rs.getRoot(x*height+y)]; seed != -1 {
+				output[x][y].Region = seed + 1
+			}
+		}
+	})
+
+	forEachColumn(width, func(x int) {
+		for y := 0; y < height; y++ {
+			setRegionEdges(output, def, x, y, width, height)
+		}
+	})
+
+	regions := make(map[int]RegionInfo)
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			if region := getRegionID(x, y, height); output[x][y].Region == region {
+				regions[region] = RegionInfo{Range: def.Palette.Entries[output[x][y].ModalIndex].Range}
+			}
+		}
+	}
+
+	return regions
+}
+
+// Whether neighbouring pixels are in the same region: their colours are in the same palette
+// range, and no further apart within it than the range allows
+func isSameRegion(a, b *ShaderInfo, palette *colour.Palette) bool {
+	paletteRange := palette.Entries[a.ModalIndex].Range
+	if paletteRange == nil || palette.Entries[b.ModalIndex].Range != paletteRange {
+		return false
+	}
+
+	gap := int(a.ModalIndex) - int(b.ModalIndex)
+	if gap < 0 {
+		gap = -gap
+	}
+
+	return gap <= paletteRange.MaxGapInRegion
+}
+
+// Join the sets of two pixels, keeping the lower root
+func (rs *regionSet) join(a, b int) {
+	ra, rb := rs.find(a), rs.find(b)
+	if ra == rb {
+		return
+	}
+
+	if rb < ra {
+		ra, rb = rb, ra
+	}
+
+	rs.parent[rb] = ra
+	if rs.seed[ra] == -1 || (rs.seed[rb] != -1 && rs.seed[rb] < rs.seed[ra]) {
+		rs.seed[ra] = rs.seed[rb]
+	}
+}
+
+// Find the root of a pixel's set, pointing every pixel on the way straight at it
+func (rs *regionSet) find(p int) int {
+	root := rs.getRoot(p)
+	for p != root {
+		p, rs.parent[p] = rs.parent[p], root
+	}
+
+	return root
+}
+
+// Find the root of a pixel's set without changing the set, so it is safe to call while
+// other pixels are being looked up
+func (rs *regionSet) getRoot(p int) int {
+	for rs.parent[p] != p {
+		p = rs.parent[p]
+	}
+
+	return p
+}
+
+// Mark a pixel as the left edge of its region if the region carries on to its right but
+// not its left, and as the bottom edge if the region carries on above it but not below
+func setRegionEdges(output ShaderOutput, def *manifest.Definition, x, y, width, height int) {
+	region := output[x][y].Region
+	if region == 0 {
+		return
+	}
+
+	if x > 0 && x < width-1 && output[x-1][y].Region != region && output[x+1][y].Region == region {
+		if !def.Manifest.NoEdgeFosterisation || output[x-1][y].ModalIndex != 0 {
+			output[x][y].IsLeft = true
+		}
+	}
+
+	// Left edge of sprite at border
+	if x == 0 && x < width-1 && output[x+1][y].Region == region {
+		if !def.Manifest.NoEdgeFosterisation {
+			output[x][y].IsLeft = true
+		}
+	}
+
+	if y > 0 && y < height-1 && output[x][y+1].Region != region && output[x][y-1].Region == region {
+		if !def.Manifest.NoEdgeFosterisation || output[x][y+1].ModalIndex != 0 {
+			output[x][y].IsBottom = true
+		}
+	}
+
+	// Bottom edge of sprite at border
+	if y == height-1 && y > 0 && output[x][y-1].Region == region {
+		if !def.Manifest.NoEdgeFosterisation {
+			output[x][y].IsBottom = true
+		}
+	}
+}
+
+// Run a function for each column of the sprite in parallel
+func forEachColumn(width int, fn func(x int)) {
+	wg := sync.WaitGroup{}
+	wg.Add(width)
+
+	for x := 0; x < width; x++ {
+		thisX := x
+		go func() {
+			defer wg.Done()
+			fn(thisX)
+		}()
+	}
+
+	wg.Wait()
+}
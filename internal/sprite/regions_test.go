@@ -0,0 +1,94 @@
+package sprite
+
+import (
+	"github.com/mattkimber/gorender/internal/colour"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"testing"
+)
+
+func Test_getRegions(t *testing.T) {
+	palette := colour.Palette{Entries: make([]colour.PaletteEntry, 32)}
+	palette.SetRanges([]colour.PaletteRange{{Start: 0, End: 0}, {Start: 1, End: 15, MaxGapInRegion: 2}, {Start: 16, End: 31}})
+	def := manifest.Definition{Palette: palette}
+
+	// Columns of the sprite, from left to right
+	indexes := [][]byte{
+		{1, 2, 0, 16},
+		{9, 3, 0, 17},
+		{8, 4, 5, 18},
+		{0, 0, 0, 0},
+		{2, 2, 9, 9},
+	}
+
+	output := NewShaderOutput(len(indexes), len(indexes[0]))
+	for x := range indexes {
+		for y, index := range indexes[x] {
+			output[x][y].ModalIndex = index
+		}
+	}
+
+	regions := getRegions(output, &def)
+
+	// 9 and 8 are too far from 3 and 4 to join them, and the 2s on the right are cut off
+	// by empty pixels
+	expected := [][]int{
+		{1, 1, 0, 4},
+		{5, 1, 0, 4},
+		{5, 1, 1, 4},
+		{0, 0, 0, 0},
+		{17, 17, 19, 19},
+	}
+
+	for x := range expected {
+		for y, region := range expected[x] {
+			if output[x][y].Region != region {
+				t.Errorf("pixel %d,%d: expected region %d, got %d", x, y, region, output[x][y].Region)
+			}
+		}
+	}
+
+	if len(regions) != 5 || regions[4].Range != palette.Entries[16].Range || regions[17].Range != palette.Entries[2].Range {
+		t.Errorf("expected 5 regions with the ranges of their pixels, got %v", regions)
+	}
+
+	// Edges are where the region carries on to the right or above but not the other way
+	if !output[0][1].IsLeft || output[1][1].IsLeft || !output[1][0].IsLeft || !output[0][3].IsLeft {
+		t.Errorf("expected left edges at 0,1, 0,3 and 1,0 only")
+	}
+
+	if !output[2][2].IsBottom || !output[0][1].IsBottom || output[1][1].IsBottom {
+		t.Errorf("expected bottom edges at 0,1 and 2,2 but not 1,1")
+	}
+}
+
+func Test_getRegions_Order(t *testing.T) {
+	palette := colour.Palette{Entries: make([]colour.PaletteEntry, 32)}
+	palette.SetRanges([]colour.PaletteRange{{Start: 0, End: 0}, {Start: 1, End: 31, MaxGapInRegion: 3}})
+	def := manifest.Definition{Palette: palette}
+
+	// A region winding back on itself, so it is joined up in a different order in each
+	// half of the sprite
+	output := NewShaderOutput(9, 7)
+	for x := range output {
+		for y := range output[x] {
+			if x%2 == 0 || (x%4 == 1 && y == 6) || (x%4 == 3 && y == 0) {
+				output[x][y].ModalIndex = byte(1 + (x+y)%4)
+			}
+		}
+	}
+
+	getRegions(output, &def)
+
+	for x := range output {
+		for y := range output[x] {
+			expected := 0
+			if output[x][y].ModalIndex != 0 {
+				expected = 1
+			}
+
+			if output[x][y].Region != expected {
+				t.Errorf("pixel %d,%d: expected region %d, got %d", x, y, expected, output[x][y].Region)
+			}
+		}
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"github.com/mattkimber/gorender/internal/colour"
 	"github.com/mattkimber/gorender/internal/manifest"
 	"github.com/mattkimber/gorender/internal/raycaster"
+	"github.com/mattkimber/gorender/internal/utils/poolutils"
 	"math"
 	"sort"
+	"sync"
 )
 
 type ShaderInfo struct {
@@ -21,6 +23,7 @@ type ShaderInfo struct {
 	Shadowing        colour.RGB
 	Detail           colour.RGB
 	Transparency     colour.RGB
+	Gloss            colour.RGB
 	Region           int
 	LightingCalcDone bool
 	DitherChecked    bool
@@ -31,12 +34,22 @@ type ShaderInfo struct {
 	DitheredIndex    byte
 	IsMaskColour     bool
 	IsAnimated       bool
+	IsClassMasked    bool
 	IsBottom         bool
 	IsLeft           bool
+	SampleCount      int
+	RaysCast         int
+	RaysHit          int
+	IsBleedCorrected bool
+	Translucency     float64
+	ViewDepth        float64
 }
 
 type ShaderOutput [][]ShaderInfo
 
+var shaderInfoPool poolutils.SlicePool[ShaderInfo]
+var errorLinePool poolutils.SlicePool[colour.RGB]
+
 type RegionInfo struct {
 	MinDistanceFromMidpoint float64
 	MaxDistanceFromMidpoint float64
@@ -64,6 +77,10 @@ func GetDepth(s *ShaderInfo) colour.RGB {
 	return s.Depth
 }
 
+func GetGloss(s *ShaderInfo) colour.RGB {
+	return s.Gloss
+}
+
 func GetOcclusion(s *ShaderInfo) colour.RGB {
 	return s.Occlusion
 }
@@ -84,6 +101,20 @@ func GetTransparency(s *ShaderInfo) colour.RGB {
 	return s.Transparency
 }
 
+// Red shows the proportion of samples which hit renderable geometry, green the
+// proportion of samples for which a ray was cast at all
+func GetSampleHeatmap(s *ShaderInfo) colour.RGB {
+	if s.SampleCount == 0 {
+		return colour.RGB{}
+	}
+
+	total := float64(s.SampleCount)
+	return colour.RGB{
+		R: 65535 * float64(s.RaysHit) / total,
+		G: 65535 * float64(s.RaysCast) / total,
+	}
+}
+
 func GetIndex(s *ShaderInfo) byte {
 	return s.DitheredIndex
 }
@@ -91,90 +122,177 @@ func GetIndex(s *ShaderInfo) byte {
 func GetMaskIndex(s *ShaderInfo) byte {
 	if s.Specialness > 0.75 || s.IsAnimated {
 		return s.ModalIndex
-	} else if s.Specialness > 0.25 && s.IsMaskColour {
+	} else if (s.Specialness > 0.25 && s.IsMaskColour) || s.IsClassMasked {
 		return s.DitheredIndex
 	}
 	return 0
 }
 
-func GetRegion(s *ShaderInfo) colour.RGB {
-	return colour.RGB{
-		R: float64(s.Region % 4 * (65535 / 4)),
-		G: float64((s.Region / 4) % 4 * (65535 / 4)),
-		B: float64((s.Region / 16) % 4 * (65535 / 4)),
-	}
+// Get the ID of the region first found at x, y when scanning each column of the sprite from
+// top to bottom, starting from the left. IDs depend only on this position, so a region keeps
+// its ID between renders and when other parts of the object change.
+func getRegionID(x, y, height int) int {
+	return x*height + y + 1
 }
 
 func GetShaderOutput(renderOutput raycaster.RenderOutput, spr manifest.Sprite, def *manifest.Definition, width int, height int) (output ShaderOutput) {
+	output = NewShaderOutput(width, height)
+	ShadeRows(output, renderOutput, 0, spr, def)
+	DitherShaderOutput(output, spr, def)
+	return
+}
+
+// Shader output is kept until the spritesheets are written, then can be handed back with
+// ReleaseShaderOutput for use by later sprites. Columns share one buffer from the pool.
+func NewShaderOutput(width int, height int) (output ShaderOutput) {
 	output = make([][]ShaderInfo, width)
+	buf := shaderInfoPool.Get(width * height)
 
-	xoffset, yoffset := int(spr.OffsetX*def.Scale), int(spr.OffsetY*def.Scale)
+	for x := 0; x < width; x++ {
+		output[x], buf = buf[:height], buf[height:]
+	}
 
-	prevIndex := byte(0)
+	return
+}
 
-	for x := 0; x < width; x++ {
-		output[x] = make([]ShaderInfo, height)
+// Mirror the output left to right. Pixels are swapped between columns rather than the
+// columns themselves, as the columns share one allocation which must stay in order.
+func flipShaderOutput(output ShaderOutput) {
+	for x, width := 0, len(output); x < width/2; x++ {
+		for y := range output[x] {
+			output[x][y], output[width-1-x][y] = output[width-1-x][y], output[x][y]
+		}
+	}
+}
 
-		for y := 0; y < height; y++ {
-			rx := x + xoffset
-			ry := y + yoffset
-			if rx < 0 || rx >= width || ry < 0 || ry >= height {
-				continue
-			}
+// Hand the output back for reuse. The output must not be used afterwards.
+func ReleaseShaderOutput(output ShaderOutput) {
+	if len(output) > 0 {
+		shaderInfoPool.Put(output[0][:cap(output[0])])
+	}
+}
 
-			if x > 1 {
-				prevIndex = output[x-1][y].ModalIndex
-			} else {
-				prevIndex = 0
-			}
+// Shade the pixels of the output which take their value from the raycast output. The
+// raycast output may cover only a band of rows starting at firstRow, so large sprites
+// can be raycast and shaded a band at a time.
+func ShadeRows(output ShaderOutput, renderOutput raycaster.RenderOutput, firstRow int, spr manifest.Sprite, def *manifest.Definition) {
+	width, height := len(output), len(output[0])
+	lastRow := firstRow + len(renderOutput[0])
 
-			output[x][y] = shade(renderOutput[rx][ry], def, prevIndex)
+	xoffset, yoffset := int(spr.OffsetX*def.Scale), int(spr.OffsetY*def.Scale)
 
-		}
+	// Flipped sprites are mirrored once dithered, so the offset is reversed here to move
+	// them in the direction seen in the output
+	if spr.FlipHorizontal {
+		xoffset = -xoffset
 	}
 
-	currentRegion := 1
-	regions := make(map[int]RegionInfo)
+	// Each pixel depends on the one to its left, but rows are independent
+	// so can be shaded in parallel
+	wg := sync.WaitGroup{}
 
-	// Calculate regions from the shaded output
-	for x := 0; x < width; x++ {
-		for y := 0; y < height; y++ {
-			info := RegionInfo{}
+	for y := 0; y < height; y++ {
+		ry := y + yoffset
+		if ry < firstRow || ry >= lastRow || ry >= height {
+			continue
+		}
 
-			// No region for transparent/empty voxels
-			if output[x][y].ModalIndex == 0 {
-				continue
-			}
+		thisY := y
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			prevIndex := byte(0)
+			values := &indexValues{}
+
+			for x := 0; x < width; x++ {
+				rx := x + xoffset
+				if rx < 0 || rx >= width {
+					continue
+				}
 
-			// Don't set region if it was already set
-			if output[x][y].Region != 0 {
-				continue
+				if x > 1 {
+					prevIndex = output[x-1][thisY].ModalIndex
+				} else {
+					prevIndex = 0
+				}
+
+				output[x][thisY] = shade(renderOutput[rx][ry-firstRow], def, spr.Seed, prevIndex, values)
 			}
+		}()
+	}
+
+	wg.Wait()
+}
 
-			// Flood fill the region connected to this pixel
-			paletteRange := def.Palette.Entries[output[x][y].ModalIndex].Range
-			info.Range = paletteRange
+// Find regions in the shaded output and dither it to the palette. This needs the whole
+// sprite to be shaded, so error diffusion carries across any bands it was shaded in.
+func DitherShaderOutput(output ShaderOutput, spr manifest.Sprite, def *manifest.Definition) {
+	width, height := len(output), len(output[0])
 
-			identifyRegions(&output, def, currentRegion, x, y, width, height, output[x][y].ModalIndex, &def.Palette, paletteRange)
+	// Flip last, so a flipped sprite is an exact mirror of the unflipped sprite
+	// rather than being dithered differently
+	if spr.FlipHorizontal {
+		defer flipShaderOutput(output)
+	}
 
-			regions[currentRegion] = info
-			currentRegion++
-		}
+	// Clean up once every other pass (including any colour limit) has run
+	if def.Manifest.DespeckleThreshold > 0 {
+		defer Despeckle(output, def, def.Manifest.DespeckleThreshold)
+	}
+
+	if spr.Type == "tile" {
+		levelHeight := float64(def.Manifest.SlopeHeight) * raycaster.GetVoxelHeightInPixels(spr, def.Manifest, height)
+		applyTileMask(output, width, height, spr.Slope, levelHeight)
+	}
+
+	// Sharpen before anything reads the colours, so regions and dithering see the sharpened output
+	SharpenShaderOutput(output, def.Manifest.Sharpen, def.Manifest.SharpenRadius)
+
+	// Calculate regions from the shaded output
+	regions := make(map[int]RegionInfo)
+	if !def.Manifest.SinglePassDither {
+		regions = getRegions(output, def)
 	}
 
 	// Floyd-Steinberg error rows
-	errCurr := make([]colour.RGB, height+2)
-	errNext := make([]colour.RGB, height+2)
+	errCurr := errorLinePool.Get(height + 2)
+	errNext := errorLinePool.Get(height + 2)
+	defer func() {
+		errorLinePool.Put(errCurr)
+		errorLinePool.Put(errNext)
+	}()
 
 	// Palettes
 	regularPalette := def.Palette.GetRegularPalette()
 	primaryCCPalette := def.Palette.GetPrimaryCompanyColourPalette()
 	secondaryCCPalette := def.Palette.GetSecondaryCompanyColourPalette()
 
+	// Limit the colours dithering can choose from, and make sure later passes keep to them
+	if def.Manifest.MaxColours > 0 {
+		limited := getLimitedPalettes(output, def, primaryCCPalette, secondaryCCPalette, regularPalette)
+		primaryCCPalette, secondaryCCPalette, regularPalette = limited.primary, limited.secondary, limited.regular
+		defer limited.apply(output, &def.Palette)
+	}
+
+	// Tiles meet their neighbours along diagonal edges, which error diffusion can't wrap
+	// around, and error diffusion changes with the size and shape of each angle of an
+	// object, so tileable and coherent output is dithered with an ordered pattern instead.
+	// The pattern is anchored to the ground point, which stays in the same place on the
+	// object at every angle and is a whole number of pattern periods from the ground point
+	// of every neighbouring tile.
+	anchorX, anchorY := 0, 0
+	if usesOrderedDither(def) {
+		groundX, groundY := raycaster.GetGroundPoint(def.Manifest, spr, def.Scale)
+		anchorX, anchorY = int(math.Round(groundX)), int(math.Round(groundY))
+	}
+
 	// Get the first pass dithered output to get the basic sprite, which may have
 	// some flat areas
 	for x := 0; x < width; x++ {
 		for y := 0; y < height; y++ {
+			if usesOrderedDither(def) {
+				errCurr[y+1] = getOrderedDitherError(x-anchorX, y-anchorY)
+			}
 
 			bestIndex := ditherOutput(def, output, x, y, errCurr, primaryCCPalette, secondaryCCPalette, regularPalette, errNext)
 
@@ -209,12 +327,21 @@ func GetShaderOutput(renderOutput raycaster.RenderOutput, spr manifest.Sprite, d
 		errCurr, errNext = errNext, errCurr
 	}
 
+	// Draft output stops at the first pass, as the region-based passes below
+	// need the region analysis we skipped
+	if def.Manifest.SinglePassDither {
+		if def.Manifest.CorrectCompanyColourBleed {
+			CorrectCompanyColourBleed(output, &def.Palette, regularPalette)
+		}
+		return
+	}
+
 	// "Fosterise" by darkening pixels at the bottom and left.
 	// Do this here so areas don't get affected by the dither algorithm later
 	for x := 0; x < width; x++ {
 		for y := 0; y < height; y++ {
 			paletteRange := def.Palette.Entries[output[x][y].DitheredIndex].Range
-			if paletteRange == nil || paletteRange.IsAnimatedLight || paletteRange.IsNonRenderable {
+			if paletteRange == nil || paletteRange.IsAnimatedLight || paletteRange.IsNonRenderable || keepsIndex(def, output[x][y].DitheredIndex) {
 				// Don't alter special colours
 				continue
 			}
@@ -227,11 +354,17 @@ func GetShaderOutput(renderOutput raycaster.RenderOutput, spr manifest.Sprite, d
 		}
 	}
 
+	// Coherent output anchors the flat area pattern to the ground point too
+	pushX, pushY := 0, 0
+	if def.Manifest.CoherentDither {
+		pushX, pushY = anchorX, anchorY
+	}
+
 	// Do the second pass dithered output to add fine detail
 	for x := 0; x < width; x++ {
 		for y := 0; y < height; y++ {
 			paletteRange := def.Palette.Entries[output[x][y].DitheredIndex].Range
-			if paletteRange == nil || paletteRange.IsAnimatedLight || paletteRange.IsNonRenderable {
+			if paletteRange == nil || paletteRange.IsAnimatedLight || paletteRange.IsNonRenderable || keepsIndex(def, output[x][y].DitheredIndex) {
 				// Don't alter special colours
 				continue
 			}
@@ -254,7 +387,7 @@ func GetShaderOutput(renderOutput raycaster.RenderOutput, spr manifest.Sprite, d
 						ditherThresholdHigh := lightingValues[(len(lightingValues)*4)/5]
 
 						if ditherThresholdLow != ditherThresholdHigh {
-							doColourPush(&output, def, x, y, width, height, output[x][y].ModalIndex, &def.Palette, minLighting, maxLighting, ditherThresholdLow, ditherThresholdHigh)
+							doColourPush(&output, def, x, y, width, height, pushX, pushY, output[x][y].ModalIndex, &def.Palette, minLighting, maxLighting, ditherThresholdLow, ditherThresholdHigh)
 						}
 					}
 				}
@@ -262,6 +395,10 @@ func GetShaderOutput(renderOutput raycaster.RenderOutput, spr manifest.Sprite, d
 		}
 	}
 
+	if def.Manifest.CorrectCompanyColourBleed {
+		CorrectCompanyColourBleed(output, &def.Palette, regularPalette)
+	}
+
 	return
 }
 
@@ -273,17 +410,23 @@ func ditherOutput(def *manifest.Definition, output ShaderOutput, x int, y int, e
 		rng = &colour.PaletteRange{}
 	}
 
-	if output[x][y].Alpha < def.Manifest.EdgeThreshold {
+	transparent := isTransparent(def, &output[x][y], x, y)
+
+	// Error isn't carried on from special colours, but the ordered pattern used by tileable
+	// and coherent output is never carried from anywhere
+	skipError := y > 0 && def.Palette.IsSpecialColour(output[x][y-1].ModalIndex) && !usesOrderedDither(def)
+
+	if transparent {
 		bestIndex = 0
 	} else if rng.IsPrimaryCompanyColour {
-		if y > 0 && def.Palette.IsSpecialColour(output[x][y-1].ModalIndex) {
+		if skipError {
 			ditherError = output[x][y].SpecialColour
 		} else {
 			ditherError = output[x][y].SpecialColour.Add(errCurr[y+1])
 		}
 		bestIndex = getBestIndex(ditherError, primaryCCPalette)
 	} else if rng.IsSecondaryCompanyColour {
-		if y > 0 && def.Palette.IsSpecialColour(output[x][y-1].ModalIndex) {
+		if skipError {
 			ditherError = output[x][y].SpecialColour
 		} else {
 			ditherError = output[x][y].SpecialColour.Add(errCurr[y+1])
@@ -294,8 +437,11 @@ func ditherOutput(def *manifest.Definition, output ShaderOutput, x int, y int, e
 		// Never add error values to special colours
 		bestIndex = output[x][y].ModalIndex
 		ditherError = def.Palette.Entries[bestIndex].GetRGB()
+	} else if keepsIndex(def, output[x][y].ModalIndex) {
+		bestIndex = output[x][y].ModalIndex
+		ditherError = def.Palette.Entries[bestIndex].GetRGB()
 	} else {
-		if y > 0 && def.Palette.IsSpecialColour(output[x][y-1].ModalIndex) {
+		if skipError {
 			ditherError = output[x][y].Colour
 		} else {
 			ditherError = output[x][y].Colour.Add(errCurr[y+1])
@@ -309,9 +455,13 @@ func ditherOutput(def *manifest.Definition, output ShaderOutput, x int, y int, e
 		output[x][y].IsMaskColour = true
 	}
 
+	if class, ok := def.GetColourClass(bestIndex); ok && class.Mask && bestIndex != 0 {
+		output[x][y].IsClassMasked = true
+	}
+
 	resultError := colour.RGB{}
 
-	if output[x][y].Alpha >= def.Manifest.EdgeThreshold {
+	if !transparent {
 		resultError = colour.PermissiveClampRGB(ditherError.Subtract(def.Palette.Entries[bestIndex].GetRGB()))
 	}
 
@@ -325,6 +475,29 @@ func ditherOutput(def *manifest.Definition, output ShaderOutput, x int, y int, e
 	return
 }
 
+// Check if the index is in a colour class which is output without dithering
+func keepsIndex(def *manifest.Definition, index byte) bool {
+	class, ok := def.GetColourClass(index)
+	return ok && class.KeepIndex
+}
+
+// Check if output is dithered with an ordered pattern rather than error diffusion
+func usesOrderedDither(def *manifest.Definition) bool {
+	return def.Manifest.TileableDither || def.Manifest.CoherentDither
+}
+
+// The spread of colours added by ordered dithering, which is about the step between
+// neighbouring shades of a palette range
+const orderedDitherSpread = 65535.0 / 8
+
+// Get the amount added to a pixel's colour by ordered dithering, in place of the error
+// carried from its neighbours. The pattern repeats every 4 pixels in each direction.
+func getOrderedDitherError(x, y int) colour.RGB {
+	threshold := (bayerMatrix[((x%4)+4)%4][((y%4)+4)%4]+0.5)/16 - 0.5
+	value := threshold * orderedDitherSpread
+	return colour.RGB{R: value, G: value, B: value}
+}
+
 func floodFill(x, y, width, height int, fn func(int, int)) {
 	// Recursively flood fill in the adjacent directions
 	if x > 0 {
@@ -345,57 +518,6 @@ func floodFill(x, y, width, height int, fn func(int, int)) {
 
 }
 
-func identifyRegions(output *ShaderOutput, def *manifest.Definition, region int, x, y int, width, height int, previousIndex byte, palette *colour.Palette, paletteRange *colour.PaletteRange) {
-	index := (*output)[x][y].ModalIndex
-	thisRegion := (*output)[x][y].Region
-	thisRange := (*palette).Entries[index].Range
-
-	gap := int(previousIndex) - int(index)
-	if gap < 0 {
-		gap = -gap
-	}
-
-	// If not the same palette range, or we already set the region, or the indexes are too far apart, return
-	if thisRange != paletteRange || thisRegion == region || gap > paletteRange.MaxGapInRegion {
-		return
-	}
-
-	(*output)[x][y].Region = region
-
-	// Recursively find the regions
-	floodFill(x, y, width, height, func(x1, y1 int) {
-		identifyRegions(output, def, region, x1, y1, width, height, index, palette, paletteRange)
-	})
-
-	if x > 0 && x < width-1 && (*output)[x-1][y].Region != region && (*output)[x+1][y].Region == region {
-		if !def.Manifest.NoEdgeFosterisation || (*output)[x-1][y].ModalIndex != 0 {
-			(*output)[x][y].IsLeft = true
-		}
-	}
-
-	// Left edge of sprite at border
-	if x == 0 && x < width-1 && (*output)[x+1][y].Region == region {
-		if !def.Manifest.NoEdgeFosterisation {
-			(*output)[x][y].IsLeft = true
-		}
-	}
-
-	if y > 0 && y < height-1 && (*output)[x][y+1].Region != region && (*output)[x][y-1].Region == region {
-		if !def.Manifest.NoEdgeFosterisation || (*output)[x][y+1].ModalIndex != 0 {
-			(*output)[x][y].IsBottom = true
-		}
-	}
-
-	// Bottom edge of sprite at border
-	if y == height-1 && y > 0 && (*output)[x][y-1].Region == region {
-		if !def.Manifest.NoEdgeFosterisation {
-			(*output)[x][y].IsBottom = true
-		}
-	}
-
-	return
-}
-
 func getLightingForSameColourArea(output *ShaderOutput, def *manifest.Definition, x, y int, width, height int, previousIndex byte, minLighting, maxLighting *float64, totalPixels *int) {
 	index := (*output)[x][y].DitheredIndex
 
@@ -441,7 +563,9 @@ func getLightingValues(output *ShaderOutput, def *manifest.Definition, x, y int,
 	return
 }
 
-func doColourPush(output *ShaderOutput, def *manifest.Definition, x, y int, width, height int, previousIndex byte, palette *colour.Palette, minLighting, maxLighting, ditherThresholdLow, ditherThresholdHigh float64) {
+// Push pixels of a flat area lighter or darker in a checkerboard pattern, which is anchored
+// to the given pixel
+func doColourPush(output *ShaderOutput, def *manifest.Definition, x, y int, width, height int, anchorX, anchorY int, previousIndex byte, palette *colour.Palette, minLighting, maxLighting, ditherThresholdLow, ditherThresholdHigh float64) {
 	index := (*output)[x][y].DitheredIndex
 	thisRange := (*palette).Entries[index].Range
 
@@ -449,10 +573,12 @@ func doColourPush(output *ShaderOutput, def *manifest.Definition, x, y int, widt
 		return
 	}
 
+	isDitherPixel := (x-anchorX+y-anchorY)&1 == 0
+
 	lightingValue := ((*output)[x][y].Lighting.R - minLighting) / (maxLighting - minLighting)
-	if lightingValue > ditherThresholdHigh && (x%2+y)%2 == 0 && index < thisRange.End {
+	if lightingValue > ditherThresholdHigh && isDitherPixel && index < thisRange.End {
 		(*output)[x][y].DitheredIndex++
-	} else if lightingValue < ditherThresholdLow && (x%2+y)%2 == 0 && index > thisRange.Start {
+	} else if lightingValue < ditherThresholdLow && isDitherPixel && index > thisRange.Start {
 		(*output)[x][y].DitheredIndex--
 	}
 
@@ -460,7 +586,7 @@ func doColourPush(output *ShaderOutput, def *manifest.Definition, x, y int, widt
 
 	// Recursively flood fill in the adjacent directions
 	floodFill(x, y, width, height, func(x1, y1 int) {
-		doColourPush(output, def, x1, y1, width, height, index, palette, minLighting, maxLighting, ditherThresholdLow, ditherThresholdHigh)
+		doColourPush(output, def, x1, y1, width, height, anchorX, anchorY, index, palette, minLighting, maxLighting, ditherThresholdLow, ditherThresholdHigh)
 	})
 
 	return
@@ -490,28 +616,76 @@ func squareDiff(a, b float64) float64 {
 	return diff * diff
 }
 
-func shade(info raycaster.RenderInfo, def *manifest.Definition, prevIndex byte) (output ShaderInfo) {
-	totalInfluence, filledInfluence := 0.0, 0.0
-	filledSamples, totalSamples := 0, 0
-	values := map[byte]float64{}
+// The weight of each palette index in a pixel. Every sample of every pixel adds to
+// this, so it is a fixed array reused between pixels rather than a map.
+type indexValues struct {
+	values [256]float64
+	seen   [256]bool
+
+	// The indexes with a value, in the order they were first added
+	indexes [256]byte
+	count   int
+}
+
+func (v *indexValues) add(index byte, value float64) {
+	if !v.seen[index] {
+		v.seen[index] = true
+		v.indexes[v.count] = index
+		v.count++
+	}
+
+	v.values[index] += value
+}
+
+// Clear the values, touching only the indexes which were used
+func (v *indexValues) reset() {
+	for _, index := range v.indexes[:v.count] {
+		v.values[index] = 0
+		v.seen[index] = false
+	}
+
+	v.count = 0
+}
+
+func shade(info raycaster.RenderInfo, def *manifest.Definition, seed int, prevIndex byte, values *indexValues) (output ShaderInfo) {
+	totalInfluence, filledInfluence, translucency := 0.0, 0.0, 0.0
+	filledSamples, totalSamples, raysCast := 0, 0, 0
+	values.reset()
 	fAccuracy := float64(def.Manifest.Accuracy)
 	hardEdgeThreshold := int(def.Manifest.HardEdgeThreshold * 100.0)
+	suppression := def.Manifest.GetRecoveredVoxels().Suppression
 
 	minDepth := math.MaxInt64
+	output.ViewDepth = math.Inf(1)
 	for _, s := range info {
 		if s.Collision && s.Depth < minDepth {
 			minDepth = s.Depth
 		}
+		if s.Collision && def.Palette.IsRenderable(def.GetRemappedIndex(s.Index)) && s.ViewDepth < output.ViewDepth {
+			output.ViewDepth = s.ViewDepth
+		}
 	}
 
 	for _, s := range info {
+		paintedIndex := s.Index
+		s.Index = def.GetRemappedIndex(s.Index)
+
+		if def.Snow != nil && s.Collision && def.Palette.IsRenderable(s.Index) && !def.Palette.IsAnimatedLight(s.Index) && isSnowCovered(s, def.Snow) {
+			s.Index = def.Snow.GetIndex()
+		}
+
+		if s.Cast {
+			raysCast++
+		}
+
 		if s.IsRecovered {
-			s.Influence = s.Influence * (1.0 - def.Manifest.RecoveredVoxelSuppression)
+			s.Influence = s.Influence * (1.0 - suppression)
 		}
 
 		// Voxel samples considered to be more representative of fine details can be boosted
-		// to make them more likely to appear in the output.
-		if def.Manifest.DetailBoost != 0 {
+		// to make them more likely to appear in the output. Boosts limited to palette ranges
+		// use the colour the voxel was painted, before any livery or snow.
+		if def.Manifest.DetailBoost != 0 && def.Manifest.IsDetailBoosted(paintedIndex, int(s.X), int(s.Y), int(s.Z)) {
 			s.Influence = s.Influence * (1.0 + (s.Detail * def.Manifest.DetailBoost))
 		}
 
@@ -526,20 +700,28 @@ func shade(info raycaster.RenderInfo, def *manifest.Definition, prevIndex byte)
 			filledInfluence += s.Influence
 			filledSamples += s.Count
 
-			output.Colour = output.Colour.Add(Colour(s, def, true, s.Influence))
-			output.SpecialColour = output.SpecialColour.Add(Colour(s, def, false, s.Influence))
+			if s.IsSeeThrough {
+				translucency += s.Influence
+			}
+
+			output.Colour = output.Colour.Add(Colour(s, def, seed, true, s.Influence))
+			output.SpecialColour = output.SpecialColour.Add(Colour(s, def, seed, false, s.Influence))
 
 			if def.Palette.IsSpecialColour(s.Index) {
 				output.Specialness += 1.0 * s.Influence
-				values[s.Index]++
+				values.add(s.Index, 1)
 			}
 
 			if s.Index != 0 {
-				values[s.Index] += s.Influence
+				values.add(s.Index, s.Influence)
 			}
 
 			output.Lighting = output.Lighting.Add(Lighting(s).MultiplyBy(s.Influence))
 
+			if def.Manifest.GlossMap {
+				output.Gloss = output.Gloss.Add(Gloss(s, def).MultiplyBy(s.Influence))
+			}
+
 			if def.Debug {
 				floatCount := float64(s.Count)
 				output.Normal = output.Normal.Add(Normal(s).MultiplyBy(floatCount))
@@ -554,28 +736,30 @@ func shade(info raycaster.RenderInfo, def *manifest.Definition, prevIndex byte)
 		totalSamples = totalSamples + s.Count
 	}
 
-	mx := 0.0
-	alternateModal := byte(0)
+	var alternateModal byte
+	output.ModalIndex, alternateModal = getModalIndexes(values, def.Manifest.CoherentDither, &def.Palette)
 
-	for k, v := range values {
-		if v > mx {
-			mx = v
-			// Store the previous modal
-			alternateModal = output.ModalIndex
-			output.ModalIndex = k
+	// Supply an alternative if we are going to repeat the same colour and we have an alternative,
+	// by default only from the same range
+	if output.ModalIndex == prevIndex && alternateModal != 0 {
+		switch def.Manifest.AlternateModal {
+		case "off":
+		case "any":
+			output.ModalIndex = alternateModal
+		default:
+			if def.Palette.Entries[output.ModalIndex].Range == def.Palette.Entries[alternateModal].Range {
+				output.ModalIndex = alternateModal
+			}
 		}
 	}
 
-	// Supply a same-range alternative if we are going to repeat the same colour and we have an alternative
-	if output.ModalIndex == prevIndex && def.Palette.Entries[output.ModalIndex].Range == def.Palette.Entries[alternateModal].Range && alternateModal != 0 {
-		output.ModalIndex = alternateModal
-	}
-
 	// Fewer than hard edge threshold collisions = transparent
 	if totalSamples == 0 || filledSamples*100/totalSamples <= hardEdgeThreshold {
-		return ShaderInfo{}
+		return ShaderInfo{SampleCount: totalSamples, RaysCast: raysCast, RaysHit: filledSamples}
 	}
 
+	output.SampleCount, output.RaysCast, output.RaysHit = totalSamples, raysCast, filledSamples
+
 	// Soften edges means that when only some rays collided (typically near edges
 	// of an object) we fade to transparent. Otherwise objects are hard-edged, which
 	// makes them more likely to suffer aliasing artifacts but also clearer at small
@@ -583,21 +767,40 @@ func shade(info raycaster.RenderInfo, def *manifest.Definition, prevIndex byte)
 	output.Alpha = 1.0
 	divisor := filledInfluence
 
+	// Samples which saw through a translucent voxel with nothing behind it make the pixel
+	// partly transparent. 8bpp output has no alpha, so those pixels are drawn opaque there.
+	if filledInfluence > 0 {
+		output.Translucency = translucency / filledInfluence
+	}
+
 	if def.SoftenEdges() {
 		output.Alpha = divisor / totalInfluence
 	}
 
-	if def.Manifest.FadeToBlack {
+	// Fading edges fills the part of the pixel the object doesn't cover with the fade colour,
+	// or black if there isn't one
+	if def.Manifest.FadeToBlack || def.Manifest.FadeToColour != 0 {
+		if def.Manifest.FadeToColour != 0 {
+			fade := def.Palette.GetRGB(def.Manifest.FadeToColour, false).MultiplyBy(totalInfluence - filledInfluence)
+			output.Colour = output.Colour.Add(fade)
+			output.SpecialColour = output.SpecialColour.Add(fade)
+		}
+
 		divisor = totalInfluence
 	}
 
 	output.Colour.DivideAndClamp(divisor)
 	output.SpecialColour.DivideAndClamp(divisor)
 
-	output.Specialness = output.Specialness / divisor
+	output.Specialness = clampSpecialness(output.Specialness/divisor, def.Manifest.SpecialnessThreshold)
 
 	output.Lighting.DivideAndClamp(divisor)
 
+	// Matt colours are fully matt, so gloss isn't clamped like colours
+	if def.Manifest.GlossMap {
+		output.Gloss = colour.PermissiveClampRGB(output.Gloss.MultiplyBy(1 / divisor))
+	}
+
 	if def.Debug {
 		debugDivisor := float64(filledSamples)
 		output.Normal.DivideAndClamp(debugDivisor)
@@ -611,3 +814,65 @@ func shade(info raycaster.RenderInfo, def *manifest.Definition, prevIndex byte)
 
 	return
 }
+
+// How far apart the values of two indexes can be, relative to their size, and still be
+// considered equal when choosing the modal index
+const modalTolerance = 1e-9
+
+// Get the most influential index, and the previous most influential index found
+// while searching. Indexes are otherwise in the order they were sampled, so sort
+// them when output must be consistent between sprites. Ties go to the index whose
+// palette range has the highest priority.
+func getModalIndexes(values *indexValues, sorted bool, palette *colour.Palette) (modal byte, alternate byte) {
+	keys := values.indexes[:values.count]
+
+	// There are rarely more than a handful of indexes, so an insertion sort
+	// in place is cheaper than sort.Slice
+	if sorted {
+		for i := 1; i < len(keys); i++ {
+			for j := i; j > 0 && keys[j] < keys[j-1]; j-- {
+				keys[j], keys[j-1] = keys[j-1], keys[j]
+			}
+		}
+	}
+
+	mx, modalPriority := 0.0, 0
+	for _, k := range keys {
+		v, priority := values.values[k], palette.GetPriority(k)
+
+		// Values within rounding error of each other are a tie, which the index with
+		// the higher priority wins
+		var wins bool
+		switch {
+		case priority > modalPriority:
+			wins = v >= mx*(1-modalTolerance)
+		case priority < modalPriority:
+			wins = v > mx*(1+modalTolerance)
+		default:
+			wins = v > mx
+		}
+
+		if wins && v > 0 {
+			mx, modalPriority = v, priority
+			// Store the previous modal
+			alternate = modal
+			modal = k
+		}
+	}
+
+	return
+}
+
+// Snap specialness to fully special or fully regular, so that there are no
+// partially special pixels at the edges of company colour areas
+func clampSpecialness(specialness float64, threshold float64) float64 {
+	if threshold <= 0 {
+		return specialness
+	}
+
+	if specialness >= threshold {
+		return 1.0
+	}
+
+	return 0.0
+}
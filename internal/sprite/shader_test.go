@@ -0,0 +1,557 @@
+package sprite
+
+import (
+	"github.com/mattkimber/gorender/internal/colour"
+	"github.com/mattkimber/gorender/internal/geometry"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"github.com/mattkimber/gorender/internal/raycaster"
+	"image"
+	"math"
+	"os"
+	"testing"
+)
+
+func Test_clampSpecialness(t *testing.T) {
+	testCases := []struct {
+		specialness, threshold, expected float64
+	}{
+		{0.4, 0, 0.4},
+		{0.4, 0.5, 0},
+		{0.5, 0.5, 1},
+		{0.9, 0.5, 1},
+	}
+
+	for _, testCase := range testCases {
+		if result := clampSpecialness(testCase.specialness, testCase.threshold); result != testCase.expected {
+			t.Errorf("Specialness %f with threshold %f expected %f, got %f", testCase.specialness, testCase.threshold, testCase.expected, result)
+		}
+	}
+}
+
+func getTestIndexValues(values [][2]float64) *indexValues {
+	result := &indexValues{}
+	for _, v := range values {
+		result.add(byte(v[0]), v[1])
+	}
+
+	return result
+}
+
+func Test_getModalIndexes(t *testing.T) {
+	testCases := []struct {
+		values           [][2]float64
+		sorted           bool
+		modal, alternate byte
+	}{
+		{[][2]float64{{9, 1.0}, {3, 2.0}, {5, 2.0}, {7, 0.5}}, true, 3, 0},
+		{[][2]float64{{3, 1.0}, {5, 2.0}}, true, 5, 3},
+		{[][2]float64{{9, 1.0}, {5, 2.0}, {3, 2.0}, {7, 0.5}}, false, 5, 9},
+		{[][2]float64{{3, 1.0}, {5, 0.5}, {3, 1.0}}, false, 3, 0},
+	}
+
+	for _, testCase := range testCases {
+		modal, alternate := getModalIndexes(getTestIndexValues(testCase.values), testCase.sorted, &colour.Palette{})
+		if modal != testCase.modal || alternate != testCase.alternate {
+			t.Errorf("Values %v (sorted %v) expected modal %d and alternate %d, got %d and %d", testCase.values, testCase.sorted, testCase.modal, testCase.alternate, modal, alternate)
+		}
+	}
+}
+
+func Test_getModalIndexes_Priority(t *testing.T) {
+	trim, companyColour := &colour.PaletteRange{Start: 1, End: 4}, &colour.PaletteRange{Start: 5, End: 8, Priority: 1}
+	palette := colour.Palette{Entries: make([]colour.PaletteEntry, 9)}
+	for i := 1; i <= 8; i++ {
+		palette.Entries[i].Range = trim
+		if i >= 5 {
+			palette.Entries[i].Range = companyColour
+		}
+	}
+
+	testCases := []struct {
+		values           [][2]float64
+		modal, alternate byte
+	}{
+		{[][2]float64{{2, 2.0}, {6, 2.0}}, 6, 2},
+		{[][2]float64{{6, 2.0}, {2, 2.0}}, 6, 0},
+		{[][2]float64{{2, 0.3}, {6, 0.3 * (1 - 1e-12)}}, 6, 2},
+		{[][2]float64{{2, 2.5}, {6, 2.0}}, 2, 0},
+		{[][2]float64{{6, 2.0}, {2, 2.5}}, 2, 6},
+	}
+
+	for _, testCase := range testCases {
+		modal, alternate := getModalIndexes(getTestIndexValues(testCase.values), false, &palette)
+		if modal != testCase.modal || alternate != testCase.alternate {
+			t.Errorf("Values %v expected modal %d and alternate %d, got %d and %d", testCase.values, testCase.modal, testCase.alternate, modal, alternate)
+		}
+	}
+}
+
+func Test_indexValues_reset(t *testing.T) {
+	values := getTestIndexValues([][2]float64{{3, 1.0}, {5, 2.0}})
+	values.reset()
+	values.add(7, 1.0)
+
+	if modal, alternate := getModalIndexes(values, true, &colour.Palette{}); modal != 7 || alternate != 0 {
+		t.Errorf("Expected modal 7 and alternate 0 after reset, got %d and %d", modal, alternate)
+	}
+
+	if values.values[5] != 0 || values.seen[5] {
+		t.Errorf("Expected index 5 to be cleared by reset, got %f", values.values[5])
+	}
+}
+
+func TestDitherShaderOutput_TileableDither(t *testing.T) {
+	// A grey ramp with a flat grey between two shades, which needs dithering
+	palette := colour.Palette{Entries: make([]colour.PaletteEntry, 9)}
+	for i := 1; i < 9; i++ {
+		palette.Entries[i] = colour.PaletteEntry{R: byte(i * 28), G: byte(i * 28), B: byte(i * 28)}
+	}
+	palette.SetRanges([]colour.PaletteRange{{Start: 1, End: 8}})
+
+	m := manifest.Manifest{Size: geometry.Vector3{X: 64, Y: 64, Z: 8}, RenderElevationAngle: 30, EdgeThreshold: 0.5, TileableDither: true, SinglePassDither: true}
+	def := manifest.Definition{Palette: palette, Manifest: m, Scale: 1}
+	spr := manifest.Sprite{Angle: 45, Width: 64, Height: 32, Type: "tile"}
+
+	output := NewShaderOutput(64, 32)
+	for x := range output {
+		for y := range output[x] {
+			v := 4.5 * 28 * 257
+			output[x][y] = ShaderInfo{Alpha: 1, ModalIndex: 4, Colour: colour.RGB{R: v, G: v, B: v}}
+		}
+	}
+
+	DitherShaderOutput(output, spr, &def)
+
+	// Place the tile next to its diagonal neighbour, which is half a tile across and down,
+	// and check every pixel of the pair uses the same index for the same place in the pattern
+	type phase struct{ x, y int }
+	indexes := make(map[phase]byte)
+	for _, neighbour := range []image.Point{{}, {X: 32, Y: 16}} {
+		for x := range output {
+			for y := range output[x] {
+				index := output[x][y].DitheredIndex
+				if index == 0 {
+					continue
+				}
+
+				p := phase{(x + neighbour.X) % 4, (y + neighbour.Y) % 4}
+				if expected, ok := indexes[p]; !ok {
+					indexes[p] = index
+				} else if index != expected {
+					t.Fatalf("neighbour at %v: expected index %d at %d,%d, got %d", neighbour, expected, x, y, index)
+				}
+			}
+		}
+	}
+
+	used := make(map[byte]bool)
+	for _, index := range indexes {
+		used[index] = true
+	}
+
+	if len(indexes) != 16 || len(used) < 2 {
+		t.Errorf("expected a dither pattern at every place in the pattern, got %v", indexes)
+	}
+}
+
+func TestDitherShaderOutput_CoherentDither(t *testing.T) {
+	// A grey ramp with a flat grey between two shades, which needs dithering
+	palette := colour.Palette{Entries: make([]colour.PaletteEntry, 9)}
+	for i := 1; i < 9; i++ {
+		palette.Entries[i] = colour.PaletteEntry{R: byte(i * 28), G: byte(i * 28), B: byte(i * 28)}
+	}
+	palette.SetRanges([]colour.PaletteRange{{Start: 1, End: 8}})
+
+	m := manifest.Manifest{Size: geometry.Vector3{X: 48, Y: 16, Z: 16}, RenderElevationAngle: 30, EdgeThreshold: 0.5, CoherentDither: true}
+	def := manifest.Definition{Palette: palette, Manifest: m, Scale: 1}
+
+	// Angles of different sizes, where error diffusion would start from a different place
+	// on the object, should dither the same flat colour in the same pattern around the
+	// ground point
+	type phase struct{ x, y int }
+	indexes := make(map[phase]byte)
+	for _, spr := range []manifest.Sprite{{Angle: 0, Width: 24, Height: 30}, {Angle: 45, Width: 41, Height: 37}} {
+		output := NewShaderOutput(spr.Width, spr.Height)
+		for x := range output {
+			for y := range output[x] {
+				v := 4.5 * 28 * 257
+				output[x][y] = ShaderInfo{Alpha: 1, ModalIndex: 4, Colour: colour.RGB{R: v, G: v, B: v}}
+			}
+		}
+
+		DitherShaderOutput(output, spr, &def)
+
+		groundX, groundY := raycaster.GetGroundPoint(def.Manifest, spr, def.Scale)
+		anchorX, anchorY := int(math.Round(groundX)), int(math.Round(groundY))
+		for x := range output {
+			for y := range output[x] {
+				index := output[x][y].DitheredIndex
+				p := phase{((x-anchorX)%4 + 4) % 4, ((y-anchorY)%4 + 4) % 4}
+				if expected, ok := indexes[p]; !ok {
+					indexes[p] = index
+				} else if index != expected {
+					t.Fatalf("angle %g: expected index %d at %d,%d, got %d", spr.Angle, expected, x, y, index)
+				}
+			}
+		}
+	}
+
+	used := make(map[byte]bool)
+	for _, index := range indexes {
+		used[index] = true
+	}
+
+	if len(indexes) != 16 || len(used) < 2 {
+		t.Errorf("expected a dither pattern at every place in the pattern, got %v", indexes)
+	}
+}
+
+func Benchmark_shade(b *testing.B) {
+	pFile, err := os.Open("../../files/ttd_palette.json")
+	if err != nil {
+		b.Fatalf("could not open palette file: %v", err)
+	}
+
+	palette, err := colour.FromJson(pFile)
+	_ = pFile.Close()
+	if err != nil {
+		b.Fatalf("could not read palette file: %v", err)
+	}
+
+	def := manifest.Definition{Palette: palette, Manifest: manifest.Manifest{Accuracy: 3, Brightness: 1, Contrast: 1, CoherentDither: true}}
+
+	// A pixel on the edge between several colours, including company colour
+	info := make(raycaster.RenderInfo, 9)
+	for i := range info {
+		info[i] = raycaster.RenderSample{Collision: i%4 != 0, Cast: true, Index: byte(10 + i%3*70), Depth: i % 2, Influence: 1, Count: 1, LightAmount: 0.5}
+	}
+
+	values := &indexValues{}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = shade(info, &def, 0, 0, values)
+	}
+}
+
+func Test_ditherOutput_ColourClass(t *testing.T) {
+	palette := colour.Palette{Entries: []colour.PaletteEntry{{}, {R: 255}, {R: 128}, {B: 255}, {B: 128}}}
+	palette.SetRanges([]colour.PaletteRange{{Start: 1, End: 2}, {Start: 3, End: 4, Class: "glow"}})
+
+	testCases := []struct {
+		class                       manifest.ColourClass
+		expectedIndex, expectedMask byte
+	}{
+		{manifest.ColourClass{}, 3, 0},
+		{manifest.ColourClass{KeepIndex: true}, 4, 0},
+		{manifest.ColourClass{KeepIndex: true, Mask: true}, 4, 4},
+	}
+
+	for _, testCase := range testCases {
+		def := manifest.Definition{Palette: palette, Manifest: manifest.Manifest{ColourClasses: map[string]manifest.ColourClass{"glow": testCase.class}}}
+
+		// Bright blue sampled from the dark blue index
+		output := ShaderOutput{{{ModalIndex: 4, Alpha: 1, Colour: colour.RGB{B: 65535}}}}
+		errCurr, errNext := make([]colour.RGB, 3), make([]colour.RGB, 3)
+		regularPalette := palette.GetRegularPalette()
+
+		if index := ditherOutput(&def, output, 0, 0, errCurr, regularPalette, regularPalette, regularPalette, errNext); index != testCase.expectedIndex {
+			t.Errorf("Class %v expected index %d, got %d", testCase.class, testCase.expectedIndex, index)
+		}
+
+		if mask := GetMaskIndex(&output[0][0]); mask != testCase.expectedMask {
+			t.Errorf("Class %v expected mask index %d, got %d", testCase.class, testCase.expectedMask, mask)
+		}
+	}
+}
+
+func Test_shade_Remap(t *testing.T) {
+	palette := colour.Palette{Entries: []colour.PaletteEntry{{}, {R: 255}, {R: 128}, {B: 255}, {B: 128}}}
+	palette.SetRanges([]colour.PaletteRange{{Start: 1, End: 2}, {Start: 3, End: 4}})
+
+	def := manifest.Definition{Palette: palette, Manifest: manifest.Manifest{Accuracy: 1, Brightness: 1, Contrast: 1}}
+	info := raycaster.RenderInfo{{Collision: true, Index: 1, Influence: 1, Count: 1, LightAmount: 0.5}}
+
+	if output := shade(info, &def, 0, 0, &indexValues{}); output.ModalIndex != 1 {
+		t.Errorf("expected index 1 without a livery, got %d", output.ModalIndex)
+	}
+
+	def.Remap = manifest.Livery{Remap: []manifest.Remap{{Start: 1, End: 2, To: 3}}}.GetRemap()
+	if output := shade(info, &def, 0, 0, &indexValues{}); output.ModalIndex != 3 || output.Colour.B <= output.Colour.R {
+		t.Errorf("expected remapped index 3 shaded blue, got %d and %v", output.ModalIndex, output.Colour)
+	}
+
+	if info[0].Index != 1 {
+		t.Errorf("expected the raycast output not to be changed, got index %d", info[0].Index)
+	}
+}
+
+func Test_shade_Translucency(t *testing.T) {
+	palette := colour.Palette{Entries: []colour.PaletteEntry{{}, {R: 255}, {B: 255}}}
+	palette.SetRanges([]colour.PaletteRange{{Start: 1, End: 1}, {Start: 2, End: 2, Transparency: 0.5}})
+	def := manifest.Definition{Palette: palette, Manifest: manifest.Manifest{Accuracy: 1, Brightness: 1, Contrast: 1}}
+
+	testCases := []struct {
+		name     string
+		info     raycaster.RenderInfo
+		expected float64
+	}{
+		{"glass in front of red", raycaster.RenderInfo{
+			{Collision: true, Index: 2, Influence: 1, Count: 1, LightAmount: 0.5},
+			{Collision: true, Index: 1, Influence: 1, Count: 1, LightAmount: 0.5},
+		}, 0},
+		{"glass over nothing", raycaster.RenderInfo{
+			{Collision: true, Index: 2, Influence: 1, Count: 1, LightAmount: 0.5},
+			{Collision: true, Index: 2, Influence: 1, Count: 1, LightAmount: 0.5, IsSeeThrough: true},
+		}, 0.5},
+	}
+
+	for _, testCase := range testCases {
+		if output := shade(testCase.info, &def, 0, 0, &indexValues{}); output.Translucency != testCase.expected {
+			t.Errorf("%s: expected translucency %f, got %f", testCase.name, testCase.expected, output.Translucency)
+		}
+	}
+
+	// Samples behind the glass are blended into the colour
+	if output := shade(testCases[0].info, &def, 0, 0, &indexValues{}); output.Colour.R == 0 || output.Colour.B == 0 {
+		t.Errorf("expected glass and red to be blended, got %v", output.Colour)
+	}
+}
+
+func TestColour_AnimatedLight(t *testing.T) {
+	palette := colour.Palette{Entries: []colour.PaletteEntry{{}, {R: 255}, {G: 255}}}
+	palette.SetRanges([]colour.PaletteRange{{Start: 1, End: 1}, {Start: 2, End: 2, IsAnimatedLight: true}})
+
+	day := manifest.Definition{Palette: palette, Manifest: manifest.Manifest{Contrast: 1}}
+	night := manifest.Definition{Palette: palette, Manifest: manifest.Manifest{Contrast: 1, Exposure: -2, ColourTemperature: 12000}}
+
+	for _, testCase := range []struct {
+		index     byte
+		unchanged bool
+	}{
+		{1, false},
+		{2, true},
+	} {
+		smp := raycaster.RenderSample{Index: testCase.index, LightAmount: 0.5}
+		dayColour, nightColour := Colour(smp, &day, 0, true, 1), Colour(smp, &night, 0, true, 1)
+		if (dayColour == nightColour) != testCase.unchanged {
+			t.Errorf("Index %d expected unchanged %v, got day %v and night %v", testCase.index, testCase.unchanged, dayColour, nightColour)
+		}
+	}
+}
+
+func TestColour_ToneMapping(t *testing.T) {
+	palette := colour.Palette{Entries: []colour.PaletteEntry{{}, {R: 255, G: 128}}}
+	palette.SetRanges([]colour.PaletteRange{{Start: 1, End: 1, Class: "bright"}})
+
+	// The class lights the colour past white on both surfaces
+	def := manifest.Definition{Palette: palette, Manifest: manifest.Manifest{
+		Contrast:      1,
+		ToneMapping:   "reinhard",
+		ColourClasses: map[string]manifest.ColourClass{"bright": {Brightness: 1}},
+	}}
+
+	lit := Colour(raycaster.RenderSample{Index: 1, LightAmount: 0.75}, &def, 0, true, 1)
+	brighter := Colour(raycaster.RenderSample{Index: 1, LightAmount: 1}, &def, 0, true, 1)
+	if brighter.R <= lit.R || brighter.B <= lit.B || brighter.B >= 65535 {
+		t.Errorf("expected lighting past white to be tone mapped to brighter colours, got %v and %v", lit, brighter)
+	}
+}
+
+func Test_shade_Snow(t *testing.T) {
+	palette := colour.Palette{Entries: []colour.PaletteEntry{{}, {R: 255}, {R: 255, G: 255, B: 255}, {G: 255}}}
+	palette.SetRanges([]colour.PaletteRange{{Start: 1, End: 2}, {Start: 3, End: 3, IsAnimatedLight: true}})
+
+	def := manifest.Definition{Palette: palette, Manifest: manifest.Manifest{Accuracy: 1, Brightness: 1, Contrast: 1}, Snow: &manifest.Snow{Index: 2}}
+
+	testCases := []struct {
+		index    byte
+		normal   geometry.Vector3
+		expected byte
+	}{
+		{1, geometry.Vector3{Z: -1}, 2},
+		{1, geometry.Vector3{X: 1}, 1},
+		{1, geometry.Vector3{Z: 1}, 1},
+		{1, geometry.Vector3{X: 0.8, Z: -0.6}, 2},
+		{1, geometry.Vector3{X: 0.9, Z: -0.4}, 1},
+		{3, geometry.Vector3{Z: -1}, 3},
+	}
+
+	for _, testCase := range testCases {
+		info := raycaster.RenderInfo{{Collision: true, Index: testCase.index, AveragedNormal: testCase.normal, Influence: 1, Count: 1, LightAmount: 0.5}}
+		if output := shade(info, &def, 0, 0, &indexValues{}); output.ModalIndex != testCase.expected {
+			t.Errorf("Index %d with normal %v expected %d, got %d", testCase.index, testCase.normal, testCase.expected, output.ModalIndex)
+		}
+	}
+}
+
+func Test_shade_DetailBoostRanges(t *testing.T) {
+	palette := colour.Palette{Entries: []colour.PaletteEntry{{}, {R: 255}, {R: 128}, {B: 255}, {B: 128}}}
+	palette.SetRanges([]colour.PaletteRange{{Start: 1, End: 2}, {Start: 3, End: 4}})
+
+	// A fine detail, and a larger surface with more influence until the detail is boosted
+	info := raycaster.RenderInfo{
+		{Collision: true, Index: 1, Influence: 1, Count: 1, Detail: 1, LightAmount: 0.5},
+		{Collision: true, Index: 4, Influence: 1.5, Count: 1, LightAmount: 0.5},
+	}
+
+	testCases := []struct {
+		ranges   []manifest.IndexRange
+		remap    *[256]byte
+		expected byte
+	}{
+		{nil, nil, 1},
+		{[]manifest.IndexRange{{Start: 1, End: 2}}, nil, 1},
+		{[]manifest.IndexRange{{Start: 3, End: 4}}, nil, 4},
+		{[]manifest.IndexRange{{Start: 1, End: 2}}, manifest.Livery{Remap: []manifest.Remap{{Start: 1, End: 1, To: 3}}}.GetRemap(), 3},
+	}
+
+	for _, testCase := range testCases {
+		def := manifest.Definition{Palette: palette, Remap: testCase.remap, Manifest: manifest.Manifest{Accuracy: 1, Brightness: 1, Contrast: 1, DetailBoost: 2, DetailBoostRanges: testCase.ranges}}
+		if output := shade(info, &def, 0, 0, &indexValues{}); output.ModalIndex != testCase.expected {
+			t.Errorf("Ranges %v expected index %d, got %d", testCase.ranges, testCase.expected, output.ModalIndex)
+		}
+	}
+}
+
+func Test_shade_FadeToColour(t *testing.T) {
+	palette := colour.Palette{Entries: []colour.PaletteEntry{{}, {R: 255}, {G: 255}}}
+	palette.SetRanges([]colour.PaletteRange{{Start: 1, End: 2}})
+
+	// An edge pixel the object covers half of
+	info := raycaster.RenderInfo{
+		{Collision: true, Index: 1, Influence: 1, Count: 1, LightAmount: 0.5},
+		{Influence: 1, Count: 1},
+	}
+
+	def := manifest.Definition{Palette: palette, Manifest: manifest.Manifest{Accuracy: 1, Brightness: 1, Contrast: 1}}
+	original := shade(info, &def, 0, 0, &indexValues{}).Colour
+
+	// Colours are clamped to 8-bit steps
+	near := func(a, b float64) bool { return math.Abs(a-b) <= 256 }
+
+	def.Manifest.FadeToBlack = true
+	if output := shade(info, &def, 0, 0, &indexValues{}).Colour; !near(output.R, original.R/2) || !near(output.G, 0) {
+		t.Errorf("expected fading to black to halve %v, got %v", original, output)
+	}
+
+	def.Manifest.FadeToBlack, def.Manifest.FadeToColour = false, 2
+	green := palette.GetRGB(2, false)
+	if output := shade(info, &def, 0, 0, &indexValues{}).Colour; !near(output.R, original.R/2) || !near(output.G, green.G/2) {
+		t.Errorf("expected half of %v and half of %v, got %v", original, green, output)
+	}
+}
+
+func Test_shade_AlternateModal(t *testing.T) {
+	palette := colour.Palette{Entries: []colour.PaletteEntry{{}, {R: 255}, {R: 192}, {G: 255}, {G: 192}}}
+	palette.SetRanges([]colour.PaletteRange{{Start: 1, End: 2}, {Start: 3, End: 4}})
+
+	// Index 1 is most sampled, after an index from the same range or a different range
+	sameRange := raycaster.RenderInfo{
+		{Collision: true, Index: 2, Influence: 1, Count: 1},
+		{Collision: true, Index: 1, Influence: 2, Count: 1},
+	}
+
+	otherRange := raycaster.RenderInfo{
+		{Collision: true, Index: 3, Influence: 1, Count: 1},
+		{Collision: true, Index: 1, Influence: 2, Count: 1},
+	}
+
+	testCases := []struct {
+		alternateModal string
+		info           raycaster.RenderInfo
+		expectedModal  byte
+	}{
+		{"", sameRange, 2},
+		{"", otherRange, 1},
+		{"same_range", sameRange, 2},
+		{"off", sameRange, 1},
+		{"off", otherRange, 1},
+		{"any", sameRange, 2},
+		{"any", otherRange, 3},
+	}
+
+	for _, testCase := range testCases {
+		def := manifest.Definition{Palette: palette, Manifest: manifest.Manifest{Accuracy: 1, Brightness: 1, Contrast: 1, AlternateModal: testCase.alternateModal}}
+
+		// The pixel to the left is index 1, so an alternative is used if allowed
+		if output := shade(testCase.info, &def, 0, 1, &indexValues{}); output.ModalIndex != testCase.expectedModal {
+			t.Errorf("alternate modal %q with indexes %d, %d: expected modal %d, got %d", testCase.alternateModal, testCase.info[0].Index, testCase.info[1].Index, testCase.expectedModal, output.ModalIndex)
+		}
+	}
+}
+
+func Test_isSnowCovered_Coverage(t *testing.T) {
+	covered := 0
+	for x := int16(0); x < 100; x++ {
+		smp := raycaster.RenderSample{X: x, AveragedNormal: geometry.Vector3{Z: -1}}
+		if isSnowCovered(smp, &manifest.Snow{Coverage: 0.5}) {
+			covered++
+		}
+	}
+
+	if covered < 30 || covered > 70 {
+		t.Errorf("Expected around half of 100 voxels covered, got %d", covered)
+	}
+}
+
+func Test_shade_Gloss(t *testing.T) {
+	palette := colour.Palette{Entries: []colour.PaletteEntry{{}, {R: 255}, {B: 255}}}
+	palette.SetRanges([]colour.PaletteRange{{Start: 1, End: 1}, {Start: 2, End: 2, Class: "chrome"}})
+
+	def := manifest.Definition{Palette: palette, Manifest: manifest.Manifest{
+		Accuracy: 1, Brightness: 1, Contrast: 1, GlossMap: true,
+		ColourClasses: map[string]manifest.ColourClass{"chrome": {Gloss: 0.8}},
+	}}
+
+	info := raycaster.RenderInfo{
+		{Collision: true, Index: 1, Influence: 1, Count: 1, LightAmount: 0.5},
+		{Collision: true, Index: 2, Influence: 1, Count: 1, LightAmount: 0.5},
+	}
+
+	if output := shade(info, &def, 0, 0, &indexValues{}); math.Abs(output.Gloss.R-0.4*65535) > 1 {
+		t.Errorf("expected gloss halfway between matt and chrome, got %f", output.Gloss.R)
+	}
+
+	def.Manifest.GlossMap = false
+	if output := shade(info, &def, 0, 0, &indexValues{}); output.Gloss.R != 0 {
+		t.Errorf("expected no gloss without a gloss map, got %f", output.Gloss.R)
+	}
+}
+
+func TestDitherShaderOutput_RegionIDs(t *testing.T) {
+	palette := colour.Palette{Entries: []colour.PaletteEntry{{}, {R: 255}, {R: 128}, {B: 255}, {B: 128}}}
+	palette.SetRanges([]colour.PaletteRange{{Start: 1, End: 2}, {Start: 3, End: 4}})
+	def := manifest.Definition{Palette: palette}
+
+	testCases := []struct {
+		name     string
+		columns  []byte
+		expected []int
+	}{
+		{"separate", []byte{1, 3, 1}, []int{1, 3, 5}},
+		{"left region joined", []byte{3, 3, 1}, []int{1, 1, 5}},
+		{"left region removed", []byte{0, 3, 1}, []int{0, 3, 5}},
+	}
+
+	for _, testCase := range testCases {
+		output := NewShaderOutput(len(testCase.columns), 2)
+		for x, index := range testCase.columns {
+			for y := range output[x] {
+				output[x][y].ModalIndex, output[x][y].Alpha = index, 1
+				output[x][y].Colour = colour.RGB{R: float64(palette.Entries[index].R) * 257, B: float64(palette.Entries[index].B) * 257}
+			}
+		}
+
+		DitherShaderOutput(output, manifest.Sprite{}, &def)
+
+		for x, expected := range testCase.expected {
+			for y := range output[x] {
+				if output[x][y].Region != expected {
+					t.Errorf("%s: expected region %d at %d,%d, got %d", testCase.name, expected, x, y, output[x][y].Region)
+				}
+			}
+		}
+	}
+}
@@ -7,9 +7,31 @@ import (
 	"github.com/mattkimber/gorender/internal/raycaster"
 )
 
-func Colour(smp raycaster.RenderSample, d *manifest.Definition, resolveSpecialColours bool, influence float64) colour.RGB {
-	lightingOffset := getLightingOffset(smp, d.Manifest.DepthInfluence)
-	return d.Palette.GetLitRGB(smp.Index, lightingOffset, d.Manifest.Brightness, d.Manifest.Contrast, resolveSpecialColours, influence)
+func Colour(smp raycaster.RenderSample, d *manifest.Definition, seed int, resolveSpecialColours bool, influence float64) colour.RGB {
+	lightingOffset := getLightingOffset(smp, d.Manifest.DepthInfluence, d.Manifest.FlatLighting)
+	if d.Manifest.Noise != 0 {
+		lightingOffset += getVoxelNoise(smp, seed) * d.Manifest.Noise * maxNoiseLighting
+	}
+
+	if class, ok := d.GetColourClass(smp.Index); ok {
+		lightingOffset = class.GetLighting(lightingOffset)
+	}
+
+	// Animated lights give off their own light, so aren't changed by exposure or white balance
+	m := &d.Manifest
+	if d.Palette.IsAnimatedLight(smp.Index) {
+		return d.Palette.GetLitRGB(smp.Index, lightingOffset, m.Brightness, m.Contrast, resolveSpecialColours, influence)
+	}
+
+	if m.ToneMapping == "" && m.Exposure == 0 && m.ColourTemperature == 0 && m.Tint == 0 {
+		return applyDirt(d.Palette.GetLitRGB(smp.Index, lightingOffset, m.Brightness, m.Contrast, resolveSpecialColours, influence), smp, d)
+	}
+
+	// Balance and tone map each sample before weighting it, so the operator sees its real brightness,
+	// including lighting brighter than white
+	lit := applyDirt(d.Palette.GetLinearLitRGB(smp.Index, lightingOffset, m.Brightness, m.Contrast, resolveSpecialColours, 1), smp, d)
+	lit = lit.MultiplyByRGB(colour.GetWhiteBalance(m.ColourTemperature, m.Tint))
+	return colour.ToneMap(lit, m.ToneMapping, m.Exposure).MultiplyBy(influence)
 }
 
 func Normal(smp raycaster.RenderSample) colour.RGB {
@@ -42,6 +64,13 @@ func Lighting(smp raycaster.RenderSample) colour.RGB {
 	return colour.RGB{R: v, G: v, B: v}
 }
 
+// Get the gloss of a sample's colour class, for the gloss map
+func Gloss(smp raycaster.RenderSample, d *manifest.Definition) colour.RGB {
+	class, _ := d.GetColourClass(smp.Index)
+	v := class.Gloss * 65535
+	return colour.RGB{R: v, G: v, B: v}
+}
+
 func Detail(smp raycaster.RenderSample) colour.RGB {
 	v := 32767 + (smp.Detail * 32767)
 	return colour.RGB{R: v, G: v, B: v}
@@ -52,12 +81,19 @@ func FloatValue(value float64) colour.RGB {
 	return colour.ClampRGB(colour.RGB{R: v, G: v, B: v})
 }
 
-func getLightingOffset(smp raycaster.RenderSample, depthInfluence float64) float64 {
+func getLightingOffset(smp raycaster.RenderSample, depthInfluence float64, flat bool) float64 {
+	lightAmount, shadowing := smp.LightAmount, smp.Shadowing
+
+	// Flat lighting is a soft studio light: faces differ by a third as much, with no shadows
+	if flat {
+		lightAmount, shadowing = 0.5+(lightAmount-0.5)/3, 0
+	}
+
 	lightingOffset := -0.3
-	lightingOffset += smp.LightAmount * 0.6
+	lightingOffset += lightAmount * 0.6
 	lightingOffset += (-(float64(smp.Depth-120) / 40)) * depthInfluence
 	lightingOffset += (-float64(smp.Occlusion) / 10.0) * 0.3
-	lightingOffset -= smp.Shadowing * 0.2
+	lightingOffset -= shadowing * 0.2
 
 	lightingOffset = lightingOffset / 1.5
 
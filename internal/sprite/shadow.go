@@ -0,0 +1,91 @@
+package sprite
+
+import (
+	"github.com/mattkimber/gorender/internal/manifest"
+	"github.com/mattkimber/gorender/internal/raycaster"
+	"image"
+	"image/color"
+)
+
+// Move and flip a drop shadow in the same way as its sprite, so the two line up
+func GetSpriteShadow(shadow raycaster.ShadowOutput, spr manifest.Sprite, scale float64) raycaster.ShadowOutput {
+	xoffset, yoffset := int(spr.OffsetX*scale), int(spr.OffsetY*scale)
+	if len(shadow) == 0 || (xoffset == 0 && yoffset == 0 && !spr.FlipHorizontal) {
+		return shadow
+	}
+
+	width, height := len(shadow), len(shadow[0])
+	result := make(raycaster.ShadowOutput, width)
+
+	for x := range result {
+		result[x] = make([]float64, height)
+
+		// The offset moves the flipped shadow, as it does the flipped sprite
+		rx := x + xoffset
+		if rx < 0 || rx >= width {
+			continue
+		}
+
+		if spr.FlipHorizontal {
+			rx = width - 1 - rx
+		}
+
+		for y := range result[x] {
+			if ry := y + yoffset; ry >= 0 && ry < height {
+				result[x][y] = shadow[rx][ry]
+			}
+		}
+	}
+
+	return result
+}
+
+// Draw a drop shadow as black with alpha taken from the shadow coverage
+func ApplyShadowSprite32bpp(img *image.RGBA, bounds image.Rectangle, loc image.Point, shadow raycaster.ShadowOutput) {
+	for x := bounds.Min.X; x < bounds.Max.X; x++ {
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			img.Set(x+loc.X, y+loc.Y, color.NRGBA64{A: uint16(shadow[x][y] * 65535)})
+		}
+	}
+}
+
+// Draw a drop shadow using a single palette index for any pixel covered beyond the threshold
+func ApplyIndexedShadowSprite(img *image.Paletted, bounds image.Rectangle, loc image.Point, shadow raycaster.ShadowOutput, index byte, threshold float64) {
+	for x := bounds.Min.X; x < bounds.Max.X; x++ {
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			if shadow[x][y] >= threshold && shadow[x][y] > 0 {
+				img.SetColorIndex(x+loc.X, y+loc.Y, index)
+			} else {
+				img.SetColorIndex(x+loc.X, y+loc.Y, 0)
+			}
+		}
+	}
+}
+
+// Reduce a drop shadow rendered at factor times the size to width by height pixels
+func ReduceShadowOutput(shadow raycaster.ShadowOutput, factor, width, height int) raycaster.ShadowOutput {
+	if len(shadow) == 0 {
+		return shadow
+	}
+
+	result := make(raycaster.ShadowOutput, width)
+	for x := range result {
+		result[x] = make([]float64, height)
+
+		for y := range result[x] {
+			total, count := 0.0, 0
+			for sx := x * factor; sx < min((x+1)*factor, len(shadow)); sx++ {
+				for sy := y * factor; sy < min((y+1)*factor, len(shadow[sx])); sy++ {
+					total += shadow[sx][sy]
+					count++
+				}
+			}
+
+			if count > 0 {
+				result[x][y] = total / float64(count)
+			}
+		}
+	}
+
+	return result
+}
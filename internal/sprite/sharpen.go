@@ -0,0 +1,58 @@
+package sprite
+
+import "github.com/mattkimber/gorender/internal/colour"
+
+// Sharpen the output with an unsharp mask, moving each pixel's colour away from the average
+// of the opaque pixels within radius of it by strength. Transparent pixels are left out of the
+// average so the edges of the sprite are not darkened by the background.
+func SharpenShaderOutput(output ShaderOutput, strength float64, radius int) {
+	if strength <= 0 || len(output) == 0 {
+		return
+	}
+
+	radius = max(radius, 1)
+	width, height := len(output), len(output[0])
+	sharpened := make([]ShaderInfo, width*height)
+
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			sharpened[x*height+y] = sharpenPixel(output, x, y, strength, radius)
+		}
+	}
+
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			output[x][y].Colour = sharpened[x*height+y].Colour
+			output[x][y].SpecialColour = sharpened[x*height+y].SpecialColour
+		}
+	}
+}
+
+// Get the sharpened colour and special colour of a pixel
+func sharpenPixel(output ShaderOutput, x, y int, strength float64, radius int) (s ShaderInfo) {
+	s = output[x][y]
+	if s.Alpha == 0 {
+		return
+	}
+
+	var colourTotal, specialTotal colour.RGB
+	count := 0.0
+
+	for nx := max(x-radius, 0); nx <= min(x+radius, len(output)-1); nx++ {
+		for ny := max(y-radius, 0); ny <= min(y+radius, len(output[nx])-1); ny++ {
+			if output[nx][ny].Alpha > 0 {
+				colourTotal = colourTotal.Add(output[nx][ny].Colour)
+				specialTotal = specialTotal.Add(output[nx][ny].SpecialColour)
+				count++
+			}
+		}
+	}
+
+	s.Colour = sharpenColour(s.Colour, colourTotal.MultiplyBy(1/count), strength)
+	s.SpecialColour = sharpenColour(s.SpecialColour, specialTotal.MultiplyBy(1/count), strength)
+	return
+}
+
+func sharpenColour(c, average colour.RGB, strength float64) colour.RGB {
+	return colour.PermissiveClampRGB(c.Add(c.Subtract(average).MultiplyBy(strength)))
+}
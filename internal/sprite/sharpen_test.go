@@ -0,0 +1,52 @@
+package sprite
+
+import (
+	"github.com/mattkimber/gorender/internal/colour"
+	"testing"
+)
+
+func TestSharpenShaderOutput(t *testing.T) {
+	getOutput := func() ShaderOutput {
+		return ShaderOutput{{
+			{Alpha: 1, Colour: colour.RGB{R: 20000}},
+			{Alpha: 1, Colour: colour.RGB{R: 30000}, SpecialColour: colour.RGB{B: 30000}},
+			{Alpha: 1, Colour: colour.RGB{R: 30000}},
+			{Alpha: 1, Colour: colour.RGB{R: 40000}},
+			{},
+		}}
+	}
+
+	testCases := []struct {
+		strength float64
+		radius   int
+		expected []float64
+	}{
+		{0, 1, []float64{20000, 30000, 30000, 40000, 0}},
+		{1, 1, []float64{15000, 33333.33, 26666.67, 45000, 0}},
+		{0.5, 0, []float64{17500, 31666.67, 28333.33, 42500, 0}},
+		{1, 2, []float64{13333.33, 30000, 30000, 46666.67, 0}},
+	}
+
+	for _, testCase := range testCases {
+		output := getOutput()
+		SharpenShaderOutput(output, testCase.strength, testCase.radius)
+
+		for y, expected := range testCase.expected {
+			if result := output[0][y].Colour.R; result < expected-0.01 || result > expected+0.01 {
+				t.Errorf("Strength %f radius %d pixel %d expected %f, got %f", testCase.strength, testCase.radius, y, expected, result)
+			}
+		}
+	}
+}
+
+func Test_sharpenPixel_SpecialColour(t *testing.T) {
+	output := ShaderOutput{{{Alpha: 1}, {Alpha: 1, SpecialColour: colour.RGB{B: 30000}}, {}}}
+
+	if result := sharpenPixel(output, 0, 1, 1, 1); result.SpecialColour.B != 45000 {
+		t.Errorf("Sharpened special colour expected 45000, got %f", result.SpecialColour.B)
+	}
+
+	if result := sharpenPixel(output, 0, 2, 1, 1); result.Colour != (colour.RGB{}) || result.SpecialColour != (colour.RGB{}) {
+		t.Errorf("Empty pixel expected to be unchanged, got %v", result)
+	}
+}
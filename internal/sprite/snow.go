@@ -0,0 +1,24 @@
+package sprite
+
+import (
+	"github.com/mattkimber/gorender/internal/manifest"
+	"github.com/mattkimber/gorender/internal/raycaster"
+)
+
+// Snow doesn't use the sprite's seed, so it settles in the same places from every angle
+const (
+	snowEdgeSeed     = -1
+	snowCoverageSeed = -2
+)
+
+// Check if snow settles on the voxel a sample hit. Noise moves the edge of the snow to make it
+// patchy, and coverage leaves some voxels which face upwards uncovered.
+func isSnowCovered(smp raycaster.RenderSample, snow *manifest.Snow) bool {
+	// Normals point into the object, so upward-facing voxels have negative Z
+	upwards := -smp.AveragedNormal.Z + getVoxelNoise(smp, snowEdgeSeed)*snow.Noise*0.5
+	if upwards < snow.GetThreshold() {
+		return false
+	}
+
+	return (getVoxelNoise(smp, snowCoverageSeed)+1)/2 < snow.GetCoverage()
+}
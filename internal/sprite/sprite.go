@@ -21,7 +21,28 @@ func Apply32bppSprite(img *image.RGBA, bounds image.Rectangle, loc image.Point,
 	for x := bounds.Min.X; x < bounds.Max.X; x++ {
 		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
 			c := getProperty(&info[x][y])
-			img.Set(x+loc.X, y+loc.Y, c.GetRGBA(info[x][y].Alpha))
+			img.Set(x+loc.X, y+loc.Y, c.GetRGBA(info[x][y].Alpha*(1.0-info[x][y].Translucency)))
+		}
+	}
+}
+
+func ApplyOpaque32bppSprite(img *image.RGBA, bounds image.Rectangle, loc image.Point, info ShaderOutput, getProperty func(*ShaderInfo) colour.RGB) {
+	for x := bounds.Min.X; x < bounds.Max.X; x++ {
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			c := getProperty(&info[x][y])
+			img.Set(x+loc.X, y+loc.Y, c.GetRGBA(1.0))
+		}
+	}
+}
+
+// Draw the region ID of each pixel as an opaque colour, with the lowest 8 bits of the ID in
+// red, the next 8 in green and the next 8 in blue. Pixels outside any region are transparent.
+func ApplyRegionSprite(img *image.RGBA, bounds image.Rectangle, loc image.Point, info ShaderOutput) {
+	for x := bounds.Min.X; x < bounds.Max.X; x++ {
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			if region := info[x][y].Region; region != 0 {
+				img.SetRGBA(x+loc.X, y+loc.Y, color.RGBA{R: uint8(region), G: uint8(region >> 8), B: uint8(region >> 16), A: 255})
+			}
 		}
 	}
 }
@@ -34,3 +55,31 @@ func ApplyIndexedSprite(img *image.Paletted, bounds image.Rectangle, loc image.P
 		}
 	}
 }
+
+// Fill the empty pixels of an indexed sprite with a backdrop colour
+func ApplyIndexedBackdrop(img *image.Paletted, bounds image.Rectangle, loc image.Point, index byte) {
+	for x := bounds.Min.X; x < bounds.Max.X; x++ {
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			if img.ColorIndexAt(x+loc.X, y+loc.Y) == 0 {
+				img.SetColorIndex(x+loc.X, y+loc.Y, index)
+			}
+		}
+	}
+}
+
+// Make a 32bpp sprite opaque by blending its pixels over a backdrop colour
+func Apply32bppBackdrop(img *image.RGBA, bounds image.Rectangle, loc image.Point, backdrop color.RGBA) {
+	for x := bounds.Min.X; x < bounds.Max.X; x++ {
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			// Pixels are premultiplied, so only the backdrop needs scaling
+			c := img.RGBAAt(x+loc.X, y+loc.Y)
+			transparency := 255 - uint32(c.A)
+			img.SetRGBA(x+loc.X, y+loc.Y, color.RGBA{
+				R: c.R + uint8(uint32(backdrop.R)*transparency/255),
+				G: c.G + uint8(uint32(backdrop.G)*transparency/255),
+				B: c.B + uint8(uint32(backdrop.B)*transparency/255),
+				A: 255,
+			})
+		}
+	}
+}
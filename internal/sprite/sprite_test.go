@@ -1,9 +1,13 @@
 package sprite
 
 import (
+	"github.com/mattkimber/gorender/internal/colour"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"github.com/mattkimber/gorender/internal/raycaster"
 	"github.com/mattkimber/gorender/internal/utils/imageutils"
 	"image"
 	"image/color"
+	"math"
 	"testing"
 )
 
@@ -24,3 +28,131 @@ func TestApplySprite(t *testing.T) {
 		}
 	}
 }
+
+func TestGetSampleHeatmap(t *testing.T) {
+	testCases := []struct {
+		info     ShaderInfo
+		expected colour.RGB
+	}{
+		{ShaderInfo{}, colour.RGB{}},
+		{ShaderInfo{SampleCount: 4, RaysCast: 4, RaysHit: 4}, colour.RGB{R: 65535, G: 65535}},
+		{ShaderInfo{SampleCount: 4, RaysCast: 2, RaysHit: 1}, colour.RGB{R: 65535.0 / 4, G: 65535.0 / 2}},
+	}
+
+	for _, testCase := range testCases {
+		if result := GetSampleHeatmap(&testCase.info); result != testCase.expected {
+			t.Errorf("Sample heatmap for %d/%d/%d expected %v, got %v", testCase.info.RaysHit, testCase.info.RaysCast, testCase.info.SampleCount, testCase.expected, result)
+		}
+	}
+}
+
+func TestApplyIndexedShadowSprite(t *testing.T) {
+	rect := image.Rectangle{Max: image.Point{X: 3, Y: 1}}
+	img := image.NewPaletted(rect, color.Palette{color.White, color.Black, color.Gray{Y: 128}})
+	shadow := raycaster.ShadowOutput{{0}, {0.25}, {0.75}}
+
+	ApplyIndexedShadowSprite(img, rect, image.Point{}, shadow, 2, 0.5)
+
+	for x, expected := range []byte{0, 0, 2} {
+		if result := img.ColorIndexAt(x, 0); result != expected {
+			t.Errorf("Shadow pixel at %d,0 expected index %d, got %d", x, expected, result)
+		}
+	}
+}
+
+func TestGetSpriteShadow(t *testing.T) {
+	shadow := raycaster.ShadowOutput{{0.1, 0.2}, {0.3, 0.4}, {0.5, 0.6}}
+
+	testCases := []struct {
+		spr      manifest.Sprite
+		expected raycaster.ShadowOutput
+	}{
+		{manifest.Sprite{}, shadow},
+		{manifest.Sprite{OffsetX: 1}, raycaster.ShadowOutput{{0.3, 0.4}, {0.5, 0.6}, {0, 0}}},
+		{manifest.Sprite{OffsetY: -1}, raycaster.ShadowOutput{{0, 0.1}, {0, 0.3}, {0, 0.5}}},
+		{manifest.Sprite{FlipHorizontal: true}, raycaster.ShadowOutput{{0.5, 0.6}, {0.3, 0.4}, {0.1, 0.2}}},
+		{manifest.Sprite{FlipHorizontal: true, OffsetX: 1}, raycaster.ShadowOutput{{0.3, 0.4}, {0.1, 0.2}, {0, 0}}},
+	}
+
+	for _, testCase := range testCases {
+		result := GetSpriteShadow(shadow, testCase.spr, 1)
+		for x := range testCase.expected {
+			for y := range testCase.expected[x] {
+				if result[x][y] != testCase.expected[x][y] {
+					t.Errorf("sprite %+v: shadow at %d,%d expected %v, got %v", testCase.spr, x, y, testCase.expected[x][y], result[x][y])
+				}
+			}
+		}
+	}
+}
+
+func TestReduceShadowOutput(t *testing.T) {
+	shadow := raycaster.ShadowOutput{{0.1, 0.3, 1}, {0.5, 0.7, 1}, {0, 0.4, 0}}
+	expected := raycaster.ShadowOutput{{0.4, 1}, {0.2, 0}}
+
+	result := ReduceShadowOutput(shadow, 2, 2, 2)
+	for x := range expected {
+		for y := range expected[x] {
+			if math.Abs(result[x][y]-expected[x][y]) > 1e-9 {
+				t.Errorf("shadow at %d,%d expected %v, got %v", x, y, expected[x][y], result[x][y])
+			}
+		}
+	}
+}
+
+func TestApply32bppSprite_Translucency(t *testing.T) {
+	rect := image.Rectangle{Max: image.Point{X: 2, Y: 1}}
+	img := imageutils.GetUniformImage(rect, color.White)
+	info := ShaderOutput{{{Alpha: 1}}, {{Alpha: 1, Translucency: 0.75}}}
+
+	Apply32bppSprite(img, rect, image.Point{}, info, GetColour)
+
+	for x, expected := range []uint8{255, 63} {
+		if a := img.RGBAAt(x, 0).A; a != expected {
+			t.Errorf("Alpha at %d,0 expected %d, got %d", x, expected, a)
+		}
+	}
+}
+
+func TestApplyIndexedBackdrop(t *testing.T) {
+	rect := image.Rectangle{Max: image.Point{X: 3, Y: 1}}
+	img := image.NewPaletted(image.Rect(0, 0, 4, 1), color.Palette{color.White, color.Black, color.Gray{Y: 128}})
+	img.SetColorIndex(1, 0, 1)
+
+	ApplyIndexedBackdrop(img, rect, image.Point{}, 2)
+
+	for x, expected := range []byte{2, 1, 2, 0} {
+		if result := img.ColorIndexAt(x, 0); result != expected {
+			t.Errorf("Backdrop pixel at %d,0 expected index %d, got %d", x, expected, result)
+		}
+	}
+}
+
+func TestApply32bppBackdrop(t *testing.T) {
+	rect := image.Rectangle{Max: image.Point{X: 3, Y: 1}}
+	img := image.NewRGBA(rect)
+	img.Set(1, 0, color.NRGBA{R: 200, A: 255})
+	img.Set(2, 0, color.NRGBA{R: 200, A: 128})
+
+	Apply32bppBackdrop(img, rect, image.Point{}, color.RGBA{B: 100, A: 255})
+
+	for x, expected := range []color.RGBA{{B: 100, A: 255}, {R: 200, A: 255}, {R: 100, B: 49, A: 255}} {
+		if result := img.RGBAAt(x, 0); result != expected {
+			t.Errorf("Backdrop pixel at %d,0 expected %v, got %v", x, expected, result)
+		}
+	}
+}
+
+func TestApplyRegionSprite(t *testing.T) {
+	rect := image.Rectangle{Max: image.Point{X: 2, Y: 1}}
+	img := image.NewRGBA(rect)
+	info := ShaderOutput{{{Alpha: 0.5, Region: 0x030201}}, {{}}}
+
+	ApplyRegionSprite(img, rect, image.Point{}, info)
+
+	for x, expected := range []color.RGBA{{R: 1, G: 2, B: 3, A: 255}, {}} {
+		if c := img.RGBAAt(x, 0); c != expected {
+			t.Errorf("Colour at %d,0 expected %v, got %v", x, expected, c)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package sprite
+
+import (
+	"github.com/mattkimber/gorender/internal/geometry"
+	"github.com/mattkimber/gorender/internal/manifest"
+)
+
+// Number of subsamples in each direction used to work out tile edge coverage
+const tileCoverageSamples = 4
+
+// Clip the output to the 2:1 tile diamond at the bottom of the sprite, scaling the
+// alpha of edge pixels by how much of the pixel the diamond covers
+func applyTileMask(output ShaderOutput, width, height int, slope int, levelHeight float64) {
+	outline := getTileOutline(width, height, slope, levelHeight)
+
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			coverage := getTileCoverage(x, y, outline)
+
+			if coverage == 0 {
+				output[x][y] = ShaderInfo{SampleCount: output[x][y].SampleCount, RaysCast: output[x][y].RaysCast, RaysHit: output[x][y].RaysHit}
+			} else {
+				output[x][y].Alpha *= coverage
+			}
+		}
+	}
+}
+
+// Get the N, E, S and W points of the tile. The diamond is as wide as the sprite, half
+// as tall, and its bottom point sits half a pixel below the sprite so a 64px wide tile
+// is 31px tall as in OpenTTD. Corners raised by the slope move up by the level height
+// in pixels for each level.
+func getTileOutline(width, height int, slope int, levelHeight float64) (outline [4]geometry.Vector2) {
+	halfWidth := float64(width) / 2.0
+	halfHeight := halfWidth / 2.0
+	cx, cy := halfWidth, float64(height)+0.5-halfHeight
+
+	outline[manifest.CornerN] = geometry.Vector2{X: cx, Y: cy - halfHeight}
+	outline[manifest.CornerE] = geometry.Vector2{X: cx + halfWidth, Y: cy}
+	outline[manifest.CornerS] = geometry.Vector2{X: cx, Y: cy + halfHeight}
+	outline[manifest.CornerW] = geometry.Vector2{X: cx - halfWidth, Y: cy}
+
+	for i, level := range manifest.GetSlopeCornerLevels(slope) {
+		outline[i].Y -= float64(level) * levelHeight
+	}
+
+	return
+}
+
+// Get the proportion of the pixel at x,y inside the tile outline
+func getTileCoverage(x, y int, outline [4]geometry.Vector2) float64 {
+	inside := 0
+	for i := 0; i < tileCoverageSamples; i++ {
+		for j := 0; j < tileCoverageSamples; j++ {
+			sx := float64(x) + (float64(i)+0.5)/tileCoverageSamples
+			sy := float64(y) + (float64(j)+0.5)/tileCoverageSamples
+
+			if isInsideOutline(geometry.Vector2{X: sx, Y: sy}, outline) {
+				inside++
+			}
+		}
+	}
+
+	return float64(inside) / (tileCoverageSamples * tileCoverageSamples)
+}
+
+// Even-odd test, as a sloped tile outline is not always convex
+func isInsideOutline(p geometry.Vector2, outline [4]geometry.Vector2) bool {
+	inside := false
+
+	for i := range outline {
+		a, b := outline[i], outline[(i+1)%len(outline)]
+		if (a.Y > p.Y) != (b.Y > p.Y) && p.X < a.X+(p.Y-a.Y)*(b.X-a.X)/(b.Y-a.Y) {
+			inside = !inside
+		}
+	}
+
+	return inside
+}
@@ -0,0 +1,63 @@
+package sprite
+
+import "testing"
+
+func Test_getTileCoverage(t *testing.T) {
+	testCases := []struct {
+		x, y     int
+		expected float64
+	}{
+		{0, 0, 0},
+		{63, 30, 0},
+		{32, 15, 1},
+		{0, 15, 0.5},
+		{31, 0, 1},
+		{30, 0, 0.75},
+		{33, 30, 0.75},
+		{34, 30, 0.25},
+	}
+
+	for _, testCase := range testCases {
+		if result := getTileCoverage(testCase.x, testCase.y, getTileOutline(64, 31, 0, 0)); result != testCase.expected {
+			t.Errorf("Tile coverage at %d,%d expected %f, got %f", testCase.x, testCase.y, testCase.expected, result)
+		}
+	}
+}
+
+func Test_applyTileMask(t *testing.T) {
+	output := make(ShaderOutput, 64)
+	for x := range output {
+		output[x] = make([]ShaderInfo, 31)
+		for y := range output[x] {
+			output[x][y] = ShaderInfo{Alpha: 1.0, ModalIndex: 1}
+		}
+	}
+
+	applyTileMask(output, 64, 31, 0, 0)
+
+	// Each row of the diamond should be 4 pixels wider than the previous
+	for y := 0; y < 16; y++ {
+		count := 0
+		for x := 0; x < 64; x++ {
+			if output[x][y].Alpha >= 0.5 {
+				count++
+			}
+		}
+
+		if expected := 4 + y*4; count != expected {
+			t.Errorf("Row %d expected %d visible pixels, got %d", y, expected, count)
+		}
+	}
+}
+
+func Test_getTileOutline(t *testing.T) {
+	outline := getTileOutline(64, 47, 29, 8)
+
+	// Steep north slope raises N by two levels, E and W by one level
+	expected := []float64{-0.5, 23.5, 47.5, 23.5}
+	for i, e := range expected {
+		if outline[i].Y != e {
+			t.Errorf("Corner %d expected height %f, got %f", i, e, outline[i].Y)
+		}
+	}
+}
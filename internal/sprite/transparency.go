@@ -0,0 +1,41 @@
+package sprite
+
+import "github.com/mattkimber/gorender/internal/manifest"
+
+// A 4x4 Bayer matrix, whose thresholds are spread evenly over every part of the sprite so
+// any alpha gives a regular pattern, with alternate pixels drawn at an alpha of 0.5
+var bayerMatrix = [4][4]float64{
+	{0, 8, 2, 10},
+	{12, 4, 14, 6},
+	{3, 11, 1, 9},
+	{15, 7, 13, 5},
+}
+
+// Check if a pixel is transparent in 8bpp output. Pixels are transparent below the edge
+// threshold, unless transparency is dithered, in which case the threshold varies from one
+// pixel to the next so the proportion of pixels drawn follows their alpha.
+func isTransparent(def *manifest.Definition, s *ShaderInfo, x, y int) bool {
+	switch def.Manifest.TransparencyDither {
+	case "ordered":
+		return s.Alpha < (bayerMatrix[x%4][y%4]+0.5)/16
+	case "random":
+		return s.Alpha < getPixelNoise(x, y)
+	default:
+		return s.Alpha < def.Manifest.EdgeThreshold
+	}
+}
+
+// Get a random value between 0 and 1 for a pixel, which is the same every time the sprite
+// is rendered
+func getPixelNoise(x, y int) float64 {
+	h := 0x9e3779b97f4a7c15 ^ uint64(x)*0xbf58476d1ce4e5b9 ^ uint64(y)*0x94d049bb133111eb
+
+	// SplitMix64 finaliser, so neighbouring pixels give unrelated values
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	h ^= h >> 31
+
+	return float64(h>>11) / (1 << 53)
+}
@@ -0,0 +1,56 @@
+package sprite
+
+import (
+	"github.com/mattkimber/gorender/internal/manifest"
+	"math"
+	"testing"
+)
+
+func Test_isTransparent(t *testing.T) {
+	testCases := []struct {
+		dither    string
+		alpha     float64
+		expected  float64
+		tolerance float64
+	}{
+		{"", 0.4, 0, 0},
+		{"", 0.5, 1, 0},
+		{"ordered", 0, 0, 0},
+		{"ordered", 0.25, 0.25, 0},
+		{"ordered", 0.5, 0.5, 0},
+		{"ordered", 0.9, 0.875, 0},
+		{"ordered", 1, 1, 0},
+		{"random", 0.25, 0.25, 0.05},
+		{"random", 0.5, 0.5, 0.05},
+		{"random", 1, 1, 0},
+	}
+
+	for _, testCase := range testCases {
+		def := manifest.Definition{Manifest: manifest.Manifest{EdgeThreshold: 0.5, TransparencyDither: testCase.dither}}
+		s := ShaderInfo{Alpha: testCase.alpha}
+
+		drawn := 0
+		for x := 0; x < 32; x++ {
+			for y := 0; y < 32; y++ {
+				if !isTransparent(&def, &s, x, y) {
+					drawn++
+				}
+			}
+		}
+
+		// The proportion of pixels drawn follows the alpha when dithered
+		if proportion := float64(drawn) / (32 * 32); math.Abs(proportion-testCase.expected) > testCase.tolerance {
+			t.Errorf("%s dither with alpha %f: expected %f of pixels drawn, got %f", testCase.dither, testCase.alpha, testCase.expected, proportion)
+		}
+	}
+
+	// Ordered dithering at half coverage draws alternate pixels
+	def := manifest.Definition{Manifest: manifest.Manifest{TransparencyDither: "ordered"}}
+	for x := 0; x < 4; x++ {
+		for y := 0; y < 4; y++ {
+			if result := isTransparent(&def, &ShaderInfo{Alpha: 0.5}, x, y); result != ((x+y)%2 == 1) {
+				t.Errorf("expected a checkerboard at alpha 0.5, got pixel %d,%d transparent %v", x, y, result)
+			}
+		}
+	}
+}
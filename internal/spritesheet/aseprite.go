@@ -0,0 +1,196 @@
+package spritesheet
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"github.com/mattkimber/gorender/internal/utils/fileutils"
+	"image"
+	"image/color"
+	"io"
+	"sort"
+)
+
+const (
+	asepriteHeaderMagic = 0xA5E0
+	asepriteFrameMagic  = 0xF1FA
+	asepriteLayerChunk  = 0x2004
+	asepriteCelChunk    = 0x2005
+	asepriteFrameTime   = 100
+)
+
+// An Aseprite file with a layer for each spritesheet and a frame for each sprite, so
+// sprites can be touched up without cutting them out of the spritesheets by hand
+type asepriteFile struct {
+	// Layers from bottom to top. Only the top layer is visible.
+	layers []asepriteLayer
+	frames []image.Rectangle
+}
+
+type asepriteLayer struct {
+	name  string
+	image image.Image
+}
+
+// Save the sprites to an Aseprite file, with the 32bpp spritesheet (or the 8bpp one, if
+// only 8bpp output is written) as the visible top layer and every other spritesheet of
+// the same size, such as masks, layers and debug output, as a hidden layer beneath it
+func (sheets *Spritesheets) SaveAseprite(filename string) error {
+	return fileutils.WriteToFile(filename, sheets.getAseprite())
+}
+
+func (sheets *Spritesheets) getAseprite() (f asepriteFile) {
+	top := "32bpp"
+	if _, ok := sheets.Data[top]; !ok {
+		top = "8bpp"
+	}
+
+	bounds := sheets.Data[top].Image.Bounds()
+
+	var keys []string
+	for key, sheet := range sheets.Data {
+		// Spritesheets which aren't the size of the others, such as the sampler, have no
+		// sprites in them
+		if key != top && sheet.Image.Bounds() == bounds {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+	for _, key := range append(keys, top) {
+		f.layers = append(f.layers, asepriteLayer{name: key, image: sheets.Data[key].Image})
+	}
+
+	for _, spr := range sheets.Layout {
+		f.frames = append(f.frames, image.Rect(spr.X, spr.Y, spr.X+spr.Width, spr.Y+spr.Height))
+	}
+
+	return
+}
+
+// Get the size of the canvas, which fits the largest sprite
+func (f asepriteFile) getSize() (size image.Point) {
+	for _, frame := range f.frames {
+		size.X, size.Y = max(size.X, frame.Dx()), max(size.Y, frame.Dy())
+	}
+
+	return
+}
+
+func (f asepriteFile) OutputToWriter(w io.Writer) (err error) {
+	body := bytes.Buffer{}
+	for i, frame := range f.frames {
+		var chunks [][]byte
+
+		// Layers are defined in the first frame
+		if i == 0 {
+			for j, layer := range f.layers {
+				chunks = append(chunks, getAsepriteLayerChunk(layer.name, j == len(f.layers)-1))
+			}
+		}
+
+		for j, layer := range f.layers {
+			var chunk []byte
+			if chunk, err = getAsepriteCelChunk(j, layer.image, frame); err != nil {
+				return
+			}
+
+			chunks = append(chunks, chunk)
+		}
+
+		writeAsepriteFrame(&body, chunks)
+	}
+
+	size := f.getSize()
+	header := bytes.Buffer{}
+	writeAseprite(&header, uint32(128+body.Len()), uint16(asepriteHeaderMagic), uint16(len(f.frames)), uint16(size.X), uint16(size.Y))
+
+	// 32 bits per pixel, with layer opacity used
+	writeAseprite(&header, uint16(32), uint32(1), uint16(asepriteFrameTime), uint32(0), uint32(0))
+
+	// Transparent index, number of colours, pixel ratio and grid, then reserved space
+	writeAseprite(&header, uint8(0), [3]byte{}, uint16(0), uint8(1), uint8(1), int16(0), int16(0), uint16(16), uint16(16), [84]byte{})
+
+	if _, err = w.Write(header.Bytes()); err != nil {
+		return
+	}
+
+	_, err = w.Write(body.Bytes())
+	return
+}
+
+func writeAsepriteFrame(w *bytes.Buffer, chunks [][]byte) {
+	size := 16
+	for _, chunk := range chunks {
+		size += len(chunk)
+	}
+
+	writeAseprite(w, uint32(size), uint16(asepriteFrameMagic), uint16(min(len(chunks), 0xFFFF)), uint16(asepriteFrameTime), [2]byte{}, uint32(len(chunks)))
+	for _, chunk := range chunks {
+		w.Write(chunk)
+	}
+}
+
+func getAsepriteChunk(chunkType uint16, data []byte) []byte {
+	chunk := bytes.Buffer{}
+	writeAseprite(&chunk, uint32(6+len(data)), chunkType)
+	chunk.Write(data)
+	return chunk.Bytes()
+}
+
+func getAsepriteLayerChunk(name string, visible bool) []byte {
+	// Layers are always editable
+	flags := uint16(2)
+	if visible {
+		flags |= 1
+	}
+
+	data := bytes.Buffer{}
+	writeAseprite(&data, flags, uint16(0), uint16(0), uint16(0), uint16(0), uint16(0), uint8(255), [3]byte{}, uint16(len(name)))
+	data.WriteString(name)
+
+	return getAsepriteChunk(asepriteLayerChunk, data.Bytes())
+}
+
+// Get a compressed image cel of the part of the image within rect, placed at the top
+// left of the canvas
+func getAsepriteCelChunk(layer int, img image.Image, rect image.Rectangle) ([]byte, error) {
+	data := bytes.Buffer{}
+	writeAseprite(&data, uint16(layer), int16(0), int16(0), uint8(255), uint16(2), int16(0), [5]byte{}, uint16(rect.Dx()), uint16(rect.Dy()))
+
+	// Strip images can always give colour indexes, so check the colour model instead
+	_, isPaletted := img.ColorModel().(color.Palette)
+	pixels := make([]byte, 0, rect.Dx()*rect.Dy()*4)
+
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			// Index 0 is transparent in 8bpp spritesheets
+			if isPaletted && img.(image.PalettedImage).ColorIndexAt(x, y) == 0 {
+				pixels = append(pixels, 0, 0, 0, 0)
+				continue
+			}
+
+			r, g, b, a := img.At(x, y).RGBA()
+			pixels = append(pixels, byte(r>>8), byte(g>>8), byte(b>>8), byte(a>>8))
+		}
+	}
+
+	zw := zlib.NewWriter(&data)
+	if _, err := zw.Write(pixels); err != nil {
+		return nil, err
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return getAsepriteChunk(asepriteCelChunk, data.Bytes()), nil
+}
+
+// Write values in the little-endian order Aseprite files use
+func writeAseprite(w *bytes.Buffer, values ...interface{}) {
+	for _, v := range values {
+		// Writes to a buffer can't fail
+		_ = binary.Write(w, binary.LittleEndian, v)
+	}
+}
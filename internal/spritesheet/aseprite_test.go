@@ -0,0 +1,111 @@
+package spritesheet
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+func TestSpritesheets_getAseprite(t *testing.T) {
+	def := getTestCubeDefinition(t)
+	sheets := GetSpritesheets(def)
+	defer sheets.Release()
+
+	f := sheets.getAseprite()
+
+	if len(f.frames) != len(sheets.Layout) {
+		t.Errorf("expected %d frames, got %d", len(sheets.Layout), len(f.frames))
+	}
+
+	if len(f.layers) != 3 || f.layers[len(f.layers)-1].name != "32bpp" {
+		t.Fatalf("expected 3 layers with 32bpp on top, got %v", f.layers)
+	}
+
+	buf := bytes.Buffer{}
+	if err := f.OutputToWriter(&buf); err != nil {
+		t.Fatalf("could not output Aseprite file: %v", err)
+	}
+
+	data := buf.Bytes()
+	if size := binary.LittleEndian.Uint32(data); int(size) != len(data) {
+		t.Errorf("expected file size %d, got %d", len(data), size)
+	}
+
+	if magic := binary.LittleEndian.Uint16(data[4:]); magic != asepriteHeaderMagic {
+		t.Errorf("expected magic number %x, got %x", asepriteHeaderMagic, magic)
+	}
+
+	if frames := binary.LittleEndian.Uint16(data[6:]); int(frames) != len(sheets.Layout) {
+		t.Errorf("expected %d frames in header, got %d", len(sheets.Layout), frames)
+	}
+
+	// Walk the frames and chunks, checking each cel matches the spritesheet
+	offset := 128
+	for i, spr := range sheets.Layout {
+		frameSize := int(binary.LittleEndian.Uint32(data[offset:]))
+		if magic := binary.LittleEndian.Uint16(data[offset+4:]); magic != asepriteFrameMagic {
+			t.Fatalf("frame %d: expected magic number %x, got %x", i, asepriteFrameMagic, magic)
+		}
+
+		layers, cels := 0, 0
+		for chunk := offset + 16; chunk < offset+frameSize; {
+			chunkSize := int(binary.LittleEndian.Uint32(data[chunk:]))
+
+			switch binary.LittleEndian.Uint16(data[chunk+4:]) {
+			case asepriteLayerChunk:
+				layers++
+			case asepriteCelChunk:
+				if binary.LittleEndian.Uint16(data[chunk+6:]) == uint16(len(f.layers)-1) {
+					checkAsepriteCel(t, data[chunk+6:chunk+chunkSize], &sheets, spr)
+				}
+				cels++
+			}
+
+			chunk += chunkSize
+		}
+
+		if (i == 0 && layers != len(f.layers)) || (i > 0 && layers != 0) {
+			t.Errorf("frame %d: unexpected %d layer chunks", i, layers)
+		}
+
+		if cels != len(f.layers) {
+			t.Errorf("frame %d: expected %d cels, got %d", i, len(f.layers), cels)
+		}
+
+		offset += frameSize
+	}
+
+	if offset != len(data) {
+		t.Errorf("expected frames to end at %d, got %d", len(data), offset)
+	}
+}
+
+func checkAsepriteCel(t *testing.T, cel []byte, sheets *Spritesheets, spr LayoutSprite) {
+	w, h := int(binary.LittleEndian.Uint16(cel[16:])), int(binary.LittleEndian.Uint16(cel[18:]))
+	if w != spr.Width || h != spr.Height {
+		t.Fatalf("expected cel size %dx%d, got %dx%d", spr.Width, spr.Height, w, h)
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(cel[20:]))
+	if err != nil {
+		t.Fatalf("could not read cel: %v", err)
+	}
+
+	pixels, err := io.ReadAll(zr)
+	if err != nil || len(pixels) != w*h*4 {
+		t.Fatalf("expected %d bytes of pixels, got %d (%v)", w*h*4, len(pixels), err)
+	}
+
+	sheet := sheets.Data["32bpp"].Image
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			r, g, b, a := sheet.At(spr.X+x, spr.Y+y).RGBA()
+			p := pixels[(y*w+x)*4:]
+			if p[0] != byte(r>>8) || p[1] != byte(g>>8) || p[2] != byte(b>>8) || p[3] != byte(a>>8) {
+				t.Fatalf("pixel %d,%d differs from the spritesheet", x, y)
+			}
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package spritesheet
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+)
+
+// A TexturePacker-style JSON atlas (in the "array" format) giving the frame and pivot of
+// each sprite in a spritesheet, which most 2D game engines and frameworks can import
+type TexturePackerAtlas struct {
+	Name   string
+	Image  string
+	Layout Layout
+}
+
+type texturePackerRect struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+type texturePackerSize struct {
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+type texturePackerPoint struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+type texturePackerFrame struct {
+	Filename         string             `json:"filename"`
+	Frame            texturePackerRect  `json:"frame"`
+	Rotated          bool               `json:"rotated"`
+	Trimmed          bool               `json:"trimmed"`
+	SpriteSourceSize texturePackerRect  `json:"spriteSourceSize"`
+	SourceSize       texturePackerSize  `json:"sourceSize"`
+	Pivot            texturePackerPoint `json:"pivot"`
+}
+
+type texturePackerMeta struct {
+	App    string            `json:"app"`
+	Image  string            `json:"image"`
+	Format string            `json:"format"`
+	Size   texturePackerSize `json:"size"`
+	Scale  string            `json:"scale"`
+}
+
+func (a TexturePackerAtlas) OutputToWriter(w io.Writer) (err error) {
+	frames := make([]texturePackerFrame, len(a.Layout))
+	for i, spr := range a.Layout {
+		frames[i] = texturePackerFrame{
+			Filename:         getAtlasFrameName(a.Name, i),
+			Frame:            texturePackerRect{X: spr.X, Y: spr.Y, W: spr.Width, H: spr.Height},
+			SpriteSourceSize: texturePackerRect{W: spr.Width, H: spr.Height},
+			SourceSize:       texturePackerSize{W: spr.Width, H: spr.Height},
+			Pivot:            getAtlasPivot(spr),
+		}
+	}
+
+	size := a.Layout.getSize()
+	atlas := struct {
+		Frames []texturePackerFrame `json:"frames"`
+		Meta   texturePackerMeta    `json:"meta"`
+	}{
+		Frames: frames,
+		Meta:   texturePackerMeta{App: "gorender", Image: a.Image, Format: "RGBA8888", Size: texturePackerSize{W: size.X, H: size.Y}, Scale: "1"},
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(atlas)
+	return
+}
+
+// A Godot 4 SpriteFrames resource with one frame per sprite, in sprite order. Frames
+// are padded with transparent margins so the pivot is at the centre of each frame, which
+// is the origin of a centred AnimatedSprite2D.
+type GodotSpriteFrames struct {
+	Image  string
+	Layout Layout
+}
+
+func (g GodotSpriteFrames) OutputToWriter(w io.Writer) (err error) {
+	if _, err = fmt.Fprintf(w, "[gd_resource type=\"SpriteFrames\" load_steps=%d format=3]\n\n", len(g.Layout)+2); err != nil {
+		return
+	}
+
+	if _, err = fmt.Fprintf(w, "[ext_resource type=\"Texture2D\" path=%q id=\"1\"]\n\n", g.Image); err != nil {
+		return
+	}
+
+	for i, spr := range g.Layout {
+		left, right := getCentringMargins(spr.Width, -spr.XOffset)
+		top, bottom := getCentringMargins(spr.Height, -spr.YOffset)
+
+		if _, err = fmt.Fprintf(w, "[sub_resource type=\"AtlasTexture\" id=\"AtlasTexture_%d\"]\natlas = ExtResource(\"1\")\nregion = Rect2(%d, %d, %d, %d)\nmargin = Rect2(%d, %d, %d, %d)\n\n",
+			i, spr.X, spr.Y, spr.Width, spr.Height, left, top, left+right, top+bottom); err != nil {
+			return
+		}
+	}
+
+	if _, err = fmt.Fprint(w, "[resource]\nanimations = [{\n\"frames\": ["); err != nil {
+		return
+	}
+
+	for i := range g.Layout {
+		separator := ", "
+		if i == 0 {
+			separator = ""
+		}
+
+		if _, err = fmt.Fprintf(w, "%s{\n\"duration\": 1.0,\n\"texture\": SubResource(\"AtlasTexture_%d\")\n}", separator, i); err != nil {
+			return
+		}
+	}
+
+	_, err = fmt.Fprint(w, "],\n\"loop\": true,\n\"name\": &\"default\",\n\"speed\": 5.0\n}]\n")
+	return
+}
+
+func getAtlasFrameName(name string, index int) string {
+	return fmt.Sprintf("%s_%d", name, index)
+}
+
+// Get the position of the ground under the centre of the object as a proportion of the
+// sprite's size, which is how TexturePacker gives pivots
+func getAtlasPivot(spr LayoutSprite) texturePackerPoint {
+	if spr.Width == 0 || spr.Height == 0 {
+		return texturePackerPoint{}
+	}
+
+	return texturePackerPoint{X: float64(-spr.XOffset) / float64(spr.Width), Y: float64(-spr.YOffset) / float64(spr.Height)}
+}
+
+// Get the padding needed before and after a sprite of the given size so the pivot is at
+// its centre
+func getCentringMargins(size, pivot int) (before, after int) {
+	return max(0, size-2*pivot), max(0, 2*pivot-size)
+}
+
+// Get the size of the spritesheet the sprites are placed in
+func (l Layout) getSize() (size image.Point) {
+	for _, spr := range l {
+		size.X, size.Y = max(size.X, spr.X+spr.Width), max(size.Y, spr.Y+spr.Height)
+	}
+
+	return
+}
@@ -0,0 +1,92 @@
+package spritesheet
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+var atlasTestLayout = Layout{
+	{Angle: 0, X: 0, Width: 8, Height: 24, XOffset: -4, YOffset: -17},
+	{Angle: 45, X: 16, Y: 4, Width: 22, Height: 20, XOffset: -11, YOffset: -5},
+}
+
+func TestTexturePackerAtlas_OutputToWriter(t *testing.T) {
+	atlas := TexturePackerAtlas{Name: "bus", Image: "bus_32bpp.png", Layout: atlasTestLayout}
+
+	buf := bytes.Buffer{}
+	if err := atlas.OutputToWriter(&buf); err != nil {
+		t.Fatalf("could not output atlas: %v", err)
+	}
+
+	var result struct {
+		Frames []texturePackerFrame `json:"frames"`
+		Meta   texturePackerMeta    `json:"meta"`
+	}
+
+	if err := json.Unmarshal(buf.Bytes(), &result); err != nil {
+		t.Fatalf("could not read atlas: %v", err)
+	}
+
+	if len(result.Frames) != 2 {
+		t.Fatalf("expected 2 frames, got %d", len(result.Frames))
+	}
+
+	frame := result.Frames[1]
+	if frame.Filename != "bus_1" {
+		t.Errorf("expected name bus_1, got %s", frame.Filename)
+	}
+
+	if expected := (texturePackerRect{X: 16, Y: 4, W: 22, H: 20}); frame.Frame != expected {
+		t.Errorf("expected frame %v, got %v", expected, frame.Frame)
+	}
+
+	if expected := (texturePackerPoint{X: 0.5, Y: 0.25}); frame.Pivot != expected {
+		t.Errorf("expected pivot %v, got %v", expected, frame.Pivot)
+	}
+
+	if result.Meta.Image != "bus_32bpp.png" || result.Meta.Size != (texturePackerSize{W: 38, H: 24}) {
+		t.Errorf("expected meta for bus_32bpp.png at 38x24, got %v", result.Meta)
+	}
+}
+
+func TestGodotSpriteFrames_OutputToWriter(t *testing.T) {
+	frames := GodotSpriteFrames{Image: "bus_32bpp.png", Layout: atlasTestLayout}
+
+	buf := bytes.Buffer{}
+	if err := frames.OutputToWriter(&buf); err != nil {
+		t.Fatalf("could not output sprite frames: %v", err)
+	}
+
+	expected := []string{
+		"[gd_resource type=\"SpriteFrames\" load_steps=4 format=3]",
+		"[ext_resource type=\"Texture2D\" path=\"bus_32bpp.png\" id=\"1\"]",
+		"region = Rect2(0, 0, 8, 24)\nmargin = Rect2(0, 0, 0, 10)",
+		"region = Rect2(16, 4, 22, 20)\nmargin = Rect2(0, 10, 0, 10)",
+		"\"texture\": SubResource(\"AtlasTexture_1\")",
+	}
+
+	for _, e := range expected {
+		if !strings.Contains(buf.String(), e) {
+			t.Errorf("expected sprite frames to contain %q, got:\n%s", e, buf.String())
+		}
+	}
+}
+
+func TestGetCentringMargins(t *testing.T) {
+	testCases := []struct {
+		size, pivot, before, after int
+	}{
+		{10, 5, 0, 0},
+		{10, 2, 6, 0},
+		{10, 8, 0, 6},
+		{10, -1, 12, 0},
+	}
+
+	for _, testCase := range testCases {
+		if before, after := getCentringMargins(testCase.size, testCase.pivot); before != testCase.before || after != testCase.after {
+			t.Errorf("size %d pivot %d expected %d, %d, got %d, %d", testCase.size, testCase.pivot, testCase.before, testCase.after, before, after)
+		}
+	}
+}
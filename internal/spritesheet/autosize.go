@@ -0,0 +1,48 @@
+package spritesheet
+
+import (
+	"github.com/mattkimber/gorender/internal/manifest"
+	"math"
+)
+
+// Get the sprites with the canvas of any auto-sized sprite set to fit the object drawn in
+// it, and the offsets set to move the object to the middle of the canvas. Offsets are
+// whole pixels, so the object is in the same place relative to the canvas at any scale,
+// and the sprite's own offsets are added to them.
+func getAutoSizedSprites(def manifest.Definition) []manifest.Sprite {
+	if def.Manifest.AutoSize == nil {
+		return def.Manifest.Sprites
+	}
+
+	sprites := make([]manifest.Sprite, len(def.Manifest.Sprites))
+	copy(sprites, def.Manifest.Sprites)
+
+	margin := def.Manifest.AutoSize.GetMargin()
+	bounds := make(map[string]occupiedBounds)
+
+	for i, spr := range sprites {
+		if !def.Manifest.IsAutoSized(spr) || isSliced(def, spr) {
+			continue
+		}
+
+		b := getOccupiedBounds(def, spr, bounds)
+		if !b.ok {
+			continue
+		}
+
+		offsetX, offsetY := spr.OffsetX, spr.OffsetY
+		spr.OffsetX, spr.OffsetY = 0, 0
+		topLeft, bottomRight := getProjectedBounds(def, spr, b, 1)
+		spr.CanvasWidth = int(math.Ceil(bottomRight.X-topLeft.X)) + margin*2
+		spr.CanvasHeight = int(math.Ceil(bottomRight.Y-topLeft.Y)) + margin*2
+
+		// A larger canvas moves the object, so find where it is drawn on the new canvas
+		topLeft, bottomRight = getProjectedBounds(def, spr, b, 1)
+		spr.OffsetX = math.Round((topLeft.X+bottomRight.X-float64(spr.CanvasWidth))/2) + offsetX
+		spr.OffsetY = math.Round((topLeft.Y+bottomRight.Y-float64(spr.CanvasHeight))/2) + offsetY
+
+		sprites[i] = spr
+	}
+
+	return sprites
+}
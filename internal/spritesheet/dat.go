@@ -0,0 +1,25 @@
+package spritesheet
+
+import (
+	"fmt"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"io"
+)
+
+// The image lines of a Simutrans .dat file, referencing each sprite in a spritesheet
+// made with a template which has directions
+type SimutransImages struct {
+	Name     string
+	Template manifest.Template
+}
+
+func (s SimutransImages) OutputToWriter(w io.Writer) (err error) {
+	for i, dir := range s.Template.Directions {
+		cell := s.Template.Cells[i]
+		if _, err = fmt.Fprintf(w, "Image[%s]=%s.0.%d\n", dir, s.Name, cell.X/cell.Width); err != nil {
+			return
+		}
+	}
+
+	return
+}
@@ -0,0 +1,29 @@
+package spritesheet
+
+import (
+	"bytes"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"testing"
+)
+
+func TestSimutransImages_OutputToWriter(t *testing.T) {
+	images := SimutransImages{Name: "bus", Template: manifest.Manifest{Template: "simutrans"}.GetTemplate()}
+
+	expected := "Image[NW]=bus.0.5\n" +
+		"Image[N]=bus.0.1\n" +
+		"Image[NE]=bus.0.6\n" +
+		"Image[E]=bus.0.2\n" +
+		"Image[SE]=bus.0.4\n" +
+		"Image[S]=bus.0.0\n" +
+		"Image[SW]=bus.0.7\n" +
+		"Image[W]=bus.0.3\n"
+
+	buf := bytes.Buffer{}
+	if err := images.OutputToWriter(&buf); err != nil {
+		t.Fatalf("could not output images: %v", err)
+	}
+
+	if buf.String() != expected {
+		t.Errorf("expected images:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
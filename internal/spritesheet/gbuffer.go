@@ -0,0 +1,64 @@
+package spritesheet
+
+import (
+	"compress/gzip"
+	"encoding/gob"
+	"fmt"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"github.com/mattkimber/gorender/internal/raycaster"
+	"io"
+)
+
+// A G-buffer holds the raycast output for each sprite, so sprites can be shaded again with
+// different lighting, shading and dithering settings without repeating the raycasting.
+type GBuffer struct {
+	Sprites []GBufferSprite
+}
+
+type GBufferSprite struct {
+	Output raycaster.RenderOutput
+	Shadow raycaster.ShadowOutput
+}
+
+func (g *GBuffer) OutputToWriter(w io.Writer) (err error) {
+	gz := gzip.NewWriter(w)
+	if err = gob.NewEncoder(gz).Encode(g); err != nil {
+		return err
+	}
+
+	return gz.Close()
+}
+
+func (g *GBuffer) GetFromReader(r io.Reader) (err error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+
+	if err = gob.NewDecoder(gz).Decode(g); err != nil {
+		return err
+	}
+
+	return gz.Close()
+}
+
+// Check the G-buffer was rendered with the same sprites as the definition
+func (g *GBuffer) validate(def manifest.Definition) error {
+	if len(g.Sprites) != len(def.Manifest.Sprites) {
+		return fmt.Errorf("G-buffer has %d sprites, manifest has %d", len(g.Sprites), len(def.Manifest.Sprites))
+	}
+
+	for i, spr := range def.Manifest.Sprites {
+		rect := getSpriteSizeForAngle(spr, getSupersampledDefinition(def, spr).Scale)
+		output := g.Sprites[i].Output
+		if len(output) != rect.Max.X || (len(output) > 0 && len(output[0]) != rect.Max.Y) {
+			return fmt.Errorf("G-buffer sprite %d does not match manifest size %dx%d", i, rect.Max.X, rect.Max.Y)
+		}
+
+		if def.Manifest.DropShadow && g.Sprites[i].Shadow == nil {
+			return fmt.Errorf("G-buffer sprite %d has no drop shadow", i)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package spritesheet
+
+import (
+	"bytes"
+	"github.com/mattkimber/gorender/internal/colour"
+	"github.com/mattkimber/gorender/internal/geometry"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"testing"
+)
+
+func TestGetRelitSpritesheets(t *testing.T) {
+	def := manifest.Definition{
+		Palette:       colour.Palette{Entries: []colour.PaletteEntry{{R: 0, G: 0, B: 0}, {R: 255, G: 255, B: 255}}},
+		Scale:         1.0,
+		OutputGBuffer: true,
+		Manifest: manifest.Manifest{
+			LightingAngle:     45,
+			LightingElevation: 60,
+			Size:              geometry.Vector3{},
+			Accuracy:          2,
+			Sprites: []manifest.Sprite{
+				{Angle: 0, Width: 32, Height: 32},
+				{Angle: 45, Width: 32, Height: 32},
+			},
+		},
+	}
+
+	sheets := GetSpritesheets(def)
+
+	buf := new(bytes.Buffer)
+	if err := sheets.GBuffer.OutputToWriter(buf); err != nil {
+		t.Fatalf("Could not write G-buffer: %v", err)
+	}
+
+	gbuffer := GBuffer{}
+	if err := gbuffer.GetFromReader(buf); err != nil {
+		t.Fatalf("Could not read G-buffer: %v", err)
+	}
+
+	relit, err := GetRelitSpritesheets(def, gbuffer)
+	if err != nil {
+		t.Fatalf("Could not relight G-buffer: %v", err)
+	}
+
+	testSpritesheet(t, &relit, "32bpp")
+	testSpritesheet(t, &relit, "8bpp")
+
+	def.Manifest.Sprites[1].Width = 16
+	if _, err := GetRelitSpritesheets(def, gbuffer); err == nil {
+		t.Errorf("Expected error for G-buffer not matching manifest")
+	}
+}
@@ -0,0 +1,35 @@
+package spritesheet
+
+import (
+	"github.com/mattkimber/gorender/internal/manifest"
+	"github.com/mattkimber/gorender/internal/sprite"
+)
+
+// Get the spritesheets for the manifest's icon. The icon is raycast and shaded at a multiple
+// of its size, then reduced to its size with a palette-aware reducer.
+func GetIconSpritesheets(def manifest.Definition) (sheets Spritesheets) {
+	icon := *def.Manifest.Icon
+	factor := icon.GetSupersample()
+
+	def.Manifest = def.Manifest.GetIconManifest()
+	sheets.Data = make(map[string]Spritesheet)
+
+	hiDef := def
+	hiDef.Scale = def.Scale * float64(factor)
+	hiInfos := make([]SpriteInfo, len(def.Manifest.Sprites))
+	shade(hiDef, raycast(hiDef, hiInfos), hiInfos, nil)
+
+	spriteInfos := make([]SpriteInfo, len(hiInfos))
+	def.Timings.Time("Icon reduction", def.Time, func() {
+		for i, spr := range def.Manifest.Sprites {
+			rect := getSpriteSizeForAngle(spr, def.Scale)
+			spriteInfos[i].SpriteBounds = rect
+			spriteInfos[i].ShaderOutput = sprite.ReduceShaderOutput(hiInfos[i].ShaderOutput, factor, rect.Max.X, rect.Max.Y, &def, icon.Sharpen)
+			spriteInfos[i].Log = hiInfos[i].Log
+			sprite.ReleaseShaderOutput(hiInfos[i].ShaderOutput)
+		}
+	})
+
+	addSpritesheets(&sheets, def, spriteInfos)
+	return
+}
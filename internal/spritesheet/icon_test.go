@@ -0,0 +1,35 @@
+package spritesheet
+
+import (
+	"github.com/mattkimber/gorender/internal/manifest"
+	"image"
+	"testing"
+)
+
+func TestGetIconSpritesheets(t *testing.T) {
+	def := getTestCubeDefinition(t)
+	def.Manifest.Icon = &manifest.Icon{Angle: 45, Width: 16, Height: 16, Supersample: 2, Sharpen: 0.5}
+
+	sheets := GetIconSpritesheets(def)
+	defer sheets.Release()
+
+	if len(sheets.Layout) != 1 || sheets.Layout[0].Width != 16 || sheets.Layout[0].Height != 16 {
+		t.Fatalf("expected one 16x16 icon, got %v", sheets.Layout)
+	}
+
+	expectedRect := image.Rectangle{Max: image.Point{X: 24, Y: 16}}
+	img := sheets.Data["8bpp"].Image.(image.PalettedImage)
+	if bounds := img.Bounds(); bounds != expectedRect {
+		t.Errorf("expected size %v, got %v", expectedRect, bounds)
+	}
+
+	if index := img.ColorIndexAt(8, 8); index == 0 {
+		t.Errorf("expected the cube at the centre of the icon")
+	}
+
+	for _, key := range []string{"32bpp", "mask"} {
+		if _, ok := sheets.Data[key]; !ok {
+			t.Errorf("no %s icon spritesheet", key)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package spritesheet
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"github.com/mattkimber/gorender/internal/raycaster"
+	"github.com/mattkimber/gorender/internal/report"
+	"github.com/mattkimber/gorender/internal/sprite"
+	"image"
+	"io"
+	"math"
+)
+
+// The position of each sprite in the spritesheets
+type Layout []LayoutSprite
+
+type LayoutSprite struct {
+	Angle  float64 `json:"angle"`
+	X      int     `json:"x"`
+	Y      int     `json:"y"`
+	Width  int     `json:"width"`
+	Height int     `json:"height"`
+
+	// The index of an earlier identical sprite sharing this position, or -1
+	DuplicateOf int `json:"duplicate_of"`
+
+	// The position of the sprite's top left relative to the ground under the centre of the
+	// object, which is how OpenTTD positions sprites
+	XOffset int `json:"x_offset"`
+	YOffset int `json:"y_offset"`
+}
+
+func (l *Layout) OutputToWriter(w io.Writer) (err error) {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(l)
+	return
+}
+
+// Place sprites side by side, returning the bounds of the spritesheet. If the manifest
+// asks for duplicates to be removed, identical sprites share the same position. Sprites
+// in a template are placed in the template's cells.
+func getLayout(def manifest.Definition, duplicates []int) (layout Layout, bounds image.Rectangle) {
+	layout = make(Layout, len(def.Manifest.Sprites))
+
+	template := def.Manifest.GetTemplate()
+
+	w, h := 0, 0
+	for i, spr := range def.Manifest.Sprites {
+		rect := getSpriteSizeForAngle(spr, def.Scale)
+		layout[i] = LayoutSprite{Angle: spr.Angle, Width: rect.Max.X, Height: rect.Max.Y, DuplicateOf: duplicates[i]}
+
+		groundX, groundY := raycaster.GetGroundPoint(def.Manifest, spr, def.Scale)
+		layout[i].XOffset, layout[i].YOffset = -int(math.Round(groundX)), -int(math.Round(groundY))
+
+		if i < len(template.Cells) {
+			// Templates place sprites in their cell, with no spacing
+			layout[i].X = int(float64(template.Cells[i].X) * def.Scale)
+			w = max(w, layout[i].X+rect.Max.X)
+		} else if def.Manifest.Deduplicate && duplicates[i] != -1 {
+			layout[i].X = layout[duplicates[i]].X
+		} else {
+			layout[i].X = w
+			width, _ := spr.GetCanvasSize()
+			w += int(float64(width+spriteSpacing) * def.Scale)
+		}
+
+		def.Manifest.Sprites[i].X = layout[i].X
+
+		if rect.Max.Y > h {
+			h = rect.Max.Y
+		}
+	}
+
+	return layout, image.Rectangle{Max: image.Point{X: w, Y: h}}
+}
+
+// Get the index of an earlier pixel-identical sprite for each sprite, or -1 if there
+// isn't one
+func getDuplicates(def manifest.Definition, spriteInfos []SpriteInfo) (duplicates []int) {
+	duplicates = make([]int, len(spriteInfos))
+	seen := make(map[[sha256.Size]byte]int)
+
+	for i, info := range spriteInfos {
+		key := getSpriteKey(def, info)
+		if j, ok := seen[key]; ok {
+			duplicates[i] = j
+		} else {
+			seen[key] = i
+			duplicates[i] = -1
+		}
+	}
+
+	return
+}
+
+func addDuplicatesToReport(r *report.Report, duplicates []int) {
+	for i, of := range duplicates {
+		if of != -1 {
+			r.Duplicates = append(r.Duplicates, report.Duplicate{Sprite: i, Of: of})
+		}
+	}
+}
+
+// Get a hash of everything output for a sprite, so sprites with the same hash can
+// share a position in all spritesheets
+func getSpriteKey(def manifest.Definition, info SpriteInfo) (key [sha256.Size]byte) {
+	bounds := image.Rectangle{Max: info.SpriteBounds.Max}
+	palette := def.Palette.GetGoPalette()
+	h := sha256.New()
+
+	// Sprites with identical pixels but different sizes are not interchangeable
+	_, _ = fmt.Fprintf(h, "%dx%d", bounds.Max.X, bounds.Max.Y)
+
+	write8bpp := func(info SpriteInfo, depth string) {
+		img := image.NewPaletted(bounds, palette)
+		applySprite8bpp(img, def, info, image.Point{}, depth)
+		_, _ = h.Write(img.Pix)
+	}
+
+	write32bpp := func(info SpriteInfo, depth string) {
+		img := image.NewRGBA(bounds)
+		applySprite32bpp(img, def, info, image.Point{}, depth)
+		_, _ = h.Write(img.Pix)
+	}
+
+	write8bpp(info, "8bpp")
+	if !def.Only8bpp {
+		write32bpp(info, "32bpp")
+		write8bpp(info, "mask")
+	}
+
+	if def.Manifest.GlossMap && !def.Only8bpp {
+		write32bpp(info, "gloss")
+	}
+
+	if def.Manifest.DropShadow {
+		write8bpp(info, "dropshadow")
+		if !def.Only8bpp {
+			write32bpp(info, "dropshadow")
+		}
+	}
+
+	for _, l := range def.Manifest.Layers {
+		layerInfo := SpriteInfo{ShaderOutput: sprite.GetLayerOutput(info.ShaderOutput, l), SpriteBounds: info.SpriteBounds}
+		write8bpp(layerInfo, "8bpp")
+		if !def.Only8bpp {
+			write32bpp(layerInfo, "32bpp")
+			write8bpp(layerInfo, "mask")
+		}
+	}
+
+	if def.Manifest.DepthBuffer {
+		img := image.NewGray16(bounds)
+		sprite.ApplyDepthBufferSprite(img, info.SpriteBounds, image.Point{}, info.ShaderOutput)
+		_, _ = h.Write(img.Pix)
+	}
+
+	copy(key[:], h.Sum(nil))
+	return
+}
@@ -0,0 +1,181 @@
+package spritesheet
+
+import (
+	"github.com/mattkimber/gorender/internal/colour"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"image"
+	"testing"
+)
+
+func TestGetSpritesheets_Duplicates(t *testing.T) {
+	testCases := []struct {
+		deduplicate bool
+		width, x    int
+	}{
+		{false, 104, 40},
+		{true, 64, 0},
+	}
+
+	for _, testCase := range testCases {
+		// Without an object, sprites of the same size are identical
+		def := manifest.Definition{
+			Palette: colour.Palette{Entries: []colour.PaletteEntry{{R: 0, G: 0, B: 0}, {R: 255, G: 255, B: 255}}},
+			Scale:   1.0,
+			Manifest: manifest.Manifest{
+				Accuracy:    2,
+				Deduplicate: testCase.deduplicate,
+				Sprites: []manifest.Sprite{
+					{Angle: 0, Width: 32, Height: 32},
+					{Angle: 45, Width: 32, Height: 32},
+					{Angle: 90, Width: 16, Height: 32},
+				},
+			},
+		}
+
+		sheets := GetSpritesheets(def)
+
+		expectedRect := image.Rectangle{Max: image.Point{X: testCase.width, Y: 32}}
+		if bounds := sheets.Data["8bpp"].Image.Bounds(); bounds != expectedRect {
+			t.Errorf("deduplicate %v: expected size %v, got %v", testCase.deduplicate, expectedRect, bounds)
+		}
+
+		if len(sheets.Report.Duplicates) != 1 || sheets.Report.Duplicates[0].Sprite != 1 || sheets.Report.Duplicates[0].Of != 0 {
+			t.Errorf("deduplicate %v: expected sprite 1 to duplicate sprite 0, got %v", testCase.deduplicate, sheets.Report.Duplicates)
+		}
+
+		if sheets.Layout[1].X != testCase.x || sheets.Layout[1].DuplicateOf != 0 {
+			t.Errorf("deduplicate %v: expected sprite 1 at %d, got %v", testCase.deduplicate, testCase.x, sheets.Layout[1])
+		}
+
+		if sheets.Layout[2].X != testCase.x+40 || sheets.Layout[2].DuplicateOf != -1 {
+			t.Errorf("deduplicate %v: expected sprite 2 at %d, got %v", testCase.deduplicate, testCase.x+40, sheets.Layout[2])
+		}
+	}
+}
+
+func TestGetSpritesheets_CanvasSize(t *testing.T) {
+	def := manifest.Definition{
+		Palette: colour.Palette{Entries: []colour.PaletteEntry{{R: 0, G: 0, B: 0}, {R: 255, G: 255, B: 255}}},
+		Scale:   2.0,
+		Manifest: manifest.Manifest{
+			Accuracy: 1,
+			Sprites: []manifest.Sprite{
+				{Angle: 0, Width: 16, Height: 16},
+				{Angle: 45, Width: 16, Height: 16, CanvasWidth: 24, CanvasHeight: 20},
+				{Angle: 90, Width: 16, Height: 16},
+			},
+		},
+	}
+
+	sheets := GetSpritesheets(def)
+
+	// Each sprite takes its canvas width plus spacing, and the sheet is as tall as the tallest canvas
+	expectedRect := image.Rectangle{Max: image.Point{X: 160, Y: 40}}
+	if bounds := sheets.Data["8bpp"].Image.Bounds(); bounds != expectedRect {
+		t.Errorf("expected size %v, got %v", expectedRect, bounds)
+	}
+
+	for i, x := range []int{0, 48, 112} {
+		if sheets.Layout[i].X != x {
+			t.Errorf("expected sprite %d at %d, got %v", i, x, sheets.Layout[i])
+		}
+	}
+}
+
+func TestGetSpritesheets_Template(t *testing.T) {
+	def := manifest.Definition{
+		Palette:  colour.Palette{Entries: []colour.PaletteEntry{{R: 0, G: 0, B: 0}, {R: 255, G: 255, B: 255}}},
+		Scale:    2.0,
+		Manifest: manifest.Manifest{Accuracy: 1, Template: "nml_vehicle"},
+	}
+
+	for i, cell := range def.Manifest.GetTemplate().Cells {
+		def.Manifest.Sprites = append(def.Manifest.Sprites, manifest.Sprite{
+			Angle: float64(i * 45), Width: 16, Height: 16, CanvasWidth: cell.Width, CanvasHeight: cell.Height,
+		})
+	}
+
+	sheets := GetSpritesheets(def)
+
+	// Sprites are placed in their cells with no spacing, so the last ends at 175 (153 + 22)
+	expectedRect := image.Rectangle{Max: image.Point{X: 350, Y: 48}}
+	if bounds := sheets.Data["8bpp"].Image.Bounds(); bounds != expectedRect {
+		t.Errorf("expected size %v, got %v", expectedRect, bounds)
+	}
+
+	for i, x := range []int{0, 18, 64, 130, 176, 194, 240, 306} {
+		if sheets.Layout[i].X != x {
+			t.Errorf("expected sprite %d at %d, got %v", i, x, sheets.Layout[i])
+		}
+	}
+}
+
+func TestGetSpritesheets_AutoSize(t *testing.T) {
+	def := getTestCubeDefinition(t)
+	def.Scale = 2.0
+	margin := 2
+	def.Manifest.AutoSize = &manifest.AutoSize{Margin: &margin}
+	def.Manifest.Sprites[1].CanvasWidth = 40
+
+	sheets := GetSpritesheets(def)
+
+	// Sprites which set their own canvas size are not auto-sized
+	if sheets.Layout[1].Width != 80 {
+		t.Errorf("expected sprite 1 to keep its canvas width, got %v", sheets.Layout[1])
+	}
+
+	// The object is in the middle of the canvas, with the margin around it
+	b := sheets.spriteInfos[0].SpriteBounds
+	if expected, visible := b.Inset(4), getVisibleBounds(sheets.spriteInfos[0]); visible != expected {
+		t.Errorf("expected visible pixels in %v of %v, got %v", expected, b, visible)
+	}
+
+	if len(sheets.Report.Warnings) != 0 {
+		t.Errorf("expected no warnings, got %v", sheets.Report.Warnings)
+	}
+}
+
+func TestGetSpritesheets_AutoSizeOffset(t *testing.T) {
+	def := getTestCubeDefinition(t)
+	def.Scale = 2.0
+	margin := 0
+	def.Manifest.AutoSize = &manifest.AutoSize{Margin: &margin}
+	def.Manifest.Sprites = def.Manifest.Sprites[:1]
+
+	sheets := GetSpritesheets(def)
+	b := sheets.spriteInfos[0].SpriteBounds
+
+	// An explicit margin of 0 leaves no empty pixels around the object
+	if visible := getVisibleBounds(sheets.spriteInfos[0]); visible != b {
+		t.Errorf("expected visible pixels in %v, got %v", b, visible)
+	}
+
+	// The sprite's own offsets are added to the ones which centre the object
+	def.Manifest.Sprites[0].OffsetX, def.Manifest.Sprites[0].OffsetY = 3, -2
+	offsetSheets := GetSpritesheets(def)
+
+	if offsetSheets.Layout[0].Width != sheets.Layout[0].Width || offsetSheets.Layout[0].Height != sheets.Layout[0].Height {
+		t.Errorf("expected size %v, got %v", sheets.Layout[0], offsetSheets.Layout[0])
+	}
+
+	expected := b.Sub(image.Point{X: 6, Y: -4}).Intersect(b)
+	if visible := getVisibleBounds(offsetSheets.spriteInfos[0]); visible != expected {
+		t.Errorf("expected visible pixels in %v, got %v", expected, visible)
+	}
+}
+
+// Get the box around the visible pixels of a sprite
+func getVisibleBounds(info SpriteInfo) image.Rectangle {
+	b := info.SpriteBounds
+	visible := image.Rectangle{Min: b.Max, Max: b.Min}
+	for x := b.Min.X; x < b.Max.X; x++ {
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			if info.ShaderOutput[x][y].Alpha > 0 {
+				visible.Min = image.Point{X: min(visible.Min.X, x), Y: min(visible.Min.Y, y)}
+				visible.Max = image.Point{X: max(visible.Max.X, x+1), Y: max(visible.Max.Y, y+1)}
+			}
+		}
+	}
+
+	return visible
+}
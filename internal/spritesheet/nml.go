@@ -0,0 +1,36 @@
+package spritesheet
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+)
+
+// An NML template giving the position, size and offsets of each sprite in the spritesheets,
+// so sprites can be used without lining them up by hand
+type NMLTemplate struct {
+	Name   string
+	Layout Layout
+}
+
+var nmlInvalidCharacters = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// Get the name of the template for a spritesheet, which must be a valid NML identifier
+func GetNMLTemplateName(baseFilename string) string {
+	return "tmpl_" + nmlInvalidCharacters.ReplaceAllString(baseFilename, "_")
+}
+
+func (t NMLTemplate) OutputToWriter(w io.Writer) (err error) {
+	if _, err = fmt.Fprintf(w, "template %s() {\n", t.Name); err != nil {
+		return
+	}
+
+	for _, spr := range t.Layout {
+		if _, err = fmt.Fprintf(w, "    [%d, %d, %d, %d, %d, %d] // %g degrees\n", spr.X, spr.Y, spr.Width, spr.Height, spr.XOffset, spr.YOffset, spr.Angle); err != nil {
+			return
+		}
+	}
+
+	_, err = fmt.Fprintln(w, "}")
+	return
+}
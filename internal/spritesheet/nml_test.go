@@ -0,0 +1,30 @@
+package spritesheet
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNMLTemplate_OutputToWriter(t *testing.T) {
+	template := NMLTemplate{
+		Name: GetNMLTemplateName("bus-2x"),
+		Layout: Layout{
+			{Angle: 0, X: 0, Width: 8, Height: 24, XOffset: -4, YOffset: -17},
+			{Angle: 22.5, X: 16, Y: 4, Width: 22, Height: 20, XOffset: -11, YOffset: -15},
+		},
+	}
+
+	expected := "template tmpl_bus_2x() {\n" +
+		"    [0, 0, 8, 24, -4, -17] // 0 degrees\n" +
+		"    [16, 4, 22, 20, -11, -15] // 22.5 degrees\n" +
+		"}\n"
+
+	buf := bytes.Buffer{}
+	if err := template.OutputToWriter(&buf); err != nil {
+		t.Fatalf("could not output template: %v", err)
+	}
+
+	if buf.String() != expected {
+		t.Errorf("expected template:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
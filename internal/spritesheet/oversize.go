@@ -0,0 +1,105 @@
+package spritesheet
+
+import (
+	"github.com/mattkimber/gorender/internal/geometry"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"github.com/mattkimber/gorender/internal/raycaster"
+	"math"
+)
+
+type occupiedBounds struct {
+	from, to, size geometry.Point
+	ok             bool
+}
+
+// Get how many pixels the object drawn in each sprite extends beyond the edge of the
+// sprite, by projecting the corners of the box containing its voxels. Sliced sprites
+// only draw part of the object, so are never oversize.
+func getOverflows(def manifest.Definition) (overflows []float64) {
+	overflows = make([]float64, len(def.Manifest.Sprites))
+	bounds := make(map[string]occupiedBounds)
+
+	for i, spr := range def.Manifest.Sprites {
+		if isSliced(def, spr) {
+			continue
+		}
+
+		if b := getOccupiedBounds(def, spr, bounds); b.ok {
+			overflows[i] = getOverflow(def, spr, b)
+		}
+	}
+
+	return
+}
+
+// Check if a sprite only draws a slice of the object
+func isSliced(def manifest.Definition, spr manifest.Sprite) bool {
+	m := def.Manifest
+	return m.SliceLength > 0 && m.SliceThreshold > 0 && m.SliceThreshold < getSpriteObject(def, spr).Size.X
+}
+
+// Get the box containing the voxels of the object drawn in a sprite, which is only found
+// once for each object
+func getOccupiedBounds(def manifest.Definition, spr manifest.Sprite, bounds map[string]occupiedBounds) occupiedBounds {
+	key := spr.ObjectKey()
+	if spr.Slope != 0 {
+		key = raycaster.GetSlopedObjectKey(spr, def.Manifest, def.Object.Size)
+	}
+
+	b, found := bounds[key]
+	if !found {
+		object := getSpriteObject(def, spr)
+		b.from, b.to, b.ok = object.GetOccupiedBounds()
+		b.size = object.Size
+		bounds[key] = b
+	}
+
+	return b
+}
+
+func getOverflow(def manifest.Definition, spr manifest.Sprite, b occupiedBounds) float64 {
+	rect := getSpriteSizeForAngle(spr, def.Scale)
+	w, h := float64(rect.Max.X), float64(rect.Max.Y)
+
+	topLeft, bottomRight := getProjectedBounds(def, spr, b, def.Scale)
+	return math.Max(math.Max(math.Max(-topLeft.X, bottomRight.X-w), math.Max(-topLeft.Y, bottomRight.Y-h)), 0)
+}
+
+// Get the corners of the rectangle in a sprite containing the projected corners of the
+// box around the object's voxels
+func getProjectedBounds(def manifest.Definition, spr manifest.Sprite, b occupiedBounds, scale float64) (topLeft, bottomRight geometry.Vector2) {
+	from, to, size := b.from, b.to, b.size
+
+	// Flipped objects are mirrored in Y when rendered
+	if spr.Flip {
+		from.Y, to.Y = size.Y-to.Y, size.Y-from.Y
+	}
+
+	// The object is moved along X by the length of its stretched end slices, and the
+	// end slices are longer if the object reaches them
+	e := def.Manifest.JoinOverlap
+	minX, maxX := float64(from.X)+e, float64(to.X)+e
+	if from.X == 0 {
+		minX -= e
+	}
+
+	if to.X == size.X {
+		maxX += e
+	}
+
+	topLeft = geometry.Vector2{X: math.Inf(1), Y: math.Inf(1)}
+	bottomRight = geometry.Vector2{X: math.Inf(-1), Y: math.Inf(-1)}
+
+	for _, x := range []float64{minX, maxX} {
+		for _, y := range []int{from.Y, to.Y} {
+			for _, z := range []int{from.Z, to.Z} {
+				corner := geometry.Vector3{X: x, Y: float64(y), Z: float64(z)}
+				px, py := raycaster.ProjectPoint(def.Manifest, spr, size, corner, scale)
+				topLeft = geometry.Vector2{X: math.Min(topLeft.X, px), Y: math.Min(topLeft.Y, py)}
+				bottomRight = geometry.Vector2{X: math.Max(bottomRight.X, px), Y: math.Max(bottomRight.Y, py)}
+			}
+		}
+	}
+
+	return
+}
@@ -0,0 +1,68 @@
+package spritesheet
+
+import (
+	"fmt"
+	"github.com/mattkimber/gorender/internal/utils/fileutils"
+	"image"
+	"image/color"
+)
+
+// The spritesheets each sprite is written to its own files from
+var spriteFileKeys = []string{"8bpp", "32bpp", "mask"}
+
+// Get the file name a sprite's image is saved to by SaveSpriteFiles
+func GetSpriteFilename(baseFilename string, sprite int, key string) string {
+	return fmt.Sprintf("%s_%d_%s.png", baseFilename, sprite, key)
+}
+
+// Save the 8bpp, 32bpp and mask images of each sprite to their own files. The images are
+// cut from the spritesheets at the sprite's position in the layout, so the images of a
+// sprite always line up with each other. Returns the spritesheet each file was cut from, by
+// file name.
+func (sheets *Spritesheets) SaveSpriteFiles(baseFilename string) (filenames map[string]string, err error) {
+	filenames = make(map[string]string)
+
+	for i, spr := range sheets.Layout {
+		rect := image.Rect(spr.X, spr.Y, spr.X+spr.Width, spr.Y+spr.Height)
+
+		for _, key := range spriteFileKeys {
+			sheet, ok := sheets.Data[key]
+			if !ok {
+				continue
+			}
+
+			filename := GetSpriteFilename(baseFilename, i, key)
+			if err = fileutils.WriteToFile(filename, Spritesheet{Image: croppedImage{sheet.Image, rect}}); err != nil {
+				return
+			}
+
+			filenames[filename] = key
+		}
+	}
+
+	return
+}
+
+// A rectangle of a spritesheet image. Unlike SubImage this works for spritesheets which
+// are drawn as they are read.
+type croppedImage struct {
+	image  image.Image
+	bounds image.Rectangle
+}
+
+func (c croppedImage) ColorModel() color.Model {
+	return c.image.ColorModel()
+}
+
+func (c croppedImage) Bounds() image.Rectangle {
+	return c.bounds
+}
+
+func (c croppedImage) At(x, y int) color.Color {
+	return c.image.At(x, y)
+}
+
+// Paletted images need this to be encoded as paletted PNGs
+func (c croppedImage) ColorIndexAt(x, y int) uint8 {
+	return c.image.(image.PalettedImage).ColorIndexAt(x, y)
+}
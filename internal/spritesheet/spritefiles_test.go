@@ -0,0 +1,71 @@
+package spritesheet
+
+import (
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSpritesheets_SaveSpriteFiles(t *testing.T) {
+	def := getTestCubeDefinition(t)
+	sheets := GetSpritesheets(def)
+	defer sheets.Release()
+
+	base := filepath.Join(t.TempDir(), "cube")
+	filenames, err := sheets.SaveSpriteFiles(base)
+	if err != nil {
+		t.Fatalf("could not save sprite files: %v", err)
+	}
+
+	if len(filenames) != 6 {
+		t.Errorf("expected 6 sprite files, got %d", len(filenames))
+	}
+
+	for i, spr := range sheets.Layout {
+		for _, key := range spriteFileKeys {
+			filename := GetSpriteFilename(base, i, key)
+			if filenames[filename] != key {
+				t.Errorf("expected %s to be cut from the %s spritesheet, got %s", filename, key, filenames[filename])
+			}
+
+			img := getSpriteFileImage(t, filename)
+			if img.Bounds().Dx() != spr.Width || img.Bounds().Dy() != spr.Height {
+				t.Errorf("%s: expected size %dx%d, got %v", filename, spr.Width, spr.Height, img.Bounds())
+			}
+
+			if _, ok := img.(*image.Paletted); ok != (key != "32bpp") {
+				t.Errorf("%s: expected paletted image %v, got %T", filename, key != "32bpp", img)
+			}
+
+			// Every pixel matches the spritesheet at the sprite's position
+			sheet := sheets.Data[key].Image
+			for x := 0; x < spr.Width; x++ {
+				for y := 0; y < spr.Height; y++ {
+					r1, g1, b1, a1 := img.At(img.Bounds().Min.X+x, img.Bounds().Min.Y+y).RGBA()
+					r2, g2, b2, a2 := sheet.At(spr.X+x, spr.Y+y).RGBA()
+					if r1 != r2 || g1 != g2 || b1 != b2 || a1 != a2 {
+						t.Fatalf("%s: pixel %d,%d differs from the spritesheet", filename, x, y)
+					}
+				}
+			}
+		}
+	}
+}
+
+func getSpriteFileImage(t *testing.T, filename string) image.Image {
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatalf("could not open %s: %v", filename, err)
+	}
+
+	defer f.Close()
+
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatalf("could not decode %s: %v", filename, err)
+	}
+
+	return img
+}
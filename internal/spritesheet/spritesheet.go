@@ -1,145 +1,446 @@
 package spritesheet
 
 import (
+	"fmt"
 	"github.com/mattkimber/gorender/internal/manifest"
 	"github.com/mattkimber/gorender/internal/raycaster"
+	"github.com/mattkimber/gorender/internal/report"
 	"github.com/mattkimber/gorender/internal/sampler"
 	"github.com/mattkimber/gorender/internal/sprite"
 	"github.com/mattkimber/gorender/internal/utils/fileutils"
 	"github.com/mattkimber/gorender/internal/utils/imageutils"
 	"github.com/mattkimber/gorender/internal/utils/timingutils"
+	"github.com/mattkimber/gorender/internal/voxelobject"
 	"image"
 	"image/color"
+	"image/draw"
 	"image/png"
 	"io"
+	"math"
 	"sync"
 )
 
 type Spritesheet struct {
 	Image image.Image
+
+	// The image format to encode as, or PNG if not set
+	Format string
 }
 
 type Spritesheets struct {
 	sync.RWMutex
-	Data map[string]Spritesheet
+	Data    map[string]Spritesheet
+	Report  report.Report
+	Layout  Layout
+	GBuffer GBuffer
+
+	// Spritesheets are drawn from the shaded sprites as they are encoded
+	spriteInfos []SpriteInfo
+
+	template manifest.Template
 }
 
 type SpriteInfo struct {
 	ShaderOutput sprite.ShaderOutput
+	Shadow       raycaster.ShadowOutput
 	SpriteBounds image.Rectangle
+
+	// Messages about rendering the sprite, kept for the report rather than printed so
+	// they aren't mixed up with other sprites and files rendered at the same time
+	Log []string
 }
 
 const spriteSpacing = 8
 
 func GetSpritesheets(def manifest.Definition) (sheets Spritesheets) {
+	def.Manifest.Sprites = getAutoSizedSprites(def)
+	return getSpritesheets(def, nil, nil)
+}
+
+// A function called with the index and images of each sprite as soon as it is shaded, before
+// the whole spritesheet is complete. Sprites are shaded in parallel, so this may be called from
+// several goroutines at once.
+type SpriteFunc func(index int, images map[string]image.Image)
+
+// Get spritesheets, passing each sprite's 8bpp, 32bpp and mask images to onSprite as soon as
+// the sprite is ready
+func GetStreamedSpritesheets(def manifest.Definition, onSprite SpriteFunc) (sheets Spritesheets) {
+	def.Manifest.Sprites = getAutoSizedSprites(def)
+	return getSpritesheets(def, nil, onSprite)
+}
+
+// Get spritesheets from a previously raycast G-buffer, re-running only lighting, shading
+// and dithering
+func GetRelitSpritesheets(def manifest.Definition, gbuffer GBuffer) (sheets Spritesheets, err error) {
+	def.Manifest.Sprites = getAutoSizedSprites(def)
+	if err = gbuffer.validate(def); err != nil {
+		return
+	}
+
+	return getSpritesheets(def, &gbuffer, nil), nil
+}
+
+func getSpritesheets(def manifest.Definition, gbuffer *GBuffer, onSprite SpriteFunc) (sheets Spritesheets) {
 	sheets.Data = make(map[string]Spritesheet)
+	sheets.template = def.Manifest.GetTemplate()
+	spriteInfos := make([]SpriteInfo, len(def.Manifest.Sprites))
 
-	w, h := 0, 0
-	for i, spr := range def.Manifest.Sprites {
-		def.Manifest.Sprites[i].X = w
-		w += int(float64(spr.Width+spriteSpacing) * def.Scale)
+	if gbuffer == nil && def.MaxMemory > 0 && !def.OutputGBuffer {
+		raycastTiled(def, spriteInfos, onSprite)
+	} else {
+		if gbuffer == nil {
+			gbuffer = raycast(def, spriteInfos)
+		} else {
+			relight(def, gbuffer)
+		}
+
+		shade(def, gbuffer, spriteInfos, onSprite)
 
-		if int(float64(spr.Height)*def.Scale) > h {
-			h = int(float64(spr.Height) * def.Scale)
+		if def.OutputGBuffer {
+			sheets.GBuffer = *gbuffer
 		}
 	}
 
-	bounds := image.Rectangle{Max: image.Point{X: w, Y: h}}
-	spriteInfos := make([]SpriteInfo, len(def.Manifest.Sprites))
+	addSpritesheets(&sheets, def, spriteInfos)
+	return
+}
+
+// Add the spritesheets drawn from shaded sprites, and the report and layout for them
+func addSpritesheets(sheets *Spritesheets, def manifest.Definition, spriteInfos []SpriteInfo) {
+	sheets.spriteInfos = spriteInfos
 
-	raycast(def, spriteInfos)
+	sheets.Report = getReport(def, spriteInfos)
 
-	timingutils.Time("Spritesheets", def.Time, func() {
-		getRegularSheets(&sheets, def, bounds, spriteInfos)
+	var duplicates []int
+	def.Timings.Time("Duplicate detection", def.Time, func() {
+		duplicates = getDuplicates(def, spriteInfos)
 	})
+	addDuplicatesToReport(&sheets.Report, duplicates)
+
+	var bounds image.Rectangle
+	sheets.Layout, bounds = getLayout(def, duplicates)
+
+	// Spritesheets are drawn as they are encoded, so are timed along with file output
+	getRegularSheets(sheets, def, bounds, spriteInfos)
 	if def.Debug {
-		timingutils.Time("Debug output", def.Time, func() {
-			getDebugSheets(&sheets, def, bounds, spriteInfos)
-		})
+		getDebugSheets(sheets, def, bounds, spriteInfos)
 	}
+}
+
+func getReport(def manifest.Definition, spriteInfos []SpriteInfo) (r report.Report) {
+	overflows := getOverflows(def)
+
+	for i, spr := range def.Manifest.Sprites {
+		r.AddSprite(spr, spriteInfos[i].ShaderOutput, spriteInfos[i].SpriteBounds, &def.Palette)
+		r.Sprites[i].Log = spriteInfos[i].Log
+		r.CheckEmpty(i, spriteInfos[i].ShaderOutput, spriteInfos[i].SpriteBounds)
+		r.CheckOversize(i, overflows[i])
+
+		// Tiles are expected to fill the sprite to its edges
+		if spr.Type != "tile" {
+			r.CheckEdgePixels(i, spriteInfos[i].ShaderOutput, spriteInfos[i].SpriteBounds)
+		}
+
+		r.CheckUnmappedColours(i, spriteInfos[i].ShaderOutput, spriteInfos[i].SpriteBounds, &def.Palette)
+		r.CheckCompanyColourBleed(i, spriteInfos[i].ShaderOutput, spriteInfos[i].SpriteBounds, &def.Palette)
+		r.CheckBanding(i, spriteInfos[i].ShaderOutput, spriteInfos[i].SpriteBounds)
 
+		if !def.Manifest.Animated {
+			r.CheckAnimatedPixels(i, spriteInfos[i].ShaderOutput, spriteInfos[i].SpriteBounds, &def.Palette)
+		}
+	}
+
+	r.CheckCompanyColourCoverage()
 	return
 }
 
+// Add a message to the sprite's log. Each sprite is only rendered by one goroutine at a
+// time, so this needs no locking.
+func (s *SpriteInfo) logf(format string, args ...interface{}) {
+	s.Log = append(s.Log, fmt.Sprintf(format, args...))
+}
+
 func getDebugSheets(sheets *Spritesheets, def manifest.Definition, bounds image.Rectangle, spriteInfos []SpriteInfo) {
-	debugOutputs := []string{"lighting", "depth", "normals", "occlusion", "shadow", "avg_normals", "detail", "transparency", "region"}
-	var wg sync.WaitGroup
-	wg.Add(len(debugOutputs) + 1)
+	debugOutputs := []string{"lighting", "depth", "normals", "occlusion", "shadow", "avg_normals", "detail", "transparency", "region", "samples"}
 
 	for _, s := range debugOutputs {
-		thisS := s
-		go func() {
-			defer wg.Done()
-			sheets.Store(thisS, Spritesheet{Image: get32bppSpritesheetImage(def, bounds, spriteInfos, thisS)})
-		}()
+		sheets.Store(s, get32bppSpritesheet(def, bounds, inStrip(spriteInfos), s))
 	}
 
-	go func() {
-		defer wg.Done()
-		smp := sampler.Get(def.Manifest.Sampler)(1, 1, def.Manifest.Accuracy, def.Manifest.Overlap, 0.5+def.Manifest.Falloff)
-		sheets.Store("sampler", Spritesheet{Image: smp.GetImage()})
-	}()
-
-	wg.Wait()
+	smp := sampler.Get(def.Manifest.Sampler)(1, 1, def.Manifest.Accuracy, def.Manifest.Overlap, 0.5+def.Manifest.Falloff)
+	sheets.Store("sampler", Spritesheet{Image: smp.GetImage()})
 }
 
 func getRegularSheets(sheets *Spritesheets, def manifest.Definition, bounds image.Rectangle, spriteInfos []SpriteInfo) {
-	var wg sync.WaitGroup
-	wg.Add(1)
+	sheets.Store("8bpp", get8bppSpritesheet(def, bounds, inStrip(spriteInfos), "8bpp"))
+
 	if !def.Only8bpp {
-		wg.Add(2)
+		sheets.Store("32bpp", get32bppSpritesheet(def, bounds, inStrip(spriteInfos), "32bpp"))
+		sheets.Store("mask", get8bppSpritesheet(def, bounds, inStrip(spriteInfos), "mask"))
 	}
 
-	go func() {
-		defer wg.Done()
-		sheets.Store("8bpp", Spritesheet{Image: get8bppSpritesheetImage(def, bounds, spriteInfos, "8bpp")})
-	}()
+	if def.Manifest.DepthBuffer {
+		sheets.Store("depthbuffer", Spritesheet{Image: newStripImage(bounds, color.Gray16Model, func(strip image.Rectangle) image.Image {
+			return getDepthBufferSpritesheetImage(def, strip, getStripInfos(spriteInfos, strip))
+		})})
+	}
+
+	// The gloss map is for 32bpp blitters, so isn't needed without 32bpp output
+	if def.Manifest.GlossMap && !def.Only8bpp {
+		sheets.Store("gloss", get32bppSpritesheet(def, bounds, inStrip(spriteInfos), "gloss"))
+	}
+
+	for _, kind := range def.Manifest.ColourBlindPreviews {
+		sheets.Store(kind, getColourBlindSpritesheet(def, bounds, spriteInfos, kind))
+	}
+
+	for _, l := range def.Manifest.Layers {
+		getLayerSheets(sheets, def, bounds, spriteInfos, l)
+	}
 
+	if def.Manifest.DropShadow {
+		sheets.Store("dropshadow_8bpp", get8bppSpritesheet(def, bounds, inStrip(spriteInfos), "dropshadow"))
+		if !def.Only8bpp {
+			sheets.Store("dropshadow_32bpp", get32bppSpritesheet(def, bounds, inStrip(spriteInfos), "dropshadow"))
+		}
+	}
+}
+
+func getLayerSheets(sheets *Spritesheets, def manifest.Definition, bounds image.Rectangle, spriteInfos []SpriteInfo, layer manifest.Layer) {
+	// Only the rows of the strip being drawn are copied for the layer
+	layerInStrip := func(strip image.Rectangle) []SpriteInfo {
+		layerInfos := getStripInfos(spriteInfos, strip)
+		for i, info := range layerInfos {
+			layerInfos[i].ShaderOutput = sprite.GetLayerOutput(info.ShaderOutput, layer)
+		}
+		return layerInfos
+	}
+
+	sheets.Store(layer.Name+"_8bpp", get8bppSpritesheet(def, bounds, layerInStrip, "8bpp"))
 	if !def.Only8bpp {
-		go func() {
-			defer wg.Done()
-			sheets.Store("32bpp", Spritesheet{Image: get32bppSpritesheetImage(def, bounds, spriteInfos, "32bpp")})
-		}()
-		go func() {
-			defer wg.Done()
-			sheets.Store("mask", Spritesheet{Image: get8bppSpritesheetImage(def, bounds, spriteInfos, "mask")})
-		}()
+		sheets.Store(layer.Name+"_32bpp", get32bppSpritesheet(def, bounds, layerInStrip, "32bpp"))
+		sheets.Store(layer.Name+"_mask", get8bppSpritesheet(def, bounds, layerInStrip, "mask"))
 	}
+}
 
-	wg.Wait()
+// Get the 8bpp spritesheet as it would be seen with a type of colour blindness, by drawing
+// it with a simulated palette
+func getColourBlindSpritesheet(def manifest.Definition, bounds image.Rectangle, spriteInfos []SpriteInfo, kind string) Spritesheet {
+	palette := def.Palette.GetColourBlindPalette(kind)
+	return Spritesheet{Image: newStripImage(bounds, palette, func(strip image.Rectangle) image.Image {
+		img := get8bppSpritesheetImage(def, strip, getStripInfos(spriteInfos, strip), "8bpp").(*image.Paletted)
+		img.Palette = palette
+		return img
+	})}
 }
 
-func raycast(def manifest.Definition, spriteInfos []SpriteInfo) {
-	renderOutputs := make([]raycaster.RenderOutput, len(def.Manifest.Sprites))
+func get8bppSpritesheet(def manifest.Definition, bounds image.Rectangle, getInfos func(strip image.Rectangle) []SpriteInfo, depth string) Spritesheet {
+	return Spritesheet{Image: newStripImage(bounds, def.Palette.GetGoPalette(), func(strip image.Rectangle) image.Image {
+		return get8bppSpritesheetImage(def, strip, getInfos(strip), depth)
+	})}
+}
+
+func get32bppSpritesheet(def manifest.Definition, bounds image.Rectangle, getInfos func(strip image.Rectangle) []SpriteInfo, depth string) Spritesheet {
+	return Spritesheet{Image: newStripImage(bounds, color.RGBAModel, func(strip image.Rectangle) image.Image {
+		return get32bppSpritesheetImage(def, strip, getInfos(strip), depth)
+	})}
+}
+
+func raycast(def manifest.Definition, spriteInfos []SpriteInfo) *GBuffer {
+	gbuffer := GBuffer{Sprites: make([]GBufferSprite, len(def.Manifest.Sprites))}
 
-	timingutils.Time("Raycasting", def.Time, func() {
+	mirrorSources := getMirrorSources(def)
+
+	def.Timings.Time("Raycasting", def.Time, func() {
 		for i, spr := range def.Manifest.Sprites {
-			rect := getSpriteSizeForAngle(spr, def.Scale)
+			rect := getSpriteSizeForAngle(spr, getSupersampledDefinition(def, spr).Scale)
+
+			smp, coarse := getSamples(def, rect)
+			object := getSpriteObject(def, spr)
+
+			// Mirrored sprites are filled in once the sprites they are mirrored from are complete
+			if mirrorSources[i] == -1 {
+				ms := timingutils.Time("", false, func() {
+					gbuffer.Sprites[i].Output = getRaycastOutput(&raycaster.OutputBuffer{}, def, object, spr, smp, coarse)
+				})
+				spriteInfos[i].logf("raycast %dx%d pixels in %d ms", rect.Max.X, rect.Max.Y, ms)
+			}
+
+			if def.Manifest.DropShadow {
+				ms := timingutils.Time("", false, func() {
+					gbuffer.Sprites[i].Shadow = raycaster.GetShadowOutput(object, def.Manifest, spr, smp)
+				})
+				spriteInfos[i].logf("drop shadow cast in %d ms", ms)
+			}
+		}
+
+		for i, source := range mirrorSources {
+			if source == -1 {
+				continue
+			}
+
+			spr := def.Manifest.Sprites[i]
+			rect := getSpriteSizeForAngle(spr, getSupersampledDefinition(def, spr).Scale)
+			smp, coarse := getSamples(def, rect)
+			object := getSpriteObject(def, spr)
+
+			// Samples which aren't symmetric have nothing to mirror them from, so are raycast
+			var ok bool
+			if gbuffer.Sprites[i].Output, ok = raycaster.Mirror(gbuffer.Sprites[source].Output, object, def.Manifest, spr, smp); ok {
+				spriteInfos[i].logf("mirrored from sprite %d", source)
+				continue
+			}
+
+			ms := timingutils.Time("", false, func() {
+				gbuffer.Sprites[i].Output = getRaycastOutput(&raycaster.OutputBuffer{}, def, object, spr, smp, coarse)
+			})
+			spriteInfos[i].logf("raycast %dx%d pixels in %d ms", rect.Max.X, rect.Max.Y, ms)
+		}
+	})
+
+	return &gbuffer
+}
+
+// The accuracy of the first pass when raycasting with adaptive accuracy
+const adaptiveCoarseAccuracy = 2
+
+// Get the samples for a sprite. With adaptive accuracy the coarse samples for the first
+// pass are also returned, unless the accuracy is no higher than the first pass would be.
+func getSamples(def manifest.Definition, rect image.Rectangle) (smp, coarse sampler.Samples) {
+	smpFunc := sampler.Get(def.Manifest.Sampler)
+	smp = smpFunc(rect.Max.X, rect.Max.Y, def.Manifest.Accuracy, def.Manifest.Overlap, 0.5+def.Manifest.Falloff)
+
+	if def.Manifest.AdaptiveThreshold > 0 && def.Manifest.Accuracy > adaptiveCoarseAccuracy {
+		coarse = smpFunc(rect.Max.X, rect.Max.Y, adaptiveCoarseAccuracy, def.Manifest.Overlap, 0.5+def.Manifest.Falloff)
+	}
+
+	return
+}
+
+func getRaycastOutput(buffer *raycaster.OutputBuffer, def manifest.Definition, object voxelobject.ProcessedVoxelObject, spr manifest.Sprite, smp, coarse sampler.Samples) raycaster.RenderOutput {
+	if coarse != nil {
+		return buffer.GetAdaptiveRaycastOutput(object, def.Manifest, spr, coarse, smp, def.Manifest.AdaptiveThreshold)
+	}
+
+	return buffer.GetRaycastOutput(object, def.Manifest, spr, smp)
+}
+
+func getSpriteObject(def manifest.Definition, spr manifest.Sprite) voxelobject.ProcessedVoxelObject {
+	if spr.Slope != 0 {
+		return def.SlopedObjects[raycaster.GetSlopedObjectKey(spr, def.Manifest, def.Object.Size)]
+	}
+
+	if spr.HasOwnObject() {
+		return def.SpriteObjects[spr.ObjectKey()]
+	}
+
+	return def.Object
+}
+
+// For symmetric objects, get the index of the sprite at the opposite angle for each sprite
+// between 180 and 360 degrees, or -1 if the sprite must be raycast
+func getMirrorSources(def manifest.Definition) (sources []int) {
+	sources = make([]int, len(def.Manifest.Sprites))
 
-			smpFunc := sampler.Get(def.Manifest.Sampler)
-			smp := smpFunc(rect.Max.X, rect.Max.Y, def.Manifest.Accuracy, def.Manifest.Overlap, 0.5+def.Manifest.Falloff)
+	for i, spr := range def.Manifest.Sprites {
+		sources[i] = -1
+		if !def.Manifest.Symmetric || !canMirrorRaycast(def) || spr.Angle <= 180 || spr.Angle >= 360 {
+			continue
+		}
 
-			spriteInfos[i].SpriteBounds = rect
-			renderOutputs[i] = raycaster.GetRaycastOutput(def.Object, def.Manifest, spr, smp)
+		for j, source := range def.Manifest.Sprites {
+			if source.Angle == raycaster.GetMirrorAngle(spr.Angle) && canMirror(source, spr) {
+				sources[i] = j
+				break
+			}
+		}
+	}
+
+	return
+}
+
+// Check the raycast output of sprites can be mirrored. Adaptive accuracy only casts more
+// samples where the first pass found detail, which isn't symmetric.
+func canMirrorRaycast(def manifest.Definition) bool {
+	return raycaster.CanMirror(def.Manifest) && !(def.Manifest.AdaptiveThreshold > 0 && def.Manifest.Accuracy > adaptiveCoarseAccuracy)
+}
+
+// Check two sprites differ only by angle and offset, so one can be mirrored from the other.
+// Sloped sprites use a different object at each angle, so can't be mirrored.
+func canMirror(a, b manifest.Sprite) bool {
+	return a.Slope == 0 && b.Slope == 0 && a.Width == b.Width && a.Height == b.Height && a.CanvasWidth == b.CanvasWidth && a.CanvasHeight == b.CanvasHeight && math.Abs(a.ZError-b.ZError) < 1e-9 && a.Flip == b.Flip &&
+		a.Slice == b.Slice && a.RenderElevationAngle == b.RenderElevationAngle && a.Joggle == b.Joggle && a.Zoom == b.Zoom &&
+		a.Type == b.Type && a.Supersample == b.Supersample && a.ObjectKey() == b.ObjectKey()
+}
+
+func relight(def manifest.Definition, gbuffer *GBuffer) {
+	def.Timings.Time("Relighting", def.Time, func() {
+		for i, spr := range def.Manifest.Sprites {
+			raycaster.Relight(gbuffer.Sprites[i].Output, def.Manifest, spr)
 		}
 	})
+}
+
+func shade(def manifest.Definition, gbuffer *GBuffer, spriteInfos []SpriteInfo, onSprite SpriteFunc) {
+	def.Timings.Time("Sampling", def.Time, func() {
+		// Region analysis and dithering are independent between sprites
+		var wg sync.WaitGroup
+		wg.Add(len(def.Manifest.Sprites))
 
-	timingutils.Time("Sampling", def.Time, func() {
 		for i, spr := range def.Manifest.Sprites {
-			rect := getSpriteSizeForAngle(spr, def.Scale)
-			spriteInfos[i].ShaderOutput = sprite.GetShaderOutput(renderOutputs[i], spr, &def, rect.Max.X, rect.Max.Y)
+			thisI, thisSpr := i, spr
+			go func() {
+				defer wg.Done()
+				sprDef := getSupersampledDefinition(def, thisSpr)
+				rect := getSpriteSizeForAngle(thisSpr, sprDef.Scale)
+				ms := timingutils.Time("", false, func() {
+					spriteInfos[thisI].SpriteBounds = rect
+					spriteInfos[thisI].Shadow = sprite.GetSpriteShadow(gbuffer.Sprites[thisI].Shadow, thisSpr, sprDef.Scale)
+					spriteInfos[thisI].ShaderOutput = sprite.GetShaderOutput(gbuffer.Sprites[thisI].Output, thisSpr, &sprDef, rect.Max.X, rect.Max.Y)
+					reduceSupersampled(def, thisSpr, &spriteInfos[thisI])
+				})
+				spriteInfos[thisI].logf("shaded in %d ms", ms)
+
+				if onSprite != nil {
+					onSprite(thisI, getSpriteImages(def, thisSpr, spriteInfos[thisI]))
+				}
+			}()
 		}
+
+		wg.Wait()
 	})
 }
 
+// Draw the 8bpp, 32bpp and mask images of a single shaded sprite
+func getSpriteImages(def manifest.Definition, spr manifest.Sprite, info SpriteInfo) map[string]image.Image {
+	rect := getSpriteSizeForAngle(spr, def.Scale)
+	images := make(map[string]image.Image)
+	palette := def.Palette.GetGoPalette()
+
+	for _, depth := range []string{"8bpp", "mask"} {
+		img := image.NewPaletted(rect, palette)
+		imageutils.ClearToColourIndex(img, byte(len(palette)-1))
+		applySprite8bpp(img, def, info, image.Point{}, depth)
+		images[depth] = img
+	}
+
+	img := imageutils.GetUniformImage(rect, color.White)
+	applySprite32bpp(img, def, info, image.Point{}, "32bpp")
+	images["32bpp"] = img
+
+	return images
+}
+
+// Draw the sprites within bounds, which may be a strip of rows of the spritesheet. The sprite
+// infos must already be cut to the same rows with getStripInfos.
 func get8bppSpritesheetImage(def manifest.Definition, bounds image.Rectangle, spriteInfos []SpriteInfo, depth string) image.Image {
 	palette := def.Palette.GetGoPalette()
 	img := image.NewPaletted(bounds, palette)
 	imageutils.ClearToColourIndex(img, byte(len(palette)-1))
 
 	for i := 0; i < len(def.Manifest.Sprites); i++ {
-		loc := image.Point{X: def.Manifest.Sprites[i].X}
-		applySprite8bpp(img, spriteInfos[i], loc, depth)
+		loc := image.Point{X: def.Manifest.Sprites[i].X, Y: bounds.Min.Y}
+		applySprite8bpp(img, def, spriteInfos[i], loc, depth)
 	}
 
 	return img
@@ -149,16 +450,33 @@ func get32bppSpritesheetImage(def manifest.Definition, bounds image.Rectangle, s
 	img := imageutils.GetUniformImage(bounds, color.White)
 
 	for i := 0; i < len(def.Manifest.Sprites); i++ {
-		loc := image.Point{X: def.Manifest.Sprites[i].X}
+		loc := image.Point{X: def.Manifest.Sprites[i].X, Y: bounds.Min.Y}
 		applySprite32bpp(img, def, spriteInfos[i], loc, depth)
 	}
 
 	return img
 }
 
-func applySprite8bpp(img *image.Paletted, spriteInfo SpriteInfo, loc image.Point, depth string) {
-	if depth == "8bpp" {
+func getDepthBufferSpritesheetImage(def manifest.Definition, bounds image.Rectangle, spriteInfos []SpriteInfo) image.Image {
+	img := image.NewGray16(bounds)
+	draw.Draw(img, bounds, image.NewUniform(color.Gray16{Y: math.MaxUint16}), image.Point{}, draw.Src)
+
+	for i := 0; i < len(def.Manifest.Sprites); i++ {
+		loc := image.Point{X: def.Manifest.Sprites[i].X, Y: bounds.Min.Y}
+		sprite.ApplyDepthBufferSprite(img, spriteInfos[i].SpriteBounds, loc, spriteInfos[i].ShaderOutput)
+	}
+
+	return img
+}
+
+func applySprite8bpp(img *image.Paletted, def manifest.Definition, spriteInfo SpriteInfo, loc image.Point, depth string) {
+	if depth == "dropshadow" {
+		sprite.ApplyIndexedShadowSprite(img, spriteInfo.SpriteBounds, loc, spriteInfo.Shadow, def.Manifest.DropShadowIndex, def.Manifest.EdgeThreshold)
+	} else if depth == "8bpp" {
 		sprite.ApplyIndexedSprite(img, spriteInfo.SpriteBounds, loc, spriteInfo.ShaderOutput, sprite.GetIndex)
+		if def.Manifest.Backdrop != 0 {
+			sprite.ApplyIndexedBackdrop(img, spriteInfo.SpriteBounds, loc, def.Manifest.Backdrop)
+		}
 	} else if depth == "mask" {
 		sprite.ApplyIndexedSprite(img, spriteInfo.SpriteBounds, loc, spriteInfo.ShaderOutput, sprite.GetMaskIndex)
 	}
@@ -169,6 +487,8 @@ func applySprite8bpp(img *image.Paletted, spriteInfo SpriteInfo, loc image.Point
 func applySprite32bpp(img *image.RGBA, def manifest.Definition, spriteInfo SpriteInfo, loc image.Point, depth string) {
 	if def.Object.Invalid() {
 		sprite.ApplyUniformSprite(img, spriteInfo.SpriteBounds, loc)
+	} else if depth == "dropshadow" {
+		sprite.ApplyShadowSprite32bpp(img, spriteInfo.SpriteBounds, loc, spriteInfo.Shadow)
 	} else if depth == "lighting" {
 		sprite.Apply32bppSprite(img, spriteInfo.SpriteBounds, loc, spriteInfo.ShaderOutput, sprite.GetLighting)
 	} else if depth == "depth" {
@@ -183,34 +503,70 @@ func applySprite32bpp(img *image.RGBA, def manifest.Definition, spriteInfo Sprit
 		sprite.Apply32bppSprite(img, spriteInfo.SpriteBounds, loc, spriteInfo.ShaderOutput, sprite.GetAveragedNormal)
 	} else if depth == "detail" {
 		sprite.Apply32bppSprite(img, spriteInfo.SpriteBounds, loc, spriteInfo.ShaderOutput, sprite.GetDetail)
+	} else if depth == "gloss" {
+		sprite.Apply32bppSprite(img, spriteInfo.SpriteBounds, loc, spriteInfo.ShaderOutput, sprite.GetGloss)
 	} else if depth == "transparency" {
 		sprite.Apply32bppSprite(img, spriteInfo.SpriteBounds, loc, spriteInfo.ShaderOutput, sprite.GetTransparency)
 	} else if depth == "region" {
-		sprite.Apply32bppSprite(img, spriteInfo.SpriteBounds, loc, spriteInfo.ShaderOutput, sprite.GetRegion)
+		sprite.ApplyRegionSprite(img, spriteInfo.SpriteBounds, loc, spriteInfo.ShaderOutput)
+	} else if depth == "samples" {
+		// Show the heatmap for transparent pixels too, as these are the ones rejected by the hard edge threshold
+		sprite.ApplyOpaque32bppSprite(img, spriteInfo.SpriteBounds, loc, spriteInfo.ShaderOutput, sprite.GetSampleHeatmap)
 	} else {
 		sprite.Apply32bppSprite(img, spriteInfo.SpriteBounds, loc, spriteInfo.ShaderOutput, sprite.GetColour)
+		if backdrop := def.Manifest.Backdrop; backdrop != 0 && int(backdrop) < len(def.Palette.Entries) {
+			e := def.Palette.Entries[backdrop]
+			sprite.Apply32bppBackdrop(img, spriteInfo.SpriteBounds, loc, color.RGBA{R: e.R, G: e.G, B: e.B, A: 255})
+		}
 	}
 
 	return
 }
 
 func (s Spritesheet) OutputToWriter(w io.Writer) (err error) {
-	err = png.Encode(w, s.Image)
+	switch s.Format {
+	case "tga":
+		err = imageutils.EncodeTGA(w, s.Image)
+	case "bmp":
+		err = imageutils.EncodeBMP(w, s.Image)
+	case "webp":
+		err = imageutils.EncodeWebP(w, s.Image)
+	default:
+		err = png.Encode(w, s.Image)
+	}
+
 	return
 }
 
 func (sheets *Spritesheets) Store(key string, s Spritesheet) {
+	s.Format = sheets.template.GetFormat(key)
+
 	sheets.Lock()
 	sheets.Data[key] = s
 	sheets.Unlock()
 }
 
+// Hand the shaded sprites back for reuse by later renders, once the spritesheets have been
+// written. The spritesheets can't be encoded afterwards.
+func (sheets *Spritesheets) Release() {
+	for _, info := range sheets.spriteInfos {
+		sprite.ReleaseShaderOutput(info.ShaderOutput)
+	}
+
+	sheets.spriteInfos = nil
+}
+
+// Get the file name a spritesheet is saved to by SaveAll
+func (sheets *Spritesheets) GetFilename(baseFilename string, key string) string {
+	return sheets.template.GetFilename(baseFilename, key)
+}
+
 func (sheets *Spritesheets) SaveAll(baseFilename string) (err error) {
 	var wg sync.WaitGroup
 	wg.Add(len(sheets.Data))
 
 	for i, sheet := range sheets.Data {
-		filename := baseFilename + "_" + i + ".png"
+		filename := sheets.GetFilename(baseFilename, i)
 		thisSheet := sheet
 		go func() { _ = fileutils.WriteToFile(filename, thisSheet); wg.Done() }()
 	}
@@ -220,6 +576,7 @@ func (sheets *Spritesheets) SaveAll(baseFilename string) (err error) {
 }
 
 func getSpriteSizeForAngle(sprite manifest.Sprite, scale float64) image.Rectangle {
-	fx, fy := float64(sprite.Width), float64(sprite.Height)
+	width, height := sprite.GetCanvasSize()
+	fx, fy := float64(width), float64(height)
 	return image.Rectangle{Max: image.Point{X: int(fx * scale), Y: int(fy * scale)}}
 }
@@ -1,6 +1,8 @@
 package spritesheet
 
 import (
+	"bytes"
+	gandalfgeo "github.com/mattkimber/gandalf/geometry"
 	"github.com/mattkimber/gandalf/magica"
 	"github.com/mattkimber/gorender/internal/colour"
 	"github.com/mattkimber/gorender/internal/geometry"
@@ -12,6 +14,8 @@ import (
 	"github.com/mattkimber/gorender/internal/voxelobject"
 	"image"
 	"os"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -38,6 +42,168 @@ func TestGetSpritesheets(t *testing.T) {
 	testSpritesheet(t, &sheets, "mask")
 }
 
+func TestSpritesheets_Release(t *testing.T) {
+	def := getTestCubeDefinition(t)
+
+	sheets := GetSpritesheets(def)
+	expected := getPNG(t, sheets.Data["32bpp"])
+	sheets.Release()
+
+	// Rendering again reuses the released buffers
+	sheets = GetSpritesheets(def)
+	if !bytes.Equal(expected, getPNG(t, sheets.Data["32bpp"])) {
+		t.Errorf("output differs when rendering with reused buffers")
+	}
+}
+
+func TestGetSpritesheets_FlipHorizontal(t *testing.T) {
+	def := getTestCubeDefinition(t)
+	def.Manifest.DropShadow = true
+	def.Manifest.Sprites = []manifest.Sprite{
+		{Angle: 45, Width: 32, Height: 32, OffsetX: -2},
+		{Angle: 45, Width: 32, Height: 32, OffsetX: 2, FlipHorizontal: true},
+	}
+
+	// A flipped sprite is an exact mirror of the unflipped sprite, with the offset
+	// applied in the direction seen in the output
+	sheets := GetSpritesheets(def)
+	for _, key := range []string{"8bpp", "32bpp", "dropshadow_8bpp"} {
+		img := sheets.Data[key].Image
+		a, b := sheets.Layout[0], sheets.Layout[1]
+
+		for x := 0; x < a.Width; x++ {
+			for y := 0; y < a.Height; y++ {
+				if expected, result := img.At(a.X+a.Width-1-x, y), img.At(b.X+x, y); expected != result {
+					t.Fatalf("%s: flipped pixel at %d,%d expected %v, got %v", key, x, y, expected, result)
+				}
+			}
+		}
+	}
+}
+
+func TestGetSpritesheets_Log(t *testing.T) {
+	def := getTestCubeDefinition(t)
+	def.Manifest.Symmetric = true
+	def.Manifest.Sprites = []manifest.Sprite{
+		{Angle: 45, Width: 32, Height: 32},
+		{Angle: 315, Width: 32, Height: 32},
+	}
+
+	sheets := GetSpritesheets(def)
+
+	expected := [][]string{{"raycast 32x32 pixels in", "shaded in"}, {"mirrored from sprite 0", "shaded in"}}
+	for i, messages := range expected {
+		log := sheets.Report.Sprites[i].Log
+		if len(log) != len(messages) {
+			t.Fatalf("sprite %d expected %d log messages, got %v", i, len(messages), log)
+		}
+
+		for j, message := range messages {
+			if !strings.HasPrefix(log[j], message) {
+				t.Errorf("sprite %d log message %d expected to start %q, got %q", i, j, message, log[j])
+			}
+		}
+	}
+}
+
+func TestGetSpritesheets_Mirror(t *testing.T) {
+	// A body with a cab and a post on each side, symmetric in Y, so the posts and cab
+	// shadow the body differently on each side
+	v := magica.VoxelObject{Size: gandalfgeo.Point{X: 24, Y: 12, Z: 12}}
+	v.Voxels = make([][][]byte, v.Size.X)
+	for x := range v.Voxels {
+		v.Voxels[x] = make([][]byte, v.Size.Y)
+		for y := range v.Voxels[x] {
+			v.Voxels[x][y] = make([]byte, v.Size.Z)
+			for z := range v.Voxels[x][y] {
+				body := x >= 2 && x < 22 && y >= 2 && y < 10 && z < 5
+				cab := x >= 14 && x < 20 && y >= 3 && y < 9 && z < 10
+				post := (x == 5 || x == 6) && (y == 1 || y == 10) && z < 9
+				if body || cab || post {
+					v.Voxels[x][y][z] = byte(72 + min(y, 11-y)%3)
+				}
+			}
+		}
+	}
+
+	def := getTestCubeDefinition(t)
+	def.Object = voxelobject.GetProcessedVoxelObject(v, &def.Palette, false, "normal", false, false)
+	def.Manifest.Size = def.Object.Size.ToVector3()
+	def.Manifest.RenderElevationAngle = 30
+	def.Manifest.LightingAngle, def.Manifest.LightingElevation = 60, 30
+	def.Manifest.Noise = 1
+	def.Manifest.Sprites = []manifest.Sprite{
+		{Angle: 45, Width: 40, Height: 40, X: 0},
+		{Angle: 135, Width: 40, Height: 40, X: 50},
+		{Angle: 225, Width: 40, Height: 40, X: 100},
+		{Angle: 315, Width: 40, Height: 40, X: 150},
+	}
+	def.Manifest.SetSpriteSizes()
+
+	expected := GetSpritesheets(def)
+	def.Manifest.Symmetric = true
+	result := GetSpritesheets(def)
+
+	for i := 2; i < 4; i++ {
+		if log := result.Report.Sprites[i].Log; !strings.HasPrefix(log[0], "mirrored") {
+			t.Fatalf("expected sprite %d to be mirrored, got %v", i, log)
+		}
+	}
+
+	// Mirrored sprites are identical to raycast ones
+	for _, key := range []string{"8bpp", "32bpp"} {
+		img, expectedImg := result.Data[key].Image, expected.Data[key].Image
+		differences := 0
+		for x := img.Bounds().Min.X; x < img.Bounds().Max.X; x++ {
+			for y := img.Bounds().Min.Y; y < img.Bounds().Max.Y; y++ {
+				if img.At(x, y) != expectedImg.At(x, y) {
+					differences++
+				}
+			}
+		}
+
+		if differences != 0 {
+			t.Errorf("%s: expected mirrored sprites to match raycast sprites, %d pixels differ", key, differences)
+		}
+	}
+}
+
+func TestGetStreamedSpritesheets(t *testing.T) {
+	def := getTestCubeDefinition(t)
+	def.Manifest.Symmetric = true
+	def.Manifest.Sprites = append(def.Manifest.Sprites, manifest.Sprite{Angle: 225, Width: 32, Height: 32})
+
+	var mutex sync.Mutex
+	streamed := make(map[int]map[string]image.Image)
+	sheets := GetStreamedSpritesheets(def, func(index int, images map[string]image.Image) {
+		mutex.Lock()
+		streamed[index] = images
+		mutex.Unlock()
+	})
+
+	if len(streamed) != len(def.Manifest.Sprites) {
+		t.Fatalf("expected %d sprites, got %d", len(def.Manifest.Sprites), len(streamed))
+	}
+
+	// Each sprite's images are the same as the sprite on the spritesheet
+	for i, spr := range sheets.Layout {
+		for _, key := range []string{"8bpp", "32bpp", "mask"} {
+			img, sheet := streamed[i][key], sheets.Data[key].Image
+			if size := img.Bounds().Size(); size.X != spr.Width || size.Y != spr.Height {
+				t.Fatalf("sprite %d %s expected size %dx%d, got %dx%d", i, key, spr.Width, spr.Height, size.X, size.Y)
+			}
+
+			for x := 0; x < spr.Width; x++ {
+				for y := 0; y < spr.Height; y++ {
+					if expected, result := sheet.At(spr.X+x, spr.Y+y), img.At(x, y); expected != result {
+						t.Fatalf("sprite %d %s pixel at %d,%d expected %v, got %v", i, key, x, y, expected, result)
+					}
+				}
+			}
+		}
+	}
+}
+
 func testSpritesheet(t *testing.T, sheets *Spritesheets, bpp string) {
 	sheet, ok := sheets.Data[bpp]
 
@@ -108,7 +274,7 @@ func benchmarkSpritesheet(b *testing.B, spritesheetImage func(def manifest.Defin
 	}
 }
 
-func getPalette(b *testing.B) colour.Palette {
+func getPalette(b testing.TB) colour.Palette {
 	pFile, err := os.Open("../../files/ttd_palette.json")
 	if err != nil {
 		b.Fatalf("Could nopt open palette file: %v", err)
@@ -123,12 +289,39 @@ func getPalette(b *testing.B) colour.Palette {
 	return palette
 }
 
+func getTestCubeDefinition(t *testing.T) manifest.Definition {
+	palette := getPalette(t)
+	mv, err := magica.FromFile("../raycaster/testdata/testcube")
+	if err != nil {
+		t.Fatalf("error loading test file: %v", err)
+	}
+	object := voxelobject.GetProcessedVoxelObject(mv, &palette, false, "normal", false, false)
+
+	return manifest.Definition{
+		Object:  object,
+		Palette: palette,
+		Scale:   1.0,
+		Manifest: manifest.Manifest{
+			LightingAngle:     45,
+			LightingElevation: 60,
+			Size:              object.Size.ToVector3(),
+			Accuracy:          2,
+			Brightness:        1.0,
+			Contrast:          1.0,
+			Sprites: []manifest.Sprite{
+				{Angle: 0, Width: 32, Height: 32, X: 0},
+				{Angle: 45, Width: 32, Height: 32, X: 40, OffsetY: 2},
+			},
+		},
+	}
+}
+
 func getObjectForBenchmark(filename string, b *testing.B) voxelobject.ProcessedVoxelObject {
 	mv, err := magica.FromFile("../raycaster/testdata/" + filename)
 	if err != nil {
 		b.Fatalf("error loading test file: %v", err)
 	}
 
-	v := voxelobject.GetProcessedVoxelObject(mv, &colour.Palette{}, false, "normal", false)
+	v := voxelobject.GetProcessedVoxelObject(mv, &colour.Palette{}, false, "normal", false, false)
 	return v
 }
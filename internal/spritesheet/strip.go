@@ -0,0 +1,100 @@
+package spritesheet
+
+import (
+	"github.com/mattkimber/gorender/internal/sprite"
+	"image"
+	"image/color"
+)
+
+// The number of rows of a spritesheet drawn at once
+const stripHeight = 64
+
+// A spritesheet image which is drawn a strip of rows at a time as it is read, so the
+// whole image is never held in memory. The PNG encoder reads rows from top to bottom,
+// so each strip is drawn once when encoding. Not safe for concurrent use.
+type stripImage struct {
+	bounds     image.Rectangle
+	colorModel color.Model
+	draw       func(strip image.Rectangle) image.Image
+	strip      image.Image
+}
+
+func newStripImage(bounds image.Rectangle, colorModel color.Model, draw func(strip image.Rectangle) image.Image) *stripImage {
+	return &stripImage{bounds: bounds, colorModel: colorModel, draw: draw}
+}
+
+func (s *stripImage) ColorModel() color.Model {
+	return s.colorModel
+}
+
+func (s *stripImage) Bounds() image.Rectangle {
+	return s.bounds
+}
+
+func (s *stripImage) At(x, y int) color.Color {
+	return s.getStrip(y).At(x, y)
+}
+
+// Paletted images need this to be encoded as paletted PNGs
+func (s *stripImage) ColorIndexAt(x, y int) uint8 {
+	return s.getStrip(y).(image.PalettedImage).ColorIndexAt(x, y)
+}
+
+// Check each strip in turn, as otherwise the PNG encoder checks every pixel
+func (s *stripImage) Opaque() bool {
+	for y := s.bounds.Min.Y; y < s.bounds.Max.Y; y += stripHeight {
+		if o, ok := s.getStrip(y).(interface{ Opaque() bool }); !ok || !o.Opaque() {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (s *stripImage) getStrip(y int) image.Image {
+	y = max(s.bounds.Min.Y, min(y, s.bounds.Max.Y-1))
+
+	if s.strip == nil || y < s.strip.Bounds().Min.Y || y >= s.strip.Bounds().Max.Y {
+		minY := s.bounds.Min.Y + (y-s.bounds.Min.Y)/stripHeight*stripHeight
+		s.strip = s.draw(image.Rect(s.bounds.Min.X, minY, s.bounds.Max.X, min(minY+stripHeight, s.bounds.Max.Y)))
+	}
+
+	return s.strip
+}
+
+// Get a function cutting the sprite infos to a strip of rows
+func inStrip(spriteInfos []SpriteInfo) func(strip image.Rectangle) []SpriteInfo {
+	return func(strip image.Rectangle) []SpriteInfo {
+		return getStripInfos(spriteInfos, strip)
+	}
+}
+
+// Cut the sprite infos to the rows of a strip of the spritesheet. Sprites are placed at
+// the top of the spritesheet, so sprite and spritesheet rows are the same. The shader
+// output is shared rather than copied.
+func getStripInfos(spriteInfos []SpriteInfo, strip image.Rectangle) []SpriteInfo {
+	result := make([]SpriteInfo, len(spriteInfos))
+
+	for i, info := range spriteInfos {
+		minY, maxY := max(strip.Min.Y, info.SpriteBounds.Min.Y), min(strip.Max.Y, info.SpriteBounds.Max.Y)
+		if minY >= maxY {
+			continue
+		}
+
+		result[i].SpriteBounds = image.Rect(info.SpriteBounds.Min.X, 0, info.SpriteBounds.Max.X, maxY-minY)
+
+		result[i].ShaderOutput = make(sprite.ShaderOutput, len(info.ShaderOutput))
+		for x := range info.ShaderOutput {
+			result[i].ShaderOutput[x] = info.ShaderOutput[x][minY:maxY]
+		}
+
+		if info.Shadow != nil {
+			result[i].Shadow = make([][]float64, len(info.Shadow))
+			for x := range info.Shadow {
+				result[i].Shadow[x] = info.Shadow[x][minY:maxY]
+			}
+		}
+	}
+
+	return result
+}
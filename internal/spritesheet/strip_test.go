@@ -0,0 +1,55 @@
+package spritesheet
+
+import (
+	"bytes"
+	"github.com/mattkimber/gorender/internal/colour"
+	"github.com/mattkimber/gorender/internal/manifest"
+	"github.com/mattkimber/gorender/internal/sprite"
+	"image"
+	"image/png"
+	"testing"
+)
+
+func TestStripImage(t *testing.T) {
+	def := manifest.Definition{Manifest: manifest.Manifest{Sprites: []manifest.Sprite{{X: 0}, {X: 20}}}}
+	for i := 0; i < 8; i++ {
+		def.Palette.Entries = append(def.Palette.Entries, colour.PaletteEntry{R: byte(i * 30), G: 255, B: byte(255 - i*30)})
+	}
+
+	// Sprites taller than a strip, ending part-way through different strips
+	spriteInfos := []SpriteInfo{getTestSpriteInfo(16, stripHeight*2+10), getTestSpriteInfo(16, stripHeight-5)}
+	bounds := image.Rect(0, 0, 36, stripHeight*2+10)
+
+	testCases := []struct {
+		name  string
+		full  image.Image
+		strip Spritesheet
+	}{
+		{"8bpp", get8bppSpritesheetImage(def, bounds, spriteInfos, "8bpp"), get8bppSpritesheet(def, bounds, inStrip(spriteInfos), "8bpp")},
+		{"32bpp", get32bppSpritesheetImage(def, bounds, spriteInfos, "32bpp"), get32bppSpritesheet(def, bounds, inStrip(spriteInfos), "32bpp")},
+	}
+
+	for _, testCase := range testCases {
+		expected := bytes.Buffer{}
+		if err := png.Encode(&expected, testCase.full); err != nil {
+			t.Fatalf("%s: could not encode image: %v", testCase.name, err)
+		}
+
+		if !bytes.Equal(expected.Bytes(), getPNG(t, testCase.strip)) {
+			t.Errorf("%s: image drawn in strips does not match image drawn in full", testCase.name)
+		}
+	}
+}
+
+func getTestSpriteInfo(width, height int) SpriteInfo {
+	output := sprite.NewShaderOutput(width, height)
+	for x := range output {
+		for y := range output[x] {
+			output[x][y].DitheredIndex = byte((x+y)%7 + 1)
+			output[x][y].Colour = colour.RGB{R: float64(x * 1000), G: float64(y * 300)}
+			output[x][y].Alpha = float64(y%3) / 2
+		}
+	}
+
+	return SpriteInfo{ShaderOutput: output, SpriteBounds: image.Rect(0, 0, width, height)}
+}
@@ -0,0 +1,29 @@
+package spritesheet
+
+import (
+	"github.com/mattkimber/gorender/internal/manifest"
+	"github.com/mattkimber/gorender/internal/sprite"
+)
+
+// Get the definition a sprite is raycast and shaded with, which is at a larger scale than
+// its output for supersampled sprites
+func getSupersampledDefinition(def manifest.Definition, spr manifest.Sprite) manifest.Definition {
+	def.Scale *= float64(spr.GetSupersample())
+	return def
+}
+
+// Reduce a supersampled sprite's shaded and dithered output, and its drop shadow, to the
+// sprite's size at the definition's scale
+func reduceSupersampled(def manifest.Definition, spr manifest.Sprite, info *SpriteInfo) {
+	factor := spr.GetSupersample()
+	if factor == 1 {
+		return
+	}
+
+	hi, rect := info.ShaderOutput, getSpriteSizeForAngle(spr, def.Scale)
+
+	info.SpriteBounds = rect
+	info.ShaderOutput = sprite.ReduceShaderOutput(hi, factor, rect.Max.X, rect.Max.Y, &def, 0)
+	info.Shadow = sprite.ReduceShadowOutput(info.Shadow, factor, rect.Max.X, rect.Max.Y)
+	sprite.ReleaseShaderOutput(hi)
+}
@@ -0,0 +1,38 @@
+package spritesheet
+
+import (
+	"bytes"
+	"image"
+	"testing"
+)
+
+func TestGetSpritesheets_Supersample(t *testing.T) {
+	def := getTestCubeDefinition(t)
+	def.Manifest.DropShadow = true
+	def.Manifest.Sprites[1].Supersample = 2
+
+	sheets := GetSpritesheets(def)
+	defer sheets.Release()
+
+	expectedRect := image.Rectangle{Max: image.Point{X: 32, Y: 32}}
+	for i, info := range sheets.spriteInfos {
+		if info.SpriteBounds != expectedRect || len(info.ShaderOutput) != 32 || len(info.Shadow) != 32 {
+			t.Errorf("sprite %d: expected size %v, got %v", i, expectedRect, info.SpriteBounds)
+		}
+	}
+
+	if index := sheets.spriteInfos[1].ShaderOutput[16][16].DitheredIndex; index == 0 {
+		t.Errorf("expected the cube at the centre of the supersampled sprite")
+	}
+
+	// One row per band
+	def.MaxMemory = 1
+	tiled := GetSpritesheets(def)
+	defer tiled.Release()
+
+	for _, key := range []string{"8bpp", "32bpp", "mask"} {
+		if !bytes.Equal(getPNG(t, sheets.Data[key]), getPNG(t, tiled.Data[key])) {
+			t.Errorf("%s: tiled supersampled output differs from untiled output", key)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package spritesheet
+
+import (
+	"github.com/mattkimber/gorender/internal/manifest"
+	"github.com/mattkimber/gorender/internal/raycaster"
+	"github.com/mattkimber/gorender/internal/sampler"
+	"github.com/mattkimber/gorender/internal/sprite"
+	"github.com/mattkimber/gorender/internal/utils/timingutils"
+	"sync"
+)
+
+// Raycast and shade sprites one at a time, in bands of rows small enough for the raycast
+// output to fit in def.MaxMemory. Shading a row only needs the raycast output for that
+// row, so each band is discarded once shaded. Region analysis and dithering then run on
+// the whole shaded sprite, so error diffusion carries across the seams between bands.
+func raycastTiled(def manifest.Definition, spriteInfos []SpriteInfo, onSprite SpriteFunc) {
+	def.Timings.Time("Raycasting", def.Time, func() {
+		// Each band is discarded once shaded, so all bands can share one buffer
+		buffer := raycaster.OutputBuffer{}
+
+		for i, spr := range def.Manifest.Sprites {
+			sprDef := getSupersampledDefinition(def, spr)
+			rect := getSpriteSizeForAngle(spr, sprDef.Scale)
+
+			smp, coarse := getSamples(def, rect)
+			object := getSpriteObject(def, spr)
+
+			// Bands are sized for the full samples, which is the most an adaptive raycast can use
+			output := sprite.NewShaderOutput(rect.Max.X, rect.Max.Y)
+			bands := getBands(smp, raycaster.GetAlgorithm(def.Manifest.Raycaster), def.MaxMemory)
+			ms := timingutils.Time("", false, func() {
+				for _, band := range bands {
+					var bandCoarse sampler.Samples
+					if coarse != nil {
+						bandCoarse = coarse.Rows(band[0], band[1])
+					}
+
+					renderOutput := getRaycastOutput(&buffer, def, object, spr, smp.Rows(band[0], band[1]), bandCoarse)
+					sprite.ShadeRows(output, renderOutput, band[0], spr, &sprDef)
+				}
+			})
+			spriteInfos[i].logf("raycast and shaded %dx%d pixels in %d bands in %d ms", rect.Max.X, rect.Max.Y, len(bands), ms)
+
+			spriteInfos[i].SpriteBounds = rect
+			spriteInfos[i].ShaderOutput = output
+
+			if def.Manifest.DropShadow {
+				spriteInfos[i].Shadow = sprite.GetSpriteShadow(raycaster.GetShadowOutput(object, def.Manifest, spr, smp), spr, sprDef.Scale)
+			}
+		}
+	})
+
+	def.Timings.Time("Sampling", def.Time, func() {
+		var wg sync.WaitGroup
+		wg.Add(len(def.Manifest.Sprites))
+
+		for i, spr := range def.Manifest.Sprites {
+			thisI, thisSpr := i, spr
+			go func() {
+				defer wg.Done()
+				sprDef := getSupersampledDefinition(def, thisSpr)
+				ms := timingutils.Time("", false, func() {
+					sprite.DitherShaderOutput(spriteInfos[thisI].ShaderOutput, thisSpr, &sprDef)
+					reduceSupersampled(def, thisSpr, &spriteInfos[thisI])
+				})
+				spriteInfos[thisI].logf("dithered in %d ms", ms)
+
+				if onSprite != nil {
+					onSprite(thisI, getSpriteImages(def, thisSpr, spriteInfos[thisI]))
+				}
+			}()
+		}
+
+		wg.Wait()
+	})
+}
+
+// Split the rows of a sprite into bands whose raycast output fits in maxMemory bytes.
+// A band always has at least one row, however large.
+func getBands(smp sampler.Samples, algorithm raycaster.Algorithm, maxMemory int64) (bands [][2]int) {
+	start, size := 0, int64(0)
+
+	for y := 0; y < smp.Height(); y++ {
+		rowSize := int64(0)
+		for x := range smp {
+			rowSize += raycaster.GetOutputSize(smp[x][y], algorithm)
+		}
+
+		if y > start && size+rowSize > maxMemory {
+			bands = append(bands, [2]int{start, y})
+			start, size = y, 0
+		}
+
+		size += rowSize
+	}
+
+	return append(bands, [2]int{start, smp.Height()})
+}
@@ -0,0 +1,68 @@
+package spritesheet
+
+import (
+	"bytes"
+	"github.com/mattkimber/gorender/internal/raycaster"
+	"github.com/mattkimber/gorender/internal/sampler"
+	"testing"
+)
+
+func TestGetBands(t *testing.T) {
+	smp := sampler.Square(4, 10, 2, 0, 0.5)
+	algorithm := raycaster.GetAlgorithm("")
+	rowSize := 4 * raycaster.GetOutputSize(smp[0][0], algorithm)
+
+	testCases := []struct {
+		maxMemory int64
+		expected  [][2]int
+	}{
+		{10 * rowSize, [][2]int{{0, 10}}},
+		{4 * rowSize, [][2]int{{0, 4}, {4, 8}, {8, 10}}},
+		{rowSize + 1, [][2]int{{0, 1}, {1, 2}, {2, 3}, {3, 4}, {4, 5}, {5, 6}, {6, 7}, {7, 8}, {8, 9}, {9, 10}}},
+		{1, [][2]int{{0, 1}, {1, 2}, {2, 3}, {3, 4}, {4, 5}, {5, 6}, {6, 7}, {7, 8}, {8, 9}, {9, 10}}},
+	}
+
+	for _, testCase := range testCases {
+		bands := getBands(smp, algorithm, testCase.maxMemory)
+		if len(bands) != len(testCase.expected) {
+			t.Errorf("max memory %d: expected bands %v, got %v", testCase.maxMemory, testCase.expected, bands)
+			continue
+		}
+
+		for i := range bands {
+			if bands[i] != testCase.expected[i] {
+				t.Errorf("max memory %d: expected bands %v, got %v", testCase.maxMemory, testCase.expected, bands)
+				break
+			}
+		}
+	}
+}
+
+func TestGetSpritesheets_Tiled(t *testing.T) {
+	for _, threshold := range []float64{0, 0.05} {
+		def := getTestCubeDefinition(t)
+		def.Manifest.Accuracy = 4
+		def.Manifest.AdaptiveThreshold = threshold
+
+		expected := GetSpritesheets(def)
+
+		// One row per band
+		def.MaxMemory = 1
+		tiled := GetSpritesheets(def)
+
+		for _, key := range []string{"8bpp", "32bpp", "mask"} {
+			if !bytes.Equal(getPNG(t, expected.Data[key]), getPNG(t, tiled.Data[key])) {
+				t.Errorf("%s (adaptive threshold %v): tiled output differs from untiled output", key, threshold)
+			}
+		}
+	}
+}
+
+func getPNG(t *testing.T, sheet Spritesheet) []byte {
+	buf := bytes.Buffer{}
+	if err := sheet.OutputToWriter(&buf); err != nil {
+		t.Fatalf("could not encode spritesheet: %v", err)
+	}
+
+	return buf.Bytes()
+}
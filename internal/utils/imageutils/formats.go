@@ -0,0 +1,110 @@
+package imageutils
+
+import (
+	"bufio"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"io"
+)
+
+// Encode an image as an uncompressed TGA. Paletted images are written with a colour
+// map, and other images as 32 bits per pixel with alpha.
+func EncodeTGA(w io.Writer, img image.Image) error {
+	bounds := img.Bounds()
+	palette, isPaletted := img.ColorModel().(color.Palette)
+
+	bw := bufio.NewWriter(w)
+
+	// ID length, colour map type, image type, colour map start, length and bits per entry
+	if isPaletted {
+		writeLittleEndian(bw, uint8(0), uint8(1), uint8(1), uint16(0), uint16(len(palette)), uint8(24))
+	} else {
+		writeLittleEndian(bw, uint8(0), uint8(0), uint8(2), uint16(0), uint16(0), uint8(0))
+	}
+
+	// Origin, size, bits per pixel and descriptor. Rows are written from the top, and
+	// full colour images have 8 bits of alpha.
+	if isPaletted {
+		writeLittleEndian(bw, uint16(0), uint16(0), uint16(bounds.Dx()), uint16(bounds.Dy()), uint8(8), uint8(0x20))
+	} else {
+		writeLittleEndian(bw, uint16(0), uint16(0), uint16(bounds.Dx()), uint16(bounds.Dy()), uint8(32), uint8(0x28))
+	}
+
+	if isPaletted {
+		for _, c := range palette {
+			r, g, b, _ := c.RGBA()
+			writeLittleEndian(bw, uint8(b>>8), uint8(g>>8), uint8(r>>8))
+		}
+	}
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if isPaletted {
+				_ = bw.WriteByte(img.(image.PalettedImage).ColorIndexAt(x, y))
+				continue
+			}
+
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			_, _ = bw.Write([]byte{c.B, c.G, c.R, c.A})
+		}
+	}
+
+	return bw.Flush()
+}
+
+// Encode an image as an uncompressed BMP. Paletted images are written as 8 bits per
+// pixel with a colour table, and other images as 24 bits per pixel, as few programs
+// read alpha from BMPs.
+func EncodeBMP(w io.Writer, img image.Image) error {
+	bounds := img.Bounds()
+	palette, isPaletted := img.ColorModel().(color.Palette)
+
+	bytesPerPixel := 3
+	if isPaletted {
+		bytesPerPixel = 1
+	}
+
+	// Rows are padded to a multiple of 4 bytes
+	stride := (bounds.Dx()*bytesPerPixel + 3) &^ 3
+	dataOffset := 14 + 40 + len(palette)*4
+	fileSize := dataOffset + stride*bounds.Dy()
+
+	bw := bufio.NewWriter(w)
+
+	// File header, then an info header for an uncompressed image
+	writeLittleEndian(bw, []byte("BM"), uint32(fileSize), uint32(0), uint32(dataOffset))
+	writeLittleEndian(bw, uint32(40), int32(bounds.Dx()), int32(bounds.Dy()), uint16(1), uint16(bytesPerPixel*8), uint32(0),
+		uint32(stride*bounds.Dy()), int32(2835), int32(2835), uint32(len(palette)), uint32(0))
+
+	for _, c := range palette {
+		r, g, b, _ := c.RGBA()
+		writeLittleEndian(bw, uint8(b>>8), uint8(g>>8), uint8(r>>8), uint8(0))
+	}
+
+	// Rows are written from the bottom
+	row := make([]byte, stride)
+	for y := bounds.Max.Y - 1; y >= bounds.Min.Y; y-- {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			i := (x - bounds.Min.X) * bytesPerPixel
+			if isPaletted {
+				row[i] = img.(image.PalettedImage).ColorIndexAt(x, y)
+				continue
+			}
+
+			r, g, b, _ := img.At(x, y).RGBA()
+			row[i], row[i+1], row[i+2] = byte(b>>8), byte(g>>8), byte(r>>8)
+		}
+
+		_, _ = bw.Write(row)
+	}
+
+	return bw.Flush()
+}
+
+// Write values in little-endian order. Errors are returned when the writer is flushed.
+func writeLittleEndian(w *bufio.Writer, values ...interface{}) {
+	for _, v := range values {
+		_ = binary.Write(w, binary.LittleEndian, v)
+	}
+}
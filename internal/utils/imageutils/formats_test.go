@@ -0,0 +1,155 @@
+package imageutils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func getTestFormatImages() (*image.Paletted, *image.NRGBA) {
+	palette := color.Palette{color.RGBA{A: 255}, color.RGBA{R: 255, G: 128, B: 64, A: 255}}
+	paletted := image.NewPaletted(image.Rect(0, 0, 3, 2), palette)
+	paletted.SetColorIndex(2, 0, 1)
+
+	rgba := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	rgba.Set(2, 0, color.NRGBA{R: 255, G: 128, B: 64, A: 128})
+
+	return paletted, rgba
+}
+
+func TestEncodeTGA(t *testing.T) {
+	paletted, rgba := getTestFormatImages()
+
+	testCases := []struct {
+		img                     image.Image
+		imageType, bpp          byte
+		headerSize, pixelOffset int
+		pixel                   []byte
+	}{
+		{paletted, 1, 8, 18 + 2*3, 2, []byte{1}},
+		{rgba, 2, 32, 18, 2 * 4, []byte{64, 128, 255, 128}},
+	}
+
+	for _, testCase := range testCases {
+		buf := bytes.Buffer{}
+		if err := EncodeTGA(&buf, testCase.img); err != nil {
+			t.Fatalf("could not encode TGA: %v", err)
+		}
+
+		data := buf.Bytes()
+		if data[2] != testCase.imageType || data[16] != testCase.bpp {
+			t.Errorf("expected image type %d at %d bits, got %d at %d bits", testCase.imageType, testCase.bpp, data[2], data[16])
+		}
+
+		if w, h := binary.LittleEndian.Uint16(data[12:]), binary.LittleEndian.Uint16(data[14:]); w != 3 || h != 2 {
+			t.Errorf("expected size 3x2, got %dx%d", w, h)
+		}
+
+		if expected := testCase.headerSize + 6*len(testCase.pixel); len(data) != expected {
+			t.Errorf("expected %d bytes, got %d", expected, len(data))
+		}
+
+		// The top right pixel is first in the top row
+		offset := testCase.headerSize + testCase.pixelOffset
+		if pixel := data[offset : offset+len(testCase.pixel)]; !bytes.Equal(pixel, testCase.pixel) {
+			t.Errorf("expected pixel %v, got %v", testCase.pixel, pixel)
+		}
+	}
+}
+
+func TestEncodeBMP(t *testing.T) {
+	paletted, rgba := getTestFormatImages()
+
+	testCases := []struct {
+		img                image.Image
+		bpp                uint16
+		dataOffset, stride int
+		pixelOffset        int
+		pixel              []byte
+	}{
+		{paletted, 8, 14 + 40 + 2*4, 4, 2, []byte{1}},
+		{rgba, 24, 14 + 40, 12, 2 * 3, []byte{32, 64, 128}},
+	}
+
+	for _, testCase := range testCases {
+		buf := bytes.Buffer{}
+		if err := EncodeBMP(&buf, testCase.img); err != nil {
+			t.Fatalf("could not encode BMP: %v", err)
+		}
+
+		data := buf.Bytes()
+		if string(data[0:2]) != "BM" || int(binary.LittleEndian.Uint32(data[2:])) != len(data) {
+			t.Errorf("expected BM header with file size %d, got %q and %d", len(data), data[0:2], binary.LittleEndian.Uint32(data[2:]))
+		}
+
+		if offset := int(binary.LittleEndian.Uint32(data[10:])); offset != testCase.dataOffset {
+			t.Errorf("expected pixel data at %d, got %d", testCase.dataOffset, offset)
+		}
+
+		if bpp := binary.LittleEndian.Uint16(data[28:]); bpp != testCase.bpp {
+			t.Errorf("expected %d bits per pixel, got %d", testCase.bpp, bpp)
+		}
+
+		if expected := testCase.dataOffset + 2*testCase.stride; len(data) != expected {
+			t.Errorf("expected %d bytes, got %d", expected, len(data))
+		}
+
+		// Rows are stored from the bottom, so the top row is second
+		offset := testCase.dataOffset + testCase.stride + testCase.pixelOffset
+		if pixel := data[offset : offset+len(testCase.pixel)]; !bytes.Equal(pixel, testCase.pixel) {
+			t.Errorf("expected pixel %v, got %v", testCase.pixel, pixel)
+		}
+	}
+}
+
+func TestEncodeWebP(t *testing.T) {
+	paletted, rgba := getTestFormatImages()
+
+	for _, img := range []image.Image{paletted, rgba} {
+		buf := bytes.Buffer{}
+		if err := EncodeWebP(&buf, img); err != nil {
+			t.Fatalf("could not encode WebP: %v", err)
+		}
+
+		data := buf.Bytes()
+		if string(data[0:4]) != "RIFF" || string(data[8:16]) != "WEBPVP8L" || int(binary.LittleEndian.Uint32(data[4:]))+8 != len(data) {
+			t.Errorf("expected RIFF header for a VP8L chunk of the file size, got %q", data[0:16])
+		}
+
+		if data[20] != 0x2f {
+			t.Errorf("expected VP8L signature, got %x", data[20])
+		}
+
+		// Width and height less one, in 14 bits each
+		if size := binary.LittleEndian.Uint32(data[21:]); size&0x3fff != 2 || (size>>14)&0x3fff != 1 {
+			t.Errorf("expected size 3x2, got %dx%d", size&0x3fff+1, (size>>14)&0x3fff+1)
+		}
+	}
+
+	if err := EncodeWebP(&bytes.Buffer{}, image.NewNRGBA(image.Rect(0, 0, webpMaxSize+1, 1))); err == nil {
+		t.Errorf("expected error for image too large for WebP")
+	}
+}
+
+func TestGetWebPPrefix(t *testing.T) {
+	testCases := []struct {
+		value, prefix, extraBits int
+		extra                    uint32
+	}{
+		{1, 0, 0, 0},
+		{4, 3, 0, 0},
+		{5, 4, 1, 0},
+		{7, 5, 1, 0},
+		{8, 5, 1, 1},
+		{4096, 23, 10, 1023},
+	}
+
+	for _, testCase := range testCases {
+		prefix, extraBits, extra := getWebPPrefix(testCase.value)
+		if prefix != testCase.prefix || extraBits != testCase.extraBits || extra != testCase.extra {
+			t.Errorf("value %d expected %d, %d, %d, got %d, %d, %d", testCase.value, testCase.prefix, testCase.extraBits, testCase.extra, prefix, extraBits, extra)
+		}
+	}
+}
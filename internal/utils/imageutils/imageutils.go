@@ -43,3 +43,77 @@ func IsImageEqualToSubImage(img image.Image, sub image.Image, bounds image.Recta
 	}
 	return true
 }
+
+// Stack images vertically, returning the combined image and the y offset of each image
+// within it. Paletted images sharing a palette stay paletted, and 16-bit greyscale images
+// stay 16-bit.
+func Stack(images []image.Image) (result image.Image, offsets []int) {
+	width, height := 0, 0
+	offsets = make([]int, len(images))
+
+	var palette color.Palette
+	isPaletted, isGray16 := len(images) > 0, len(images) > 0
+
+	for i, img := range images {
+		offsets[i] = height
+		height += img.Bounds().Dy()
+		if img.Bounds().Dx() > width {
+			width = img.Bounds().Dx()
+		}
+
+		if _, ok := img.(*image.Gray16); !ok {
+			isGray16 = false
+		}
+
+		if p, ok := img.(*image.Paletted); !ok || (palette != nil && !isPaletteEqual(palette, p.Palette)) {
+			isPaletted = false
+		} else {
+			palette = p.Palette
+		}
+	}
+
+	bounds := image.Rect(0, 0, width, height)
+
+	if isPaletted {
+		// Copy indexes rather than colours, as palettes may contain the same colour more than once
+		output := image.NewPaletted(bounds, palette)
+		for i, img := range images {
+			src := img.(*image.Paletted)
+			for y := 0; y < src.Bounds().Dy(); y++ {
+				for x := 0; x < src.Bounds().Dx(); x++ {
+					output.SetColorIndex(x, offsets[i]+y, src.ColorIndexAt(src.Bounds().Min.X+x, src.Bounds().Min.Y+y))
+				}
+			}
+		}
+
+		return output, offsets
+	}
+
+	var output draw.Image = image.NewRGBA(bounds)
+	if isGray16 {
+		output = image.NewGray16(bounds)
+	}
+
+	for i, img := range images {
+		dest := image.Rect(0, offsets[i], img.Bounds().Dx(), offsets[i]+img.Bounds().Dy())
+		draw.Draw(output, dest, img, img.Bounds().Min, draw.Src)
+	}
+
+	return output, offsets
+}
+
+func isPaletteEqual(a, b color.Palette) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		r1, g1, b1, a1 := a[i].RGBA()
+		r2, g2, b2, a2 := b[i].RGBA()
+		if r1 != r2 || g1 != g2 || b1 != b2 || a1 != a2 {
+			return false
+		}
+	}
+
+	return true
+}
@@ -71,3 +71,60 @@ func TestClearToColourIndex(t *testing.T) {
 		}
 	}
 }
+
+func TestStack(t *testing.T) {
+	a := image.NewPaletted(image.Rect(0, 0, 3, 2), palette.Plan9)
+	b := image.NewPaletted(image.Rect(0, 0, 5, 4), palette.Plan9)
+	ClearToColourIndex(a, 5)
+	ClearToColourIndex(b, 7)
+
+	result, offsets := Stack([]image.Image{a, b})
+	if result.Bounds().Dx() != 5 || result.Bounds().Dy() != 6 {
+		t.Fatalf("expected 5x6 image, got %v", result.Bounds())
+	}
+
+	if len(offsets) != 2 || offsets[0] != 0 || offsets[1] != 2 {
+		t.Errorf("expected offsets [0 2], got %v", offsets)
+	}
+
+	paletted, ok := result.(*image.Paletted)
+	if !ok {
+		t.Fatalf("expected paletted output for paletted inputs")
+	}
+
+	if paletted.ColorIndexAt(0, 0) != 5 || paletted.ColorIndexAt(4, 5) != 7 {
+		t.Errorf("expected colour indexes 5 and 7, got %d and %d", paletted.ColorIndexAt(0, 0), paletted.ColorIndexAt(4, 5))
+	}
+
+	mixed, _ := Stack([]image.Image{a, GetUniformImage(image.Rect(0, 0, 1, 1), color.White)})
+	if _, ok := mixed.(*image.RGBA); !ok {
+		t.Errorf("expected RGBA output for mixed inputs")
+	}
+
+	depth := image.NewGray16(image.Rect(0, 0, 1, 1))
+	depth.SetGray16(0, 0, color.Gray16{Y: 1000})
+	gray, _ := Stack([]image.Image{image.NewGray16(image.Rect(0, 0, 1, 1)), depth})
+	if result, ok := gray.(*image.Gray16); !ok {
+		t.Errorf("expected 16-bit greyscale output for 16-bit greyscale inputs")
+	} else if result.Gray16At(0, 1).Y != 1000 {
+		t.Errorf("expected depth 1000, got %d", result.Gray16At(0, 1).Y)
+	}
+}
+
+func TestDrawText(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, TextWidth("1a"), CharHeight))
+	DrawText(img, 0, 0, "1a", color.White)
+
+	// "1" has a vertical stroke in its middle column, and "a" is drawn as a capital A
+	// with its peak in the middle column and an empty corner
+	expected := map[image.Point]bool{{1, 0}: true, {0, 0}: false, {1, 4}: true, {5, 0}: true, {4, 0}: false, {4, 4}: true}
+	for p, set := range expected {
+		if _, _, _, a := img.At(p.X, p.Y).RGBA(); (a != 0) != set {
+			t.Errorf("pixel %v expected set %v", p, set)
+		}
+	}
+
+	if TextWidth("1a") != 8 {
+		t.Errorf("expected width 8, got %d", TextWidth("1a"))
+	}
+}
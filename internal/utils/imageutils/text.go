@@ -0,0 +1,45 @@
+package imageutils
+
+import (
+	"image/color"
+	"image/draw"
+	"unicode"
+)
+
+// Size of each character drawn by DrawText, including the space after it
+const CharWidth, CharHeight = 4, 6
+
+// A 3x5 pixel font for labelling debug images. Each glyph is 5 rows of 3 bits, with the
+// most significant bit on the left.
+var glyphs = map[rune][5]byte{
+	'0': {7, 5, 5, 5, 7}, '1': {2, 6, 2, 2, 7}, '2': {7, 1, 7, 4, 7}, '3': {7, 1, 7, 1, 7}, '4': {5, 5, 7, 1, 1},
+	'5': {7, 4, 7, 1, 7}, '6': {7, 4, 7, 5, 7}, '7': {7, 1, 1, 2, 2}, '8': {7, 5, 7, 5, 7}, '9': {7, 5, 7, 1, 7},
+	'A': {2, 5, 7, 5, 5}, 'B': {6, 5, 6, 5, 6}, 'C': {3, 4, 4, 4, 3}, 'D': {6, 5, 5, 5, 6}, 'E': {7, 4, 6, 4, 7},
+	'F': {7, 4, 6, 4, 4}, 'G': {3, 4, 5, 5, 3}, 'H': {5, 5, 7, 5, 5}, 'I': {7, 2, 2, 2, 7}, 'J': {1, 1, 1, 5, 2},
+	'K': {5, 5, 6, 5, 5}, 'L': {4, 4, 4, 4, 7}, 'M': {5, 7, 7, 5, 5}, 'N': {6, 5, 5, 5, 5}, 'O': {2, 5, 5, 5, 2},
+	'P': {6, 5, 6, 4, 4}, 'Q': {2, 5, 5, 6, 3}, 'R': {6, 5, 6, 5, 5}, 'S': {3, 4, 2, 1, 6}, 'T': {7, 2, 2, 2, 2},
+	'U': {5, 5, 5, 5, 7}, 'V': {5, 5, 5, 5, 2}, 'W': {5, 5, 7, 7, 5}, 'X': {5, 5, 2, 5, 5}, 'Y': {5, 5, 2, 2, 2},
+	'Z': {7, 1, 2, 4, 7}, '-': {0, 0, 7, 0, 0}, '.': {0, 0, 0, 0, 2}, ':': {0, 2, 0, 2, 0}, '/': {1, 1, 2, 4, 4},
+}
+
+// Draw text with its top left corner at x, y. Letters are drawn in capitals, and
+// characters the font doesn't have are left as spaces.
+func DrawText(img draw.Image, x, y int, text string, c color.Color) {
+	for _, r := range text {
+		glyph := glyphs[unicode.ToUpper(r)]
+		for row, bits := range glyph {
+			for col := 0; col < 3; col++ {
+				if bits&(4>>col) != 0 {
+					img.Set(x+col, y+row, c)
+				}
+			}
+		}
+
+		x += CharWidth
+	}
+}
+
+// Get the width of text drawn by DrawText
+func TextWidth(text string) int {
+	return len([]rune(text)) * CharWidth
+}
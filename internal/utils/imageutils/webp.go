@@ -0,0 +1,496 @@
+package imageutils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"sort"
+)
+
+// Lossless WebP (VP8L) images can be at most this many pixels in each direction
+const webpMaxSize = 16384
+
+const (
+	webpNumLiterals   = 256
+	webpNumLengths    = 24
+	webpNumDistances  = 40
+	webpMaxRunLength  = 4096
+	webpMinRunLength  = 3
+	webpMaxCodeLength = 15
+
+	// Distance codes for the pixel above and the pixel to the left
+	webpDistanceAbove = 1
+	webpDistanceLeft  = 2
+)
+
+// The order code lengths of the code length code are written in
+var webpCodeLengthOrder = []int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// Encode an image as a lossless WebP. Paletted images use the colour table, and runs of
+// pixels repeating the pixel to the left or above are copied rather than stored, which
+// keeps the empty space around sprites small. Only the simplest transforms are used, so
+// files are larger than those from dedicated encoders, but much smaller than PNG for
+// spritesheets with large empty areas.
+func EncodeWebP(w io.Writer, img image.Image) error {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > webpMaxSize || height > webpMaxSize {
+		return fmt.Errorf("image of %dx%d is too large for WebP, which is limited to %dx%d", width, height, webpMaxSize, webpMaxSize)
+	}
+
+	bw := &webpBitWriter{}
+	pixels, hasAlpha := make([]uint32, 0, width*height), false
+	palette, isPaletted := img.ColorModel().(color.Palette)
+
+	// VP8L signature, size and version
+	bw.writeBits(0x2f, 8)
+	bw.writeBits(uint32(width-1), 14)
+	bw.writeBits(uint32(height-1), 14)
+
+	if isPaletted {
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				pixels = append(pixels, uint32(img.(image.PalettedImage).ColorIndexAt(x, y))<<8)
+			}
+		}
+
+		// Tables of 16 colours or fewer pack several pixels together, so pad them
+		colours := make([]uint32, max(len(palette), 17))
+		for i, c := range palette {
+			colours[i] = getARGB(color.NRGBAModel.Convert(c).(color.NRGBA))
+			hasAlpha = hasAlpha || colours[i]>>24 != 0xff
+		}
+
+		bw.writeBits(boolToBit(hasAlpha), 1)
+		bw.writeBits(0, 3)
+
+		// Colour indexing transform, with the table stored as the difference from the
+		// previous colour
+		bw.writeBits(1, 1)
+		bw.writeBits(3, 2)
+		bw.writeBits(uint32(len(colours)-1), 8)
+
+		deltas := make([]uint32, len(colours))
+		for i := range colours {
+			deltas[i] = colours[i]
+			if i > 0 {
+				deltas[i] = subtractPixels(colours[i], colours[i-1])
+			}
+		}
+
+		writeWebPImage(bw, deltas, len(deltas), false)
+	} else {
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+				hasAlpha = hasAlpha || c.A != 0xff
+
+				// Subtract green, as the colour channels of most pixels move together
+				c.R, c.B = c.R-c.G, c.B-c.G
+				pixels = append(pixels, getARGB(c))
+			}
+		}
+
+		bw.writeBits(boolToBit(hasAlpha), 1)
+		bw.writeBits(0, 3)
+
+		// Subtract green transform, which has no data
+		bw.writeBits(1, 1)
+		bw.writeBits(2, 2)
+	}
+
+	// No more transforms
+	bw.writeBits(0, 1)
+	writeWebPImage(bw, pixels, width, true)
+
+	data := bw.bytes()
+	chunkSize := len(data)
+
+	riff := bytes.Buffer{}
+	riff.WriteString("RIFF")
+	_ = binary.Write(&riff, binary.LittleEndian, uint32(4+8+chunkSize+chunkSize%2))
+	riff.WriteString("WEBPVP8L")
+	_ = binary.Write(&riff, binary.LittleEndian, uint32(chunkSize))
+	riff.Write(data)
+
+	// Chunks are padded to an even size
+	if chunkSize%2 == 1 {
+		riff.WriteByte(0)
+	}
+
+	_, err := w.Write(riff.Bytes())
+	return err
+}
+
+// A literal pixel, or a copy of length pixels from distance code back
+type webpSymbol struct {
+	argb             uint32
+	length, distance int
+}
+
+// Write an entropy-coded image, with no colour cache. Only the main image can have meta
+// prefix codes, which aren't used.
+func writeWebPImage(bw *webpBitWriter, pixels []uint32, width int, isMain bool) {
+	bw.writeBits(0, 1)
+	if isMain {
+		bw.writeBits(0, 1)
+	}
+
+	symbols := getWebPSymbols(pixels, width)
+
+	// Green and lengths, red, blue, alpha and distance
+	counts := [5][]int{
+		make([]int, webpNumLiterals+webpNumLengths),
+		make([]int, webpNumLiterals),
+		make([]int, webpNumLiterals),
+		make([]int, webpNumLiterals),
+		make([]int, webpNumDistances),
+	}
+
+	for _, s := range symbols {
+		if s.length > 0 {
+			lengthPrefix, _, _ := getWebPPrefix(s.length)
+			distancePrefix, _, _ := getWebPPrefix(s.distance)
+			counts[0][webpNumLiterals+lengthPrefix]++
+			counts[4][distancePrefix]++
+			continue
+		}
+
+		counts[0][(s.argb>>8)&0xff]++
+		counts[1][(s.argb>>16)&0xff]++
+		counts[2][s.argb&0xff]++
+		counts[3][s.argb>>24]++
+	}
+
+	var codes [5]webpPrefixCode
+	for i := range counts {
+		codes[i] = writeWebPPrefixCode(bw, counts[i])
+	}
+
+	for _, s := range symbols {
+		if s.length > 0 {
+			prefix, extraBits, extra := getWebPPrefix(s.length)
+			codes[0].write(bw, webpNumLiterals+prefix)
+			bw.writeBits(extra, extraBits)
+
+			prefix, extraBits, extra = getWebPPrefix(s.distance)
+			codes[4].write(bw, prefix)
+			bw.writeBits(extra, extraBits)
+			continue
+		}
+
+		codes[0].write(bw, int((s.argb>>8)&0xff))
+		codes[1].write(bw, int((s.argb>>16)&0xff))
+		codes[2].write(bw, int(s.argb&0xff))
+		codes[3].write(bw, int(s.argb>>24))
+	}
+}
+
+// Split pixels into literals and runs copying the pixel to the left or the pixel above,
+// whichever is longer
+func getWebPSymbols(pixels []uint32, width int) (symbols []webpSymbol) {
+	for i := 0; i < len(pixels); {
+		left, above := 0, 0
+
+		if i > 0 {
+			for left < webpMaxRunLength && i+left < len(pixels) && pixels[i+left] == pixels[i+left-1] {
+				left++
+			}
+		}
+
+		if i >= width {
+			for above < webpMaxRunLength && i+above < len(pixels) && pixels[i+above] == pixels[i+above-width] {
+				above++
+			}
+		}
+
+		switch {
+		case above >= webpMinRunLength && above >= left:
+			symbols = append(symbols, webpSymbol{length: above, distance: webpDistanceAbove})
+			i += above
+		case left >= webpMinRunLength:
+			symbols = append(symbols, webpSymbol{length: left, distance: webpDistanceLeft})
+			i += left
+		default:
+			symbols = append(symbols, webpSymbol{argb: pixels[i]})
+			i++
+		}
+	}
+
+	return
+}
+
+// Get the prefix code, number of extra bits and extra bits for a length or distance
+func getWebPPrefix(value int) (prefix int, extraBits int, extra uint32) {
+	d := value - 1
+	if d < 4 {
+		return d, 0, 0
+	}
+
+	highBit := 0
+	for d>>(highBit+1) != 0 {
+		highBit++
+	}
+
+	secondBit := (d >> (highBit - 1)) & 1
+	extraBits = highBit - 1
+	return 2*highBit + secondBit, extraBits, uint32(d & ((1 << extraBits) - 1))
+}
+
+// A canonical prefix code, with the bits of each code reversed as they're written
+// least significant bit first
+type webpPrefixCode struct {
+	lengths []int
+	codes   []uint32
+}
+
+func (c webpPrefixCode) write(bw *webpBitWriter, symbol int) {
+	bw.writeBits(c.codes[symbol], c.lengths[symbol])
+}
+
+// Write a prefix code for symbols with the given counts, returning the code
+func writeWebPPrefixCode(bw *webpBitWriter, counts []int) webpPrefixCode {
+	var used []int
+	for symbol, count := range counts {
+		if count > 0 {
+			used = append(used, symbol)
+		}
+	}
+
+	// A code for a single symbol takes no bits to write, so is written as a simple code
+	if len(used) <= 1 {
+		symbol := 0
+		if len(used) == 1 {
+			symbol = used[0]
+		}
+
+		bw.writeBits(1, 1)
+		bw.writeBits(0, 1)
+		bw.writeBits(1, 1)
+		bw.writeBits(uint32(symbol), 8)
+		return webpPrefixCode{lengths: make([]int, len(counts)), codes: make([]uint32, len(counts))}
+	}
+
+	code := getWebPPrefixCode(counts, webpMaxCodeLength)
+	bw.writeBits(0, 1)
+	writeWebPCodeLengths(bw, code.lengths)
+	return code
+}
+
+// Write the lengths of a prefix code, themselves prefix coded with runs of zeros
+// shortened
+func writeWebPCodeLengths(bw *webpBitWriter, lengths []int) {
+	type token struct{ symbol, extraBits, extra int }
+	var tokens []token
+
+	for i := 0; i < len(lengths); {
+		if lengths[i] != 0 {
+			tokens = append(tokens, token{symbol: lengths[i]})
+			i++
+			continue
+		}
+
+		run := 0
+		for i+run < len(lengths) && lengths[i+run] == 0 && run < 138 {
+			run++
+		}
+
+		switch {
+		case run >= 11:
+			tokens = append(tokens, token{18, 7, run - 11})
+		case run >= 3:
+			tokens = append(tokens, token{17, 3, run - 3})
+		default:
+			for j := 0; j < run; j++ {
+				tokens = append(tokens, token{symbol: 0})
+			}
+		}
+
+		i += run
+	}
+
+	counts := make([]int, len(webpCodeLengthOrder))
+	for _, t := range tokens {
+		counts[t.symbol]++
+	}
+
+	// The code length code needs at least two symbols to take any bits to write
+	if t := tokens[0].symbol; counts[t] == len(tokens) {
+		counts[(t+1)%len(counts)]++
+	}
+
+	code := getWebPPrefixCode(counts, 7)
+
+	numLengths := len(webpCodeLengthOrder)
+	for numLengths > 4 && code.lengths[webpCodeLengthOrder[numLengths-1]] == 0 {
+		numLengths--
+	}
+
+	bw.writeBits(uint32(numLengths-4), 4)
+	for _, symbol := range webpCodeLengthOrder[:numLengths] {
+		bw.writeBits(uint32(code.lengths[symbol]), 3)
+	}
+
+	// Lengths are given for every symbol
+	bw.writeBits(0, 1)
+
+	for _, t := range tokens {
+		code.write(bw, t.symbol)
+		bw.writeBits(uint32(t.extra), t.extraBits)
+	}
+}
+
+// Build a canonical Huffman code for the counts, with no code longer than maxLength.
+// Counts are flattened until the code fits.
+func getWebPPrefixCode(counts []int, maxLength int) (code webpPrefixCode) {
+	counts = append([]int{}, counts...)
+
+	for {
+		code.lengths = getHuffmanLengths(counts)
+		if slicesMax(code.lengths) <= maxLength {
+			break
+		}
+
+		for i := range counts {
+			if counts[i] > 0 {
+				counts[i] = max(1, counts[i]/2)
+			}
+		}
+	}
+
+	// Assign codes in order of length, then symbol
+	code.codes = make([]uint32, len(counts))
+	next, nextCode := 0, make([]int, maxLength+2)
+	lengthCounts := make([]int, maxLength+1)
+	for _, l := range code.lengths {
+		lengthCounts[l]++
+	}
+
+	lengthCounts[0] = 0
+	for l := 1; l <= maxLength; l++ {
+		next = (next + lengthCounts[l-1]) << 1
+		nextCode[l] = next
+	}
+
+	for symbol, l := range code.lengths {
+		if l > 0 {
+			code.codes[symbol] = reverseBits(uint32(nextCode[l]), l)
+			nextCode[l]++
+		}
+	}
+
+	return
+}
+
+// Get the length of each symbol's code in a Huffman code for the counts
+func getHuffmanLengths(counts []int) []int {
+	type node struct {
+		count       int
+		symbol      int
+		left, right int
+	}
+
+	var nodes []node
+	var queue []int
+	for symbol, count := range counts {
+		if count > 0 {
+			nodes = append(nodes, node{count: count, symbol: symbol, left: -1, right: -1})
+			queue = append(queue, len(nodes)-1)
+		}
+	}
+
+	lengths := make([]int, len(counts))
+	if len(queue) == 1 {
+		lengths[nodes[0].symbol] = 1
+		return lengths
+	}
+
+	for len(queue) > 1 {
+		sort.SliceStable(queue, func(i, j int) bool { return nodes[queue[i]].count < nodes[queue[j]].count })
+		nodes = append(nodes, node{count: nodes[queue[0]].count + nodes[queue[1]].count, symbol: -1, left: queue[0], right: queue[1]})
+		queue = append(queue[2:], len(nodes)-1)
+	}
+
+	var walk func(n, depth int)
+	walk = func(n, depth int) {
+		if nodes[n].symbol >= 0 {
+			lengths[nodes[n].symbol] = depth
+			return
+		}
+
+		walk(nodes[n].left, depth+1)
+		walk(nodes[n].right, depth+1)
+	}
+
+	if len(queue) == 1 {
+		walk(queue[0], 0)
+	}
+
+	return lengths
+}
+
+func slicesMax(values []int) (result int) {
+	for _, v := range values {
+		result = max(result, v)
+	}
+
+	return
+}
+
+func reverseBits(value uint32, length int) (result uint32) {
+	for i := 0; i < length; i++ {
+		result = result<<1 | (value>>i)&1
+	}
+
+	return
+}
+
+func getARGB(c color.NRGBA) uint32 {
+	return uint32(c.A)<<24 | uint32(c.R)<<16 | uint32(c.G)<<8 | uint32(c.B)
+}
+
+// Subtract each channel of b from a, modulo 256
+func subtractPixels(a, b uint32) (result uint32) {
+	for shift := 0; shift < 32; shift += 8 {
+		result |= ((a>>shift - b>>shift) & 0xff) << shift
+	}
+
+	return
+}
+
+func boolToBit(b bool) uint32 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
+// Writes bits least significant first, as WebP is read
+type webpBitWriter struct {
+	data  []byte
+	bits  uint64
+	nBits int
+}
+
+func (bw *webpBitWriter) writeBits(value uint32, n int) {
+	bw.bits |= uint64(value) << bw.nBits
+	bw.nBits += n
+
+	for bw.nBits >= 8 {
+		bw.data = append(bw.data, byte(bw.bits))
+		bw.bits >>= 8
+		bw.nBits -= 8
+	}
+}
+
+func (bw *webpBitWriter) bytes() []byte {
+	if bw.nBits > 0 {
+		return append(bw.data, byte(bw.bits))
+	}
+
+	return bw.data
+}
@@ -0,0 +1,48 @@
+package poolutils
+
+import "sync"
+
+// A pool of slices which can be reused instead of being left to the garbage collector.
+// Slices are only reused for slices of the same length, so a large slice is never kept
+// alive by a small use of it, and are cleared so are the same as newly made ones.
+type SlicePool[T any] struct {
+	mutex sync.Mutex
+	pools map[int]*sync.Pool
+}
+
+// Get a slice of length n, reusing a slice from the pool if there is one
+func (p *SlicePool[T]) Get(n int) []T {
+	if s, ok := p.getPool(n).Get().(*[]T); ok {
+		result := *s
+		clear(result)
+		return result
+	}
+
+	return make([]T, n)
+}
+
+// Put a slice back in the pool. The slice and anything sharing its backing array must
+// not be used afterwards.
+func (p *SlicePool[T]) Put(s []T) {
+	if cap(s) == 0 {
+		return
+	}
+
+	s = s[:cap(s)]
+	p.getPool(len(s)).Put(&s)
+}
+
+func (p *SlicePool[T]) getPool(n int) *sync.Pool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.pools == nil {
+		p.pools = make(map[int]*sync.Pool)
+	}
+
+	if _, ok := p.pools[n]; !ok {
+		p.pools[n] = &sync.Pool{}
+	}
+
+	return p.pools[n]
+}
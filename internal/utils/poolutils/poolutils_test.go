@@ -0,0 +1,34 @@
+package poolutils
+
+import "testing"
+
+func TestSlicePool(t *testing.T) {
+	p := SlicePool[int]{}
+
+	s := p.Get(4)
+	if len(s) != 4 {
+		t.Fatalf("expected length 4, got %d", len(s))
+	}
+
+	for i := range s {
+		s[i] = i + 1
+	}
+	p.Put(s)
+
+	// Reused slices are cleared, and slices of other lengths are newly made
+	testCases := []int{2, 4, 10}
+	for _, n := range testCases {
+		s = p.Get(n)
+		if len(s) != n {
+			t.Errorf("expected length %d, got %d", n, len(s))
+		}
+
+		for i, v := range s {
+			if v != 0 {
+				t.Errorf("length %d: expected cleared slice, got %d at %d", n, v, i)
+			}
+		}
+
+		p.Put(s)
+	}
+}
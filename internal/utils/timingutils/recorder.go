@@ -0,0 +1,27 @@
+package timingutils
+
+import "sync"
+
+// A recorder keeps the time taken by each stage of an operation. A nil recorder
+// times operations without keeping the results.
+type Recorder struct {
+	sync.Mutex
+	Stages []Stage
+}
+
+type Stage struct {
+	Name string `json:"name"`
+	Ms   int64  `json:"ms"`
+}
+
+func (r *Recorder) Time(name string, showOutput bool, op func()) (ms int64) {
+	ms = Time(name, showOutput, op)
+
+	if r != nil {
+		r.Lock()
+		r.Stages = append(r.Stages, Stage{Name: name, Ms: ms})
+		r.Unlock()
+	}
+
+	return
+}
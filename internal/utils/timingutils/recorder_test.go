@@ -0,0 +1,26 @@
+package timingutils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecorder_Time(t *testing.T) {
+	r := &Recorder{}
+	r.Time("first", false, func() {})
+	r.Time("second", false, func() { time.Sleep(10 * time.Millisecond) })
+
+	if len(r.Stages) != 2 || r.Stages[0].Name != "first" || r.Stages[1].Name != "second" {
+		t.Fatalf("Expected stages first and second, got %v", r.Stages)
+	}
+
+	if r.Stages[1].Ms < 10 {
+		t.Errorf("Expected second stage to take at least 10ms, got %d", r.Stages[1].Ms)
+	}
+
+	// A nil recorder still times the operation
+	var nilRecorder *Recorder
+	if ms := nilRecorder.Time("nil", false, func() { time.Sleep(10 * time.Millisecond) }); ms < 10 {
+		t.Errorf("Expected nil recorder to time operation, got %d", ms)
+	}
+}
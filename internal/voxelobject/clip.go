@@ -0,0 +1,18 @@
+package voxelobject
+
+import (
+	"github.com/mattkimber/gandalf/magica"
+	"github.com/mattkimber/gorender/internal/geometry"
+)
+
+// Remove the voxels inside a box, inclusive of both corners. Parts of the box outside the
+// object are ignored, and the object keeps its size so sprites line up with the whole object.
+func ClearBox(v magica.VoxelObject, from, to geometry.Point) {
+	for x := max(from.X, 0); x <= min(to.X, v.Size.X-1); x++ {
+		for y := max(from.Y, 0); y <= min(to.Y, v.Size.Y-1); y++ {
+			for z := max(from.Z, 0); z <= min(to.Z, v.Size.Z-1); z++ {
+				v.Voxels[x][y][z] = 0
+			}
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package voxelobject
+
+import (
+	gandalfgeo "github.com/mattkimber/gandalf/geometry"
+	"github.com/mattkimber/gandalf/magica"
+	"github.com/mattkimber/gorender/internal/geometry"
+	"testing"
+)
+
+func TestClearBox(t *testing.T) {
+	testCases := []struct {
+		name      string
+		from, to  geometry.Point
+		remaining int
+	}{
+		{"single voxel", geometry.Point{X: 1, Y: 1, Z: 1}, geometry.Point{X: 1, Y: 1, Z: 1}, 26},
+		{"front half", geometry.Point{X: 0, Y: 0, Z: 0}, geometry.Point{X: 0, Y: 2, Z: 2}, 18},
+		{"partly outside", geometry.Point{X: -5, Y: 2, Z: -5}, geometry.Point{X: 10, Y: 10, Z: 10}, 18},
+		{"all outside", geometry.Point{X: 3, Y: 0, Z: 0}, geometry.Point{X: 5, Y: 2, Z: 2}, 27},
+	}
+
+	for _, testCase := range testCases {
+		v := magica.VoxelObject{Size: gandalfgeo.Point{X: 3, Y: 3, Z: 3}}
+		v.Voxels = make([][][]byte, v.Size.X)
+		for x := range v.Voxels {
+			v.Voxels[x] = make([][]byte, v.Size.Y)
+			for y := range v.Voxels[x] {
+				v.Voxels[x][y] = []byte{5, 5, 5}
+			}
+		}
+
+		ClearBox(v, testCase.from, testCase.to)
+
+		remaining := 0
+		v.Iterate(func(x, y, z int) {
+			if v.Voxels[x][y][z] != 0 {
+				remaining++
+			}
+		})
+
+		if remaining != testCase.remaining {
+			t.Errorf("%s: expected %d voxels remaining, got %d", testCase.name, testCase.remaining, remaining)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package voxelobject
+
+import (
+	"github.com/mattkimber/gandalf/magica"
+	"math"
+)
+
+// Paint a vertical gradient over the voxels using the palette indexes from start to end. Each
+// voxel's index is moved by an amount which changes evenly from bottom at the lowest of these
+// voxels to top at the highest, and is kept between start and end.
+func PaintHeightGradient(v magica.VoxelObject, start, end byte, bottom, top float64) {
+	inRange := func(idx byte) bool { return idx != 0 && idx >= start && idx <= end }
+
+	lowest, highest := v.Size.Z, -1
+	v.Iterate(func(x, y, z int) {
+		if inRange(v.Voxels[x][y][z]) {
+			lowest, highest = min(lowest, z), max(highest, z)
+		}
+	})
+
+	if highest < 0 {
+		return
+	}
+
+	v.Iterate(func(x, y, z int) {
+		idx := v.Voxels[x][y][z]
+		if !inRange(idx) {
+			return
+		}
+
+		shift := bottom
+		if highest > lowest {
+			shift += (top - bottom) * float64(z-lowest) / float64(highest-lowest)
+		}
+
+		shifted := int(idx) + int(math.Round(shift))
+		v.Voxels[x][y][z] = byte(min(max(shifted, int(start), 1), int(end)))
+	})
+}
@@ -0,0 +1,45 @@
+package voxelobject
+
+import (
+	"github.com/mattkimber/gandalf/geometry"
+	"github.com/mattkimber/gandalf/magica"
+	"testing"
+)
+
+func TestPaintHeightGradient(t *testing.T) {
+	v := getTestObject(1, 1, 5, 10)
+	v.Voxels[0][0][4] = 20
+
+	PaintHeightGradient(v, 8, 15, 0, 4)
+
+	// The voxel at the top is outside the range, so the gradient ends at the one below it
+	expected := []byte{10, 11, 13, 14, 20}
+	for z, e := range expected {
+		if v.Voxels[0][0][z] != e {
+			t.Errorf("voxel at z=%d: expected %d, got %d", z, e, v.Voxels[0][0][z])
+		}
+	}
+
+	// Indexes are kept within the range
+	v = getTestObject(1, 1, 2, 14)
+	PaintHeightGradient(v, 8, 15, -10, 10)
+
+	if v.Voxels[0][0][0] != 8 || v.Voxels[0][0][1] != 15 {
+		t.Errorf("expected 8 and 15, got %d and %d", v.Voxels[0][0][0], v.Voxels[0][0][1])
+	}
+}
+
+func getTestObject(x, y, z int, idx byte) magica.VoxelObject {
+	v := magica.VoxelObject{Size: geometry.Point{X: x, Y: y, Z: z}}
+	v.Voxels = make([][][]byte, x)
+	for i := range v.Voxels {
+		v.Voxels[i] = make([][]byte, y)
+		for j := range v.Voxels[i] {
+			v.Voxels[i][j] = make([]byte, z)
+			for k := range v.Voxels[i][j] {
+				v.Voxels[i][j][k] = idx
+			}
+		}
+	}
+	return v
+}
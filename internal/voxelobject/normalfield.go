@@ -0,0 +1,122 @@
+package voxelobject
+
+import (
+	"github.com/mattkimber/gorender/internal/geometry"
+	"math"
+)
+
+// Binomial weights for smoothing the occupancy of the object before taking its gradient,
+// which spread over the same radius as the normal calculation
+var normalFieldKernel = [2*normalRadius + 1]float64{1 / 64.0, 6 / 64.0, 15 / 64.0, 20 / 64.0, 15 / 64.0, 6 / 64.0, 1 / 64.0}
+
+// Build a field over the voxel grid from the gradient of the object's smoothed occupancy.
+// This points towards the inside of the object like the normals of each voxel, but
+// varies gradually across surfaces so a normal can be interpolated at any point rather
+// than taken from the voxel which was hit. The smoothing is separable, so the field is
+// quicker to build than the normals of every surface voxel.
+func (p *ProcessedVoxelObject) setNormalField() {
+	lookup := p.borderedElementLookup
+	sx, sy, sz := len(lookup), len(lookup[0]), len(lookup[0][0])
+
+	density := make([]float64, sx*sy*sz)
+	index := func(x, y, z int) int { return (x*sy+y)*sz + z }
+
+	// The lookup has 1 for empty voxels, including those beyond the edges of a tiled object
+	for x := 0; x < sx; x++ {
+		for y := 0; y < sy; y++ {
+			for z := 0; z < sz; z++ {
+				density[index(x, y, z)] = float64(1 - lookup[x][y][z])
+			}
+		}
+	}
+
+	density = smoothAxis(density, sx, sy*sz)
+	density = smoothAxis(density, sy, sz)
+	density = smoothAxis(density, sz, 1)
+
+	p.NormalField = make([][][]geometry.Vector3, p.Size.X)
+	for x := 0; x < p.Size.X; x++ {
+		p.NormalField[x] = make([][]geometry.Vector3, p.Size.Y)
+		for y := 0; y < p.Size.Y; y++ {
+			p.NormalField[x][y] = make([]geometry.Vector3, p.Size.Z)
+			for z := 0; z < p.Size.Z; z++ {
+				bx, by, bz := x+accessBorder, y+accessBorder, z+accessBorder
+				p.NormalField[x][y][z] = geometry.Vector3{
+					X: (density[index(bx+1, by, bz)] - density[index(bx-1, by, bz)]) / 2,
+					Y: (density[index(bx, by+1, bz)] - density[index(bx, by-1, bz)]) / 2,
+					Z: (density[index(bx, by, bz+1)] - density[index(bx, by, bz-1)]) / 2,
+				}
+			}
+		}
+	}
+}
+
+// Smooth values along one axis of length n, where stride is the distance between
+// neighbouring values along the axis. Positions beyond the ends of the axis take the
+// value at the end.
+func smoothAxis(values []float64, n, stride int) []float64 {
+	result := make([]float64, len(values))
+
+	for i := range values {
+		pos := (i / stride) % n
+		for k, weight := range normalFieldKernel {
+			result[i] += values[i+(min(max(pos+k-normalRadius, 0), n-1)-pos)*stride] * weight
+		}
+	}
+
+	return result
+}
+
+// Get the normal of a surface voxel from the normal field
+func (p *ProcessedVoxelObject) getFieldNormal(x, y, z int) geometry.Vector3 {
+	if !p.Elements[x][y][z].IsSurface {
+		return geometry.Vector3{}
+	}
+
+	if normal := p.NormalField[x][y][z]; normal.Length() > 0.01 {
+		return normal.Normalise()
+	}
+
+	return geometry.Vector3{}
+}
+
+// Get the normal at a location within the object, interpolated from the normal field
+// between the centres of the surrounding voxels. Returns false if the object has no
+// normal field or the field has no direction at the location.
+func (p *ProcessedVoxelObject) GetNormalAt(loc geometry.Vector3) (geometry.Vector3, bool) {
+	if p.NormalField == nil {
+		return geometry.Vector3{}, false
+	}
+
+	fx, fy, fz := loc.X-0.5, loc.Y-0.5, loc.Z-0.5
+	x0, y0, z0 := int(math.Floor(fx)), int(math.Floor(fy)), int(math.Floor(fz))
+	tx, ty, tz := fx-float64(x0), fy-float64(y0), fz-float64(z0)
+
+	normal := geometry.Vector3{}
+	for i := 0; i < 8; i++ {
+		dx, dy, dz := i&1, (i>>1)&1, (i>>2)&1
+		weight := lerpWeight(tx, dx) * lerpWeight(ty, dy) * lerpWeight(tz, dz)
+		if weight == 0 {
+			continue
+		}
+
+		x := min(max(x0+dx, 0), p.Size.X-1)
+		y := min(max(y0+dy, 0), p.Size.Y-1)
+		z := min(max(z0+dz, 0), p.Size.Z-1)
+		normal = normal.Add(p.NormalField[x][y][z].MultiplyByConstant(weight))
+	}
+
+	if normal.Length() > 0.01 {
+		return normal.Normalise(), true
+	}
+
+	return geometry.Vector3{}, false
+}
+
+func lerpWeight(t float64, side int) float64 {
+	if side == 0 {
+		return 1 - t
+	}
+
+	return t
+}
@@ -0,0 +1,67 @@
+package voxelobject
+
+import (
+	"github.com/mattkimber/gandalf/magica"
+	"github.com/mattkimber/gorender/internal/colour"
+	"github.com/mattkimber/gorender/internal/geometry"
+	"math"
+	"testing"
+)
+
+func TestProcessedVoxelObject_NormalField(t *testing.T) {
+	mv, err := magica.FromFile("testdata/testcube_big")
+	if err != nil {
+		t.Fatalf("error loading test file: %v", err)
+	}
+
+	pal := colour.Palette{Entries: make([]colour.PaletteEntry, 256)}
+	pal.SetRanges([]colour.PaletteRange{{Start: 0, End: 255}})
+
+	v := GetProcessedVoxelObject(mv, &pal, false, "normal", false, true)
+	plain := GetProcessedVoxelObject(mv, &pal, false, "normal", false, false)
+
+	if _, ok := plain.GetNormalAt(geometry.Vector3{X: 2.5, Y: 6, Z: 6}); ok {
+		t.Errorf("expected no normal without a normal field")
+	}
+
+	// Surface voxels face the same way as normals calculated from the voxels around them
+	for x := 0; x < v.Size.X; x++ {
+		for y := 0; y < v.Size.Y; y++ {
+			for z := 0; z < v.Size.Z; z++ {
+				if !v.Elements[x][y][z].IsSurface {
+					continue
+				}
+
+				if dot := v.Elements[x][y][z].Normal.Dot(plain.Elements[x][y][z].Normal); dot < 0.8 {
+					t.Errorf("normal at [%d,%d,%d] expected close to %v, got %v", x, y, z, plain.Elements[x][y][z].Normal, v.Elements[x][y][z].Normal)
+				}
+			}
+		}
+	}
+
+	// The cube spans 2 to 10 on each axis, so the centres of its faces point straight in
+	testCases := []struct {
+		loc, expected geometry.Vector3
+	}{
+		{geometry.Vector3{X: 2, Y: 6, Z: 6}, geometry.UnitX()},
+		{geometry.Vector3{X: 10, Y: 6, Z: 6}, geometry.Zero().Subtract(geometry.UnitX())},
+		{geometry.Vector3{X: 6, Y: 6, Z: 10}, geometry.Zero().Subtract(geometry.UnitZ())},
+	}
+
+	for _, testCase := range testCases {
+		result, ok := v.GetNormalAt(testCase.loc)
+		if !ok || result.Subtract(testCase.expected).Length() > 1e-9 {
+			t.Errorf("normal at %v expected %v, got %v", testCase.loc, testCase.expected, result)
+		}
+	}
+
+	// Normals change gradually across a face rather than jumping between voxels
+	previous, _ := v.GetNormalAt(geometry.Vector3{X: 2, Y: 3, Z: 6})
+	for y := 3.1; y < 9; y += 0.1 {
+		result, _ := v.GetNormalAt(geometry.Vector3{X: 2, Y: y, Z: 6})
+		if angle := math.Acos(math.Min(result.Dot(previous), 1)); angle > 0.1 {
+			t.Errorf("normal at y=%f changed by %f radians", y, angle)
+		}
+		previous = result
+	}
+}
@@ -15,12 +15,27 @@ type ProcessedElement struct {
 	Occlusion      int
 	Index          byte
 	IsSurface      bool
+	EmptyDistance  byte
 }
 
 type ProcessedVoxelObject struct {
 	Elements [][][]ProcessedElement
 	Size     geometry.Point
 	Palette  *colour.Palette
+
+	// How far the first and last slices in X are stretched outwards when rendered, in
+	// voxels. The object then occupies X locations 0 to Size.X + EndExtension*2.
+	EndExtension float64
+
+	// The gradient of the object's smoothed occupancy at the centre of each voxel, if
+	// normals are calculated from it rather than from the voxels around each one
+	NormalField [][][]geometry.Vector3
+
+	// Shadows cast onto the object, shared by copies of it
+	shadowMaps *shadowMaps
+
+	// Only needed while processing, so objects can be processed concurrently
+	borderedElementLookup [][][]int
 }
 
 type startValue struct {
@@ -32,27 +47,29 @@ type radiusStartValues struct {
 	K [][]startValue
 }
 
-var startValues map[int]radiusStartValues
+var startValues = map[int]radiusStartValues{}
 var startValuesLock sync.RWMutex
 
-var borderedElementLookup [][][]int
-
 const normalRadius = 3
 const normalAverageDistance = 1
 const occlusionRadius = 4
 const accessBorder = 8
+const maxEmptyDistance = 255
 
-func GetProcessedVoxelObject(o magica.VoxelObject, pal *colour.Palette, isTiled bool, tilingMode string, hasBase bool) (p ProcessedVoxelObject) {
+func GetProcessedVoxelObject(o magica.VoxelObject, pal *colour.Palette, isTiled bool, tilingMode string, hasBase bool, gradientNormals bool) (p ProcessedVoxelObject) {
 	p.Size = geometry.FromGandalfPoint(o.Size)
 	p.Palette = pal
+	p.shadowMaps = &shadowMaps{}
 
-	if startValues == nil {
-		startValues = map[int]radiusStartValues{}
+	p.setElements(o, isTiled, tilingMode, hasBase)
+	if gradientNormals {
+		p.setNormalField()
 	}
 
-	p.setElements(o, isTiled, tilingMode, hasBase)
 	p.calculatePass(processFirstPassElement)
 	p.calculatePass(processSecondPassElement)
+	p.setEmptyDistances()
+	p.borderedElementLookup = nil
 
 	return
 }
@@ -79,7 +96,11 @@ func (p *ProcessedVoxelObject) calculatePass(processor func(*ProcessedVoxelObjec
 
 func processFirstPassElement(p *ProcessedVoxelObject, x int, y int, z int) {
 	p.Elements[x][y][z].IsSurface = p.isSurface(x, y, z)
-	p.Elements[x][y][z].Normal = p.calculateNormal(x, y, z)
+	if p.NormalField != nil {
+		p.Elements[x][y][z].Normal = p.getFieldNormal(x, y, z)
+	} else {
+		p.Elements[x][y][z].Normal = p.calculateNormal(x, y, z)
+	}
 }
 
 func processSecondPassElement(p *ProcessedVoxelObject, x int, y int, z int) {
@@ -220,7 +241,7 @@ func (p *ProcessedVoxelObject) calculateNormal(x, y, z int) (normal geometry.Vec
 	for i := -radius; i <= radius; i++ {
 		for j := values.J[i+radius].min; j <= values.J[i+radius].max; j++ {
 			for k := values.K[i+radius][j+radius].min; k <= values.K[i+radius][j+radius].max; k++ {
-				v := borderedElementLookup[x+i][y+j][z+k]
+				v := p.borderedElementLookup[x+i][y+j][z+k]
 				ti -= i * v
 				tj -= j * v
 				tk -= k * v
@@ -340,16 +361,24 @@ func (p *ProcessedVoxelObject) getOcclusion(x, y, z int) (occlusion int) {
 }
 
 func (p *ProcessedVoxelObject) isSurface(x, y, z int) bool {
-	// A voxel is a surface voxel if any of the adjacent directions is zero
+	// A voxel is a surface voxel if any of the adjacent directions is zero, or is a translucent
+	// colour it can be seen through
 	// The edges of the voxel object are trivially surface voxels
-	return !p.isInvisibleColourIndex(p.Elements[x][y][z].Index) && (x == 0 || y == 0 || z == 0 || // Edges are surface voxels
+	idx := p.Elements[x][y][z].Index
+	return !p.isInvisibleColourIndex(idx) && (x == 0 || y == 0 || z == 0 || // Edges are surface voxels
 		x == p.Size.X-1 || y == p.Size.Y-1 || z == p.Size.Z-1 || // Edges are surface voxels
-		p.isInvisibleColourIndex(p.Elements[x+1][y][z].Index) ||
-		p.isInvisibleColourIndex(p.Elements[x-1][y][z].Index) ||
-		p.isInvisibleColourIndex(p.Elements[x][y+1][z].Index) ||
-		p.isInvisibleColourIndex(p.Elements[x][y-1][z].Index) ||
-		p.isInvisibleColourIndex(p.Elements[x][y][z+1].Index) ||
-		p.isInvisibleColourIndex(p.Elements[x][y][z-1].Index))
+		p.isSeenPast(idx, p.Elements[x+1][y][z].Index) ||
+		p.isSeenPast(idx, p.Elements[x-1][y][z].Index) ||
+		p.isSeenPast(idx, p.Elements[x][y+1][z].Index) ||
+		p.isSeenPast(idx, p.Elements[x][y-1][z].Index) ||
+		p.isSeenPast(idx, p.Elements[x][y][z+1].Index) ||
+		p.isSeenPast(idx, p.Elements[x][y][z-1].Index))
+}
+
+// Whether a voxel can be seen past a neighbouring voxel, which is the case if the neighbour is
+// invisible or a translucent voxel of another colour
+func (p *ProcessedVoxelObject) isSeenPast(idx, neighbour byte) bool {
+	return p.isInvisibleColourIndex(neighbour) || (neighbour != idx && p.Palette.GetTransparency(neighbour) > 0)
 }
 
 func (p *ProcessedVoxelObject) isInvisibleColourIndex(idx byte) bool {
@@ -376,7 +405,7 @@ func reflect101(a, n int) int {
 
 func (p *ProcessedVoxelObject) setElements(r magica.VoxelObject, isTiled bool, tilingMode string, hasBase bool) {
 	p.Elements = make([][][]ProcessedElement, p.Size.X)
-	borderedElementLookup = make([][][]int, p.Size.X+(accessBorder*2))
+	p.borderedElementLookup = make([][][]int, p.Size.X+(accessBorder*2))
 
 	sx, sy, sz := p.Size.X, p.Size.Y, p.Size.Z
 
@@ -393,35 +422,35 @@ func (p *ProcessedVoxelObject) setElements(r magica.VoxelObject, isTiled bool, t
 	}
 
 	for x := 0; x < p.Size.X+(accessBorder*2); x++ {
-		borderedElementLookup[x] = make([][]int, p.Size.Y+(accessBorder*2))
+		p.borderedElementLookup[x] = make([][]int, p.Size.Y+(accessBorder*2))
 		for y := 0; y < p.Size.Y+(accessBorder*2); y++ {
-			borderedElementLookup[x][y] = make([]int, p.Size.Z+(accessBorder*2))
+			p.borderedElementLookup[x][y] = make([]int, p.Size.Z+(accessBorder*2))
 			for z := 0; z < p.Size.Z+(accessBorder*2); z++ {
 				if isTiled {
 					if tilingMode == "repeat" {
 						if r.Voxels[min(max(x-accessBorder, 0), p.Size.X-1)][min(max(y-accessBorder, 0), p.Size.Y-1)][min(max(z-accessBorder, 0), p.Size.Z-1)] == 0 {
-							borderedElementLookup[x][y][z] = 1
+							p.borderedElementLookup[x][y][z] = 1
 						}
 					} else if tilingMode == "reflect" {
 						if r.Voxels[reflect(x-accessBorder, p.Size.X)][reflect(y-accessBorder, p.Size.Y)][reflect(z-accessBorder, p.Size.Z)] == 0 {
-							borderedElementLookup[x][y][z] = 1
+							p.borderedElementLookup[x][y][z] = 1
 						}
 					} else if tilingMode == "reflect101" {
 						if r.Voxels[reflect101(x-accessBorder, p.Size.X)][reflect101(y-accessBorder, p.Size.Y)][reflect101(z-accessBorder, p.Size.Z)] == 0 {
-							borderedElementLookup[x][y][z] = 1
+							p.borderedElementLookup[x][y][z] = 1
 						}
 					} else {
 						if r.Voxels[(x+sx-accessBorder)%p.Size.X][(y+sy-accessBorder)%p.Size.Y][(z+sz-accessBorder)%p.Size.Z] == 0 {
-							borderedElementLookup[x][y][z] = 1
+							p.borderedElementLookup[x][y][z] = 1
 						}
 					}
 				} else {
-					borderedElementLookup[x][y][z] = 1
+					p.borderedElementLookup[x][y][z] = 1
 				}
 
 				if hasBase && z < accessBorder {
 					// If this object has a solid base then the lookup below z=0 is considered to be solid
-					borderedElementLookup[x][y][z] = 0
+					p.borderedElementLookup[x][y][z] = 0
 				}
 			}
 		}
@@ -440,7 +469,7 @@ func (p *ProcessedVoxelObject) setElements(r magica.VoxelObject, isTiled bool, t
 				// a value that can be multiplied by every time rather than needing an `if thing == 0`
 				// in the inner normal calculation loop
 				if r.Voxels[x][y][z] != 0 && !isTiled {
-					borderedElementLookup[x+accessBorder][y+accessBorder][z+accessBorder] = 0
+					p.borderedElementLookup[x+accessBorder][y+accessBorder][z+accessBorder] = 0
 				}
 			}
 		}
@@ -448,6 +477,72 @@ func (p *ProcessedVoxelObject) setElements(r magica.VoxelObject, isTiled bool, t
 
 }
 
+// Build a chessboard distance field over the voxel grid, so each empty voxel knows how
+// far away the nearest filled voxel is. This lets rays take large steps through empty
+// space. Uses the two-pass chamfer algorithm, which is exact for the chessboard metric.
+func (p *ProcessedVoxelObject) setEmptyDistances() {
+	for x := 0; x < p.Size.X; x++ {
+		for y := 0; y < p.Size.Y; y++ {
+			for z := 0; z < p.Size.Z; z++ {
+				if p.Elements[x][y][z].Index != 0 {
+					p.Elements[x][y][z].EmptyDistance = 0
+				} else {
+					p.Elements[x][y][z].EmptyDistance = maxEmptyDistance
+				}
+			}
+		}
+	}
+
+	// Forward pass
+	for x := 0; x < p.Size.X; x++ {
+		for y := 0; y < p.Size.Y; y++ {
+			for z := 0; z < p.Size.Z; z++ {
+				p.relaxEmptyDistance(x, y, z, -1)
+			}
+		}
+	}
+
+	// Backward pass
+	for x := p.Size.X - 1; x >= 0; x-- {
+		for y := p.Size.Y - 1; y >= 0; y-- {
+			for z := p.Size.Z - 1; z >= 0; z-- {
+				p.relaxEmptyDistance(x, y, z, 1)
+			}
+		}
+	}
+}
+
+// Update the distance at x,y,z from the neighbours which have already been visited
+// when scanning in the given direction.
+func (p *ProcessedVoxelObject) relaxEmptyDistance(x, y, z int, direction int) {
+	distance := int(p.Elements[x][y][z].EmptyDistance)
+	if distance == 0 {
+		return
+	}
+
+	for i := -1; i <= 1; i++ {
+		for j := -1; j <= 1; j++ {
+			for k := -1; k <= 1; k++ {
+				// Only neighbours that come before this voxel in scan order
+				if (i*9+j*3+k)*direction <= 0 {
+					continue
+				}
+
+				nx, ny, nz := x+i, y+j, z+k
+				if nx < 0 || ny < 0 || nz < 0 || nx >= p.Size.X || ny >= p.Size.Y || nz >= p.Size.Z {
+					continue
+				}
+
+				if d := int(p.Elements[nx][ny][nz].EmptyDistance) + 1; d < distance {
+					distance = d
+				}
+			}
+		}
+	}
+
+	p.Elements[x][y][z].EmptyDistance = byte(distance)
+}
+
 func (pv *ProcessedVoxelObject) SafeGetData(x, y, z int) (pe ProcessedElement) {
 	if x >= 0 && y >= 0 && z >= 0 && x < pv.Size.X && y < pv.Size.Y && z < pv.Size.Z {
 		pe = pv.Elements[x][y][z]
@@ -459,3 +554,33 @@ func (pv *ProcessedVoxelObject) SafeGetData(x, y, z int) (pe ProcessedElement) {
 func (pv *ProcessedVoxelObject) Invalid() bool {
 	return pv.Size.X == 0 || pv.Size.Y == 0 || pv.Size.Z == 0
 }
+
+// Get the smallest box containing every voxel of the object, from the lowest corner of the
+// first voxel to the highest corner of the last. ok is false if the object is empty.
+func (p *ProcessedVoxelObject) GetOccupiedBounds() (from, to geometry.Point, ok bool) {
+	for x := range p.Elements {
+		for y := range p.Elements[x] {
+			for z := range p.Elements[x][y] {
+				if p.Elements[x][y][z].Index == 0 {
+					continue
+				}
+
+				if !ok {
+					from, to, ok = geometry.Point{X: x, Y: y, Z: z}, geometry.Point{X: x + 1, Y: y + 1, Z: z + 1}, true
+					continue
+				}
+
+				from = geometry.Point{X: min(from.X, x), Y: min(from.Y, y), Z: min(from.Z, z)}
+				to = geometry.Point{X: max(to.X, x+1), Y: max(to.Y, y+1), Z: max(to.Z, z+1)}
+			}
+		}
+	}
+
+	return
+}
+
+// Get the slice in X of the object at an X location, taking account of the stretched end
+// slices. Locations before or after the object give the first or last slice.
+func (p *ProcessedVoxelObject) GetSliceX(x float64) int {
+	return min(max(int(x-p.EndExtension), 0), p.Size.X-1)
+}
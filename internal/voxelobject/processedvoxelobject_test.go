@@ -24,16 +24,16 @@ func TestRawVoxelObject_GetProcessedVoxelObject(t *testing.T) {
 
 	pal.SetRanges([]colour.PaletteRange{{Start: 0, End: 255}})
 
-	v := GetProcessedVoxelObject(mv, &pal, false, "normal", false)
+	v := GetProcessedVoxelObject(mv, &pal, false, "normal", false, false)
 	testObject(t, mv, v)
 
-	v = GetProcessedVoxelObject(mv, &pal, true, "normal", false)
+	v = GetProcessedVoxelObject(mv, &pal, true, "normal", false, false)
 	testObject(t, mv, v)
 
-	v = GetProcessedVoxelObject(mv, &pal, true, "repeat", false)
+	v = GetProcessedVoxelObject(mv, &pal, true, "repeat", false, false)
 	testObject(t, mv, v)
 
-	v = GetProcessedVoxelObject(mv, &pal, false, "repeat", false)
+	v = GetProcessedVoxelObject(mv, &pal, false, "repeat", false, false)
 	testObject(t, mv, v)
 }
 
@@ -119,7 +119,7 @@ func getObject(filename string, t *testing.T) ProcessedVoxelObject {
 
 	pal.SetRanges([]colour.PaletteRange{{Start: 0, End: 255}})
 
-	v := GetProcessedVoxelObject(mv, &pal, false, "normal", false)
+	v := GetProcessedVoxelObject(mv, &pal, false, "normal", false, false)
 	return v
 }
 
@@ -134,3 +134,42 @@ func TestProcessedVoxelObject_getOcclusion(t *testing.T) {
 	}
 
 }
+
+func TestProcessedVoxelObject_EmptyDistance(t *testing.T) {
+	p := getObject("testcube_big", t)
+
+	for x := 0; x < p.Size.X; x++ {
+		for y := 0; y < p.Size.Y; y++ {
+			for z := 0; z < p.Size.Z; z++ {
+				expected := maxEmptyDistance
+
+				// Brute force the chessboard distance to the nearest filled voxel
+				for i := 0; i < p.Size.X; i++ {
+					for j := 0; j < p.Size.Y; j++ {
+						for k := 0; k < p.Size.Z; k++ {
+							if p.Elements[i][j][k].Index == 0 {
+								continue
+							}
+
+							d := max(abs(x-i), abs(y-j), abs(z-k))
+							if d < expected {
+								expected = d
+							}
+						}
+					}
+				}
+
+				if result := int(p.Elements[x][y][z].EmptyDistance); result != expected {
+					t.Errorf("Empty distance at [%d,%d,%d] is %d, expected %d", x, y, z, result, expected)
+				}
+			}
+		}
+	}
+}
+
+func abs(a int) int {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
@@ -0,0 +1,57 @@
+package voxelobject
+
+import "github.com/mattkimber/gandalf/magica"
+
+// Remove every voxel outside the slices from and to (inclusive) along an axis, leaving a
+// cross section of the object. If hatch is set, the surfaces where the object was cut are
+// striped with that palette index, so they stand out from the object's own surfaces.
+func CutSection(v magica.VoxelObject, axis string, from, to int, hatch byte) {
+	// Get the position of a voxel along the axis, and its position across the cut surface
+	position := func(x, y, z int) (pos, a, b int) {
+		switch axis {
+		case "y":
+			return y, x, z
+		case "z":
+			return z, x, y
+		default:
+			return x, y, z
+		}
+	}
+
+	neighbour := func(x, y, z, delta int) byte {
+		switch axis {
+		case "y":
+			y += delta
+		case "z":
+			z += delta
+		default:
+			x += delta
+		}
+
+		if x < 0 || y < 0 || z < 0 || x >= v.Size.X || y >= v.Size.Y || z >= v.Size.Z {
+			return 0
+		}
+
+		return v.Voxels[x][y][z]
+	}
+
+	// Hatch the cut surfaces before removing anything, so the removed neighbours can be seen
+	if hatch != 0 {
+		v.Iterate(func(x, y, z int) {
+			pos, a, b := position(x, y, z)
+			if v.Voxels[x][y][z] == 0 || (a+b)%4 >= 2 {
+				return
+			}
+
+			if (pos == from && neighbour(x, y, z, -1) != 0) || (pos == to && neighbour(x, y, z, 1) != 0) {
+				v.Voxels[x][y][z] = hatch
+			}
+		})
+	}
+
+	v.Iterate(func(x, y, z int) {
+		if pos, _, _ := position(x, y, z); pos < from || pos > to {
+			v.Voxels[x][y][z] = 0
+		}
+	})
+}
@@ -0,0 +1,54 @@
+package voxelobject
+
+import (
+	"github.com/mattkimber/gandalf/geometry"
+	"github.com/mattkimber/gandalf/magica"
+	"testing"
+)
+
+func TestCutSection(t *testing.T) {
+	testCases := []struct {
+		name               string
+		axis               string
+		from, to           int
+		hatch              byte
+		remaining, hatched int
+	}{
+		{"single slice", "x", 1, 1, 0, 16, 0},
+		{"range", "y", 0, 1, 0, 32, 0},
+		{"top", "z", 3, 3, 0, 16, 0},
+		{"outside", "x", 4, 6, 0, 0, 0},
+		{"hatched middle", "x", 1, 2, 9, 32, 16},
+		{"hatched end", "z", 2, 3, 9, 32, 8},
+	}
+
+	for _, testCase := range testCases {
+		v := magica.VoxelObject{Size: geometry.Point{X: 4, Y: 4, Z: 4}}
+		v.Voxels = make([][][]byte, v.Size.X)
+		for x := range v.Voxels {
+			v.Voxels[x] = make([][]byte, v.Size.Y)
+			for y := range v.Voxels[x] {
+				v.Voxels[x][y] = []byte{5, 5, 5, 5}
+			}
+		}
+
+		CutSection(v, testCase.axis, testCase.from, testCase.to, testCase.hatch)
+
+		remaining, hatched := 0, 0
+		v.Iterate(func(x, y, z int) {
+			switch v.Voxels[x][y][z] {
+			case 0:
+			case testCase.hatch:
+				hatched++
+				remaining++
+			default:
+				remaining++
+			}
+		})
+
+		if remaining != testCase.remaining || hatched != testCase.hatched {
+			t.Errorf("%s: expected %d voxels remaining with %d hatched, got %d with %d hatched", testCase.name,
+				testCase.remaining, testCase.hatched, remaining, hatched)
+		}
+	}
+}
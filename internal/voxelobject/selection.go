@@ -0,0 +1,206 @@
+package voxelobject
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/mattkimber/gandalf/geometry"
+	"github.com/mattkimber/gandalf/magica"
+	"github.com/mattkimber/gandalf/magica/scenegraph"
+	"github.com/mattkimber/gandalf/magica/types"
+	"github.com/mattkimber/gandalf/utils"
+	"io"
+	"os"
+)
+
+type chunk struct {
+	id   string
+	data []byte
+}
+
+// Load part of a MagicaVoxel file. If node is set, only the transform node with that name
+// and its children are loaded, as if they were a file of their own. If layers are set, only
+// voxels on those layers are visible, and the object keeps the size and position of the
+// whole model so objects with different visible layers line up.
+func FromFileWithSelection(filename string, node string, layers []string) (v magica.VoxelObject, err error) {
+	handle, err := os.Open(filename)
+	if err != nil {
+		return v, err
+	}
+
+	defer func(handle *os.File) {
+		_ = handle.Close()
+	}(handle)
+
+	chunks, err := readChunks(handle)
+	if err != nil {
+		return v, fmt.Errorf("could not read %s: %v", filename, err)
+	}
+
+	return getSelectedObject(chunks, node, layers)
+}
+
+// Get the names of the layers and named transform nodes in a MagicaVoxel file
+func GetNames(filename string) (layers []string, nodes []string, err error) {
+	handle, err := os.Open(filename)
+	if err != nil {
+		return
+	}
+
+	defer func(handle *os.File) {
+		_ = handle.Close()
+	}(handle)
+
+	chunks, err := readChunks(handle)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read %s: %v", filename, err)
+	}
+
+	for _, c := range chunks {
+		rd := types.GetReader(c.data)
+
+		switch c.id {
+		case "nTRN":
+			if name := rd.GetTranslation().Attributes.Values["_name"]; name != "" {
+				nodes = append(nodes, name)
+			}
+		case "LAYR":
+			_ = rd.GetInt32()
+			if name := rd.GetDictionary().Values["_name"]; name != "" {
+				layers = append(layers, name)
+			}
+		}
+	}
+
+	return
+}
+
+func getSelectedObject(chunks []chunk, node string, layers []string) (v magica.VoxelObject, err error) {
+	sizeData := make([]types.Size, 0)
+	pointData := make([]types.PointData, 0)
+	graph := make(scenegraph.Map)
+	layerNames := make(map[int]string)
+
+	var palette types.Palette
+
+	for _, c := range chunks {
+		rd := types.GetReader(c.data)
+
+		switch c.id {
+		case "SIZE":
+			sizeData = append(sizeData, rd.GetSize())
+		case "XYZI":
+			pointData = append(pointData, rd.GetPointData())
+		case "RGBA":
+			palette = rd.GetPalette()
+		case "nTRN":
+			translation := rd.GetTranslation()
+			graph[translation.NodeID] = &translation
+		case "nGRP":
+			group := rd.GetGroup()
+			graph[group.NodeID] = &group
+		case "nSHP":
+			shape := rd.GetShape()
+			graph[shape.NodeID] = &shape
+		case "LAYR":
+			id := rd.GetInt32()
+			layerNames[id] = rd.GetDictionary().Values["_name"]
+		}
+	}
+
+	rootID, err := getNodeID(graph, node)
+	if err != nil {
+		return v, err
+	}
+
+	visible := make(map[int]bool)
+	for _, name := range layers {
+		found := false
+		for id, layerName := range layerNames {
+			if layerName == name {
+				visible[id] = true
+				found = true
+			}
+		}
+
+		if !found {
+			return v, fmt.Errorf("layer %s not found", name)
+		}
+	}
+
+	full := getScenegraph(graph, rootID, pointData, sizeData)
+
+	// Remove any transforms belonging to hidden layers. Nodes without a layer are always kept,
+	// as is the selected node itself.
+	if len(layers) > 0 {
+		for id, item := range graph {
+			if translation, ok := item.(*types.Translation); ok && id != rootID {
+				if _, isLayer := layerNames[translation.LayerID]; isLayer && !visible[translation.LayerID] {
+					delete(graph, id)
+				}
+			}
+		}
+	}
+
+	filtered := getScenegraph(graph, rootID, pointData, sizeData)
+
+	extents := full.GetExtents()
+	size := types.Size{X: extents.Max.X - extents.Min.X, Y: extents.Max.Y - extents.Min.Y, Z: extents.Max.Z - extents.Min.Z}
+	data := utils.Make3DByteSlice(size)
+	filtered.AppendVoxels(extents.Min, size, &data)
+
+	v.PaletteData = palette
+	v.Size = geometry.Point{X: size.X, Y: size.Y, Z: size.Z}
+	v.Voxels = data
+	return v, nil
+}
+
+// Find the transform node with the given name, or the root node if the name is empty
+func getNodeID(graph scenegraph.Map, node string) (int, error) {
+	if node == "" {
+		return 0, nil
+	}
+
+	for id, item := range graph {
+		if translation, ok := item.(*types.Translation); ok && translation.Attributes.Values["_name"] == node {
+			return id, nil
+		}
+	}
+
+	return 0, fmt.Errorf("node %s not found", node)
+}
+
+func getScenegraph(graph scenegraph.Map, rootID int, pointData []types.PointData, sizeData []types.Size) scenegraph.Node {
+	if rootID == 0 {
+		return scenegraph.GetScenegraph(graph, pointData, sizeData)
+	}
+
+	return scenegraph.Compose(graph, graph[rootID], 0, 0, 0, pointData, sizeData)
+}
+
+func readChunks(handle io.Reader) (chunks []chunk, err error) {
+	header := make([]byte, 8)
+	if _, err = io.ReadFull(handle, header); err != nil {
+		return nil, err
+	}
+
+	if string(header[0:4]) != "VOX " {
+		return nil, fmt.Errorf("header not valid")
+	}
+
+	// Chunks are read as a flat list, as the MAIN chunk has no content of its own
+	for {
+		chunkHeader := make([]byte, 12)
+		if _, err = io.ReadFull(handle, chunkHeader); err == io.EOF {
+			return chunks, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		data := make([]byte, binary.LittleEndian.Uint32(chunkHeader[4:8]))
+		if _, err = io.ReadFull(handle, data); err != nil {
+			return nil, err
+		}
+
+		chunks = append(chunks, chunk{id: string(chunkHeader[0:4]), data: data})
+	}
+}
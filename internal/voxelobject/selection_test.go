@@ -0,0 +1,107 @@
+package voxelobject
+
+import (
+	"github.com/mattkimber/gandalf/geometry"
+	"github.com/mattkimber/gandalf/magica"
+	"testing"
+)
+
+func TestFromFileWithSelection_Layers(t *testing.T) {
+	expected, err := magica.FromFile("testdata/layers")
+	if err != nil {
+		t.Fatalf("error loading test file: %v", err)
+	}
+
+	all, err := FromFileWithSelection("testdata/layers", "", []string{"body", "cargo"})
+	if err != nil {
+		t.Fatalf("error loading test file with layers: %v", err)
+	}
+
+	if all.Size != expected.Size {
+		t.Fatalf("Object with all layers visible expected size %v, got %v", expected.Size, all.Size)
+	}
+
+	hidden := 0
+	for x := 0; x < all.Size.X; x++ {
+		for y := 0; y < all.Size.Y; y++ {
+			for z := 0; z < all.Size.Z; z++ {
+				if all.Voxels[x][y][z] != expected.Voxels[x][y][z] {
+					t.Errorf("Voxel at %d,%d,%d expected %d, got %d", x, y, z, expected.Voxels[x][y][z], all.Voxels[x][y][z])
+				}
+				if expected.Voxels[x][y][z] == 2 {
+					hidden++
+				}
+			}
+		}
+	}
+
+	if hidden == 0 {
+		t.Fatalf("Test file has no voxels on the cargo layer")
+	}
+
+	body, err := FromFileWithSelection("testdata/layers", "", []string{"body"})
+	if err != nil {
+		t.Fatalf("error loading test file with layers: %v", err)
+	}
+
+	if body.Size != expected.Size {
+		t.Errorf("Object with hidden layers expected size %v, got %v", expected.Size, body.Size)
+	}
+
+	for x := 0; x < body.Size.X; x++ {
+		for y := 0; y < body.Size.Y; y++ {
+			for z := 0; z < body.Size.Z; z++ {
+				if body.Voxels[x][y][z] == 2 {
+					t.Errorf("Voxel from hidden layer present at %d,%d,%d", x, y, z)
+				} else if body.Voxels[x][y][z] != expected.Voxels[x][y][z] && expected.Voxels[x][y][z] != 2 {
+					t.Errorf("Voxel at %d,%d,%d expected %d, got %d", x, y, z, expected.Voxels[x][y][z], body.Voxels[x][y][z])
+				}
+			}
+		}
+	}
+
+	if _, err := FromFileWithSelection("testdata/layers", "", []string{"winter"}); err == nil {
+		t.Errorf("Expected error for missing layer")
+	}
+}
+
+func TestFromFileWithSelection_Node(t *testing.T) {
+	bogie, err := FromFileWithSelection("testdata/layers", "bogie", nil)
+	if err != nil {
+		t.Fatalf("error loading node from test file: %v", err)
+	}
+
+	expected := geometry.Point{X: 2, Y: 2, Z: 2}
+	if bogie.Size != expected {
+		t.Errorf("Node expected size %v, got %v", expected, bogie.Size)
+	}
+
+	for x := 0; x < bogie.Size.X; x++ {
+		for y := 0; y < bogie.Size.Y; y++ {
+			for z := 0; z < bogie.Size.Z; z++ {
+				if bogie.Voxels[x][y][z] != 2 {
+					t.Errorf("Voxel at %d,%d,%d expected 2, got %d", x, y, z, bogie.Voxels[x][y][z])
+				}
+			}
+		}
+	}
+
+	if _, err := FromFileWithSelection("testdata/layers", "chassis", nil); err == nil {
+		t.Errorf("Expected error for missing node")
+	}
+}
+
+func TestGetNames(t *testing.T) {
+	layers, nodes, err := GetNames("testdata/layers")
+	if err != nil {
+		t.Fatalf("error loading test file: %v", err)
+	}
+
+	if len(layers) != 2 || layers[0] != "body" || layers[1] != "cargo" {
+		t.Errorf("Expected layers [body cargo], got %v", layers)
+	}
+
+	if len(nodes) != 2 || nodes[0] != "hull" || nodes[1] != "bogie" {
+		t.Errorf("Expected nodes [hull bogie], got %v", nodes)
+	}
+}
@@ -0,0 +1,90 @@
+package voxelobject
+
+import (
+	"github.com/mattkimber/gorender/internal/geometry"
+	"sync"
+	"sync/atomic"
+)
+
+// The most shadow maps kept for an object. Sprites are usually lit from a few directions
+// at most, but the preview lights the object from a new one whenever the lighting changes.
+const maxShadowMaps = 64
+
+// Depths beyond this are stored as this, which is too far away to cast a visible shadow
+const maxShadowDepth = 254
+
+// The distance from each voxel of an object to whatever shadows it, for one lighting
+// direction. Depths are cast when first needed and kept so every sprite lit from the same
+// direction can share them. Each is stored as a byte, packed four to a word so they can be
+// set while the object is being raycast.
+type ShadowMap struct {
+	size   geometry.Point
+	depths []atomic.Uint32
+}
+
+type shadowMapKey struct {
+	lighting     geometry.Vector3
+	endExtension float64
+}
+
+type shadowMaps struct {
+	sync.Mutex
+	maps map[shadowMapKey]*ShadowMap
+	keys []shadowMapKey
+}
+
+// Get the shadow map for the object lit from a direction. Returns nil for objects which
+// were not processed, which cast every shadow as it is needed.
+func (p ProcessedVoxelObject) GetShadowMap(lighting geometry.Vector3) *ShadowMap {
+	if p.shadowMaps == nil {
+		return nil
+	}
+
+	s := p.shadowMaps
+	s.Lock()
+	defer s.Unlock()
+
+	key := shadowMapKey{lighting: lighting, endExtension: p.EndExtension}
+	if result, ok := s.maps[key]; ok {
+		return result
+	}
+
+	if s.maps == nil {
+		s.maps = make(map[shadowMapKey]*ShadowMap)
+	}
+
+	// Forget the oldest map, which sprites already holding it can carry on using
+	if len(s.keys) >= maxShadowMaps {
+		delete(s.maps, s.keys[0])
+		s.keys = s.keys[1:]
+	}
+
+	result := &ShadowMap{size: p.Size, depths: make([]atomic.Uint32, (p.Size.X*p.Size.Y*p.Size.Z+3)/4)}
+	s.maps[key] = result
+	s.keys = append(s.keys, key)
+
+	return result
+}
+
+// Get the shadow depth of a voxel, casting it if it isn't known yet
+func (s *ShadowMap) Get(x, y, z int, cast func() int) int {
+	if s == nil {
+		return cast()
+	}
+
+	i := (x*s.size.Y+y)*s.size.Z + z
+	word, shift := &s.depths[i/4], (i%4)*8
+
+	// Depths are stored one higher so zero can mean they haven't been cast
+	if stored := (word.Load() >> shift) & 0xFF; stored != 0 {
+		return int(stored - 1)
+	}
+
+	depth := min(cast(), maxShadowDepth)
+	for {
+		old := word.Load()
+		if word.CompareAndSwap(old, old|uint32(depth+1)<<shift) {
+			return depth
+		}
+	}
+}
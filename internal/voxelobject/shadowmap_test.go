@@ -0,0 +1,76 @@
+package voxelobject
+
+import (
+	"github.com/mattkimber/gandalf/magica"
+	"github.com/mattkimber/gorender/internal/colour"
+	"github.com/mattkimber/gorender/internal/geometry"
+	"testing"
+)
+
+func TestProcessedVoxelObject_GetShadowMap(t *testing.T) {
+	mv, err := magica.FromFile("testdata/testcube")
+	if err != nil {
+		t.Fatalf("error loading test file: %v", err)
+	}
+
+	pal := colour.Palette{Entries: make([]colour.PaletteEntry, 256)}
+	pal.SetRanges([]colour.PaletteRange{{Start: 0, End: 255}})
+	v := GetProcessedVoxelObject(mv, &pal, false, "normal", false, false)
+	lighting := geometry.Vector3{X: 1, Z: 1}.Normalise()
+
+	shadows := v.GetShadowMap(lighting)
+	if shadows == nil {
+		t.Fatalf("expected a shadow map")
+	}
+
+	// Copies of the object share its shadow maps, unless their ends are stretched differently
+	copied := v
+	if copied.GetShadowMap(lighting) != shadows {
+		t.Errorf("expected copies of the object to share the shadow map")
+	}
+
+	if v.GetShadowMap(geometry.Vector3{X: -1, Z: 1}.Normalise()) == shadows {
+		t.Errorf("expected a different shadow map for different lighting")
+	}
+
+	copied.EndExtension = 1
+	if copied.GetShadowMap(lighting) == shadows {
+		t.Errorf("expected a different shadow map for a stretched object")
+	}
+
+	// Depths are cast once, and are distinct from those of neighbouring voxels
+	testCases := []struct {
+		x, y, z, depth, expected int
+	}{
+		{0, 0, 0, 0, 0},
+		{1, 0, 0, 5, 5},
+		{2, 0, 0, 1000, maxShadowDepth},
+		{3, 0, 0, 80, 80},
+	}
+
+	for _, testCase := range testCases {
+		casts := 0
+		cast := func() int { casts++; return testCase.depth }
+
+		for i := 0; i < 2; i++ {
+			if result := shadows.Get(testCase.x, testCase.y, testCase.z, cast); result != testCase.expected {
+				t.Errorf("[%d,%d,%d]: expected %d, got %d", testCase.x, testCase.y, testCase.z, testCase.expected, result)
+			}
+		}
+
+		if casts != 1 {
+			t.Errorf("[%d,%d,%d]: expected 1 cast, got %d", testCase.x, testCase.y, testCase.z, casts)
+		}
+	}
+
+	// Objects which weren't processed cast every time
+	casts := 0
+	var unprocessed ProcessedVoxelObject
+	for i := 0; i < 2; i++ {
+		unprocessed.GetShadowMap(lighting).Get(0, 0, 0, func() int { casts++; return 1 })
+	}
+
+	if casts != 2 {
+		t.Errorf("expected 2 casts without a shadow map, got %d", casts)
+	}
+}
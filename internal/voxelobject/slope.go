@@ -0,0 +1,65 @@
+package voxelobject
+
+import (
+	"github.com/mattkimber/gandalf/magica"
+	"math"
+)
+
+// Shear a voxel object so its base follows a slope. Corner heights are given in the
+// order (0,0), (max,0), (max,max), (0,max) and are interpolated across the object.
+func GetSlopedVoxelObject(o magica.VoxelObject, cornerHeights [4]int) magica.VoxelObject {
+	maxHeight := 0
+	for _, h := range cornerHeights {
+		if h > maxHeight {
+			maxHeight = h
+		}
+	}
+
+	size := o.Size
+	size.Z += maxHeight
+	result := magica.NewVoxelObject(size, o.PaletteData)
+
+	for x := 0; x < o.Size.X; x++ {
+		for y := 0; y < o.Size.Y; y++ {
+			offset := getSlopeOffset(x, y, o.Size.X, o.Size.Y, cornerHeights)
+			for z := 0; z < o.Size.Z; z++ {
+				result.Voxels[x][y][z+offset] = o.Voxels[x][y][z]
+			}
+		}
+	}
+
+	return result
+}
+
+// Get the height of the slope at a voxel. As in OpenTTD, the slope is two flat triangles
+// folded along a diagonal: the one which leaves a corner at a different height from its
+// neighbours on its own, or the higher one when both do, so a single raised corner leaves
+// the opposite half flat.
+func getSlopeOffset(x, y, sizeX, sizeY int, cornerHeights [4]int) int {
+	fx, fy := 0.0, 0.0
+	if sizeX > 1 {
+		fx = float64(x) / float64(sizeX-1)
+	}
+	if sizeY > 1 {
+		fy = float64(y) / float64(sizeY-1)
+	}
+
+	h0, h1, h2, h3 := float64(cornerHeights[0]), float64(cornerHeights[1]), float64(cornerHeights[2]), float64(cornerHeights[3])
+
+	diff02, diff13 := math.Abs(h0-h2), math.Abs(h1-h3)
+	foldOn02 := diff02 < diff13 || (diff02 == diff13 && h0+h2 >= h1+h3)
+
+	var height float64
+	switch {
+	case foldOn02 && fx >= fy:
+		height = h0 + (h1-h0)*fx + (h2-h1)*fy
+	case foldOn02:
+		height = h0 + (h2-h3)*fx + (h3-h0)*fy
+	case fx+fy <= 1:
+		height = h0 + (h1-h0)*fx + (h3-h0)*fy
+	default:
+		height = h2 + (h3-h2)*(1-fx) + (h1-h2)*(1-fy)
+	}
+
+	return int(math.Round(height))
+}
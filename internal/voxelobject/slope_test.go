@@ -0,0 +1,84 @@
+package voxelobject
+
+import (
+	"github.com/mattkimber/gandalf/geometry"
+	"github.com/mattkimber/gandalf/magica"
+	"testing"
+)
+
+func TestGetSlopedVoxelObject(t *testing.T) {
+	o := magica.NewVoxelObject(geometry.Point{X: 3, Y: 3, Z: 1}, nil)
+	for x := 0; x < 3; x++ {
+		for y := 0; y < 3; y++ {
+			o.Voxels[x][y][0] = 1
+		}
+	}
+
+	sloped := GetSlopedVoxelObject(o, [4]int{0, 2, 2, 0})
+
+	if sloped.Size.Z != 3 {
+		t.Fatalf("Expected sloped object height 3, got %d", sloped.Size.Z)
+	}
+
+	for x := 0; x < 3; x++ {
+		for y := 0; y < 3; y++ {
+			if sloped.Voxels[x][y][x] != 1 {
+				t.Errorf("Expected voxel at [%d,%d,%d]", x, y, x)
+			}
+		}
+	}
+}
+
+func TestGetSlopeOffset(t *testing.T) {
+	testCases := []struct {
+		name          string
+		cornerHeights [4]int
+		expected      [5][5]int
+	}{
+		{
+			// The fold runs between the neighbours of the raised corner, and the half
+			// beyond it stays flat
+			"one corner raised",
+			[4]int{4, 0, 0, 0},
+			[5][5]int{
+				{4, 3, 2, 1, 0},
+				{3, 2, 1, 0, 0},
+				{2, 1, 0, 0, 0},
+				{1, 0, 0, 0, 0},
+				{0, 0, 0, 0, 0},
+			},
+		},
+		{
+			"three corners raised",
+			[4]int{4, 4, 4, 0},
+			[5][5]int{
+				{4, 3, 2, 1, 0},
+				{4, 4, 3, 2, 1},
+				{4, 4, 4, 3, 2},
+				{4, 4, 4, 4, 3},
+				{4, 4, 4, 4, 4},
+			},
+		},
+		{
+			"one side raised",
+			[4]int{0, 4, 4, 0},
+			[5][5]int{
+				{0, 0, 0, 0, 0},
+				{1, 1, 1, 1, 1},
+				{2, 2, 2, 2, 2},
+				{3, 3, 3, 3, 3},
+				{4, 4, 4, 4, 4},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		for x := 0; x < 5; x++ {
+			for y := 0; y < 5; y++ {
+				if result := getSlopeOffset(x, y, 5, 5, testCase.cornerHeights); result != testCase.expected[x][y] {
+					t.Errorf("%s: expected offset %d at %d,%d, got %d", testCase.name, testCase.expected[x][y], x, y, result)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package voxelobject
+
+import "github.com/mattkimber/gandalf/magica"
+
+// Count the voxels which differ from the voxel in the mirror image position across the
+// object's long axis. An object where no voxels differ can be rendered as symmetric.
+func GetAsymmetry(v magica.VoxelObject) (differing int, total int) {
+	v.Iterate(func(x, y, z int) {
+		index := v.Voxels[x][y][z]
+		if index == 0 {
+			return
+		}
+
+		total++
+		if v.Voxels[x][v.Size.Y-1-y][z] != index {
+			differing++
+		}
+	})
+
+	return
+}
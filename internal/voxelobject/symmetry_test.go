@@ -0,0 +1,41 @@
+package voxelobject
+
+import (
+	"github.com/mattkimber/gandalf/geometry"
+	"github.com/mattkimber/gandalf/magica"
+	"testing"
+)
+
+func TestGetAsymmetry(t *testing.T) {
+	testCases := []struct {
+		name             string
+		set              [][4]int
+		differing, total int
+	}{
+		{"empty", nil, 0, 0},
+		{"centred", [][4]int{{1, 1, 0, 5}}, 0, 1},
+		{"mirrored", [][4]int{{0, 0, 0, 5}, {0, 2, 0, 5}}, 0, 2},
+		{"one side", [][4]int{{0, 0, 0, 5}}, 1, 1},
+		{"different colours", [][4]int{{0, 0, 0, 5}, {0, 2, 0, 6}}, 2, 2},
+	}
+
+	for _, testCase := range testCases {
+		v := magica.VoxelObject{Size: geometry.Point{X: 2, Y: 3, Z: 1}}
+		v.Voxels = make([][][]byte, v.Size.X)
+		for x := range v.Voxels {
+			v.Voxels[x] = make([][]byte, v.Size.Y)
+			for y := range v.Voxels[x] {
+				v.Voxels[x][y] = make([]byte, v.Size.Z)
+			}
+		}
+
+		for _, s := range testCase.set {
+			v.Voxels[s[0]][s[1]][s[2]] = byte(s[3])
+		}
+
+		differing, total := GetAsymmetry(v)
+		if differing != testCase.differing || total != testCase.total {
+			t.Errorf("%s: expected %d of %d differing, got %d of %d", testCase.name, testCase.differing, testCase.total, differing, total)
+		}
+	}
+}